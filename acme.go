@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/crypto/acme"
+
+	"github.com/mjl-/bstore"
+)
+
+// acmeConfig is the configuration for obtaining a certificate for the admin
+// interface through ACME, with DNS-01 challenges for which the TXT record is set
+// through the provider of a managed zone.
+type acmeConfig struct {
+	Domain         string // Without trailing dot.
+	DirectoryURL   string
+	Email          string // Optional contact address for the account.
+	AccountKeyPath string // PKCS#8 ECDSA private key, created if absent.
+	CertPath       string // Private key and certificate chain, written after obtaining a certificate.
+
+	certs      *certReloader
+	sync.Mutex // For a single attempt at a time.
+}
+
+// adminACME is set if a certificate for the admin interface is obtained through
+// ACME.
+var adminACME *acmeConfig
+
+// Certificates are renewed when they expire within this period.
+var acmeRenewBefore = 30 * 24 * time.Hour
+
+// acmeRenew obtains a new certificate if there is none yet, or if the current
+// certificate expires soon. Called periodically by the refresher.
+func acmeRenew(ctx context.Context, log *slog.Logger, ac *acmeConfig) error {
+	ac.Lock()
+	defer ac.Unlock()
+
+	if leaf := ac.certs.leaf(); leaf != nil && time.Until(leaf.NotAfter) > acmeRenewBefore {
+		return nil
+	}
+
+	log = log.With("domain", ac.Domain)
+	log.Info("requesting certificate through acme")
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+	if err := acmeObtain(ctx, log, ac); err != nil {
+		return fmt.Errorf("obtaining certificate through acme: %w", err)
+	}
+	if err := ac.certs.check(); err != nil {
+		return fmt.Errorf("loading new certificate: %w", err)
+	}
+	return nil
+}
+
+// acmeAccountKey reads the account key, generating and writing a new ECDSA key if
+// the file does not exist.
+func acmeAccountKey(path string) (crypto.Signer, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil && errors.Is(err, fs.ErrNotExist) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generating account key: %v", err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("marshal account key: %v", err)
+		}
+		buf = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		if err := os.WriteFile(path, buf, 0600); err != nil {
+			return nil, fmt.Errorf("writing account key: %v", err)
+		}
+		slog.Info("wrote new acme account private key file", "path", path)
+	} else if err != nil {
+		return nil, fmt.Errorf("reading account key: %v", err)
+	}
+	b, _ := pem.Decode(buf)
+	if b == nil || b.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("no pkcs#8 private key in %s", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(b.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing account key: %v", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("account key is %T, not a signer", key)
+	}
+	return signer, nil
+}
+
+// acmeObtain requests a certificate, and writes it along with a new private key to
+// the certificate path.
+func acmeObtain(ctx context.Context, log *slog.Logger, ac *acmeConfig) error {
+	key, err := acmeAccountKey(ac.AccountKeyPath)
+	if err != nil {
+		return err
+	}
+	client := &acme.Client{Key: key, DirectoryURL: ac.DirectoryURL, UserAgent: "dnsclay/" + version}
+	acct := &acme.Account{}
+	if ac.Email != "" {
+		acct.Contact = []string{"mailto:" + ac.Email}
+	}
+	if _, err := client.Register(ctx, acct, acme.AcceptTOS); err != nil && err != acme.ErrAccountAlreadyExists {
+		return fmt.Errorf("registering acme account: %w", err)
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(ac.Domain))
+	if err != nil {
+		return fmt.Errorf("creating order: %w", err)
+	}
+	for _, u := range order.AuthzURLs {
+		authz, err := client.GetAuthorization(ctx, u)
+		if err != nil {
+			return fmt.Errorf("get authorization: %w", err)
+		}
+		if authz.Status == acme.StatusValid {
+			continue
+		}
+		var chal *acme.Challenge
+		for _, c := range authz.Challenges {
+			if c.Type == "dns-01" {
+				chal = c
+				break
+			}
+		}
+		if chal == nil {
+			return fmt.Errorf("no dns-01 challenge offered for %s", authz.Identifier.Value)
+		}
+		value, err := client.DNS01ChallengeRecord(chal.Token)
+		if err != nil {
+			return fmt.Errorf("making challenge record value: %w", err)
+		}
+		cleanup, err := acmeChallengeRecord(ctx, log, authz.Identifier.Value, value)
+		if err != nil {
+			return fmt.Errorf("adding challenge record: %w", err)
+		}
+		defer cleanup()
+		if _, err := client.Accept(ctx, chal); err != nil {
+			return fmt.Errorf("accepting challenge: %w", err)
+		}
+		if _, err := client.WaitAuthorization(ctx, authz.URI); err != nil {
+			return fmt.Errorf("waiting for authorization: %w", err)
+		}
+	}
+	if _, err := client.WaitOrder(ctx, order.URI); err != nil {
+		return fmt.Errorf("waiting for order: %w", err)
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		return fmt.Errorf("generating certificate key: %v", err)
+	}
+	csr, err := x509.CreateCertificateRequest(cryptorand.Reader, &x509.CertificateRequest{DNSNames: []string{ac.Domain}}, certKey)
+	if err != nil {
+		return fmt.Errorf("making certificate request: %v", err)
+	}
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return fmt.Errorf("finalizing order: %w", err)
+	}
+
+	keyder, err := x509.MarshalPKCS8PrivateKey(certKey)
+	if err != nil {
+		return fmt.Errorf("marshal certificate key: %v", err)
+	}
+	var b bytes.Buffer
+	pem.Encode(&b, &pem.Block{Type: "PRIVATE KEY", Bytes: keyder})
+	for _, der := range chain {
+		pem.Encode(&b, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}
+	// Write to temporary file and rename, so a reload never sees a partial file.
+	tmp := ac.CertPath + ".tmp"
+	if err := os.WriteFile(tmp, b.Bytes(), 0600); err != nil {
+		return fmt.Errorf("writing certificate: %v", err)
+	}
+	if err := os.Rename(tmp, ac.CertPath); err != nil {
+		return fmt.Errorf("renaming certificate file: %v", err)
+	}
+	log.Info("obtained certificate through acme", "path", ac.CertPath)
+	return nil
+}
+
+// acmeChallengeRecord adds the TXT record for a DNS-01 challenge for domain
+// through the provider of the managed zone the record falls in, and waits until
+// it is visible. The returned function removes the record again.
+func acmeChallengeRecord(ctx context.Context, log *slog.Logger, domain, value string) (cleanup func(), rerr error) {
+	name := "_acme-challenge." + strings.ToLower(dns.Fqdn(domain))
+
+	var z Zone
+	var provider Provider
+	err := database.Read(ctx, func(tx *bstore.Tx) error {
+		zones, err := bstore.QueryTx[Zone](tx).List()
+		if err != nil {
+			return fmt.Errorf("listing zones: %v", err)
+		}
+		var zone string
+		for _, z := range zones {
+			if strings.HasSuffix(name, "."+z.Name) && len(z.Name) > len(zone) {
+				zone = z.Name
+			}
+		}
+		if zone == "" {
+			return fmt.Errorf("no managed zone for %s", name)
+		}
+		z, provider, err = zoneProvider(tx, zone)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Short TTL, as the record is temporary, but not below the minimum of the
+	// provider.
+	ttl := max(60, providerMinTTL(provider))
+	rr, err := dns.NewRR(fmt.Sprintf("%s %d TXT %q", name, ttl, value))
+	if err != nil {
+		return nil, fmt.Errorf("making txt record: %v", err)
+	}
+	hex, text, err := recordData(rr)
+	if err != nil {
+		return nil, fmt.Errorf("record data: %v", err)
+	}
+	r := Record{0, z.Name, 0, 0, time.Time{}, nil, name, Type(dns.TypeTXT), dns.ClassINET, TTL(ttl), hex, text, ""}
+
+	log = log.With("zone", z.Name, "name", name)
+	inserted, err := acmeRecordChange(ctx, log, provider, z, []Record{r}, nil)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("added acme challenge record")
+	cleanup = func() {
+		// The context may have expired, or a shutdown may have canceled shutdownCtx, the
+		// record must be removed regardless.
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		_, err := acmeRecordChange(ctx, log, provider, z, nil, inserted)
+		logCheck(log, err, "removing acme challenge record")
+	}
+	return cleanup, nil
+}
+
+// acmeRecordChange adds and removes records through the provider, and waits until
+// the changes are visible.
+func acmeRecordChange(ctx context.Context, log *slog.Logger, provider Provider, z Zone, add, del []Record) ([]Record, error) {
+	unlock := lockZone(z.Name)
+	defer unlock()
+
+	latest, err := getZoneRecords(ctx, log, provider, z, false)
+	if err != nil {
+		return nil, fmt.Errorf("get latest records: %w", err)
+	}
+	var notify bool
+	defer possiblyZoneNotify(log, z.Name, &notify)
+	var soa Record
+	err = database.Write(ctx, func(tx *bstore.Tx) error {
+		notify, _, _, _, err = syncRecords(log, tx, z, latest)
+		soa = zoneSOA(log, tx, z.Name)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("updating records from latest: %w", err)
+	}
+
+	if len(add) > 0 {
+		if _, err := appendRecords(ctx, log, provider, z.Name, libdnsRecords(add)); err != nil {
+			return nil, fmt.Errorf("adding records through provider: %w", err)
+		}
+	}
+	if len(del) > 0 {
+		if _, err := deleteRecords(ctx, log, provider, z.Name, libdnsRecords(del)); err != nil {
+			return nil, fmt.Errorf("deleting records through provider: %w", err)
+		}
+	}
+	inserted, _, err := ensurePropagate(ctx, log, provider, z, add, del, soa.SerialFirst, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ensuring record propagation: %w", err)
+	}
+	return inserted, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestACMEChallengeRecord(t *testing.T) {
+	testDNS(t, func(te testEnv, z Zone) {
+		// TTL of the challenge record, 0 if absent.
+		recordTTL := func() TTL {
+			t.Helper()
+			for _, r := range te.api.ZoneRecords(ctxbg, z.Name) {
+				if r.AbsName == "_acme-challenge.www."+z.Name && r.Type == Type(dns.TypeTXT) && r.Deleted == nil {
+					return r.TTL
+				}
+			}
+			return 0
+		}
+		hasRecord := func(exp bool) {
+			t.Helper()
+			tcompare(t, recordTTL() != 0, exp)
+		}
+
+		_, err := acmeChallengeRecord(ctxbg, slog.Default(), "www.bogus.example", "value")
+		if err == nil {
+			t.Fatalf("challenge record for unmanaged domain succeeded")
+		}
+
+		cleanup, err := acmeChallengeRecord(ctxbg, slog.Default(), "www."+z.Name[:len(z.Name)-1], "value")
+		tcheck(t, err, "add challenge record")
+		hasRecord(true)
+		tcompare(t, recordTTL(), TTL(60))
+		cleanup()
+		hasRecord(false)
+
+		// TTL is raised to the minimum of the provider.
+		providerCapabilities["fake"] = ProviderCapabilities{MinTTL: 300}
+		defer delete(providerCapabilities, "fake")
+		cleanup, err = acmeChallengeRecord(ctxbg, slog.Default(), "www."+z.Name[:len(z.Name)-1], "value")
+		tcheck(t, err, "add challenge record with provider minimum ttl")
+		tcompare(t, recordTTL(), TTL(300))
+		cleanup()
+		hasRecord(false)
+
+		// Record is removed after shutdown canceled shutdownCtx during the order.
+		cleanup, err = acmeChallengeRecord(ctxbg, slog.Default(), "www."+z.Name[:len(z.Name)-1], "value")
+		tcheck(t, err, "add challenge record before shutdown")
+		hasRecord(true)
+		shutdownCancel()
+		cleanup()
+		hasRecord(false)
+	})
+}
@@ -16,12 +16,24 @@ export interface Zone {
 	RefreshInterval: number  // Time between zone refresh: checks for an updated SOA record (after which a sync is initiated). After a detected record change, checks are done more often. For 1 RefreshInterval, during the first 1/10th of time, a check is done 5 times. For the remaining 9/10th of time, a check is also done every 10 times. If 0, refresh is disabled.
 	NextSync: Date
 	NextRefresh: Date  // Only used when RefreshInterval > 0.
+	RecordsFreshness: number  // If > 0, records fetched from the provider within this window are reused for DNS UPDATE/XFR and web API requests instead of fetching them again. Useful for bursts of requests, e.g. DNS UPDATEs for ACME challenges. Changing records through the provider clears the cached records. If 0, records are fetched for each request.
+	FreshPrerequisites: boolean  // If set, records are always fetched from the provider for DNS UPDATE requests with prerequisites, ignoring RecordsFreshness. Clients can also request this per message by adding an empty EDNS0 option with code 65301 (from the range for local/experimental use).
+	QueueUpdates: boolean  // If set, DNS UPDATEs are validated against the local records (including changes still queued), stored in a persistent queue and acknowledged immediately. A background worker applies queued changes through the provider, retrying on failure. Useful when the provider is not always available, e.g. for ACME challenges that are allowed to take a while.
+	VerifyNameservers: boolean  // If set, after changes are seen through the provider, the authoritative name servers (from the NS records of the zone) are queried directly until they all serve the changed records.
+	DelayUpdateResponse: boolean  // If set, the response to a DNS UPDATE is only sent after the changes have propagated, i.e. are seen through the provider, and with VerifyNameservers, at the authoritative name servers. If propagation fails, SERVFAIL is returned. Clients may need a longer timeout. Not used with QueueUpdates.
+	ConfigManaged: boolean  // If set, the zone is managed through the configuration file, and cannot be changed or removed through the admin interface.
 }
 
 export interface ProviderConfig {
 	Name: string
 	ProviderName: string  // Name of a libdns package.
 	ProviderConfigJSON: string  // JSON encoding of the "Provider" type from the libdns package referenced by ProviderName.
+	Retries: number  // Number of times a provider operation that failed with a transient error (timeout, connection error, HTTP 5xx) is retried, with increasing delay. Adding records is not retried, it may already have been done by the failed attempt. If 0, operations are not retried.
+	FailureThreshold: number  // Number of consecutive transient failures after which the provider config is marked unhealthy. While unhealthy, operations fail immediately, and automatic syncs are paused, with exponential backoff between attempts. If 0, the provider config is never marked unhealthy.
+	DiscoverInterval: number  // Interval for listing zones at the provider, flagging zones that are not configured and configured zones that vanished. Only for providers that can list zones. If 0, zones are only listed on request.
+	RRSetUpdates: boolean  // If set, changes from DNS UPDATE are applied as complete record sets (name and type): the full desired record set is passed to a single SetRecords call, instead of appending and deleting individual records. Only for providers that implement SetRecords by replacing record sets. Fewer API calls are made, and there are no intermediate states with partial record sets. Record sets that become empty are deleted. Enabled by default in the web interface for new provider configs of providers known to operate on record sets.
+	MinTTL: number  // If non-zero, the minimum TTL in seconds for records added or set through the provider, instead of the minimum known for the provider, e.g. after the DNS operator lowered the minimum for the account.
+	ConfigManaged: boolean  // If set, managed through the configuration file.
 }
 
 // ZoneNotify is an address to DNS NOTIFY when a change to the zone is discovered.
@@ -31,6 +43,7 @@ export interface ZoneNotify {
 	Zone: string
 	Address: string  // E.g. 127.0.0.1:53
 	Protocol: string  // "tcp" or "udp"
+	ConfigManaged: boolean  // If set, managed through the configuration file.
 }
 
 // Credential is used for TSIG or mutual TLS authentication during DNS.
@@ -41,6 +54,7 @@ export interface Credential {
 	Type: string  // "tsig" or "tlspubkey"
 	TSIGSecret: string  // Base64-encoded.
 	TLSPublicKey: string  // Raw-url-base64-encoded SHA-256 hash of TLS certificate subject public key info ("SPKI").
+	ConfigManaged: boolean  // If set, managed through the configuration file.
 }
 
 // RecordSet holds the records (values) for a name and type, and optionally
@@ -55,7 +69,7 @@ export interface Record {
 	ID: number
 	Zone: string  // Name of zone, lower-case.
 	SerialFirst: number  // Serial where this record first appeared. For SOA records, this is equal to its Serial field.
-	SerialDeleted: number  // Serial when record was removed. For future IXFR.
+	SerialDeleted: number  // Serial when record was removed, zero for current records. For future IXFR.
 	First: Date
 	Deleted?: Date | null
 	AbsName: string  // Fully qualified, in lower-case.
@@ -76,6 +90,23 @@ export interface PropagationState {
 	Records?: Record[] | null  // Records active during the period Start-End.
 }
 
+// QueuedChange is a change from a DNS UPDATE for a zone with QueueUpdates, still
+// to be applied through the provider. Changes for a zone are applied in order of
+// ID. Records are added, then set, then deleted.
+export interface QueuedChange {
+	ID: number
+	Created: Date
+	Zone: string
+	Add?: Record[] | null  // Cleared after the records have been added/set/deleted through the provider, so a next attempt only applies the remaining changes.
+	Set?: Record[] | null
+	Delete?: Record[] | null
+	Attempts: number  // Failed attempts.
+	LastAttempt?: Date | null
+	LastError: string
+	NextAttempt: Date
+	Failed: boolean  // Set after too many failed attempts. Failed changes are no longer attempted until retried. Later changes for the zone are held back, and new DNS UPDATEs for the zone are refused, until the failed change is retried or deleted.
+}
+
 // RecordSetChange is a new or updated record set.
 export interface RecordSetChange {
 	RelName: string
@@ -584,6 +615,110 @@ export interface Provider_westcn {
 	api_password?: string | null  // APIPassword is your API password for west.cn, see https://www.west.cn/CustomerCenter/doc/apiv2.html#12u3001u8eabu4efdu9a8cu8bc10a3ca20id3d12u3001u8eabu4efdu9a8cu8bc13e203ca3e
 }
 
+// BuiltinProviders ensures the providers implemented in dnsclay itself are
+// included in the sherpadoc API documentation. Types are renamed to
+// Provider_<name> in genapidoc.sh.
+export interface BuiltinProviders {
+	Xinmemory: Provider_inmemory
+	Xplugin: Provider_plugin
+	Xwebhook: Provider_webhook
+	Xzonefile: Provider_zonefile
+}
+
+// InMemoryProvider keeps records in memory, for testing integrations and the
+// propagation and sync logic of dnsclay without an account at a DNS operator.
+// Records are lost on restart. A zone is created on first use, with a SOA record
+// whose serial is incremented on each change.
+// 
+// Faults can be injected deterministically: latency, eventual consistency,
+// fixed SOA serials (like Route53), periodic errors and unsupported record types.
+export interface Provider_inmemory {
+	store?: string | null  // Name of store with zones. Provider configs with the same store share zones.
+	latency_ms?: number | null  // Delay for each operation.
+	propagation_delay?: number | null  // Number of GetRecords calls after a change that still return the old records.
+	fixed_serial?: boolean | null  // Return SOA records with serial 1, like Route53.
+	error_every?: number | null  // Fail every n-th operation, for a zone.
+	error_transient?: boolean | null  // Make the injected errors look like server errors, causing retries.
+	unsupported_types?: string | null  // Comma-separated record types that cannot be added, e.g. "CAA,TLSA".
+}
+
+// PluginProvider is a provider implemented by an external program, for DNS
+// operators without a libdns provider, or in-house DNS systems.
+// 
+// For each operation, a JSON request is sent to the plugin, and a JSON response is
+// read. With Command, the program is started for each operation, the request is
+// written to its stdin and the response is read from its stdout. With Socket, a
+// connection is made to a unix domain socket, the request is written as a single
+// line, and the response is read from the connection.
+// 
+// Request: {"Version": 1, "Method": "GetRecords", "Zone": "example.com.", "Records": [...], "Config": "..."}.
+// Methods are GetRecords, AppendRecords, SetRecords, DeleteRecords and ListZones.
+// 
+// Response: {"Records": [...], "Zones": ["example.com."], "Error": ""}. Records
+// are returned for all methods but ListZones, and are the records in the zone
+// (GetRecords), or the records that were added/set/deleted. If Error is non-empty,
+// the operation failed.
+// 
+// Records: {"ID": "...", "Type": "A", "Name": "www", "Value": "10.0.0.1", "TTL": 300, "Priority": 0, "Weight": 0, "Target": ""}.
+// Names are relative to the zone, "@" or empty for the zone apex. TTL is in
+// seconds.
+export interface Provider_plugin {
+	command?: string | null  // Program and arguments, separated by whitespace. Started for each operation. Not run through a shell. The program must be allowed with -providercommands.
+	socket?: string | null  // Path to unix domain socket to connect to for each operation.
+	config?: string | null  // Passed as is in each request, e.g. for credentials or plugin-specific settings.
+}
+
+// WebhookProvider is a provider for DNS systems with an HTTP/JSON API, e.g. an
+// in-house IPAM, configured with templates for requests and a mapping for
+// responses.
+// 
+// Paths and bodies are Go text/template templates, executed with fields Zone
+// (with trailing dot), Record (for append/delete, with fields ID, Type, Name,
+// Value and TTL in seconds) and RRSet (for set, with fields Type, Name, TTL,
+// Values and Records). Template functions: "json" for JSON-encoding a value,
+// "path" for escaping a URL path segment, "trimdot" for removing a trailing dot.
+// Records are appended and deleted with one request per record. Set replaces
+// rrsets, with one request per rrset, as records for new rrsets have no ID.
+// 
+// The get response (and optionally append/set responses) is parsed as JSON. The
+// records are found at records_path (dot-separated keys, or empty for a top-level
+// array), with each record an object with fields as configured in the field_*
+// settings.
+export interface Provider_webhook {
+	base_url?: string | null  // Base URL, paths are appended, e.g. https://ipam.example/api.
+	auth_header?: string | null  // Optional header for authentication, e.g. "Authorization: Bearer token". Can reference secrets, e.g. "env:IPAM_AUTH".
+	get_method?: string | null  // Default GET.
+	get_path?: string | null  // Template, default "/zones/{{path (trimdot .Zone)}}/records".
+	append_method?: string | null  // Default POST.
+	append_path?: string | null  // Template, default "/zones/{{path (trimdot .Zone)}}/records".
+	append_body?: string | null  // Template, default a JSON object with fields as configured for records.
+	set_method?: string | null  // Default PUT.
+	set_path?: string | null  // Template, default "/zones/{{path (trimdot .Zone)}}/rrsets".
+	set_body?: string | null  // Template, default a JSON object with fields "type", "name", "ttl" and "records" with objects like append_body.
+	delete_method?: string | null  // Default DELETE.
+	delete_path?: string | null  // Template, default "/zones/{{path (trimdot .Zone)}}/records/{{path .Record.ID}}".
+	delete_body?: string | null  // Template, default no body.
+	records_path?: string | null  // Dot-separated path to the array of records in the get response, e.g. "data.records". Empty for a top-level array.
+	field_id?: string | null  // Name of field with record ID, default "id".
+	field_type?: string | null  // Default "type".
+	field_name?: string | null  // Name relative to zone, default "name".
+	field_value?: string | null  // Default "value".
+	field_ttl?: string | null  // TTL in seconds, default "ttl".
+}
+
+// ZoneFileProvider stores records in a master zone file on disk, as used by BIND,
+// Knot, NSD and others. For labs, air-gapped setups and testing without a DNS
+// operator account.
+// 
+// Files are rewritten atomically, by writing a new file and renaming it over the
+// old file. The SOA serial is incremented for each change, unless the change sets
+// the SOA record. The file must exist and have a SOA record. Comments and
+// formatting are not preserved.
+export interface Provider_zonefile {
+	path?: string | null  // Path to zone file. "{zone}" is replaced with the zone name without trailing dot, e.g. "/var/lib/knot/{zone}.zone".
+	reload_command?: string | null  // Optional command to run after writing the file, with arguments separated by whitespace, not run through a shell. "{zone}" is replaced with the zone name, e.g. "knotc zone-reload {zone}". The program must be allowed with -providercommands.
+}
+
 // Section represents documentation about a Sherpa API section, as returned by the "_docs" function.
 export interface sherpadocSection {
 	Name: string  // Name of an API section.
@@ -664,22 +799,142 @@ export interface StringValue {
 	Docs: string
 }
 
+// ProviderCapabilities describes known limitations and behaviour of a provider.
+// Changes are validated against them before calling the provider, so clients get
+// a clear error instead of an opaque failure halfway through an update.
+export interface ProviderCapabilities {
+	Builtin: boolean  // Implemented in dnsclay itself.
+	Types?: string[] | null  // Supported record types. If empty, not known, and not checked.
+	MinTTL: number  // Minimum TTL in seconds, 0 if none or not known.
+	MaxTTL: number  // Maximum TTL in seconds, 0 if none or not known.
+	Notes: string  // Human-readable remarks.
+	RRSets: boolean  // Whether the provider API operates on record sets (name and type) instead of individual records. New provider configs default to RRSetUpdates.
+	NoSOA: boolean  // Whether the provider API does not return the SOA record of a zone. The SOA record is then always fetched from the authoritative name servers.
+	FixedSerial: boolean  // Whether the SOA serial stays the same when the zone changes. The serial can't be used to detect changes, so refreshes do a full sync, and the local serial is incremented independently of the remote serial.
+}
+
+// DiscoveredZone is a zone listed at a provider.
+export interface DiscoveredZone {
+	Name: string  // Absolute name, lower-case.
+	ProviderConfigName: string  // Provider config of the zone if it is configured, possibly different from the provider config used for listing. Empty if not configured.
+}
+
+// ZoneDiscovery is the result of the last periodic listing of zones for a
+// provider config.
+export interface ZoneDiscovery {
+	ProviderConfigName: string
+	Last?: Date | null
+	Next: Date
+	Error: string  // Error during last discovery, e.g. provider cannot list zones.
+	New?: string[] | null  // Zones listed at the provider, not configured.
+	Vanished?: string[] | null  // Zones configured with the provider config, not listed at the provider.
+}
+
+// ProviderHealth is the health of a provider config, as tracked by the circuit
+// breaker. Only kept in memory, all provider configs start out healthy.
+export interface ProviderHealth {
+	ProviderConfigName: string
+	Healthy: boolean  // False after FailureThreshold consecutive transient failures, until an operation succeeds again.
+	ConsecutiveFailures: number
+	LastError: string
+	LastErrorTime?: Date | null
+	UnhealthySince?: Date | null
+	NextAttempt?: Date | null  // While unhealthy, time until which operations fail without calling the provider, including automatic syncs.
+}
+
+// PropagationCheck is a verification that changes made through the provider are
+// returned by the provider. Stored while checks are pending, so verification can
+// be resumed after a restart. Removed when all changes are seen. Kept when
+// verification failed, until removed through the admin interface.
+export interface PropagationCheck {
+	ID: number
+	Created: Date
+	Zone: string
+	Add?: Record[] | null  // Records expected to be present.
+	Delete?: Record[] | null  // Records expected to be gone. If ID is nonzero, that exact record is checked.
+	PrevSerial: number  // Serial of zone before the changes.
+	Checks: number  // Number of checks done in the current phase, as index into its schedule.
+	LastCheck?: Date | null
+	NextCheck: Date
+	ProviderDone: boolean  // Whether the changes have been seen through the provider. For zones with VerifyNameservers, this starts the phase of checking the name servers.
+	Nameservers?: NameserverCheck[] | null  // State for each authoritative name server address, for zones with VerifyNameservers. Set when the name server phase starts.
+	Failed: boolean  // Set when the changes weren't all seen after the last check, or when checking failed.
+	LastError: string
+}
+
+// NameserverCheck is the state of propagation of changes at an address of an
+// authoritative name server.
+export interface NameserverCheck {
+	Host: string  // From NS record.
+	Addr: string  // IP and port.
+	Done: boolean  // Whether all changes were served.
+	LastCheck?: Date | null
+	LastError: string
+}
+
+// User is an account for the admin web interface.
+export interface User {
+	ID: number
+	Created: Date
+	Username: string
+	Role: Role
+	Zones?: string[] | null  // For role editor, the zones that can be changed. Absolute names with trailing dot.
+	PasswordHash: string  // Bcrypt hash of the password. Never returned through the API. Empty for users logging in through OpenID Connect.
+	OIDCSubject: string  // For users logging in through OpenID Connect, the issuer and subject of the ID token, separated by a space. Role and zones are updated from claims at each login. Empty for local users.
+}
+
+// AuditEvent is a change made, or a secret revealed, through the admin web
+// interface, for attributing changes to users.
+export interface AuditEvent {
+	ID: number
+	Time: Date
+	Username: string  // Empty for changes not made through the web interface.
+	APIToken: string  // Name of the API token used for the change, if any.
+	Zone: string  // Empty for changes not specific to a zone.
+	Action: string  // API function, e.g. "RecordSetAdd".
+	Details: string
+}
+
+// APIToken is a bearer token for scripts calling the API. A token acts for the
+// user that added it, limited further by its scopes.
+export interface APIToken {
+	ID: number
+	Created: Date
+	UserID: number
+	Name: string  // Description, e.g. of the script using the token.
+	TokenHash: string  // Hex SHA-256 of the token. Never returned through the API.
+	Prefix: string  // Start of the token, for recognizing it.
+	ReadOnly: boolean  // Only viewing, as if the user has role viewer.
+	Zones?: string[] | null  // If non-empty, only these zones can be viewed and changed, and functions requiring an admin cannot be called. Absolute names with trailing dot.
+	Functions?: string[] | null  // If non-empty, only these API functions can be called, e.g. "RecordSetUpdate".
+	Expires?: Date | null  // If set, the token cannot be used after this time.
+	LastUsed?: Date | null  // Updated at most once a minute.
+}
+
 export enum BaseURL {
 	Sandbox = "https://api.sandbox.dnsmadeeasy.com/V2.0/",
 	Prod = "https://api.dnsmadeeasy.com/V2.0/",
 }
 
-export const structTypes: {[typename: string]: boolean} = {"AuthOpenStack":true,"Credential":true,"IntValue":true,"KnownProviders":true,"PropagationState":true,"Provider":true,"ProviderConfig":true,"Provider_alidns":true,"Provider_autodns":true,"Provider_azure":true,"Provider_bunny":true,"Provider_civo":true,"Provider_cloudflare":true,"Provider_cloudns":true,"Provider_ddnss":true,"Provider_desec":true,"Provider_digitalocean":true,"Provider_directadmin":true,"Provider_dnsimple":true,"Provider_dnsmadeeasy":true,"Provider_dnspod":true,"Provider_dnsupdate":true,"Provider_domainnameshop":true,"Provider_dreamhost":true,"Provider_duckdns":true,"Provider_dynu":true,"Provider_dynv6":true,"Provider_easydns":true,"Provider_exoscale":true,"Provider_gandi":true,"Provider_gcore":true,"Provider_glesys":true,"Provider_godaddy":true,"Provider_googleclouddns":true,"Provider_he":true,"Provider_hetzner":true,"Provider_hexonet":true,"Provider_hosttech":true,"Provider_huaweicloud":true,"Provider_infomaniak":true,"Provider_inwx":true,"Provider_ionos":true,"Provider_katapult":true,"Provider_leaseweb":true,"Provider_linode":true,"Provider_loopia":true,"Provider_luadns":true,"Provider_mailinabox":true,"Provider_metaname":true,"Provider_mijnhost":true,"Provider_mythicbeasts":true,"Provider_namecheap":true,"Provider_namedotcom":true,"Provider_namesilo":true,"Provider_nanelo":true,"Provider_netcup":true,"Provider_netlify":true,"Provider_nfsn":true,"Provider_njalla":true,"Provider_ovh":true,"Provider_porkbun":true,"Provider_powerdns":true,"Provider_rfc2136":true,"Provider_route53":true,"Provider_scaleway":true,"Provider_selectel":true,"Provider_tencentcloud":true,"Provider_timeweb":true,"Provider_totaluptime":true,"Provider_vultr":true,"Provider_westcn":true,"Record":true,"RecordSet":true,"RecordSetChange":true,"StringValue":true,"Zone":true,"ZoneNotify":true,"sherpadocArg":true,"sherpadocField":true,"sherpadocFunction":true,"sherpadocInts":true,"sherpadocSection":true,"sherpadocStrings":true,"sherpadocStruct":true}
-export const stringsTypes: {[typename: string]: boolean} = {"BaseURL":true}
+// Role of a user of the admin web interface.
+export enum Role {
+	RoleViewer = "viewer",  // Can view zones, records and settings, without secrets.
+	RoleEditor = "editor",  // Can change records and settings of the zones listed in User.Zones.
+	RoleAdmin = "admin",  // Can change everything, including provider configs and users.
+}
+
+export const structTypes: {[typename: string]: boolean} = {"APIToken":true,"AuditEvent":true,"AuthOpenStack":true,"BuiltinProviders":true,"Credential":true,"DiscoveredZone":true,"IntValue":true,"KnownProviders":true,"NameserverCheck":true,"PropagationCheck":true,"PropagationState":true,"Provider":true,"ProviderCapabilities":true,"ProviderConfig":true,"ProviderHealth":true,"Provider_alidns":true,"Provider_autodns":true,"Provider_azure":true,"Provider_bunny":true,"Provider_civo":true,"Provider_cloudflare":true,"Provider_cloudns":true,"Provider_ddnss":true,"Provider_desec":true,"Provider_digitalocean":true,"Provider_directadmin":true,"Provider_dnsimple":true,"Provider_dnsmadeeasy":true,"Provider_dnspod":true,"Provider_dnsupdate":true,"Provider_domainnameshop":true,"Provider_dreamhost":true,"Provider_duckdns":true,"Provider_dynu":true,"Provider_dynv6":true,"Provider_easydns":true,"Provider_exoscale":true,"Provider_gandi":true,"Provider_gcore":true,"Provider_glesys":true,"Provider_godaddy":true,"Provider_googleclouddns":true,"Provider_he":true,"Provider_hetzner":true,"Provider_hexonet":true,"Provider_hosttech":true,"Provider_huaweicloud":true,"Provider_infomaniak":true,"Provider_inmemory":true,"Provider_inwx":true,"Provider_ionos":true,"Provider_katapult":true,"Provider_leaseweb":true,"Provider_linode":true,"Provider_loopia":true,"Provider_luadns":true,"Provider_mailinabox":true,"Provider_metaname":true,"Provider_mijnhost":true,"Provider_mythicbeasts":true,"Provider_namecheap":true,"Provider_namedotcom":true,"Provider_namesilo":true,"Provider_nanelo":true,"Provider_netcup":true,"Provider_netlify":true,"Provider_nfsn":true,"Provider_njalla":true,"Provider_ovh":true,"Provider_plugin":true,"Provider_porkbun":true,"Provider_powerdns":true,"Provider_rfc2136":true,"Provider_route53":true,"Provider_scaleway":true,"Provider_selectel":true,"Provider_tencentcloud":true,"Provider_timeweb":true,"Provider_totaluptime":true,"Provider_vultr":true,"Provider_webhook":true,"Provider_westcn":true,"Provider_zonefile":true,"QueuedChange":true,"Record":true,"RecordSet":true,"RecordSetChange":true,"StringValue":true,"User":true,"Zone":true,"ZoneDiscovery":true,"ZoneNotify":true,"sherpadocArg":true,"sherpadocField":true,"sherpadocFunction":true,"sherpadocInts":true,"sherpadocSection":true,"sherpadocStrings":true,"sherpadocStruct":true}
+export const stringsTypes: {[typename: string]: boolean} = {"BaseURL":true,"Role":true}
 export const intsTypes: {[typename: string]: boolean} = {}
 export const types: TypenameMap = {
-	"Zone": {"Name":"Zone","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"ProviderConfigName","Docs":"","Typewords":["string"]},{"Name":"SerialLocal","Docs":"","Typewords":["uint32"]},{"Name":"SerialRemote","Docs":"","Typewords":["uint32"]},{"Name":"LastSync","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"LastRecordChange","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"SyncInterval","Docs":"","Typewords":["int64"]},{"Name":"RefreshInterval","Docs":"","Typewords":["int64"]},{"Name":"NextSync","Docs":"","Typewords":["timestamp"]},{"Name":"NextRefresh","Docs":"","Typewords":["timestamp"]}]},
-	"ProviderConfig": {"Name":"ProviderConfig","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"ProviderName","Docs":"","Typewords":["string"]},{"Name":"ProviderConfigJSON","Docs":"","Typewords":["string"]}]},
-	"ZoneNotify": {"Name":"ZoneNotify","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"Zone","Docs":"","Typewords":["string"]},{"Name":"Address","Docs":"","Typewords":["string"]},{"Name":"Protocol","Docs":"","Typewords":["string"]}]},
-	"Credential": {"Name":"Credential","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Type","Docs":"","Typewords":["string"]},{"Name":"TSIGSecret","Docs":"","Typewords":["string"]},{"Name":"TLSPublicKey","Docs":"","Typewords":["string"]}]},
+	"Zone": {"Name":"Zone","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"ProviderConfigName","Docs":"","Typewords":["string"]},{"Name":"SerialLocal","Docs":"","Typewords":["uint32"]},{"Name":"SerialRemote","Docs":"","Typewords":["uint32"]},{"Name":"LastSync","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"LastRecordChange","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"SyncInterval","Docs":"","Typewords":["int64"]},{"Name":"RefreshInterval","Docs":"","Typewords":["int64"]},{"Name":"NextSync","Docs":"","Typewords":["timestamp"]},{"Name":"NextRefresh","Docs":"","Typewords":["timestamp"]},{"Name":"RecordsFreshness","Docs":"","Typewords":["int64"]},{"Name":"FreshPrerequisites","Docs":"","Typewords":["bool"]},{"Name":"QueueUpdates","Docs":"","Typewords":["bool"]},{"Name":"VerifyNameservers","Docs":"","Typewords":["bool"]},{"Name":"DelayUpdateResponse","Docs":"","Typewords":["bool"]},{"Name":"ConfigManaged","Docs":"","Typewords":["bool"]}]},
+	"ProviderConfig": {"Name":"ProviderConfig","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"ProviderName","Docs":"","Typewords":["string"]},{"Name":"ProviderConfigJSON","Docs":"","Typewords":["string"]},{"Name":"Retries","Docs":"","Typewords":["int32"]},{"Name":"FailureThreshold","Docs":"","Typewords":["int32"]},{"Name":"DiscoverInterval","Docs":"","Typewords":["int64"]},{"Name":"RRSetUpdates","Docs":"","Typewords":["bool"]},{"Name":"MinTTL","Docs":"","Typewords":["uint32"]},{"Name":"ConfigManaged","Docs":"","Typewords":["bool"]}]},
+	"ZoneNotify": {"Name":"ZoneNotify","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"Zone","Docs":"","Typewords":["string"]},{"Name":"Address","Docs":"","Typewords":["string"]},{"Name":"Protocol","Docs":"","Typewords":["string"]},{"Name":"ConfigManaged","Docs":"","Typewords":["bool"]}]},
+	"Credential": {"Name":"Credential","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Type","Docs":"","Typewords":["string"]},{"Name":"TSIGSecret","Docs":"","Typewords":["string"]},{"Name":"TLSPublicKey","Docs":"","Typewords":["string"]},{"Name":"ConfigManaged","Docs":"","Typewords":["bool"]}]},
 	"RecordSet": {"Name":"RecordSet","Docs":"","Fields":[{"Name":"Records","Docs":"","Typewords":["[]","Record"]},{"Name":"States","Docs":"","Typewords":["[]","PropagationState"]}]},
 	"Record": {"Name":"Record","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Zone","Docs":"","Typewords":["string"]},{"Name":"SerialFirst","Docs":"","Typewords":["uint32"]},{"Name":"SerialDeleted","Docs":"","Typewords":["uint32"]},{"Name":"First","Docs":"","Typewords":["timestamp"]},{"Name":"Deleted","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"AbsName","Docs":"","Typewords":["string"]},{"Name":"Type","Docs":"","Typewords":["uint16"]},{"Name":"Class","Docs":"","Typewords":["uint16"]},{"Name":"TTL","Docs":"","Typewords":["uint32"]},{"Name":"DataHex","Docs":"","Typewords":["string"]},{"Name":"Value","Docs":"","Typewords":["string"]},{"Name":"ProviderID","Docs":"","Typewords":["string"]}]},
 	"PropagationState": {"Name":"PropagationState","Docs":"","Fields":[{"Name":"Start","Docs":"","Typewords":["timestamp"]},{"Name":"End","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"Negative","Docs":"","Typewords":["bool"]},{"Name":"Records","Docs":"","Typewords":["[]","Record"]}]},
+	"QueuedChange": {"Name":"QueuedChange","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"Zone","Docs":"","Typewords":["string"]},{"Name":"Add","Docs":"","Typewords":["[]","Record"]},{"Name":"Set","Docs":"","Typewords":["[]","Record"]},{"Name":"Delete","Docs":"","Typewords":["[]","Record"]},{"Name":"Attempts","Docs":"","Typewords":["int32"]},{"Name":"LastAttempt","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"LastError","Docs":"","Typewords":["string"]},{"Name":"NextAttempt","Docs":"","Typewords":["timestamp"]},{"Name":"Failed","Docs":"","Typewords":["bool"]}]},
 	"RecordSetChange": {"Name":"RecordSetChange","Docs":"","Fields":[{"Name":"RelName","Docs":"","Typewords":["string"]},{"Name":"TTL","Docs":"","Typewords":["uint32"]},{"Name":"Type","Docs":"","Typewords":["uint16"]},{"Name":"Values","Docs":"","Typewords":["[]","string"]}]},
 	"KnownProviders": {"Name":"KnownProviders","Docs":"","Fields":[{"Name":"Xalidns","Docs":"","Typewords":["Provider_alidns"]},{"Name":"Xautodns","Docs":"","Typewords":["Provider_autodns"]},{"Name":"Xazure","Docs":"","Typewords":["Provider_azure"]},{"Name":"Xbunny","Docs":"","Typewords":["Provider_bunny"]},{"Name":"Xcivo","Docs":"","Typewords":["Provider_civo"]},{"Name":"Xcloudflare","Docs":"","Typewords":["Provider_cloudflare"]},{"Name":"Xcloudns","Docs":"","Typewords":["Provider_cloudns"]},{"Name":"Xddnss","Docs":"","Typewords":["Provider_ddnss"]},{"Name":"Xdesec","Docs":"","Typewords":["Provider_desec"]},{"Name":"Xdigitalocean","Docs":"","Typewords":["Provider_digitalocean"]},{"Name":"Xdirectadmin","Docs":"","Typewords":["Provider_directadmin"]},{"Name":"Xdnsimple","Docs":"","Typewords":["Provider_dnsimple"]},{"Name":"Xdnsmadeeasy","Docs":"","Typewords":["Provider_dnsmadeeasy"]},{"Name":"Xdnspod","Docs":"","Typewords":["Provider_dnspod"]},{"Name":"Xdnsupdate","Docs":"","Typewords":["Provider_dnsupdate"]},{"Name":"Xdomainnameshop","Docs":"","Typewords":["Provider_domainnameshop"]},{"Name":"Xdreamhost","Docs":"","Typewords":["Provider_dreamhost"]},{"Name":"Xduckdns","Docs":"","Typewords":["Provider_duckdns"]},{"Name":"Xdynu","Docs":"","Typewords":["Provider_dynu"]},{"Name":"Xdynv6","Docs":"","Typewords":["Provider_dynv6"]},{"Name":"Xeasydns","Docs":"","Typewords":["Provider_easydns"]},{"Name":"Xexoscale","Docs":"","Typewords":["Provider_exoscale"]},{"Name":"Xgandi","Docs":"","Typewords":["Provider_gandi"]},{"Name":"Xgcore","Docs":"","Typewords":["Provider_gcore"]},{"Name":"Xglesys","Docs":"","Typewords":["Provider_glesys"]},{"Name":"Xgodaddy","Docs":"","Typewords":["Provider_godaddy"]},{"Name":"Xgoogleclouddns","Docs":"","Typewords":["Provider_googleclouddns"]},{"Name":"Xhe","Docs":"","Typewords":["Provider_he"]},{"Name":"Xhetzner","Docs":"","Typewords":["Provider_hetzner"]},{"Name":"Xhexonet","Docs":"","Typewords":["Provider_hexonet"]},{"Name":"Xhosttech","Docs":"","Typewords":["Provider_hosttech"]},{"Name":"Xhuaweicloud","Docs":"","Typewords":["Provider_huaweicloud"]},{"Name":"Xinfomaniak","Docs":"","Typewords":["Provider_infomaniak"]},{"Name":"Xinwx","Docs":"","Typewords":["Provider_inwx"]},{"Name":"Xionos","Docs":"","Typewords":["Provider_ionos"]},{"Name":"Xkatapult","Docs":"","Typewords":["Provider_katapult"]},{"Name":"Xleaseweb","Docs":"","Typewords":["Provider_leaseweb"]},{"Name":"Xlinode","Docs":"","Typewords":["Provider_linode"]},{"Name":"Xloopia","Docs":"","Typewords":["Provider_loopia"]},{"Name":"Xluadns","Docs":"","Typewords":["Provider_luadns"]},{"Name":"Xmailinabox","Docs":"","Typewords":["Provider_mailinabox"]},{"Name":"Xmetaname","Docs":"","Typewords":["Provider_metaname"]},{"Name":"Xmijnhost","Docs":"","Typewords":["Provider_mijnhost"]},{"Name":"Xmythicbeasts","Docs":"","Typewords":["Provider_mythicbeasts"]},{"Name":"Xnamecheap","Docs":"","Typewords":["Provider_namecheap"]},{"Name":"Xnamedotcom","Docs":"","Typewords":["Provider_namedotcom"]},{"Name":"Xnamesilo","Docs":"","Typewords":["Provider_namesilo"]},{"Name":"Xnanelo","Docs":"","Typewords":["Provider_nanelo"]},{"Name":"Xnetcup","Docs":"","Typewords":["Provider_netcup"]},{"Name":"Xnetlify","Docs":"","Typewords":["Provider_netlify"]},{"Name":"Xnfsn","Docs":"","Typewords":["Provider_nfsn"]},{"Name":"Xnjalla","Docs":"","Typewords":["Provider_njalla"]},{"Name":"Xopenstackdesignate","Docs":"","Typewords":["Provider"]},{"Name":"Xovh","Docs":"","Typewords":["Provider_ovh"]},{"Name":"Xporkbun","Docs":"","Typewords":["Provider_porkbun"]},{"Name":"Xpowerdns","Docs":"","Typewords":["Provider_powerdns"]},{"Name":"Xrfc2136","Docs":"","Typewords":["Provider_rfc2136"]},{"Name":"Xroute53","Docs":"","Typewords":["Provider_route53"]},{"Name":"Xscaleway","Docs":"","Typewords":["Provider_scaleway"]},{"Name":"Xselectel","Docs":"","Typewords":["Provider_selectel"]},{"Name":"Xtencentcloud","Docs":"","Typewords":["Provider_tencentcloud"]},{"Name":"Xtimeweb","Docs":"","Typewords":["Provider_timeweb"]},{"Name":"Xtotaluptime","Docs":"","Typewords":["Provider_totaluptime"]},{"Name":"Xvultr","Docs":"","Typewords":["Provider_vultr"]},{"Name":"Xwestcn","Docs":"","Typewords":["Provider_westcn"]}]},
 	"Provider_alidns": {"Name":"Provider_alidns","Docs":"","Fields":[{"Name":"access_key_id","Docs":"","Typewords":["string"]},{"Name":"access_key_secret","Docs":"","Typewords":["string"]},{"Name":"region_id","Docs":"","Typewords":["nullable","string"]}]},
@@ -748,6 +1003,11 @@ export const types: TypenameMap = {
 	"Provider_totaluptime": {"Name":"Provider_totaluptime","Docs":"","Fields":[{"Name":"username","Docs":"","Typewords":["nullable","string"]},{"Name":"password","Docs":"","Typewords":["nullable","string"]}]},
 	"Provider_vultr": {"Name":"Provider_vultr","Docs":"","Fields":[{"Name":"api_token","Docs":"","Typewords":["nullable","string"]}]},
 	"Provider_westcn": {"Name":"Provider_westcn","Docs":"","Fields":[{"Name":"username","Docs":"","Typewords":["nullable","string"]},{"Name":"api_password","Docs":"","Typewords":["nullable","string"]}]},
+	"BuiltinProviders": {"Name":"BuiltinProviders","Docs":"","Fields":[{"Name":"Xinmemory","Docs":"","Typewords":["Provider_inmemory"]},{"Name":"Xplugin","Docs":"","Typewords":["Provider_plugin"]},{"Name":"Xwebhook","Docs":"","Typewords":["Provider_webhook"]},{"Name":"Xzonefile","Docs":"","Typewords":["Provider_zonefile"]}]},
+	"Provider_inmemory": {"Name":"Provider_inmemory","Docs":"","Fields":[{"Name":"store","Docs":"","Typewords":["nullable","string"]},{"Name":"latency_ms","Docs":"","Typewords":["nullable","int32"]},{"Name":"propagation_delay","Docs":"","Typewords":["nullable","int32"]},{"Name":"fixed_serial","Docs":"","Typewords":["nullable","bool"]},{"Name":"error_every","Docs":"","Typewords":["nullable","int32"]},{"Name":"error_transient","Docs":"","Typewords":["nullable","bool"]},{"Name":"unsupported_types","Docs":"","Typewords":["nullable","string"]}]},
+	"Provider_plugin": {"Name":"Provider_plugin","Docs":"","Fields":[{"Name":"command","Docs":"","Typewords":["nullable","string"]},{"Name":"socket","Docs":"","Typewords":["nullable","string"]},{"Name":"config","Docs":"","Typewords":["nullable","string"]}]},
+	"Provider_webhook": {"Name":"Provider_webhook","Docs":"","Fields":[{"Name":"base_url","Docs":"","Typewords":["nullable","string"]},{"Name":"auth_header","Docs":"","Typewords":["nullable","string"]},{"Name":"get_method","Docs":"","Typewords":["nullable","string"]},{"Name":"get_path","Docs":"","Typewords":["nullable","string"]},{"Name":"append_method","Docs":"","Typewords":["nullable","string"]},{"Name":"append_path","Docs":"","Typewords":["nullable","string"]},{"Name":"append_body","Docs":"","Typewords":["nullable","string"]},{"Name":"set_method","Docs":"","Typewords":["nullable","string"]},{"Name":"set_path","Docs":"","Typewords":["nullable","string"]},{"Name":"set_body","Docs":"","Typewords":["nullable","string"]},{"Name":"delete_method","Docs":"","Typewords":["nullable","string"]},{"Name":"delete_path","Docs":"","Typewords":["nullable","string"]},{"Name":"delete_body","Docs":"","Typewords":["nullable","string"]},{"Name":"records_path","Docs":"","Typewords":["nullable","string"]},{"Name":"field_id","Docs":"","Typewords":["nullable","string"]},{"Name":"field_type","Docs":"","Typewords":["nullable","string"]},{"Name":"field_name","Docs":"","Typewords":["nullable","string"]},{"Name":"field_value","Docs":"","Typewords":["nullable","string"]},{"Name":"field_ttl","Docs":"","Typewords":["nullable","string"]}]},
+	"Provider_zonefile": {"Name":"Provider_zonefile","Docs":"","Fields":[{"Name":"path","Docs":"","Typewords":["nullable","string"]},{"Name":"reload_command","Docs":"","Typewords":["nullable","string"]}]},
 	"sherpadocSection": {"Name":"sherpadocSection","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Docs","Docs":"","Typewords":["string"]},{"Name":"Functions","Docs":"","Typewords":["[]","nullable","sherpadocFunction"]},{"Name":"Sections","Docs":"","Typewords":["[]","nullable","sherpadocSection"]},{"Name":"Structs","Docs":"","Typewords":["[]","sherpadocStruct"]},{"Name":"Ints","Docs":"","Typewords":["[]","sherpadocInts"]},{"Name":"Strings","Docs":"","Typewords":["[]","sherpadocStrings"]},{"Name":"Version","Docs":"","Typewords":["nullable","string"]},{"Name":"SherpaVersion","Docs":"","Typewords":["int32"]},{"Name":"SherpadocVersion","Docs":"","Typewords":["nullable","int32"]}]},
 	"sherpadocFunction": {"Name":"sherpadocFunction","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Docs","Docs":"","Typewords":["string"]},{"Name":"Params","Docs":"","Typewords":["[]","sherpadocArg"]},{"Name":"Returns","Docs":"","Typewords":["[]","sherpadocArg"]}]},
 	"sherpadocArg": {"Name":"sherpadocArg","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Typewords","Docs":"","Typewords":["[]","string"]}]},
@@ -757,7 +1017,17 @@ export const types: TypenameMap = {
 	"IntValue": {"Name":"IntValue","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Value","Docs":"","Typewords":["int64"]},{"Name":"Docs","Docs":"","Typewords":["string"]}]},
 	"sherpadocStrings": {"Name":"sherpadocStrings","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Docs","Docs":"","Typewords":["string"]},{"Name":"Values","Docs":"","Typewords":["[]","StringValue"]}]},
 	"StringValue": {"Name":"StringValue","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Value","Docs":"","Typewords":["string"]},{"Name":"Docs","Docs":"","Typewords":["string"]}]},
+	"ProviderCapabilities": {"Name":"ProviderCapabilities","Docs":"","Fields":[{"Name":"Builtin","Docs":"","Typewords":["bool"]},{"Name":"Types","Docs":"","Typewords":["[]","string"]},{"Name":"MinTTL","Docs":"","Typewords":["uint32"]},{"Name":"MaxTTL","Docs":"","Typewords":["uint32"]},{"Name":"Notes","Docs":"","Typewords":["string"]},{"Name":"RRSets","Docs":"","Typewords":["bool"]},{"Name":"NoSOA","Docs":"","Typewords":["bool"]},{"Name":"FixedSerial","Docs":"","Typewords":["bool"]}]},
+	"DiscoveredZone": {"Name":"DiscoveredZone","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"ProviderConfigName","Docs":"","Typewords":["string"]}]},
+	"ZoneDiscovery": {"Name":"ZoneDiscovery","Docs":"","Fields":[{"Name":"ProviderConfigName","Docs":"","Typewords":["string"]},{"Name":"Last","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"Next","Docs":"","Typewords":["timestamp"]},{"Name":"Error","Docs":"","Typewords":["string"]},{"Name":"New","Docs":"","Typewords":["[]","string"]},{"Name":"Vanished","Docs":"","Typewords":["[]","string"]}]},
+	"ProviderHealth": {"Name":"ProviderHealth","Docs":"","Fields":[{"Name":"ProviderConfigName","Docs":"","Typewords":["string"]},{"Name":"Healthy","Docs":"","Typewords":["bool"]},{"Name":"ConsecutiveFailures","Docs":"","Typewords":["int32"]},{"Name":"LastError","Docs":"","Typewords":["string"]},{"Name":"LastErrorTime","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"UnhealthySince","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"NextAttempt","Docs":"","Typewords":["nullable","timestamp"]}]},
+	"PropagationCheck": {"Name":"PropagationCheck","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"Zone","Docs":"","Typewords":["string"]},{"Name":"Add","Docs":"","Typewords":["[]","Record"]},{"Name":"Delete","Docs":"","Typewords":["[]","Record"]},{"Name":"PrevSerial","Docs":"","Typewords":["uint32"]},{"Name":"Checks","Docs":"","Typewords":["int32"]},{"Name":"LastCheck","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"NextCheck","Docs":"","Typewords":["timestamp"]},{"Name":"ProviderDone","Docs":"","Typewords":["bool"]},{"Name":"Nameservers","Docs":"","Typewords":["[]","NameserverCheck"]},{"Name":"Failed","Docs":"","Typewords":["bool"]},{"Name":"LastError","Docs":"","Typewords":["string"]}]},
+	"NameserverCheck": {"Name":"NameserverCheck","Docs":"","Fields":[{"Name":"Host","Docs":"","Typewords":["string"]},{"Name":"Addr","Docs":"","Typewords":["string"]},{"Name":"Done","Docs":"","Typewords":["bool"]},{"Name":"LastCheck","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"LastError","Docs":"","Typewords":["string"]}]},
+	"User": {"Name":"User","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"Username","Docs":"","Typewords":["string"]},{"Name":"Role","Docs":"","Typewords":["Role"]},{"Name":"Zones","Docs":"","Typewords":["[]","string"]},{"Name":"PasswordHash","Docs":"","Typewords":["string"]},{"Name":"OIDCSubject","Docs":"","Typewords":["string"]}]},
+	"AuditEvent": {"Name":"AuditEvent","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Time","Docs":"","Typewords":["timestamp"]},{"Name":"Username","Docs":"","Typewords":["string"]},{"Name":"APIToken","Docs":"","Typewords":["string"]},{"Name":"Zone","Docs":"","Typewords":["string"]},{"Name":"Action","Docs":"","Typewords":["string"]},{"Name":"Details","Docs":"","Typewords":["string"]}]},
+	"APIToken": {"Name":"APIToken","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"UserID","Docs":"","Typewords":["int64"]},{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"TokenHash","Docs":"","Typewords":["string"]},{"Name":"Prefix","Docs":"","Typewords":["string"]},{"Name":"ReadOnly","Docs":"","Typewords":["bool"]},{"Name":"Zones","Docs":"","Typewords":["[]","string"]},{"Name":"Functions","Docs":"","Typewords":["[]","string"]},{"Name":"Expires","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"LastUsed","Docs":"","Typewords":["nullable","timestamp"]}]},
 	"BaseURL": {"Name":"BaseURL","Docs":"","Values":[{"Name":"Sandbox","Value":"https://api.sandbox.dnsmadeeasy.com/V2.0/","Docs":""},{"Name":"Prod","Value":"https://api.dnsmadeeasy.com/V2.0/","Docs":""}]},
+	"Role": {"Name":"Role","Docs":"","Values":[{"Name":"RoleViewer","Value":"viewer","Docs":""},{"Name":"RoleEditor","Value":"editor","Docs":""},{"Name":"RoleAdmin","Value":"admin","Docs":""}]},
 }
 
 export const parser = {
@@ -768,6 +1038,7 @@ export const parser = {
 	RecordSet: (v: any) => parse("RecordSet", v) as RecordSet,
 	Record: (v: any) => parse("Record", v) as Record,
 	PropagationState: (v: any) => parse("PropagationState", v) as PropagationState,
+	QueuedChange: (v: any) => parse("QueuedChange", v) as QueuedChange,
 	RecordSetChange: (v: any) => parse("RecordSetChange", v) as RecordSetChange,
 	KnownProviders: (v: any) => parse("KnownProviders", v) as KnownProviders,
 	Provider_alidns: (v: any) => parse("Provider_alidns", v) as Provider_alidns,
@@ -836,6 +1107,11 @@ export const parser = {
 	Provider_totaluptime: (v: any) => parse("Provider_totaluptime", v) as Provider_totaluptime,
 	Provider_vultr: (v: any) => parse("Provider_vultr", v) as Provider_vultr,
 	Provider_westcn: (v: any) => parse("Provider_westcn", v) as Provider_westcn,
+	BuiltinProviders: (v: any) => parse("BuiltinProviders", v) as BuiltinProviders,
+	Provider_inmemory: (v: any) => parse("Provider_inmemory", v) as Provider_inmemory,
+	Provider_plugin: (v: any) => parse("Provider_plugin", v) as Provider_plugin,
+	Provider_webhook: (v: any) => parse("Provider_webhook", v) as Provider_webhook,
+	Provider_zonefile: (v: any) => parse("Provider_zonefile", v) as Provider_zonefile,
 	sherpadocSection: (v: any) => parse("sherpadocSection", v) as sherpadocSection,
 	sherpadocFunction: (v: any) => parse("sherpadocFunction", v) as sherpadocFunction,
 	sherpadocArg: (v: any) => parse("sherpadocArg", v) as sherpadocArg,
@@ -845,7 +1121,17 @@ export const parser = {
 	IntValue: (v: any) => parse("IntValue", v) as IntValue,
 	sherpadocStrings: (v: any) => parse("sherpadocStrings", v) as sherpadocStrings,
 	StringValue: (v: any) => parse("StringValue", v) as StringValue,
+	ProviderCapabilities: (v: any) => parse("ProviderCapabilities", v) as ProviderCapabilities,
+	DiscoveredZone: (v: any) => parse("DiscoveredZone", v) as DiscoveredZone,
+	ZoneDiscovery: (v: any) => parse("ZoneDiscovery", v) as ZoneDiscovery,
+	ProviderHealth: (v: any) => parse("ProviderHealth", v) as ProviderHealth,
+	PropagationCheck: (v: any) => parse("PropagationCheck", v) as PropagationCheck,
+	NameserverCheck: (v: any) => parse("NameserverCheck", v) as NameserverCheck,
+	User: (v: any) => parse("User", v) as User,
+	AuditEvent: (v: any) => parse("AuditEvent", v) as AuditEvent,
+	APIToken: (v: any) => parse("APIToken", v) as APIToken,
 	BaseURL: (v: any) => parse("BaseURL", v) as BaseURL,
+	Role: (v: any) => parse("Role", v) as Role,
 }
 
 // API is the webapi used by the admin frontend.
@@ -890,7 +1176,8 @@ export class Client {
 	// record sets includes those no long active (i.e. deleted). The
 	// history/propagation state fo the record sets only includes those that may still
 	// be in caches. Use ZoneRecordSetHistory for the full history for a single record
-	// set.
+	// set. The provider config JSON and TSIG secrets are not returned, see
+	// ProviderConfigReveal and ZoneCredentialReveal.
 	async Zone(zone: string): Promise<[Zone, ProviderConfig, ZoneNotify[] | null, Credential[] | null, RecordSet[] | null]> {
 		const fn: string = "Zone"
 		const paramTypes: string[][] = [["string"]]
@@ -950,7 +1237,8 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
 	}
 
-	// ZoneUpdate updates the provider config and refresh & sync interval for a zone.
+	// ZoneUpdate updates the provider config, refresh & sync interval, records
+	// freshness, DNS UPDATE and propagation settings for a zone.
 	async ZoneUpdate(z: Zone): Promise<Zone> {
 		const fn: string = "ZoneUpdate"
 		const paramTypes: string[][] = [["Zone"]]
@@ -986,6 +1274,35 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
 	}
 
+	// ZoneQueuedChanges returns the changes from DNS UPDATEs for the zone that are
+	// still to be applied through the provider, including failed changes.
+	async ZoneQueuedChanges(zone: string): Promise<QueuedChange[] | null> {
+		const fn: string = "ZoneQueuedChanges"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["[]","QueuedChange"]]
+		const params: any[] = [zone]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as QueuedChange[] | null
+	}
+
+	// QueuedChangeRetry schedules an immediate attempt at applying a queued change,
+	// resetting its attempts, e.g. after it was marked as failed.
+	async QueuedChangeRetry(queuedChangeID: number): Promise<QueuedChange> {
+		const fn: string = "QueuedChangeRetry"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = [["QueuedChange"]]
+		const params: any[] = [queuedChangeID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as QueuedChange
+	}
+
+	// QueuedChangeDelete removes a queued change, it will not be applied.
+	async QueuedChangeDelete(queuedChangeID: number): Promise<void> {
+		const fn: string = "QueuedChangeDelete"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [queuedChangeID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
 	// ZoneCredentialAdd adds a new TSIG or TLS public key credential to a zone.
 	async ZoneCredentialAdd(zone: string, c: Credential): Promise<Credential> {
 		const fn: string = "ZoneCredentialAdd"
@@ -1004,6 +1321,17 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
 	}
 
+	// ZoneCredentialReveal returns the TSIG secret of a credential. The secret gives
+	// access to all zones the credential is used for, so the user must be able to
+	// change all of them.
+	async ZoneCredentialReveal(credentialID: number): Promise<string> {
+		const fn: string = "ZoneCredentialReveal"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = [["string"]]
+		const params: any[] = [credentialID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as string
+	}
+
 	// ZoneImportRecords parses records in zonefile, assuming standard zone file syntax,
 	// and adds the records via the provider and syncs the newly added records to the
 	// local database. The latest records, included historic/deleted records after the
@@ -1085,12 +1413,12 @@ export class Client {
 
 	// KnownProviders is a dummy method whose sole purpose is to get an API description
 	// of all known providers in the API documentation, for use in TypeScript.
-	async KnownProviders(): Promise<[KnownProviders, sherpadocSection]> {
+	async KnownProviders(): Promise<[KnownProviders, BuiltinProviders, sherpadocSection]> {
 		const fn: string = "KnownProviders"
 		const paramTypes: string[][] = []
-		const returnTypes: string[][] = [["KnownProviders"],["sherpadocSection"]]
+		const returnTypes: string[][] = [["KnownProviders"],["BuiltinProviders"],["sherpadocSection"]]
 		const params: any[] = []
-		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as [KnownProviders, sherpadocSection]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as [KnownProviders, BuiltinProviders, sherpadocSection]
 	}
 
 	// Docs returns the API docs. The TypeScript code uses this documentation to build
@@ -1114,7 +1442,8 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as number
 	}
 
-	// ProviderConfigs returns all provider configs.
+	// ProviderConfigs returns all provider configs, without their provider config
+	// JSON, see ProviderConfigReveal.
 	async ProviderConfigs(): Promise<ProviderConfig[] | null> {
 		const fn: string = "ProviderConfigs"
 		const paramTypes: string[][] = []
@@ -1123,6 +1452,16 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as ProviderConfig[] | null
 	}
 
+	// ProviderConfigReveal returns the provider config JSON of a provider config,
+	// with its secrets, e.g. for editing.
+	async ProviderConfigReveal(providerConfigName: string): Promise<string> {
+		const fn: string = "ProviderConfigReveal"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["string"]]
+		const params: any[] = [providerConfigName]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as string
+	}
+
 	// ProviderURLs returns a mapping of provider names to URLs of their
 	// repositories, for further help/instructions.
 	async ProviderURLs(): Promise<{ [key: string]: string }> {
@@ -1133,6 +1472,16 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as { [key: string]: string }
 	}
 
+	// ProviderCapabilities returns the known capabilities of providers, by name.
+	// Providers without capabilities are not known to have limitations.
+	async ProviderCapabilities(): Promise<{ [key: string]: ProviderCapabilities }> {
+		const fn: string = "ProviderCapabilities"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["{}","ProviderCapabilities"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as { [key: string]: ProviderCapabilities }
+	}
+
 	// ProviderConfigAdd adds a new provider config.
 	async ProviderConfigAdd(pc: ProviderConfig): Promise<ProviderConfig> {
 		const fn: string = "ProviderConfigAdd"
@@ -1142,7 +1491,8 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as ProviderConfig
 	}
 
-	// ProviderConfigUpdate updates a provider config.
+	// ProviderConfigUpdate updates a provider config. The health of the provider
+	// config is reset.
 	async ProviderConfigUpdate(pc: ProviderConfig): Promise<ProviderConfig> {
 		const fn: string = "ProviderConfigUpdate"
 		const paramTypes: string[][] = [["ProviderConfig"]]
@@ -1151,6 +1501,73 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as ProviderConfig
 	}
 
+	// ProviderConfigDiscoverZones lists the zones at the provider of a provider
+	// config. Zones that are already configured have their provider config set.
+	// Vanished are the zones configured with the provider config that are not listed
+	// at the provider. Not all providers can list zones.
+	async ProviderConfigDiscoverZones(providerConfigName: string): Promise<[DiscoveredZone[] | null, string[] | null]> {
+		const fn: string = "ProviderConfigDiscoverZones"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["[]","DiscoveredZone"],["[]","string"]]
+		const params: any[] = [providerConfigName]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as [DiscoveredZone[] | null, string[] | null]
+	}
+
+	// ZonesAdd adds zones with an existing provider config, typically zones found
+	// with ProviderConfigDiscoverZones, with default refresh and sync intervals. Each
+	// zone gets the notifies, and its own TSIG credential. Zones are added in a single
+	// transaction, if one zone cannot be added, none are.
+	async ZonesAdd(providerConfigName: string, zones: string[] | null, notifies: ZoneNotify[] | null): Promise<Zone[] | null> {
+		const fn: string = "ZonesAdd"
+		const paramTypes: string[][] = [["string"],["[]","string"],["[]","ZoneNotify"]]
+		const returnTypes: string[][] = [["[]","Zone"]]
+		const params: any[] = [providerConfigName, zones, notifies]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Zone[] | null
+	}
+
+	// ZoneDiscoveries returns the results of the last zone discovery for provider
+	// configs, with zones that are new at the provider or have vanished. Requires
+	// admin, API tokens limited to zones are not allowed.
+	async ZoneDiscoveries(): Promise<ZoneDiscovery[] | null> {
+		const fn: string = "ZoneDiscoveries"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","ZoneDiscovery"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as ZoneDiscovery[] | null
+	}
+
+	// ProviderHealth returns the health of provider configs as tracked by the
+	// circuit breaker, for provider configs used since startup. For API tokens
+	// limited to zones, only provider configs of those zones are returned. Errors are
+	// only returned to admins.
+	async ProviderHealth(): Promise<ProviderHealth[] | null> {
+		const fn: string = "ProviderHealth"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","ProviderHealth"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as ProviderHealth[] | null
+	}
+
+	// PropagationChecks returns the verifications of changes made through providers
+	// that are still pending, or that have failed.
+	async PropagationChecks(): Promise<PropagationCheck[] | null> {
+		const fn: string = "PropagationChecks"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","PropagationCheck"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as PropagationCheck[] | null
+	}
+
+	// PropagationCheckDelete removes a propagation check, typically after it failed.
+	// A pending check is no longer resumed after a restart.
+	async PropagationCheckDelete(propagationCheckID: number): Promise<void> {
+		const fn: string = "PropagationCheckDelete"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [propagationCheckID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
 	// ZoneRecordSets returns the current record sets including propagation states that
 	// are not the latest version but that may still be in caches. For the full history
 	// of a record set, see ZoneRecordSetHistory.
@@ -1171,6 +1588,153 @@ export class Client {
 		const params: any[] = [zone, relName, typ]
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as PropagationState[] | null
 	}
+
+	// LoginPrep returns a login token, and sets it as cookie. Both must be present
+	// in the call to Login.
+	async LoginPrep(): Promise<string> {
+		const fn: string = "LoginPrep"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["string"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as string
+	}
+
+	// OIDCEnabled returns whether users can log in through OpenID Connect, at
+	// /auth/oidc/login.
+	async OIDCEnabled(): Promise<boolean> {
+		const fn: string = "OIDCEnabled"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["bool"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as boolean
+	}
+
+	// Login verifies the username and password, and starts a session, setting a
+	// session cookie. The returned CSRF token must be sent with subsequent API calls
+	// in the x-dnsclay-csrf header.
+	async Login(loginToken: string, username: string, password: string): Promise<string> {
+		const fn: string = "Login"
+		const paramTypes: string[][] = [["string"],["string"],["string"]]
+		const returnTypes: string[][] = [["string"]]
+		const params: any[] = [loginToken, username, password]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as string
+	}
+
+	// Logout ends the session.
+	async Logout(): Promise<void> {
+		const fn: string = "Logout"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = []
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// CurrentUser returns the logged in user.
+	async CurrentUser(): Promise<User> {
+		const fn: string = "CurrentUser"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["User"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as User
+	}
+
+	// PasswordChange changes the password of the logged in user. Other sessions of
+	// the user are ended.
+	async PasswordChange(currentPassword: string, newPassword: string): Promise<void> {
+		const fn: string = "PasswordChange"
+		const paramTypes: string[][] = [["string"],["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [currentPassword, newPassword]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// Users returns all users, without password hashes.
+	async Users(): Promise<User[] | null> {
+		const fn: string = "Users"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","User"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as User[] | null
+	}
+
+	// UserAdd adds a new user with a password.
+	async UserAdd(u: User, password: string): Promise<User> {
+		const fn: string = "UserAdd"
+		const paramTypes: string[][] = [["User"],["string"]]
+		const returnTypes: string[][] = [["User"]]
+		const params: any[] = [u, password]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as User
+	}
+
+	// UserUpdate changes the role and zones of a user.
+	async UserUpdate(u: User): Promise<User> {
+		const fn: string = "UserUpdate"
+		const paramTypes: string[][] = [["User"]]
+		const returnTypes: string[][] = [["User"]]
+		const params: any[] = [u]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as User
+	}
+
+	// UserPasswordSet sets a new password for a user, ending the sessions of the
+	// user.
+	async UserPasswordSet(userID: number, password: string): Promise<void> {
+		const fn: string = "UserPasswordSet"
+		const paramTypes: string[][] = [["int64"],["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [userID, password]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// UserDelete removes a user, its sessions and API tokens.
+	async UserDelete(userID: number): Promise<void> {
+		const fn: string = "UserDelete"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [userID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// AuditEvents returns the most recent changes made through the admin web
+	// interface, newest first. If zone is non-empty, only changes for that zone are
+	// returned.
+	async AuditEvents(zone: string): Promise<AuditEvent[] | null> {
+		const fn: string = "AuditEvents"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["[]","AuditEvent"]]
+		const params: any[] = [zone]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as AuditEvent[] | null
+	}
+
+	// APITokens returns the API tokens of the logged in user, or of all users for
+	// admins.
+	async APITokens(): Promise<APIToken[] | null> {
+		const fn: string = "APITokens"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","APIToken"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as APIToken[] | null
+	}
+
+	// APITokenAdd adds an API token for the logged in user, for use as bearer token
+	// in the Authorization header of API calls. The token is only returned now, only
+	// a hash is stored.
+	async APITokenAdd(t: APIToken): Promise<[APIToken, string]> {
+		const fn: string = "APITokenAdd"
+		const paramTypes: string[][] = [["APIToken"]]
+		const returnTypes: string[][] = [["APIToken"],["string"]]
+		const params: any[] = [t]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as [APIToken, string]
+	}
+
+	// APITokenRevoke removes an API token. Users can revoke their own tokens, admins
+	// can revoke all tokens.
+	async APITokenRevoke(apiTokenID: number): Promise<void> {
+		const fn: string = "APITokenRevoke"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [apiTokenID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
 }
 
 export const defaultBaseURL = (function() {
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/mjl-/bstore"
+	"github.com/mjl-/sherpa"
+)
+
+// Users log in to the admin web interface with a username and password, like in
+// mox. LoginPrep sets a cookie with a random login token, which Login compares
+// against the token in its parameters, preventing login CSRF. A successful login
+// sets an HttpOnly session cookie and returns a CSRF token. The frontend stores
+// the CSRF token in localStorage and sends it in a header with each API call.
+// Both must match a session in the database.
+
+const (
+	cookieLogin   = "dnsclaylogin"
+	cookieSession = "dnsclaysession"
+	headerCSRF    = "x-dnsclay-csrf"
+)
+
+// Sessions not used for this long are no longer valid.
+var sessionIdleTimeout = 24 * time.Hour
+
+// Delay before responding to a failed login, slowing down password guessing.
+// Shorter during tests.
+var loginFailureDelay = time.Second
+
+var errForbidden = errors.New("not allowed for role")
+
+// requestInfo is stored in the context of API calls, for access to the session
+// and for setting cookies.
+type requestInfo struct {
+	w       http.ResponseWriter
+	r       *http.Request
+	session Session
+	user    *User     // Nil for calls that don't need a session, like Login.
+	token   *APIToken // If the call was authenticated with an API token.
+}
+
+var ctxKeyRequestInfo = ctxKey("requestinfo")
+
+// requestUser returns the logged in user for the API call, or nil for calls not
+// coming from the web interface, e.g. internal calls and tests, which have full
+// access.
+func requestUser(ctx context.Context) *User {
+	ri, ok := ctx.Value(ctxKeyRequestInfo).(requestInfo)
+	if !ok {
+		return nil
+	}
+	return ri.user
+}
+
+// requestToken returns the API token used for the call, or nil.
+func requestToken(ctx context.Context) *APIToken {
+	ri, ok := ctx.Value(ctxKeyRequestInfo).(requestInfo)
+	if !ok {
+		return nil
+	}
+	return ri.token
+}
+
+func genToken() string {
+	buf := make([]byte, 18)
+	_, err := cryptorand.Read(buf)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func sessionTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// sherpaAuthError writes a sherpa error response, recognized by the frontend to
+// ask for a login.
+func sherpaAuthError(w http.ResponseWriter, code, msg string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	err := json.NewEncoder(w).Encode(map[string]any{"error": sherpa.Error{Code: code, Message: msg}})
+	logCheck(slog.Default(), err, "writing auth error response")
+}
+
+// sessionCheck returns the session and user for the session cookie of the
+// request. If csrf is set, the CSRF header must match the session.
+func sessionCheck(ctx context.Context, r *http.Request, csrf bool) (Session, User, error) {
+	c, err := r.Cookie(cookieSession)
+	if err != nil || c.Value == "" {
+		return Session{}, User{}, errors.New("no session")
+	}
+	var s Session
+	var u User
+	var expired bool
+	err = database.Write(ctx, func(tx *bstore.Tx) error {
+		var err error
+		s, err = bstore.QueryTx[Session](tx).FilterNonzero(Session{TokenHash: sessionTokenHash(c.Value)}).Get()
+		if err == bstore.ErrAbsent {
+			return errors.New("unknown session")
+		} else if err != nil {
+			return fmt.Errorf("get session: %v", err)
+		}
+		now := time.Now()
+		if now.Sub(s.LastUse) > sessionIdleTimeout {
+			// Return nil so the removal is committed.
+			expired = true
+			if err := tx.Delete(&s); err != nil {
+				return fmt.Errorf("removing expired session: %v", err)
+			}
+			return nil
+		}
+		u = User{ID: s.UserID}
+		if err := tx.Get(&u); err != nil {
+			return fmt.Errorf("get user for session: %v", err)
+		}
+		// Don't write for each request.
+		if now.Sub(s.LastUse) > time.Minute {
+			s.LastUse = now
+			if err := tx.Update(&s); err != nil {
+				return fmt.Errorf("updating session: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Session{}, User{}, err
+	} else if expired {
+		return Session{}, User{}, errors.New("session expired")
+	}
+	if csrf && subtle.ConstantTimeCompare([]byte(r.Header.Get(headerCSRF)), []byte(s.CSRFToken)) != 1 {
+		return Session{}, User{}, errors.New("bad csrf token")
+	}
+	return s, u, nil
+}
+
+// sessionStart adds a new session for the user, sets the session cookie and
+// returns the CSRF token.
+func sessionStart(ctx context.Context, w http.ResponseWriter, r *http.Request, u User) (string, error) {
+	token := genToken()
+	s := Session{LastUse: time.Now(), UserID: u.ID, TokenHash: sessionTokenHash(token), CSRFToken: genToken()}
+	if err := database.Insert(ctx, &s); err != nil {
+		return "", fmt.Errorf("adding session: %v", err)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieSession,
+		Value:    token,
+		Path:     "/",
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return s.CSRFToken, nil
+}
+
+// API functions that cannot be called with an API token.
+var apiTokenExcluded = []string{"LoginPrep", "Login", "Logout", "PasswordChange", "APITokens", "APITokenAdd", "APITokenRevoke"}
+
+// tokenCheck returns the API token and its user for a bearer token.
+func tokenCheck(ctx context.Context, token string) (APIToken, User, error) {
+	var t APIToken
+	var u User
+	err := database.Write(ctx, func(tx *bstore.Tx) error {
+		var err error
+		t, err = bstore.QueryTx[APIToken](tx).FilterNonzero(APIToken{TokenHash: sessionTokenHash(token)}).Get()
+		if err == bstore.ErrAbsent {
+			return errors.New("unknown api token")
+		} else if err != nil {
+			return fmt.Errorf("get api token: %v", err)
+		}
+		now := time.Now()
+		if t.Expires != nil && now.After(*t.Expires) {
+			return errors.New("api token expired")
+		}
+		u = User{ID: t.UserID}
+		if err := tx.Get(&u); err != nil {
+			return fmt.Errorf("get user for api token: %v", err)
+		}
+		if t.LastUsed == nil || now.Sub(*t.LastUsed) > time.Minute {
+			t.LastUsed = &now
+			if err := tx.Update(&t); err != nil {
+				return fmt.Errorf("updating api token: %v", err)
+			}
+		}
+		return nil
+	})
+	return t, u, err
+}
+
+// tokenUser returns the user with the access of the token: the role and zones of
+// the user, limited by the scopes of the token.
+func tokenUser(u User, t APIToken) User {
+	if t.ReadOnly {
+		u.Role = RoleViewer
+		u.Zones = nil
+	} else if len(t.Zones) > 0 {
+		switch u.Role {
+		case RoleAdmin:
+			u.Role = RoleEditor
+			u.Zones = slices.Clone(t.Zones)
+		case RoleEditor:
+			u.Zones = slices.DeleteFunc(slices.Clone(u.Zones), func(zone string) bool { return !slices.Contains(t.Zones, zone) })
+		}
+	}
+	return u
+}
+
+// tokenAllowsZone returns whether the API token for the call, if any, can view
+// and change zone.
+func tokenAllowsZone(ctx context.Context, zone string) bool {
+	t := requestToken(ctx)
+	return t == nil || len(t.Zones) == 0 || slices.Contains(t.Zones, zone)
+}
+
+// _checkZoneView fails the API call if the API token for the call is limited to
+// other zones.
+func _checkZoneView(ctx context.Context, zone string) {
+	if !tokenAllowsZone(ctx, zone) {
+		_forbidden("zone %s not in scope of api token", zone)
+	}
+}
+
+// apiAuth requires a session for API calls, except for logging in and fetching
+// the API description. Scripts can use HTTP basic auth with the username and
+// password of a user instead, or an API token as bearer token. The session and
+// user are stored in the request context.
+func apiAuth(h http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ri := requestInfo{w: w, r: r}
+		switch strings.TrimPrefix(r.URL.Path, "/api/") {
+		case "", "sherpa.json", "sherpa.js", "LoginPrep", "Login", "OIDCEnabled":
+		default:
+			if r.Method == "OPTIONS" {
+				break
+			}
+			if username, password, ok := r.BasicAuth(); ok {
+				u, err := passwordLogin(r.Context(), username, password)
+				if err != nil {
+					sherpaAuthError(w, "user:badAuth", "invalid username or password")
+					return
+				}
+				ri.user = &u
+				break
+			}
+			if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+				t, u, err := tokenCheck(r.Context(), strings.TrimPrefix(auth, "Bearer "))
+				if err != nil {
+					cidlog(r.Context()).Debug("api call with bad token", "err", err)
+					sherpaAuthError(w, "user:badAuth", err.Error())
+					return
+				}
+				fn := strings.TrimPrefix(r.URL.Path, "/api/")
+				if slices.Contains(apiTokenExcluded, fn) || len(t.Functions) > 0 && !slices.Contains(t.Functions, fn) {
+					sherpaAuthError(w, "user:forbidden", fmt.Sprintf("%s: function %s not in scope of api token", errForbidden, fn))
+					return
+				}
+				tu := tokenUser(u, t)
+				ri.user = &tu
+				ri.token = &t
+				break
+			}
+			s, u, err := sessionCheck(r.Context(), r, true)
+			if err != nil {
+				cidlog(r.Context()).Debug("api call without valid session", "err", err)
+				sherpaAuthError(w, "user:noAuth", err.Error())
+				return
+			}
+			ri.session = s
+			ri.user = &u
+		}
+		ctx := context.WithValue(r.Context(), ctxKeyRequestInfo, ri)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// httpAdminAuth requires a session of an admin, or HTTP basic auth, for non-API
+// requests like the database export. No CSRF header is sent for these GET
+// requests, the session cookie is SameSite=Strict.
+func httpAdminAuth(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var u User
+		var err error
+		if username, password, ok := r.BasicAuth(); ok {
+			u, err = passwordLogin(r.Context(), username, password)
+		} else {
+			_, u, err = sessionCheck(r.Context(), r, false)
+		}
+		if err != nil {
+			http.Error(w, "401 - unauthorized - log in through the web interface", http.StatusUnauthorized)
+			return
+		} else if u.Role != RoleAdmin {
+			http.Error(w, "403 - forbidden", http.StatusForbidden)
+			return
+		}
+		fn(w, r)
+	}
+}
+
+func _forbidden(format string, args ...any) {
+	m := fmt.Sprintf(format, args...)
+	slog.Debug("sherpa forbidden", "err", m)
+	panic(&sherpa.Error{Code: "user:forbidden", Message: fmt.Sprintf("%s: %s", errForbidden, m)})
+}
+
+// _checkAdmin fails the API call if the user is not an admin.
+func _checkAdmin(ctx context.Context, what string) {
+	if !userIsAdmin(ctx) {
+		_forbidden("%s requires admin", what)
+	}
+}
+
+// userIsAdmin returns whether the user of the API call has the admin role, or
+// has full access.
+func userIsAdmin(ctx context.Context) bool {
+	u := requestUser(ctx)
+	return u == nil || u.Role == RoleAdmin
+}
+
+// userCanEdit returns whether the user can change zone. A nil user has full
+// access.
+func userCanEdit(u *User, zone string) bool {
+	return u == nil || u.Role == RoleAdmin || u.Role == RoleEditor && slices.Contains(u.Zones, zone)
+}
+
+// _checkZoneEdit fails the API call if the user cannot change zone.
+func _checkZoneEdit(ctx context.Context, zone string) {
+	if !userCanEdit(requestUser(ctx), zone) {
+		_forbidden("changing zone %s", zone)
+	}
+}
+
+// audit stores an event for a change made through the API, attributing it to the
+// logged in user.
+func audit(ctx context.Context, zone, action, format string, args ...any) {
+	var username, token string
+	if u := requestUser(ctx); u != nil {
+		username = u.Username
+	}
+	if t := requestToken(ctx); t != nil {
+		token = t.Name
+	}
+	ev := AuditEvent{Username: username, APIToken: token, Zone: zone, Action: action, Details: fmt.Sprintf(format, args...)}
+	err := database.Insert(context.Background(), &ev)
+	logCheck(cidlog(ctx), err, "storing audit event", "action", action)
+}
+
+func _checkPassword(password string) {
+	if len(password) < 8 {
+		_checkuserf(errors.New("must be at least 8 characters"), "checking password")
+	}
+}
+
+func passwordVerify(hash, password string) error {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+}
+
+// passwordLogin returns the user if the password is correct. On failure, the
+// response is delayed.
+func passwordLogin(ctx context.Context, username, password string) (User, error) {
+	u, err := bstore.QueryDB[User](ctx, database).FilterNonzero(User{Username: username}).Get()
+	if err == nil && u.PasswordHash == "" {
+		err = errors.New("user has no password")
+	} else if err == nil {
+		err = passwordVerify(u.PasswordHash, password)
+	}
+	if err != nil {
+		cidlog(ctx).Info("failed login", "username", username, "err", err)
+		time.Sleep(loginFailureDelay)
+		return User{}, err
+	}
+	return u, nil
+}
+
+func passwordHash(password string) (string, error) {
+	buf, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("hashing password: %w", err)
+	}
+	return string(buf), nil
+}
+
+// usersInit ensures there is a user that can log in. If the database has no
+// users, an admin user "admin" is created, with the password from the file at
+// path, which is generated and written if it doesn't exist.
+func usersInit(ctx context.Context, log *slog.Logger, path string) error {
+	return database.Write(ctx, func(tx *bstore.Tx) error {
+		exists, err := bstore.QueryTx[User](tx).Exists()
+		if err != nil {
+			return fmt.Errorf("checking for users: %v", err)
+		} else if exists {
+			return nil
+		}
+
+		var password string
+		pwbuf, err := os.ReadFile(path)
+		if err == nil {
+			password = strings.TrimRight(string(pwbuf), "\n")
+		} else if errors.Is(err, fs.ErrNotExist) {
+			password = genpassword()
+			if err := os.WriteFile(path, []byte(password+"\n"), 0600); err != nil {
+				return fmt.Errorf("write admin password file: %v", err)
+			}
+			log.Info("generated new password for admin user", "username", "admin", "password", password)
+		} else {
+			return fmt.Errorf("reading admin password file: %v", err)
+		}
+
+		hash, err := passwordHash(password)
+		if err != nil {
+			return fmt.Errorf("admin password: %w", err)
+		}
+		u := User{Username: "admin", Role: RoleAdmin, PasswordHash: hash}
+		if err := tx.Insert(&u); err != nil {
+			return fmt.Errorf("adding admin user: %v", err)
+		}
+		log.Info("added admin user", "username", u.Username)
+		return nil
+	})
+}
@@ -0,0 +1,22 @@
+package main
+
+// BuiltinProviders ensures the providers implemented in dnsclay itself are
+// included in the sherpadoc API documentation. Types are renamed to
+// Provider_<name> in genapidoc.sh.
+type BuiltinProviders struct {
+	Xinmemory InMemoryProvider
+	Xplugin   PluginProvider
+	Xwebhook  WebhookProvider
+	Xzonefile ZoneFileProvider
+}
+
+func init() {
+	providers["inmemory"] = InMemoryProvider{}
+	providerURLs["inmemory"] = "github.com/mjl-/dnsclay#in-memory-provider"
+	providers["plugin"] = PluginProvider{}
+	providerURLs["plugin"] = "github.com/mjl-/dnsclay#plugin-providers"
+	providers["webhook"] = WebhookProvider{}
+	providerURLs["webhook"] = "github.com/mjl-/dnsclay#webhook-provider"
+	providers["zonefile"] = ZoneFileProvider{}
+	providerURLs["zonefile"] = "github.com/mjl-/dnsclay#zone-file-provider"
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// ProviderCapabilities describes known limitations and behaviour of a provider.
+// Changes are validated against them before calling the provider, so clients get
+// a clear error instead of an opaque failure halfway through an update.
+type ProviderCapabilities struct {
+	Builtin bool     // Implemented in dnsclay itself.
+	Types   []string // Supported record types. If empty, not known, and not checked.
+	MinTTL  uint32   // Minimum TTL in seconds, 0 if none or not known.
+	MaxTTL  uint32   // Maximum TTL in seconds, 0 if none or not known.
+	Notes   string   // Human-readable remarks.
+
+	// Whether the provider API operates on record sets (name and type) instead of
+	// individual records. New provider configs default to RRSetUpdates.
+	RRSets bool
+
+	// Whether the provider API does not return the SOA record of a zone. The SOA
+	// record is then always fetched from the authoritative name servers.
+	NoSOA bool
+
+	// Whether the SOA serial stays the same when the zone changes. The serial can't
+	// be used to detect changes, so refreshes do a full sync, and the local serial is
+	// incremented independently of the remote serial.
+	FixedSerial bool
+}
+
+// providerCapabilities holds capabilities for providers, by name. Providers
+// without entry have unknown capabilities.
+var providerCapabilities = map[string]ProviderCapabilities{
+	"cloudflare": {
+		Types:  []string{"A", "AAAA", "CAA", "CERT", "CNAME", "DNSKEY", "DS", "HTTPS", "LOC", "MX", "NAPTR", "NS", "OPENPGPKEY", "PTR", "SMIMEA", "SRV", "SSHFP", "SVCB", "TLSA", "TXT", "URI"},
+		MinTTL: 60,
+		MaxTTL: 86400,
+		NoSOA:  true,
+	},
+	"desec": {
+		RRSets: true,
+		MinTTL: 3600,
+		Notes:  "Minimum TTL is 3600 by default, can be lowered by deSEC support, then set the minimum TTL in the provider config.",
+	},
+	"digitalocean": {
+		Types:  []string{"A", "AAAA", "CAA", "CNAME", "MX", "NS", "SOA", "SRV", "TXT"},
+		MinTTL: 30,
+	},
+	"hetzner": {
+		Types: []string{"A", "AAAA", "CAA", "CNAME", "DS", "HINFO", "MX", "NS", "RP", "SOA", "SRV", "TLSA", "TXT"},
+	},
+	"route53": {
+		Types:       []string{"A", "AAAA", "CAA", "CNAME", "DS", "HTTPS", "MX", "NAPTR", "NS", "PTR", "SOA", "SPF", "SRV", "SSHFP", "SVCB", "TLSA", "TXT"},
+		RRSets:      true,
+		FixedSerial: true,
+	},
+	"rfc2136": {
+		Notes: "Standard DNS UPDATE and AXFR, all record types.",
+	},
+
+	"inmemory": {Builtin: true, RRSets: true, Notes: "Records are kept in memory, for testing."},
+	"plugin":   {Builtin: true, Notes: "Capabilities depend on the plugin."},
+	"webhook":  {Builtin: true, RRSets: true, Notes: "Capabilities depend on the HTTP API."},
+	"zonefile": {Builtin: true, RRSets: true},
+}
+
+var errProviderUnsupported = errors.New("not supported by provider")
+
+// providerMinTTL returns the minimum TTL in seconds for records of the provider,
+// from the provider config if set, otherwise from the provider capabilities. Zero
+// if there is no known minimum.
+func providerMinTTL(provider Provider) uint32 {
+	if provider.config.MinTTL > 0 {
+		return provider.config.MinTTL
+	}
+	return providerCapabilities[provider.name].MinTTL
+}
+
+// providerCheckRecords checks records that are about to be added or set against
+// the capabilities of the provider. The error wraps errUser and
+// errProviderUnsupported.
+func providerCheckRecords(provider Provider, records []libdns.Record) error {
+	caps := providerCapabilities[provider.name]
+	minTTL := providerMinTTL(provider)
+	for _, r := range records {
+		if len(caps.Types) > 0 && !slices.Contains(caps.Types, strings.ToUpper(r.Type)) {
+			return fmt.Errorf("%w: %w: record type %s for %q (supported: %s)", errUser, errProviderUnsupported, r.Type, r.Name, strings.Join(caps.Types, ", "))
+		}
+		ttl := uint32(r.TTL / time.Second)
+		if minTTL > 0 && ttl < minTTL {
+			return fmt.Errorf("%w: %w: ttl %d for %s record %q, minimum is %d", errUser, errProviderUnsupported, ttl, r.Type, r.Name, minTTL)
+		}
+		if caps.MaxTTL > 0 && ttl > caps.MaxTTL {
+			return fmt.Errorf("%w: %w: ttl %d for %s record %q, maximum is %d", errUser, errProviderUnsupported, ttl, r.Type, r.Name, caps.MaxTTL)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/libdns/libdns"
+	"github.com/miekg/dns"
+)
+
+func TestProviderCapabilities(t *testing.T) {
+	providerCapabilities["fake"] = ProviderCapabilities{Types: []string{"A", "AAAA", "TXT"}, MinTTL: 60, MaxTTL: 86400}
+	defer delete(providerCapabilities, "fake")
+
+	fake := Provider{name: "fake"}
+	err := providerCheckRecords(fake, []libdns.Record{ldr("", "testhost", 300, "A", "10.0.0.1")})
+	tcheck(t, err, "check records")
+	err = providerCheckRecords(fake, []libdns.Record{ldr("", "", 300, "CAA", `0 issue "letsencrypt.org"`)})
+	if !errors.Is(err, errProviderUnsupported) || !errors.Is(err, errUser) {
+		t.Fatalf("got err %v, expected unsupported error", err)
+	}
+	err = providerCheckRecords(fake, []libdns.Record{ldr("", "testhost", 30, "A", "10.0.0.1")})
+	if !errors.Is(err, errProviderUnsupported) {
+		t.Fatalf("got err %v, expected unsupported error for low ttl", err)
+	}
+	// Minimum TTL from provider config overrides the capabilities.
+	fake.config.MinTTL = 10
+	err = providerCheckRecords(fake, []libdns.Record{ldr("", "testhost", 30, "A", "10.0.0.1")})
+	tcheck(t, err, "check records with minimum ttl from provider config")
+	// Unknown providers are not checked.
+	err = providerCheckRecords(Provider{name: "bogus"}, []libdns.Record{ldr("", "testhost", 1, "CAA", `0 issue "letsencrypt.org"`)})
+	tcheck(t, err, "check records for unknown provider")
+
+	testDNS(t, func(te testEnv, z Zone) {
+		te.zoneUnchanged(func() {
+			te.sherpaError("user:error", func() {
+				te.api.RecordSetAdd(ctxbg, z.Name, RecordSetChange{"testhost2", 30, Type(dns.TypeA), []string{"10.0.0.3"}})
+			})
+			te.sherpaError("user:error", func() {
+				te.api.RecordSetAdd(ctxbg, z.Name, RecordSetChange{"", 300, Type(dns.TypeCAA), []string{`0 issue "letsencrypt.org"`}})
+			})
+
+			// DNS UPDATE is refused before calling the provider.
+			rr, err := dns.NewRR("testhost2." + z.Name + " 30 A 10.0.0.3")
+			tcheck(t, err, "parse rr")
+			om := msgUpdate(z.Name)
+			om.Insert([]dns.RR{rr})
+			c := dns.Client{Net: "tcp-tls", TLSConfig: te.z0.tlsConfig}
+			tdc := dnsclient{t, &c, te.tlsaddr}
+			tdc.exchange(om, nil, dns.RcodeRefused)
+		})
+	})
+}
+
+func TestProviderCapabilitiesSOA(t *testing.T) {
+	providerCapabilities["fake"] = ProviderCapabilities{NoSOA: true, FixedSerial: true}
+	defer delete(providerCapabilities, "fake")
+
+	testDNSProvider0(t, func(te testEnv, z Zone) {
+		// SOA is fetched from the name servers, even though the provider returned one.
+		records, err := getRecords(ctxbg, slog.Default(), Provider{name: "fake", libdnsProvider: te.z0.p}, z.Name, false)
+		tcheck(t, err, "get records")
+		var nsoa int
+		for _, r := range records {
+			if r.Type == "SOA" {
+				nsoa++
+			}
+		}
+		tcompare(t, nsoa, 2)
+
+		// Changes at the provider don't change the remote serial. A refresh does a full
+		// sync, and the local serial is incremented for each change.
+		serial := z.SerialLocal
+		for _, name := range []string{"testhost2", "testhost3"} {
+			te.z0.p.Lock()
+			te.z0.p.Records = append(te.z0.p.Records, ldr("", name, 300, "A", "10.0.0.3"))
+			te.z0.p.Unlock()
+
+			err := refreshZoneSOACheck(slog.Default(), z)
+			tcheck(t, err, "refresh")
+			nz, _, _, _, _ := te.api.Zone(ctxbg, z.Name)
+			if nz.SerialLocal <= serial {
+				t.Fatalf("got serial %d after change, expected more than %d", nz.SerialLocal, serial)
+			}
+			tcompare(t, nz.SerialRemote, Serial(2024010100))
+			serial = nz.SerialLocal
+		}
+	})
+}
@@ -0,0 +1,530 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mjl-/bstore"
+)
+
+// With "serve -config", provider configs, zones, their notify addresses and
+// credentials are declared in a JSON file. The file is reconciled into the
+// database at startup and on SIGHUP: declared objects are added or updated and
+// marked as config-managed, config-managed objects that are no longer declared
+// are removed. Objects added through the admin interface are left alone.
+// Config-managed objects cannot be changed through the admin interface. Existing
+// objects with the same name as declared objects are taken over.
+
+// configPath is the configuration file, set with "serve -config". Empty if not
+// in use.
+var configPath string
+
+// config is the declarative configuration file.
+type config struct {
+	ProviderConfigs []configProviderConfig
+	Credentials     []configCredential
+	Zones           []configZone
+}
+
+type configProviderConfig struct {
+	Name             string
+	ProviderName     string          // Name of a libdns package, e.g. "cloudflare".
+	ProviderConfig   json.RawMessage // Object with the fields of the "Provider" type from the libdns package.
+	Retries          int
+	FailureThreshold int
+	DiscoverInterval configDuration
+	RRSetUpdates     bool
+	MinTTL           uint32 // Seconds, overrides the minimum TTL known for the provider.
+}
+
+type configCredential struct {
+	Name         string
+	Type         string // "tsig" or "tlspubkey"
+	TSIGSecret   string // Base64-encoded, required for type tsig.
+	TLSPublicKey string // Raw-url-base64-encoded SHA-256 hash of TLS certificate SPKI, for type tlspubkey.
+}
+
+type configZone struct {
+	Name                string
+	ProviderConfig      string // Name of provider config in configuration file.
+	RefreshInterval     configDuration
+	SyncInterval        configDuration
+	RecordsFreshness    configDuration
+	FreshPrerequisites  bool
+	QueueUpdates        bool
+	VerifyNameservers   bool
+	DelayUpdateResponse bool
+	Notifies            []configNotify
+	Credentials         []string // Names of credentials in configuration file.
+}
+
+type configNotify struct {
+	Address  string // E.g. 127.0.0.1:53
+	Protocol string // "tcp" or "udp"
+}
+
+// configDuration is a duration in a config file, as string like "1h" or "30s".
+type configDuration time.Duration
+
+func (d *configDuration) UnmarshalJSON(buf []byte) error {
+	var s string
+	if err := json.Unmarshal(buf, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"1h\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = configDuration(v)
+	return nil
+}
+
+func (d configDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func cmdConfig(args []string) {
+	if len(args) != 2 || args[0] != "check" {
+		flag.Usage()
+	}
+	if _, err := configParse(args[1]); err != nil {
+		log.Fatalf("%v", err)
+	}
+	fmt.Println("config OK")
+}
+
+// configParse reads and validates a configuration file. Zone names are made
+// absolute and lower-case, and TLS public keys are checked for duplicates.
+func configParse(path string) (config, error) {
+	var c config
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return c, fmt.Errorf("reading config file: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return c, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	var errs []error
+	addErrorf := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	pcNames := map[string]bool{}
+	for i, pc := range c.ProviderConfigs {
+		if pc.Name == "" {
+			addErrorf("provider config %d: missing name", i)
+			continue
+		}
+		if pcNames[pc.Name] {
+			addErrorf("provider config %q: duplicate name", pc.Name)
+		}
+		pcNames[pc.Name] = true
+		if pc.Retries < 0 || pc.FailureThreshold < 0 || pc.DiscoverInterval < 0 {
+			addErrorf("provider config %q: retries, failure threshold and discover interval must be >= 0", pc.Name)
+		}
+		if _, err := providerForConfig(pc.ProviderName, pc.providerConfigJSON()); err != nil {
+			addErrorf("provider config %q: %v", pc.Name, err)
+		}
+	}
+
+	credNames := map[string]bool{}
+	pubkeys := map[string]bool{}
+	for i, cred := range c.Credentials {
+		name, err := cleanAbsName(strings.TrimSuffix(cred.Name, ".") + ".")
+		if cred.Name == "" || err != nil {
+			addErrorf("credential %d: invalid name %q: %v", i, cred.Name, err)
+			continue
+		}
+		name = strings.TrimSuffix(name, ".")
+		c.Credentials[i].Name = name
+		if credNames[name] {
+			addErrorf("credential %q: duplicate name", name)
+		}
+		credNames[name] = true
+		switch cred.Type {
+		case "tsig":
+			if buf, err := base64.StdEncoding.DecodeString(cred.TSIGSecret); err != nil || len(buf) == 0 {
+				addErrorf("credential %q: tsig secret must be non-empty base64: %v", name, err)
+			}
+			if cred.TLSPublicKey != "" {
+				addErrorf("credential %q: tls public key not allowed for tsig", name)
+			}
+		case "tlspubkey":
+			if buf, err := base64.RawURLEncoding.DecodeString(cred.TLSPublicKey); err != nil || len(buf) != sha256.Size {
+				addErrorf("credential %q: tls public key must be raw-url-base64-encoded sha-256 hash", name)
+			}
+			if pubkeys[cred.TLSPublicKey] {
+				addErrorf("credential %q: duplicate tls public key", name)
+			}
+			pubkeys[cred.TLSPublicKey] = true
+			if cred.TSIGSecret != "" {
+				addErrorf("credential %q: tsig secret not allowed for tlspubkey", name)
+			}
+		default:
+			addErrorf("credential %q: unknown type %q", name, cred.Type)
+		}
+	}
+
+	zoneNames := map[string]bool{}
+	for i, z := range c.Zones {
+		name, err := cleanAbsName(strings.TrimSuffix(z.Name, ".") + ".")
+		if z.Name == "" || err != nil {
+			addErrorf("zone %d: invalid name %q: %v", i, z.Name, err)
+			continue
+		}
+		c.Zones[i].Name = name
+		if zoneNames[name] {
+			addErrorf("zone %q: duplicate name", name)
+		}
+		zoneNames[name] = true
+		if !pcNames[z.ProviderConfig] {
+			addErrorf("zone %q: unknown provider config %q", name, z.ProviderConfig)
+		}
+		if z.RefreshInterval < 0 || z.SyncInterval <= 0 || z.RecordsFreshness < 0 {
+			addErrorf("zone %q: sync interval must be > 0, refresh interval and records freshness >= 0", name)
+		}
+		notifies := map[configNotify]bool{}
+		for _, n := range z.Notifies {
+			if n.Protocol != "tcp" && n.Protocol != "udp" {
+				addErrorf("zone %q: notify %q: unknown protocol %q", name, n.Address, n.Protocol)
+			}
+			if _, _, err := net.SplitHostPort(n.Address); err != nil {
+				addErrorf("zone %q: notify address %q: %v", name, n.Address, err)
+			}
+			if notifies[n] {
+				addErrorf("zone %q: duplicate notify %s %s", name, n.Protocol, n.Address)
+			}
+			notifies[n] = true
+		}
+		for j, cn := range z.Credentials {
+			cn = strings.TrimSuffix(strings.ToLower(cn), ".")
+			z.Credentials[j] = cn
+			if !credNames[cn] {
+				addErrorf("zone %q: unknown credential %q", name, cn)
+			}
+			if slices.Index(z.Credentials, cn) != j {
+				addErrorf("zone %q: duplicate credential %q", name, cn)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return config{}, fmt.Errorf("checking config file %s: %w", path, errors.Join(errs...))
+	}
+	return c, nil
+}
+
+func (pc configProviderConfig) providerConfigJSON() string {
+	if len(pc.ProviderConfig) == 0 {
+		return "{}"
+	}
+	return string(pc.ProviderConfig)
+}
+
+// configLoad parses the configuration file and reconciles it into the database.
+func configLoad(ctx context.Context, log *slog.Logger) error {
+	c, err := configParse(configPath)
+	if err != nil {
+		return err
+	}
+	return configReconcile(ctx, log, c)
+}
+
+// configReconcile makes the database match the configuration, in a single
+// transaction. Changes made through the admin interface to objects that are not
+// config-managed are kept.
+func configReconcile(ctx context.Context, log *slog.Logger, c config) error {
+	var added []Zone
+	var removed []string
+	var providerConfigs []string
+
+	err := database.Write(ctx, func(tx *bstore.Tx) error {
+		now := time.Now()
+
+		// Provider configs.
+		for _, cpc := range c.ProviderConfigs {
+			pc := ProviderConfig{Name: cpc.Name}
+			err := tx.Get(&pc)
+			if err != nil && !errors.Is(err, bstore.ErrAbsent) {
+				return fmt.Errorf("get provider config: %w", err)
+			}
+			exists := err == nil
+			npc := ProviderConfig{
+				Name:               cpc.Name,
+				ProviderName:       cpc.ProviderName,
+				ProviderConfigJSON: cpc.providerConfigJSON(),
+				Retries:            cpc.Retries,
+				FailureThreshold:   cpc.FailureThreshold,
+				DiscoverInterval:   time.Duration(cpc.DiscoverInterval),
+				RRSetUpdates:       cpc.RRSetUpdates,
+				MinTTL:             cpc.MinTTL,
+				ConfigManaged:      true,
+			}
+			if exists {
+				// Compare with the decrypted config, so unchanged configs are left as is.
+				cur := pc
+				cur.ProviderConfigJSON, err = secretDecrypt(pc.ProviderConfigJSON)
+				if err != nil {
+					return fmt.Errorf("provider config %q: %w", pc.Name, err)
+				}
+				if npc == cur {
+					continue
+				}
+			}
+			providerConfigs = append(providerConfigs, npc.Name)
+			npc.ProviderConfigJSON, err = secretEncrypt(npc.ProviderConfigJSON)
+			if err != nil {
+				return fmt.Errorf("encrypting provider config %q: %w", npc.Name, err)
+			}
+			if exists {
+				err = tx.Update(&npc)
+			} else {
+				err = tx.Insert(&npc)
+			}
+			if err != nil {
+				return fmt.Errorf("storing provider config %q: %w", npc.Name, err)
+			}
+		}
+
+		// Credentials.
+		credIDs := map[string]int64{}
+		for _, cc := range c.Credentials {
+			cred, err := bstore.QueryTx[Credential](tx).FilterNonzero(Credential{Name: cc.Name}).Get()
+			if err != nil && !errors.Is(err, bstore.ErrAbsent) {
+				return fmt.Errorf("get credential: %w", err)
+			}
+			exists := err == nil
+			cred.Name = cc.Name
+			cred.Type = cc.Type
+			if secret, err := secretDecrypt(cred.TSIGSecret); err != nil || secret != cc.TSIGSecret {
+				cred.TSIGSecret, err = secretEncrypt(cc.TSIGSecret)
+				if err != nil {
+					return fmt.Errorf("encrypting tsig secret for credential %q: %w", cc.Name, err)
+				}
+			}
+			cred.TLSPublicKey = cc.TLSPublicKey
+			cred.ConfigManaged = true
+			if exists {
+				err = tx.Update(&cred)
+			} else {
+				err = tx.Insert(&cred)
+			}
+			if err != nil {
+				return fmt.Errorf("storing credential %q: %w", cred.Name, err)
+			}
+			credIDs[cred.Name] = cred.ID
+		}
+
+		// Zones, with their notify addresses and credentials.
+		zoneNames := map[string]bool{}
+		for _, cz := range c.Zones {
+			zoneNames[cz.Name] = true
+
+			z := Zone{Name: cz.Name}
+			err := tx.Get(&z)
+			if err != nil && !errors.Is(err, bstore.ErrAbsent) {
+				return fmt.Errorf("get zone: %w", err)
+			}
+			exists := err == nil
+			z.ProviderConfigName = cz.ProviderConfig
+			z.RefreshInterval = time.Duration(cz.RefreshInterval)
+			z.SyncInterval = time.Duration(cz.SyncInterval)
+			z.RecordsFreshness = time.Duration(cz.RecordsFreshness)
+			z.FreshPrerequisites = cz.FreshPrerequisites
+			z.QueueUpdates = cz.QueueUpdates
+			z.VerifyNameservers = cz.VerifyNameservers
+			z.DelayUpdateResponse = cz.DelayUpdateResponse
+			z.ConfigManaged = true
+			if exists {
+				if refresh := now.Add(z.RefreshInterval); refresh.Before(z.NextRefresh) {
+					z.NextRefresh = refresh
+				}
+				if sync := now.Add(z.SyncInterval); sync.Before(z.NextSync) {
+					z.NextSync = sync
+				}
+				err = tx.Update(&z)
+			} else {
+				z.NextSync = now.Add(z.SyncInterval)
+				if z.RefreshInterval > 0 {
+					z.NextRefresh = now.Add(z.RefreshInterval / (5 * 10))
+				}
+				err = tx.Insert(&z)
+				added = append(added, z)
+			}
+			if err != nil {
+				return fmt.Errorf("storing zone %q: %w", z.Name, err)
+			}
+			if !exists {
+				if err := discoverZoneConfigured(tx, z.Name); err != nil {
+					return fmt.Errorf("updating zone discoveries: %w", err)
+				}
+			}
+
+			notifies, err := bstore.QueryTx[ZoneNotify](tx).FilterNonzero(ZoneNotify{Zone: z.Name}).List()
+			if err != nil {
+				return fmt.Errorf("listing notifies for zone: %w", err)
+			}
+			for _, cn := range cz.Notifies {
+				i := slices.IndexFunc(notifies, func(zn ZoneNotify) bool {
+					return zn.Address == cn.Address && zn.Protocol == cn.Protocol
+				})
+				if i >= 0 && notifies[i].ConfigManaged {
+					notifies[i].ID = 0 // Mark as seen.
+					continue
+				}
+				var err error
+				if i >= 0 {
+					notifies[i].ConfigManaged = true
+					err = tx.Update(&notifies[i])
+					notifies[i].ID = 0
+				} else {
+					err = tx.Insert(&ZoneNotify{Zone: z.Name, Address: cn.Address, Protocol: cn.Protocol, ConfigManaged: true})
+				}
+				if err != nil {
+					return fmt.Errorf("storing notify for zone %q: %w", z.Name, err)
+				}
+			}
+			for _, zn := range notifies {
+				if zn.ID != 0 && zn.ConfigManaged {
+					if err := tx.Delete(&zn); err != nil {
+						return fmt.Errorf("deleting notify for zone %q: %w", z.Name, err)
+					}
+				}
+			}
+
+			zonecreds, err := bstore.QueryTx[ZoneCredential](tx).FilterNonzero(ZoneCredential{Zone: z.Name}).List()
+			if err != nil {
+				return fmt.Errorf("listing credentials for zone: %w", err)
+			}
+			var credIDsZone []int64
+			for _, cn := range cz.Credentials {
+				id := credIDs[cn]
+				credIDsZone = append(credIDsZone, id)
+				i := slices.IndexFunc(zonecreds, func(zc ZoneCredential) bool { return zc.CredentialID == id })
+				if i >= 0 && zonecreds[i].ConfigManaged {
+					continue
+				}
+				var err error
+				if i >= 0 {
+					zonecreds[i].ConfigManaged = true
+					err = tx.Update(&zonecreds[i])
+				} else {
+					err = tx.Insert(&ZoneCredential{Zone: z.Name, CredentialID: id, ConfigManaged: true})
+				}
+				if err != nil {
+					return fmt.Errorf("storing credential for zone %q: %w", z.Name, err)
+				}
+			}
+			for _, zc := range zonecreds {
+				if zc.ConfigManaged && !slices.Contains(credIDsZone, zc.CredentialID) {
+					if err := tx.Delete(&zc); err != nil {
+						return fmt.Errorf("deleting credential for zone %q: %w", z.Name, err)
+					}
+				}
+			}
+		}
+
+		// Remove config-managed objects that are no longer declared.
+		zones, err := bstore.QueryTx[Zone](tx).FilterEqual("ConfigManaged", true).List()
+		if err != nil {
+			return fmt.Errorf("listing config-managed zones: %w", err)
+		}
+		for _, z := range zones {
+			if zoneNames[z.Name] {
+				continue
+			}
+			if err := zoneRemove(tx, z); err != nil {
+				return fmt.Errorf("removing zone %q: %w", z.Name, err)
+			}
+			if err := discoverZoneRemoved(tx, z.ProviderConfigName, z.Name, false); err != nil {
+				return fmt.Errorf("updating zone discovery: %w", err)
+			}
+			removed = append(removed, z.Name)
+		}
+
+		creds, err := bstore.QueryTx[Credential](tx).FilterEqual("ConfigManaged", true).List()
+		if err != nil {
+			return fmt.Errorf("listing config-managed credentials: %w", err)
+		}
+		for _, cred := range creds {
+			if _, ok := credIDs[cred.Name]; ok {
+				continue
+			}
+			if _, err := bstore.QueryTx[ZoneCredential](tx).FilterNonzero(ZoneCredential{CredentialID: cred.ID}).Delete(); err != nil {
+				return fmt.Errorf("removing zone credentials for credential %q: %w", cred.Name, err)
+			}
+			if err := tx.Delete(&cred); err != nil {
+				return fmt.Errorf("removing credential %q: %w", cred.Name, err)
+			}
+		}
+
+		pcs, err := bstore.QueryTx[ProviderConfig](tx).FilterEqual("ConfigManaged", true).List()
+		if err != nil {
+			return fmt.Errorf("listing config-managed provider configs: %w", err)
+		}
+		for _, pc := range pcs {
+			if slices.ContainsFunc(c.ProviderConfigs, func(cpc configProviderConfig) bool { return cpc.Name == pc.Name }) {
+				continue
+			}
+			z, err := bstore.QueryTx[Zone](tx).FilterNonzero(Zone{ProviderConfigName: pc.Name}).Get()
+			if err == nil {
+				return fmt.Errorf("provider config %q removed from config file, but still used by zone %q", pc.Name, z.Name)
+			} else if !errors.Is(err, bstore.ErrAbsent) {
+				return fmt.Errorf("checking references to provider config: %w", err)
+			}
+			if err := discoverZoneRemoved(tx, pc.Name, "", true); err != nil {
+				return fmt.Errorf("removing zone discovery: %w", err)
+			}
+			if err := tx.Delete(&pc); err != nil {
+				return fmt.Errorf("removing provider config %q: %w", pc.Name, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range removed {
+		recordsCacheClear(name)
+	}
+	for _, name := range providerConfigs {
+		providerHealthReset(name)
+		recordsCacheClearProviderConfig(name)
+	}
+	for _, z := range added {
+		var provider Provider
+		err := database.Read(ctx, func(tx *bstore.Tx) error {
+			var err error
+			_, provider, err = zoneProvider(tx, z.Name)
+			return err
+		})
+		if err != nil {
+			log.Error("get provider for new zone from config file", "zone", z.Name, "err", err)
+			continue
+		}
+		go zoneInitialFetch(log, provider, z)
+	}
+	refreshKick()
+	discoverKick()
+
+	log.Info("config file reconciled", "path", configPath, "zonesadded", len(added), "zonesremoved", len(removed), "providerconfigschanged", len(providerConfigs))
+	return nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/libdns/libdns"
+)
+
+func TestConfig(t *testing.T) {
+	testDNS(t, func(te testEnv, z Zone) {
+		log := slog.Default()
+		path := filepath.Join(t.TempDir(), "dnsclay.conf")
+
+		write := func(text string) {
+			t.Helper()
+			err := os.WriteFile(path, []byte(text), 0600)
+			tcheck(t, err, "write config file")
+		}
+		reconcile := func(text string) {
+			t.Helper()
+			write(text)
+			c, err := configParse(path)
+			tcheck(t, err, "parse config file")
+			err = configReconcile(ctxbg, log, c)
+			tcheck(t, err, "reconcile config file")
+		}
+
+		newFakeProvider(&fakeProvider{
+			ID: "new",
+			Records: []libdns.Record{
+				ldr("", "", 300, "SOA", "ns0.example. new.example. 2024010100 3600 300 1209600 300"),
+				ldr("", "testhost", 300, "A", "10.0.0.1"),
+			},
+		})
+
+		// Take over z0 with its notify address, and add a new zone.
+		full := fmt.Sprintf(`{
+	"ProviderConfigs": [
+		{"Name": "z0.example", "ProviderName": "fake", "ProviderConfig": {"ID": "z0"}},
+		{"Name": "new", "ProviderName": "fake", "ProviderConfig": {"ID": "new"}, "Retries": 1}
+	],
+	"Credentials": [
+		{"Name": "cfg-tsig", "Type": "tsig", "TSIGSecret": "bWFkZSB5b3UgbG9vayEK"}
+	],
+	"Zones": [
+		{
+			"Name": "Z0.example",
+			"ProviderConfig": "z0.example",
+			"RefreshInterval": "1h",
+			"SyncInterval": "24h",
+			"Notifies": [{"Address": %q, "Protocol": "tcp"}],
+			"Credentials": ["cfg-tsig"]
+		},
+		{
+			"Name": "new.example.",
+			"ProviderConfig": "new",
+			"SyncInterval": "24h",
+			"Credentials": ["cfg-tsig"]
+		}
+	]
+}`, te.z0.n.addr)
+		reconcile(full)
+		te.api.ZoneRefresh(ctxbg, "new.example.") // Wait for initial fetch of records.
+
+		check := func() {
+			t.Helper()
+			nz, pc, notifies, creds, _ := te.api.Zone(ctxbg, z.Name)
+			tcompare(t, nz.ConfigManaged, true)
+			tcompare(t, pc.ConfigManaged, true)
+			tcompare(t, len(notifies), 1)
+			tcompare(t, notifies[0].ConfigManaged, true)
+			var managed int
+			for _, c := range creds {
+				if c.ConfigManaged {
+					managed++
+					tcompare(t, c.Name, "cfg-tsig")
+				}
+			}
+			tcompare(t, managed, 1)
+			tcompare(t, len(creds), 3) // Default tsig, tls public key, config.
+
+			_, _, _, creds, _ = te.api.Zone(ctxbg, "new.example.")
+			tcompare(t, len(creds), 1)
+			tcompare(t, len(te.api.ZoneRecords(ctxbg, "new.example.")), 2)
+		}
+		check()
+
+		// Reconciling again doesn't change anything.
+		reconcile(full)
+		check()
+
+		// Config-managed objects cannot be changed through the admin interface.
+		te.sherpaError("user:error", func() {
+			te.api.ZoneUpdate(ctxbg, z)
+		})
+		te.sherpaError("user:error", func() {
+			te.api.ZoneDelete(ctxbg, "new.example.")
+		})
+		te.sherpaError("user:error", func() {
+			te.api.ProviderConfigUpdate(ctxbg, te.z0.pc)
+		})
+		_, _, notifies, _, _ := te.api.Zone(ctxbg, z.Name)
+		te.sherpaError("user:error", func() {
+			te.api.ZoneNotifyDelete(ctxbg, notifies[0].ID)
+		})
+
+		// Removing from the config file removes config-managed objects. The z0 zone is
+		// kept, along with its credentials that were not config-managed.
+		reconcile(`{
+	"ProviderConfigs": [
+		{"Name": "z0.example", "ProviderName": "fake", "ProviderConfig": {"ID": "z0"}}
+	],
+	"Zones": [
+		{"Name": "z0.example", "ProviderConfig": "z0.example", "RefreshInterval": "1h", "SyncInterval": "24h"}
+	]
+}`)
+		_, _, notifies, creds, _ := te.api.Zone(ctxbg, z.Name)
+		tcompare(t, len(notifies), 0)
+		tcompare(t, len(creds), 2)
+		for _, nz := range te.api.Zones(ctxbg) {
+			if nz.Name == "new.example." {
+				t.Fatalf("zone not removed")
+			}
+		}
+		for _, pc := range te.api.ProviderConfigs(ctxbg) {
+			if pc.Name == "new" {
+				t.Fatalf("provider config not removed")
+			}
+		}
+
+		// Commands are checked against -providercommands when run, not when the config is
+		// checked, e.g. by "dnsclay config check".
+		write(`{"ProviderConfigs": [{"Name": "x", "ProviderName": "plugin", "ProviderConfig": {"command": "/usr/local/bin/dnsclay-plugin"}}]}`)
+		_, err := configParse(path)
+		tcheck(t, err, "parse config with plugin command")
+		write(`{"ProviderConfigs": [{"Name": "x", "ProviderName": "zonefile", "ProviderConfig": {"path": "/var/lib/knot/{zone}.zone", "reload_command": "knotc zone-reload {zone}"}}]}`)
+		_, err = configParse(path)
+		tcheck(t, err, "parse config with zonefile reload command")
+
+		// Invalid configs.
+		bad := []string{
+			`{"Unknown": true}`,
+			`{"ProviderConfigs": [{"Name": "x", "ProviderName": "bogus"}]}`,
+			`{"ProviderConfigs": [{"Name": "x", "ProviderName": "plugin", "ProviderConfig": {"command": " "}}]}`,
+			`{"ProviderConfigs": [{"Name": "x", "ProviderName": "fake", "ProviderConfig": {"Bogus": 1}}]}`,
+			`{"Zones": [{"Name": "a.example", "ProviderConfig": "x", "SyncInterval": "1h"}]}`,
+			`{"ProviderConfigs": [{"Name": "x", "ProviderName": "fake"}], "Zones": [{"Name": "a.example", "ProviderConfig": "x", "SyncInterval": "1"}]}`,
+			`{"ProviderConfigs": [{"Name": "x", "ProviderName": "fake"}], "Zones": [{"Name": "a.example", "ProviderConfig": "x", "SyncInterval": "1h", "Credentials": ["x"]}]}`,
+			`{"Credentials": [{"Name": "x", "Type": "tsig"}]}`,
+		}
+		for _, text := range bad {
+			write(text)
+			if _, err := configParse(path); err == nil {
+				t.Fatalf("expected error for config %s", text)
+			}
+		}
+	})
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/mjl-/bstore"
+)
+
+// For providers that can list zones, zones can be discovered: zones at the
+// provider that are not yet configured can be added in bulk. With
+// ProviderConfig.DiscoverInterval, zones are listed periodically, and new zones
+// (not yet configured) and vanished zones (configured, but no longer listed) are
+// flagged.
+
+// DiscoveredZone is a zone listed at a provider.
+type DiscoveredZone struct {
+	Name string // Absolute name, lower-case.
+
+	// Provider config of the zone if it is configured, possibly different from the
+	// provider config used for listing. Empty if not configured.
+	ProviderConfigName string
+}
+
+var discoverReschedule = make(chan struct{}, 1)
+
+// discoverKick makes the discoverer reschedule, e.g. after a provider config
+// changed.
+func discoverKick() {
+	select {
+	case discoverReschedule <- struct{}{}:
+	default:
+	}
+}
+
+// discoverZones lists the zones at the provider of a provider config. Zones that
+// are configured have their provider config set. Also returned are the zones
+// configured with the provider config that are not listed at the provider.
+func discoverZones(ctx context.Context, pc ProviderConfig) (zones []DiscoveredZone, vanished []string, rerr error) {
+	provider, err := configProvider(pc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("making provider: %w", err)
+	}
+	l, err := provider.ListZones(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing zones: %w", err)
+	}
+
+	configured, err := bstore.QueryDB[Zone](ctx, database).List()
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing configured zones: %w", err)
+	}
+	zonepc := map[string]string{}
+	for _, z := range configured {
+		zonepc[z.Name] = z.ProviderConfigName
+	}
+
+	listed := map[string]bool{}
+	for _, lz := range l {
+		name := strings.ToLower(strings.TrimSuffix(lz.Name, ".") + ".")
+		if listed[name] {
+			continue
+		}
+		listed[name] = true
+		zones = append(zones, DiscoveredZone{name, zonepc[name]})
+	}
+	slices.SortFunc(zones, func(a, b DiscoveredZone) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	for _, z := range configured {
+		if z.ProviderConfigName == pc.Name && !listed[z.Name] {
+			vanished = append(vanished, z.Name)
+		}
+	}
+	return zones, vanished, nil
+}
+
+// discoverStore stores the result of a zone discovery for a provider config.
+func discoverStore(ctx context.Context, log *slog.Logger, pc ProviderConfig, zones []DiscoveredZone, vanished []string, discoverErr error) error {
+	now := time.Now()
+	zd := ZoneDiscovery{
+		ProviderConfigName: pc.Name,
+		Last:               &now,
+		Next:               now.Add(max(pc.DiscoverInterval, time.Minute)),
+		Vanished:           vanished,
+	}
+	for _, z := range zones {
+		if z.ProviderConfigName == "" {
+			zd.New = append(zd.New, z.Name)
+		}
+	}
+
+	return database.Write(ctx, func(tx *bstore.Tx) error {
+		if err := tx.Get(&ProviderConfig{Name: pc.Name}); err != nil {
+			return fmt.Errorf("get provider config: %w", err)
+		}
+		prev := ZoneDiscovery{ProviderConfigName: pc.Name}
+		if err := tx.Get(&prev); err != nil && !errors.Is(err, bstore.ErrAbsent) {
+			return fmt.Errorf("get previous zone discovery: %w", err)
+		} else if err == nil {
+			if err := tx.Delete(&prev); err != nil {
+				return fmt.Errorf("removing previous zone discovery: %w", err)
+			}
+		}
+
+		if discoverErr != nil {
+			// Keep the zones flagged earlier, we don't know anything new.
+			zd.Error = discoverErr.Error()
+			zd.New = prev.New
+			zd.Vanished = prev.Vanished
+		} else if !slices.Equal(zd.New, prev.New) || !slices.Equal(zd.Vanished, prev.Vanished) {
+			log.Warn("zone discovery found changes in zones at provider", "providerconfig", pc.Name, "new", zd.New, "vanished", zd.Vanished)
+		}
+		metricDiscoveredZones.WithLabelValues(pc.Name, "new").Set(float64(len(zd.New)))
+		metricDiscoveredZones.WithLabelValues(pc.Name, "vanished").Set(float64(len(zd.Vanished)))
+
+		return tx.Insert(&zd)
+	})
+}
+
+// discoverZoneConfigured removes a newly configured zone from the new zones of
+// zone discoveries.
+func discoverZoneConfigured(tx *bstore.Tx, zone string) error {
+	return bstore.QueryTx[ZoneDiscovery](tx).ForEach(func(zd ZoneDiscovery) error {
+		if !slices.Contains(zd.New, zone) {
+			return nil
+		}
+		zd.New = slices.DeleteFunc(zd.New, func(s string) bool { return s == zone })
+		metricDiscoveredZones.WithLabelValues(zd.ProviderConfigName, "new").Set(float64(len(zd.New)))
+		return tx.Update(&zd)
+	})
+}
+
+// discoverZoneRemoved removes a removed zone from the vanished zones of the zone
+// discovery of its provider config. If the provider config is removed too, its
+// zone discovery is removed.
+func discoverZoneRemoved(tx *bstore.Tx, providerConfigName, zone string, removeConfig bool) error {
+	zd := ZoneDiscovery{ProviderConfigName: providerConfigName}
+	if err := tx.Get(&zd); errors.Is(err, bstore.ErrAbsent) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("get zone discovery: %w", err)
+	}
+	if removeConfig {
+		metricDiscoveredZones.DeletePartialMatch(prometheus.Labels{"providerconfig": providerConfigName})
+		return tx.Delete(&zd)
+	}
+	zd.Vanished = slices.DeleteFunc(zd.Vanished, func(s string) bool { return s == zone })
+	metricDiscoveredZones.WithLabelValues(zd.ProviderConfigName, "vanished").Set(float64(len(zd.Vanished)))
+	return tx.Update(&zd)
+}
+
+// discoverer periodically lists zones for provider configs with a
+// DiscoverInterval.
+func discoverer() {
+	log := slog.Default()
+
+	timer := time.NewTimer(0)
+	for {
+		select {
+		case <-shutdownCtx.Done():
+			return
+		case <-timer.C:
+		case <-discoverReschedule:
+		}
+		if shuttingDown() {
+			return
+		}
+
+		// Gather provider configs that are due.
+		var due []ProviderConfig
+		var next time.Time
+		err := database.Read(shutdownCtx, func(tx *bstore.Tx) error {
+			now := time.Now()
+			return bstore.QueryTx[ProviderConfig](tx).FilterGreater("DiscoverInterval", time.Duration(0)).ForEach(func(pc ProviderConfig) error {
+				zd := ZoneDiscovery{ProviderConfigName: pc.Name}
+				if err := tx.Get(&zd); err != nil && !errors.Is(err, bstore.ErrAbsent) {
+					return fmt.Errorf("get zone discovery: %w", err)
+				}
+				if !zd.Next.After(now) {
+					due = append(due, pc)
+				} else if next.IsZero() || zd.Next.Before(next) {
+					next = zd.Next
+				}
+				return nil
+			})
+		})
+		logCheck(log, err, "gathering provider configs for zone discovery")
+
+		for _, pc := range due {
+			ctx, cancel := context.WithTimeout(shutdownCtx, 30*time.Second)
+			zones, vanished, err := discoverZones(ctx, pc)
+			cancel()
+			logCheck(log, err, "periodic zone discovery", "providerconfig", pc.Name)
+			err = discoverStore(shutdownCtx, log, pc, zones, vanished, err)
+			logCheck(log, err, "storing zone discovery", "providerconfig", pc.Name)
+			if n := time.Now().Add(max(pc.DiscoverInterval, time.Minute)); next.IsZero() || n.Before(next) {
+				next = n
+			}
+		}
+
+		if next.IsZero() {
+			timer.Stop()
+		} else {
+			timer.Reset(time.Until(next))
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mjl-/bstore"
+)
+
+func TestDiscoverZones(t *testing.T) {
+	testDNS(t, func(te testEnv, z Zone) {
+		pc := te.z0.pc
+
+		discovery := func() ZoneDiscovery {
+			for _, zd := range te.api.ZoneDiscoveries(ctxbg) {
+				if zd.ProviderConfigName == pc.Name {
+					return zd
+				}
+			}
+			t.Fatalf("no zone discovery for provider config")
+			return ZoneDiscovery{}
+		}
+
+		te.z0.p.Lock()
+		te.z0.p.Zones = []string{"Other.Example", "new.example.", "z0.example."}
+		te.z0.p.Unlock()
+
+		zones, vanished := te.api.ProviderConfigDiscoverZones(ctxbg, pc.Name)
+		tcompare(t, zones, []DiscoveredZone{
+			{"new.example.", ""},
+			{"other.example.", ""},
+			{"z0.example.", pc.Name},
+		})
+		tcompare(t, len(vanished), 0)
+		tcompare(t, discovery().New, []string{"new.example.", "other.example."})
+
+		// Add a discovered zone, it gets a tsig credential and the notify. The zone uses
+		// the records of z0, but the SOA lookup is mocked by zone name.
+		newFakeProvider(&fakeProvider{ID: "new"})
+		nzones := te.api.ZonesAdd(ctxbg, pc.Name, []string{"new.example."}, []ZoneNotify{{Address: "127.0.0.1:1", Protocol: "udp"}})
+		tcompare(t, len(nzones), 1)
+		te.api.ZoneRefresh(ctxbg, "new.example.") // Wait for initial fetch of records.
+		_, _, notifies, creds, _ := te.api.Zone(ctxbg, "new.example.")
+		tcompare(t, len(notifies), 1)
+		tcompare(t, len(creds), 1)
+		tcompare(t, discovery().New, []string{"other.example."})
+
+		// Zones are added all or none: other.example is not added with the existing new.example.
+		te.sherpaError("user:error", func() {
+			te.api.ZonesAdd(ctxbg, pc.Name, []string{"other.example.", "new.example."}, nil)
+		})
+		exists, err := bstore.QueryDB[Zone](ctxbg, database).FilterNonzero(Zone{Name: "other.example."}).Exists()
+		tcheck(t, err, "checking for zone")
+		tcompare(t, exists, false)
+
+		// Zones no longer listed at the provider are flagged.
+		te.z0.p.Lock()
+		te.z0.p.Zones = []string{"new.example."}
+		te.z0.p.Unlock()
+		_, vanished = te.api.ProviderConfigDiscoverZones(ctxbg, pc.Name)
+		tcompare(t, vanished, []string{"z0.example."})
+		zd := discovery()
+		tcompare(t, len(zd.New), 0)
+		tcompare(t, zd.Vanished, []string{"z0.example."})
+
+		// Failure keeps previous results.
+		te.z0.p.Errors = []error{errUser}
+		te.sherpaError("user:error", func() {
+			te.api.ProviderConfigDiscoverZones(ctxbg, pc.Name)
+		})
+		zd = discovery()
+		tcompare(t, zd.Error != "", true)
+		tcompare(t, zd.Vanished, []string{"z0.example."})
+
+		te.api.ZoneDelete(ctxbg, "new.example.")
+
+		// Negative interval is invalid.
+		pc.DiscoverInterval = -1
+		te.sherpaError("user:error", func() {
+			te.api.ProviderConfigUpdate(ctxbg, pc)
+		})
+
+		// New provider config reschedules the discoverer.
+		select {
+		case <-discoverReschedule:
+		default:
+		}
+		npc := ProviderConfig{Name: "discover", ProviderName: "fake", ProviderConfigJSON: `{"ID": "z0"}`, DiscoverInterval: time.Hour}
+		te.api.ProviderConfigAdd(ctxbg, npc)
+		tcompare(t, len(discoverReschedule), 1)
+	})
+}
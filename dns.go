@@ -12,6 +12,7 @@ import (
 	"log/slog"
 	"net"
 	"os"
+	"slices"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -23,6 +24,12 @@ import (
 	"github.com/mjl-/bstore"
 )
 
+// EDNS0 option code, from the range for local/experimental use, that DNS UPDATE
+// clients can add to request that records are fetched from the provider before
+// evaluating prerequisites, instead of using records from the zone freshness
+// window.
+const ednsOptionFreshRecords = 65301
+
 // Enabled during tests.
 var testSyncNotify = false
 var testSyncUpdate = false
@@ -127,7 +134,15 @@ func serveDNS(nc net.Conn, l listener) {
 		c.conn = c.tlsconn
 	}
 
+	// Reads are interrupted during shutdown.
+	readerAdd(nc)
+	defer readerRemove(nc)
+
 	for {
+		if shuttingDown() {
+			return
+		}
+
 		// Deadline includes reads and writes for TLS connections.
 		err := nc.SetDeadline(time.Now().Add(30 * time.Second))
 		logCheck(c.log, err, "setting read deadline")
@@ -149,7 +164,9 @@ func serveDNS(nc net.Conn, l listener) {
 		}
 
 		// Handle message. For fatal errors, abort the connection.
+		done := operationStart("dns request on connection %x", c.cid)
 		ok := c.handleDNS(c.buf[2 : 2+size])
+		done()
 		if !ok {
 			break
 		}
@@ -158,8 +175,23 @@ func serveDNS(nc net.Conn, l listener) {
 
 // printTrace prints a trace of the DNS message to standard error, if enabled
 // through the -trace flag. The packet is potentially written in multiple formats
-// (canonical text format, json formats).
+// (canonical text format, json formats). With JSON logging, traces are logged as
+// JSON messages instead, to keep one JSON object per line.
 func (c *conn) printTrace(prefix string, m *dns.Msg) {
+	if len(serveTraceDNS) > 0 && logFormat == "json" {
+		msg := strings.TrimPrefix(prefix, "# ")
+		for _, t := range serveTraceDNS {
+			switch t {
+			case traceNone:
+				return
+			case traceText:
+				c.log.Info(msg, "text", m.String())
+			case traceJSON, traceJSONIndent:
+				c.log.Info(msg, "dnsmsg", m)
+			}
+		}
+		return
+	}
 	if len(serveTraceDNS) > 0 {
 		fmt.Fprintf(os.Stderr, "\n%s (cid %x)\n", prefix, c.cid)
 		defer fmt.Fprintln(os.Stderr)
@@ -209,6 +241,19 @@ func (c *conn) respondExtErrorf(rcode int, exterrcode uint16, format string, arg
 	return c.respond(om)
 }
 
+// providerExtErrorCode returns the extended error code for a failed provider
+// operation: "not ready" if the operation failed fast because the provider config
+// is unhealthy, "not supported" if the change isn't supported by the provider,
+// and def otherwise.
+func providerExtErrorCode(err error, def uint16) uint16 {
+	if errors.Is(err, errProviderUnhealthy) {
+		return dns.ExtendedErrorCodeNotReady
+	} else if errors.Is(err, errProviderUnsupported) {
+		return dns.ExtendedErrorCodeNotSupported
+	}
+	return def
+}
+
 // respond to request message, and potentially schedule a DNS NOTIFY in case of the
 // request resulting in changes to stored records.
 func (c *conn) respond(om *dns.Msg) (ok bool) {
@@ -222,7 +267,9 @@ func (c *conn) respond(om *dns.Msg) (ok bool) {
 		zone := c.zone
 		c.zone = ""
 		c.notify = false
+		opDone := operationStart("dns notify for zone %s", zone)
 		go func() {
+			defer opDone()
 			defer recoverPanic(c.log, "sending dns notifications for zone")
 			sendZoneNotify(c.log, zone)
 		}()
@@ -312,6 +359,15 @@ func (c *conn) respond(om *dns.Msg) (ok bool) {
 func (c *conn) handleDNS(imbuf []byte) (ok bool) {
 	c.reqKind = "n/a"
 	c.respRcode = -1
+
+	proto := "udp"
+	if c.listener.tls {
+		proto = "tls"
+	} else if c.udpRemoteAddr == nil {
+		proto = "tcp"
+	}
+	ctx, span := spanStart(context.WithValue(shutdownCtx, ctxKeyCID, c.cid), "dns request", spanServer, "proto", proto)
+
 	defer func() {
 		var rcodestr string
 		if s, ok := dns.RcodeToString[c.respRcode]; ok {
@@ -320,6 +376,16 @@ func (c *conn) handleDNS(imbuf []byte) (ok bool) {
 			rcodestr = "other"
 		}
 		metricDNSRequests.WithLabelValues(c.reqKind, rcodestr).Inc()
+
+		var err error
+		if c.respRcode == dns.RcodeServerFailure || !ok {
+			err = fmt.Errorf("dns request failed with rcode %s", rcodestr)
+		}
+		var name string
+		if len(c.im.Question) > 0 {
+			name = c.im.Question[0].Name
+		}
+		span.finish(err, "kind", c.reqKind, "rcode", rcodestr, "name", name)
 	}()
 
 	// Reset per-request/message fields.
@@ -381,7 +447,6 @@ func (c *conn) handleDNS(imbuf []byte) (ok bool) {
 
 	// Check TSIG authentication. Authorization is checked later.
 	if c.tsigIn != nil {
-		ctx := shutdownCtx
 		var cred Credential
 		var err error
 		err = database.Read(ctx, func(tx *bstore.Tx) error {
@@ -401,6 +466,10 @@ func (c *conn) handleDNS(imbuf []byte) (ok bool) {
 		} else if err != nil {
 			return c.respondErrorf("checking tsig: %v", err)
 		}
+		cred.TSIGSecret, err = secretDecrypt(cred.TSIGSecret)
+		if err != nil {
+			return c.respondErrorf("decrypting tsig secret: %v", err)
+		}
 		c.credTSIG = &cred
 		// Package dns implements hmac-sha1 and later, not hmac-md5. So we don't check
 		// which hmac is used. Package dns always checks the received mac against the full
@@ -434,18 +503,18 @@ func (c *conn) handleDNS(imbuf []byte) (ok bool) {
 
 	if c.listener.notify && c.im.Opcode == dns.OpcodeNotify {
 		c.reqKind = "notify"
-		return c.handleNotify(shutdownCtx)
+		return c.handleNotify(ctx)
 	} else if c.listener.updates && c.im.Opcode == dns.OpcodeUpdate {
 		c.reqKind = "update"
-		return c.handleUpdate(shutdownCtx)
+		return c.handleUpdate(ctx)
 	} else if c.listener.xfr && c.im.Opcode == dns.OpcodeQuery && q.Qtype == dns.TypeAXFR {
 		c.reqKind = "axfr"
-		return c.handleXFR(shutdownCtx)
+		return c.handleXFR(ctx)
 	} else if c.listener.auth && c.im.Opcode == dns.OpcodeQuery {
 		c.reqKind = "authoritative"
 		// We serve "authoritative" queries for SOA. For AXFR clients that check if they
 		// are up to date before initiating the transfer.
-		return c.handleAuth(shutdownCtx)
+		return c.handleAuth(ctx)
 	} else {
 		c.reqKind = "other"
 		// rfc/8906:268
@@ -545,7 +614,9 @@ func (c *conn) handleNotify(ctx context.Context) (ok bool) {
 
 	done := make(chan struct{}, 1)
 
+	opDone := operationStart("sync of zone %s after dns notify", z.Name)
 	go func() {
+		defer opDone()
 		defer recoverPanic(c.log, "syncing zone after dns notify")
 		defer func() {
 			done <- struct{}{}
@@ -559,9 +630,15 @@ func (c *conn) handleNotify(ctx context.Context) (ok bool) {
 		unlock := lockZone(z.Name)
 		defer unlock()
 
-		ctx, cancel := context.WithTimeout(shutdownCtx, 30*time.Second)
+		ctx, span := spanStart(ctx, "zone sync", spanInternal, "zone", z.Name)
+		var err error
+		defer func() {
+			span.finish(err)
+		}()
+
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 		defer cancel()
-		latest, err := getRecords(ctx, c.log, provider, z.Name, false)
+		latest, err := getZoneRecords(ctx, c.log, provider, z, true)
 		if err != nil {
 			log.Error("get records from provider", "err", err)
 			return
@@ -641,12 +718,20 @@ func (c *conn) handleUpdate(ctx context.Context) (ok bool) {
 		}
 	}()
 
-	// Sync latest zone before attempting to make any changes.
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
-	latest, err := getRecords(ctx, c.log, provider, z.Name, false)
-	if err != nil {
-		return c.respondExtErrorf(dns.RcodeServerFailure, dns.ExtendedErrorCodeNetworkError, "get records from provider: %v", err)
+
+	// Sync latest zone before attempting to make any changes. Records from the
+	// freshness window of the zone may be used, except when prerequisites must be
+	// checked against fresh records. With queued updates, we only use the local
+	// records, the provider may not be available.
+	var latest []libdns.Record
+	if !z.QueueUpdates {
+		fresh := len(c.im.Answer) > 0 && (z.FreshPrerequisites || c.freshRecordsRequested())
+		latest, err = getZoneRecords(ctx, c.log, provider, z, fresh)
+		if err != nil {
+			return c.respondExtErrorf(dns.RcodeServerFailure, providerExtErrorCode(err, dns.ExtendedErrorCodeNetworkError), "get records from provider: %v", err)
+		}
 	}
 
 	// We keep these up to date while removing/adding records. So logic like "remove
@@ -679,10 +764,14 @@ func (c *conn) handleUpdate(ctx context.Context) (ok bool) {
 	}
 
 	c.zone = z.Name // Used along with c.notify
+
+	var failedChange int64 // Queued change blocking the queue of the zone.
 	err = database.Write(ctx, func(tx *bstore.Tx) error {
-		c.notify, _, _, _, err = syncRecords(c.log, tx, z, latest)
-		if err != nil {
-			return err
+		if !z.QueueUpdates {
+			c.notify, _, _, _, err = syncRecords(c.log, tx, z, latest)
+			if err != nil {
+				return err
+			}
 		}
 
 		soa = zoneSOA(c.log, tx, z.Name)
@@ -700,10 +789,52 @@ func (c *conn) handleUpdate(ctx context.Context) (ok bool) {
 			adjustAdd(r)
 		}
 
+		// Changes still in the queue are applied on top of the local records, in the
+		// same order the worker will apply them. A failed change holds back all later
+		// changes, we cannot know the records the update would apply to.
+		if z.QueueUpdates {
+			q := bstore.QueryTx[QueuedChange](tx)
+			q.FilterNonzero(QueuedChange{Zone: z.Name})
+			q.SortAsc("ID")
+			err := q.ForEach(func(qc QueuedChange) error {
+				if qc.Failed {
+					failedChange = qc.ID
+					return bstore.StopForEach
+				}
+				for _, r := range qc.Add {
+					if _, ok := known[r.recordKey()]; !ok {
+						adjustAdd(r)
+					}
+				}
+				// Set replaces the rrset, which can have multiple records.
+				replaced := map[rrsetKey]bool{}
+				for _, r := range qc.Set {
+					if k := r.rrsetKey(); !replaced[k] {
+						replaced[k] = true
+						for _, d := range slices.Clone(rrsets[k]) {
+							adjustDel(d)
+						}
+					}
+					adjustAdd(r)
+				}
+				for _, r := range qc.Delete {
+					if _, ok := known[r.recordKey()]; ok {
+						adjustDel(r)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("list queued changes: %w", err)
+			}
+		}
+
 		return nil
 	})
 	if err != nil {
 		return c.respondErrorf("ensuring records are fresh: %v", err)
+	} else if failedChange != 0 {
+		return c.respondErrorf("queued change %d failed, retry or remove it before updating the zone", failedChange)
 	}
 
 	// For checking as a group (cannot be check individually).
@@ -961,30 +1092,63 @@ func (c *conn) handleUpdate(ctx context.Context) (ok bool) {
 
 	// todo: it may be better to batch adds/sets and deletes separately, and potentially do multiple of them. eg when update requests to add a request which it then deletes. we currently first try to delete it, then add it. hopefully sane clients never do that.
 
+	// For propagation, we look for the individual records that were added and
+	// removed, also when applying the changes as complete rrsets.
+	expAdd := append(slices.Clone(add), set...)
+	expDel := remove
+	if provider.config.RRSetUpdates {
+		set, remove = rrsetChanges(add, set, remove, rrsets)
+		add = nil
+	}
+
+	// Check the changes against the capabilities of the provider before making any
+	// change, also for queued updates.
+	if err := providerCheckRecords(provider, append(libdnsRecords(add), libdnsRecords(set)...)); err != nil {
+		return c.respondExtErrorf(dns.RcodeRefused, dns.ExtendedErrorCodeNotSupported, "%v", err)
+	}
+
+	if z.QueueUpdates {
+		if len(add) > 0 || len(set) > 0 || len(remove) > 0 {
+			qc := QueuedChange{Zone: z.Name, Add: add, Set: set, Delete: remove, NextAttempt: time.Now()}
+			if err := database.Insert(ctx, &qc); err != nil {
+				return c.respondErrorf("queueing changes: %v", err)
+			}
+			c.log.Info("queued changes from dns update", "queuedchange", qc.ID, "add", add, "set", set, "remove", remove)
+			queueKick()
+		}
+
+		var xm dns.Msg
+		om := xm.SetRcode(&c.im, dns.RcodeSuccess)
+		om.Authoritative = true
+		om.AuthenticatedData = false
+		return c.respond(om)
+	}
+
 	c.log.Debug("adding/setting/removing records", "add", add, "set", set, "remove", remove)
 
 	var added, xset, removed []libdns.Record
 	if len(add) > 0 {
 		added, err = appendRecords(ctx, c.log, provider, z.Name, libdnsRecords(add))
 		if err != nil {
-			return c.respondErrorf("adding records: %v", err)
+			return c.respondExtErrorf(dns.RcodeServerFailure, providerExtErrorCode(err, dns.ExtendedErrorCodeOther), "adding records: %v", err)
 		}
 	}
 	if len(set) > 0 {
 		xset, err = setRecords(ctx, c.log, provider, z.Name, libdnsRecords(set))
 		if err != nil {
-			return c.respondErrorf("setting records: %v", err)
+			return c.respondExtErrorf(dns.RcodeServerFailure, providerExtErrorCode(err, dns.ExtendedErrorCodeOther), "setting records: %v", err)
 		}
 	}
 	if len(remove) > 0 {
 		removed, err = deleteRecords(ctx, c.log, provider, z.Name, libdnsRecords(remove))
 		if err != nil {
-			return c.respondErrorf("removing records: %v", err)
+			return c.respondExtErrorf(dns.RcodeServerFailure, providerExtErrorCode(err, dns.ExtendedErrorCodeOther), "removing records: %v", err)
 		}
 	}
 	c.log.Debug("records added/set/removed", "added", added, "set", xset, "removed", removed)
 
 	done := make(chan struct{}, 1)
+	propagated := make(chan error, 1)
 
 	xunlock := unlock
 	unlock = nil
@@ -995,12 +1159,7 @@ func (c *conn) handleUpdate(ctx context.Context) (ok bool) {
 			done <- struct{}{}
 		}()
 
-		adds := make([]recordKey, len(add))
-		for i, a := range add {
-			adds[i] = a.recordKey()
-		}
-
-		_, _, err := ensurePropagate(shutdownCtx, c.log, provider, z, adds, remove, soa.SerialFirst)
+		_, _, err := ensurePropagate(shutdownCtx, c.log, provider, z, expAdd, expDel, soa.SerialFirst, propagated)
 		if err != nil {
 			c.log.Error("ensuring propagation of dns update", "err", err)
 		}
@@ -1010,6 +1169,18 @@ func (c *conn) handleUpdate(ctx context.Context) (ok bool) {
 		<-done
 	}
 
+	if z.DelayUpdateResponse {
+		c.log.Debug("waiting for propagation before responding to dns update")
+		select {
+		case <-shutdownCtx.Done():
+			return c.respondExtErrorf(dns.RcodeServerFailure, dns.ExtendedErrorCodeOther, "shutting down")
+		case err := <-propagated:
+			if err != nil {
+				return c.respondExtErrorf(dns.RcodeServerFailure, dns.ExtendedErrorCodeOther, "changes made, but not propagated: %v", err)
+			}
+		}
+	}
+
 	var xm dns.Msg
 	om := xm.SetRcode(&c.im, dns.RcodeSuccess)
 	om.Authoritative = true
@@ -1017,6 +1188,29 @@ func (c *conn) handleUpdate(ctx context.Context) (ok bool) {
 	return c.respond(om)
 }
 
+// rrsetChanges turns changes to individual records into changes to complete
+// rrsets, for provider configs with RRSetUpdates. The rrsets touched by add, set
+// and remove are set to their desired records in rrsets, which already reflect
+// the changes. SetRecords cannot remove an rrset, so records of rrsets that become
+// empty are deleted.
+func rrsetChanges(add, set, remove []Record, rrsets map[rrsetKey][]Record) (nset, nremove []Record) {
+	seen := map[rrsetKey]bool{}
+	for _, r := range slices.Concat(add, set, remove) {
+		k := r.rrsetKey()
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		nset = append(nset, rrsets[k]...)
+	}
+	for _, r := range remove {
+		if len(rrsets[r.rrsetKey()]) == 0 {
+			nremove = append(nremove, r)
+		}
+	}
+	return
+}
+
 // Handle XFR (AXFR only for now). We will send the full zone. We start and end
 // with the SOA record. We may be sending multiple messages (each DNS message is
 // max 64KB), each potentially TSIG signed.
@@ -1058,9 +1252,9 @@ func (c *conn) handleXFR(ctx context.Context) (ok bool) {
 
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
-	latest, err := getRecords(ctx, c.log, provider, z.Name, false)
+	latest, err := getZoneRecords(ctx, c.log, provider, z, false)
 	if err != nil {
-		return c.respondExtErrorf(dns.RcodeServerFailure, dns.ExtendedErrorCodeNetworkError, "get records from provider: %v", err)
+		return c.respondExtErrorf(dns.RcodeServerFailure, providerExtErrorCode(err, dns.ExtendedErrorCodeNetworkError), "get records from provider: %v", err)
 	}
 
 	c.zone = z.Name // Used along with c.notify.
@@ -1224,6 +1418,21 @@ func (c *conn) handleAuth(ctx context.Context) (ok bool) {
 	return c.respond(om)
 }
 
+// freshRecordsRequested returns whether the request has the EDNS0 option asking
+// for records to be fetched from the provider.
+func (c *conn) freshRecordsRequested() bool {
+	opt := c.im.IsEdns0()
+	if opt == nil {
+		return false
+	}
+	for _, o := range opt.Option {
+		if o.Option() == ednsOptionFreshRecords {
+			return true
+		}
+	}
+	return false
+}
+
 // responseError returns a non-nil error if a dns response message indicates a failure.
 func responseError(m *dns.Msg) error {
 	if m.Rcode == dns.RcodeSuccess {
@@ -1247,12 +1456,18 @@ func deleteRecords(ctx context.Context, log *slog.Logger, provider Provider, zon
 }
 
 func appendRecords(ctx context.Context, log *slog.Logger, provider Provider, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	if err := providerCheckRecords(provider, records); err != nil {
+		return nil, err
+	}
 	l, err := provider.AppendRecords(ctx, zone, records)
 	log.Debug("appending records at provider", "err", err, "zone", zone, "records", records, "appended", l)
 	return l, err
 }
 
 func setRecords(ctx context.Context, log *slog.Logger, provider Provider, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	if err := providerCheckRecords(provider, records); err != nil {
+		return nil, err
+	}
 	l, err := provider.SetRecords(ctx, zone, records)
 	log.Debug("setting records at provider", "err", err, "zone", zone, "records", records, "set", l)
 	return l, err
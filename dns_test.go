@@ -9,6 +9,7 @@ import (
 	"net"
 	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -584,6 +585,315 @@ func TestUpdate(t *testing.T) {
 	})
 }
 
+func TestUpdateRecordsFreshness(t *testing.T) {
+	newRR := func(s string) dns.RR {
+		rr, err := dns.NewRR(s)
+		tcheck(t, err, "parse rr")
+		return rr
+	}
+
+	testUpdate := func(te testEnv, om *dns.Msg, expRcode int) {
+		t.Helper()
+		c := dns.Client{Net: "tcp-tls", TLSConfig: te.z0.tlsConfig}
+		tdc := dnsclient{t, &c, te.tlsaddr}
+		tdc.exchange(om, nil, expRcode)
+	}
+
+	testDNS(t, func(te testEnv, z Zone) {
+		z.RecordsFreshness = time.Minute
+		z = te.api.ZoneUpdate(ctxbg, z)
+
+		// Fetches records, which are reused during the freshness window.
+		te.api.ZoneRefresh(ctxbg, z.Name)
+
+		// Changed at provider, not visible in the reused records.
+		_, err := te.z0.p.AppendRecords(ctxbg, z.Name, []libdns.Record{ldr("", "fresh", 300, "A", "10.0.0.4")})
+		tcheck(t, err, "append record")
+
+		om := msgUpdate(z.Name)
+		om.NameUsed([]dns.RR{newRR("fresh." + z.Name + " 300 A 10.0.0.4")})
+		om.Insert([]dns.RR{newRR("other." + z.Name + " 300 A 10.0.0.5")})
+		testUpdate(te, om, dns.RcodeNameError)
+
+		// Client asks for fresh records through EDNS0 option.
+		if om.IsEdns0() == nil {
+			om.SetEdns0(1232, false)
+		}
+		opt := om.IsEdns0()
+		opt.Option = append(opt.Option, &dns.EDNS0_LOCAL{Code: ednsOptionFreshRecords})
+		testUpdate(te, om, dns.RcodeSuccess)
+
+		// Changing the provider config, e.g. its credentials, clears cached records.
+		te.api.ZoneRefresh(ctxbg, z.Name)
+		recordsCache.Lock()
+		_, ok := recordsCache.zones[z.Name]
+		recordsCache.Unlock()
+		tcompare(t, ok, true)
+		te.api.ProviderConfigUpdate(ctxbg, te.z0.pc)
+		recordsCache.Lock()
+		_, ok = recordsCache.zones[z.Name]
+		recordsCache.Unlock()
+		tcompare(t, ok, false)
+
+		// Configured to always fetch records for prerequisites.
+		z.FreshPrerequisites = true
+		z = te.api.ZoneUpdate(ctxbg, z)
+		te.api.ZoneRefresh(ctxbg, z.Name)
+		_, err = te.z0.p.AppendRecords(ctxbg, z.Name, []libdns.Record{ldr("", "fresh2", 300, "A", "10.0.0.6")})
+		tcheck(t, err, "append record")
+		om = msgUpdate(z.Name)
+		om.NameUsed([]dns.RR{newRR("fresh2." + z.Name + " 300 A 10.0.0.6")})
+		om.Insert([]dns.RR{newRR("other2." + z.Name + " 300 A 10.0.0.7")})
+		testUpdate(te, om, dns.RcodeSuccess)
+	})
+}
+
+func TestUpdateRRSets(t *testing.T) {
+	newRR := func(s string) dns.RR {
+		rr, err := dns.NewRR(s)
+		tcheck(t, err, "parse rr")
+		return rr
+	}
+
+	testUpdate := func(te testEnv, om *dns.Msg, expRcode int) {
+		t.Helper()
+		c := dns.Client{Net: "tcp-tls", TLSConfig: te.z0.tlsConfig}
+		tdc := dnsclient{t, &c, te.tlsaddr}
+		tdc.exchange(om, nil, expRcode)
+	}
+
+	testDNS(t, func(te testEnv, z Zone) {
+		te.z0.p.RRSets = true
+		pc := te.z0.pc
+		pc.RRSetUpdates = true
+		te.api.ProviderConfigUpdate(ctxbg, pc)
+
+		// Mixed add and delete for the same rrset, and a new rrset, result in a single
+		// SetRecords call with the desired rrsets.
+		te.z0.p.Ops = nil
+		tc := te.zoneChanged(func() {
+			om := msgUpdate(z.Name)
+			om.Insert([]dns.RR{
+				newRR("testhost." + z.Name + " 300 A 10.0.0.3"),
+				newRR("other." + z.Name + " 300 TXT \"test\""),
+			})
+			om.Remove([]dns.RR{newRR("testhost." + z.Name + " 300 A 10.0.0.1")})
+			testUpdate(te, om, dns.RcodeSuccess)
+		})
+		tc.checkRecordDelta(typecounts{"A": 2}, typecounts{"A": 2, "TXT": 1})
+		tcompare(t, te.z0.p.Ops, []string{"set"})
+
+		// Removing all records of an rrset deletes them, SetRecords cannot remove an
+		// rrset. Adding to another rrset in the same update sets that rrset.
+		te.z0.p.Ops = nil
+		tc = te.zoneChanged(func() {
+			om := msgUpdate(z.Name)
+			om.Remove([]dns.RR{
+				newRR("testhost." + z.Name + " 300 A 10.0.0.2"),
+				newRR("testhost." + z.Name + " 300 A 10.0.0.3"),
+			})
+			om.Insert([]dns.RR{newRR("other." + z.Name + " 300 TXT \"test2\"")})
+			testUpdate(te, om, dns.RcodeSuccess)
+		})
+		tc.checkRecordDelta(typecounts{"A": 2, "TXT": 1}, typecounts{"TXT": 2})
+		tcompare(t, te.z0.p.Ops, []string{"set", "delete"})
+
+		// Queued updates are stored as rrset changes too.
+		z.QueueUpdates = true
+		z = te.api.ZoneUpdate(ctxbg, z)
+		om := msgUpdate(z.Name)
+		om.Insert([]dns.RR{newRR("other." + z.Name + " 300 TXT \"test3\"")})
+		om.Remove([]dns.RR{newRR("other." + z.Name + " 300 TXT \"test\"")})
+		testUpdate(te, om, dns.RcodeSuccess)
+		qcl := te.api.ZoneQueuedChanges(ctxbg, z.Name)
+		tcompare(t, len(qcl), 1)
+		tcompare(t, len(qcl[0].Add), 0)
+		tcompare(t, len(qcl[0].Set), 2)
+		tcompare(t, len(qcl[0].Delete), 0)
+	})
+}
+
+func TestUpdateQueue(t *testing.T) {
+	newRR := func(s string) dns.RR {
+		rr, err := dns.NewRR(s)
+		tcheck(t, err, "parse rr")
+		return rr
+	}
+
+	testUpdate := func(te testEnv, om *dns.Msg, expRcode int) {
+		t.Helper()
+		c := dns.Client{Net: "tcp-tls", TLSConfig: te.z0.tlsConfig}
+		tdc := dnsclient{t, &c, te.tlsaddr}
+		tdc.exchange(om, nil, expRcode)
+	}
+
+	log := slog.Default()
+
+	testDNS(t, func(te testEnv, z Zone) {
+		z.QueueUpdates = true
+		z = te.api.ZoneUpdate(ctxbg, z)
+
+		// Provider is not called, changes are queued.
+		te.z0.p.Errors = []error{errors.New("unavailable"), errors.New("unavailable")}
+		om := msgUpdate(z.Name)
+		om.Insert([]dns.RR{newRR("queued1." + z.Name + " 300 A 10.0.0.10")})
+		testUpdate(te, om, dns.RcodeSuccess)
+		tcompare(t, len(te.z0.p.Errors), 2)
+
+		// Prerequisites are checked against local records with queued changes applied.
+		om = msgUpdate(z.Name)
+		om.NameUsed([]dns.RR{newRR("queued1." + z.Name + " 300 A 10.0.0.10")})
+		om.Insert([]dns.RR{newRR("queued2." + z.Name + " 300 A 10.0.0.11")})
+		testUpdate(te, om, dns.RcodeSuccess)
+
+		qcl := te.api.ZoneQueuedChanges(ctxbg, z.Name)
+		tcompare(t, len(qcl), 2)
+
+		run := func() {
+			queueRun(log)
+			queueActive.Wait()
+		}
+
+		// First attempt fails, second change is not attempted before the first.
+		run()
+		qcl = te.api.ZoneQueuedChanges(ctxbg, z.Name)
+		tcompare(t, len(qcl), 2)
+		tcompare(t, qcl[0].Attempts, 1)
+		tcompare(t, qcl[0].LastError != "", true)
+		tcompare(t, qcl[0].NextAttempt.After(time.Now()), true)
+		tcompare(t, qcl[1].Attempts, 0)
+		tcompare(t, len(te.z0.p.Errors), 1)
+
+		// Last attempt fails, change is marked as failed.
+		qcl[0].Attempts = queueMaxAttempts - 1
+		qcl[0].NextAttempt = time.Now()
+		err := database.Update(ctxbg, &qcl[0])
+		tcheck(t, err, "update queued change")
+		run()
+		qcl = te.api.ZoneQueuedChanges(ctxbg, z.Name)
+		tcompare(t, qcl[0].Failed, true)
+
+		// Later changes for the zone are not applied while a change has failed.
+		run()
+		qcl = te.api.ZoneQueuedChanges(ctxbg, z.Name)
+		tcompare(t, len(qcl), 2)
+		tcompare(t, qcl[1].Attempts, 0)
+
+		// Updates are refused while the queue is blocked by a failed change, also when
+		// the prerequisites only involve held back changes.
+		om = msgUpdate(z.Name)
+		om.NameUsed([]dns.RR{newRR("queued2." + z.Name + " 300 A 10.0.0.11")})
+		om.Insert([]dns.RR{newRR("queued3." + z.Name + " 300 A 10.0.0.12")})
+		testUpdate(te, om, dns.RcodeServerFailure)
+		tcompare(t, len(te.api.ZoneQueuedChanges(ctxbg, z.Name)), 2)
+
+		// Retry applies changes in order, removing them from the queue.
+		te.api.QueuedChangeRetry(ctxbg, qcl[0].ID)
+		for range 3 {
+			run()
+		}
+		tcompare(t, len(te.api.ZoneQueuedChanges(ctxbg, z.Name)), 0)
+		records, err := te.z0.p.GetRecords(ctxbg, z.Name)
+		tcheck(t, err, "get records")
+		var n int
+		for _, r := range records {
+			if name := libdns.RelativeName(r.Name, z.Name); name == "queued1" || name == "queued2" {
+				n++
+			}
+		}
+		tcompare(t, n, 2)
+
+		// Deleting a queued change.
+		om = msgUpdate(z.Name)
+		om.Insert([]dns.RR{newRR("queued3." + z.Name + " 300 A 10.0.0.12")})
+		testUpdate(te, om, dns.RcodeSuccess)
+		qcl = te.api.ZoneQueuedChanges(ctxbg, z.Name)
+		tcompare(t, len(qcl), 1)
+		te.api.QueuedChangeDelete(ctxbg, qcl[0].ID)
+		tcompare(t, len(te.api.ZoneQueuedChanges(ctxbg, z.Name)), 0)
+	})
+}
+
+func TestUpdateVerifyNameservers(t *testing.T) {
+	newRR := func(s string) dns.RR {
+		rr, err := dns.NewRR(s)
+		tcheck(t, err, "parse rr")
+		return rr
+	}
+
+	testUpdate := func(te testEnv, om *dns.Msg, expRcode int) {
+		t.Helper()
+		c := dns.Client{Net: "tcp-tls", TLSConfig: te.z0.tlsConfig}
+		tdc := dnsclient{t, &c, te.tlsaddr}
+		tdc.exchange(om, nil, expRcode)
+	}
+
+	defer func(l []time.Duration) {
+		nameserverWaits = l
+	}(nameserverWaits)
+	nameserverWaits = []time.Duration{0, time.Second / 100, time.Second / 100}
+
+	testDNS(t, func(te testEnv, z Zone) {
+		// Authoritative name server serving the records of the provider, once enabled.
+		var serving atomic.Bool
+		handler := func(w dns.ResponseWriter, m *dns.Msg) {
+			var om dns.Msg
+			om.SetReply(m)
+			om.Authoritative = true
+			if serving.Load() {
+				records, err := te.z0.p.GetRecords(ctxbg, z.Name)
+				tcheck(t, err, "get records")
+				for _, r := range records {
+					name := libdns.AbsoluteName(libdns.RelativeName(r.Name, z.Name), z.Name)
+					rr := newRR(fmt.Sprintf("%s %d IN %s %s", name, r.TTL/time.Second, r.Type, r.Value))
+					if rr.Header().Name == m.Question[0].Name && rr.Header().Rrtype == m.Question[0].Qtype {
+						om.Answer = append(om.Answer, rr)
+					}
+				}
+			}
+			err := w.WriteMsg(&om)
+			tcheck(t, err, "write response")
+		}
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		tcheck(t, err, "listen")
+		server := dns.Server{Listener: ln, Handler: dns.HandlerFunc(handler)}
+		go server.ActivateAndServe()
+		defer server.Shutdown()
+
+		mockLookupNameservers = func(zone string) ([]NameserverCheck, error) {
+			return []NameserverCheck{{Host: "ns0." + z.Name, Addr: ln.Addr().String()}}, nil
+		}
+		defer func() {
+			mockLookupNameservers = nil
+		}()
+
+		z.VerifyNameservers = true
+		z.DelayUpdateResponse = true
+		z = te.api.ZoneUpdate(ctxbg, z)
+
+		// Name server does not serve the change, propagation fails.
+		om := msgUpdate(z.Name)
+		om.Insert([]dns.RR{newRR("verify1." + z.Name + " 300 A 10.0.0.20")})
+		testUpdate(te, om, dns.RcodeServerFailure)
+
+		checks := te.api.PropagationChecks(ctxbg)
+		tcompare(t, len(checks), 1)
+		tcompare(t, checks[0].Failed, true)
+		tcompare(t, checks[0].ProviderDone, true)
+		tcompare(t, len(checks[0].Nameservers), 1)
+		tcompare(t, checks[0].Nameservers[0].Done, false)
+		tcompare(t, checks[0].Nameservers[0].LastError != "", true)
+		te.api.PropagationCheckDelete(ctxbg, checks[0].ID)
+
+		// Now served, response after propagation.
+		serving.Store(true)
+		om = msgUpdate(z.Name)
+		om.Insert([]dns.RR{newRR("verify2." + z.Name + " 300 A 10.0.0.21")})
+		testUpdate(te, om, dns.RcodeSuccess)
+		tcompare(t, len(te.api.PropagationChecks(ctxbg)), 0)
+	})
+}
+
 func TestDNSAuthoritative(t *testing.T) {
 	testDNS(t, func(te testEnv, z Zone) {
 		// Get authoritative SOA.
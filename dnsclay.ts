@@ -4,8 +4,62 @@ let pageElem = dom.div(style({padding: '1em'}), dom.div(style({textAlign: 'cente
 
 let version: string
 let dnsTypeNames: { [key: number]: string } = {}
+let currentUser: api.User
+
+// login is called by the API client when a call fails for lack of a session. It
+// shows a login form, and resolves with the CSRF token, which is also stored in
+// localStorage for use after a reload.
+const login = async (reason: string) => {
+	const oidcEnabled = await client.OIDCEnabled()
+	return new Promise<string>((resolve: (v: string) => void) => {
+		let fieldset: HTMLFieldSetElement
+		let username: HTMLInputElement
+		let password: HTMLInputElement
+		let errorElem: HTMLElement
+
+		const [close] = popupOpts(true,
+			dom.h1('Login'),
+			dom.form(
+				async function submit(e: SubmitEvent) {
+					e.preventDefault()
+					e.stopPropagation()
+					fieldset.disabled = true
+					try {
+						const loginToken = await client.LoginPrep()
+						const token = await client.Login(loginToken, username.value, password.value)
+						localStorage.setItem('dnsclaycsrftoken', token)
+						close()
+						resolve(token)
+					} catch (err: any) {
+						dom._kids(errorElem, err.message)
+						password.focus()
+					} finally {
+						fieldset.disabled = false
+					}
+				},
+				fieldset=dom.fieldset(
+					style({display: 'flex', flexDirection: 'column', gap: '2ex', width: '20em'}),
+					errorElem=dom.div(style({color: 'red'}), reason ? 'Error: '+reason : ''),
+					dom.label(
+						dom.div('Username'),
+						username=dom.input(attr.required(''), attr.autocomplete('username'), style({width: '100%'})),
+					),
+					dom.label(
+						dom.div('Password'),
+						password=dom.input(attr.type('password'), attr.required(''), attr.autocomplete('current-password'), style({width: '100%'})),
+					),
+					dom.div(
+						dom.submitbutton('Login'),
+					),
+				),
+			),
+			oidcEnabled ? dom.p(dom.a(attr.href('auth/oidc/login'), 'Login with single sign-on')) : [],
+		)
+		username.focus()
+	})
+}
 
-const client = new api.Client()
+const client = new api.Client().withOptions({csrfHeader: 'x-dnsclay-csrf', login: login}).withAuthToken(localStorage.getItem('dnsclaycsrftoken') || '')
 
 const link = (href: string, anchor: string) => dom.a(attr.href(href), anchor)
 
@@ -97,6 +151,10 @@ const chunked = <T>(l: T[], len: number): T[][] => {
 
 const popup = (...kids: ElemArg[]) => popupOpts(false, ...kids)
 
+// Attributes for buttons that change objects managed through the configuration
+// file, disabling them.
+const configManaged = (managed: boolean) => managed ? [attr.disabled(''), attr.title('Managed through configuration file.')] : []
+
 const availableProviders = async (): Promise<[Map<string, api.sherpadocStrings>, api.sherpadocStruct[]]> => {
 	const docs = await client.Docs()
 	const stringEnums = new Map<string, api.sherpadocStrings>()
@@ -107,6 +165,47 @@ const availableProviders = async (): Promise<[Map<string, api.sherpadocStrings>,
 	return [stringEnums, providers]
 }
 
+const providerHealthView = (h: api.ProviderHealth) => {
+	if (h.Healthy) {
+		return dom.span('healthy', h.LastError ? attr.title('Last error: '+h.LastError) : [])
+	}
+	return dom.span(
+		style({color: 'red'}),
+		'unhealthy',
+		attr.title(`Unhealthy since ${ formatDate(h.UnhealthySince!) } after ${ h.ConsecutiveFailures } consecutive failures.\nNext attempt at ${ formatDate(h.NextAttempt!) }.\nLast error: ${ h.LastError }`),
+	)
+}
+
+// capabilitiesText returns a description of the known capabilities of a provider.
+const capabilitiesText = (c: api.ProviderCapabilities | undefined) => {
+	if (!c) {
+		return 'No known limitations.'
+	}
+	const l: string[] = []
+	if (c.Types && c.Types.length > 0) {
+		l.push('Record types: '+c.Types.join(', ')+'.')
+	}
+	if (c.MinTTL) {
+		l.push('Minimum TTL: '+c.MinTTL+'s.')
+	}
+	if (c.MaxTTL) {
+		l.push('Maximum TTL: '+c.MaxTTL+'s.')
+	}
+	if (c.RRSets) {
+		l.push('Operates on record sets.')
+	}
+	if (c.NoSOA) {
+		l.push('Does not return SOA record.')
+	}
+	if (c.FixedSerial) {
+		l.push('SOA serial does not change.')
+	}
+	if (c.Notes) {
+		l.push(c.Notes)
+	}
+	return l.length > 0 ? l.join(' ') : 'No known limitations.'
+}
+
 interface ProviderConfigField {
 	elem: HTMLInputElement | HTMLSelectElement
 	nullable: boolean
@@ -175,7 +274,7 @@ const providerFields = (p: api.sherpadocStruct, stringEnums: Map<string, api.she
 				input = dom.input(attr.type('number'), config[f.Name] ? attr.value(''+config[f.Name]) : [])
 				typ = 'number'
 			} else if (tw[0] === 'string') {
-				input = dom.input(config[f.Name] ? attr.value(''+config[f.Name]) : [])
+				input = dom.input(config[f.Name] ? attr.value(''+config[f.Name]) : [], attr.placeholder('value, or secret reference env:NAME, file:/path, exec:command'), attr.title('Secrets can be referenced instead of entered: env:NAME for an environment variable, file:/path for a file, exec:command args for the output of a command (if enabled). References are resolved by dnsclay, only the reference is stored.'))
 			} else {
 				const values = stringEnums.get(tw[0])
 				if (values) {
@@ -200,11 +299,245 @@ const providerFields = (p: api.sherpadocStruct, stringEnums: Map<string, api.she
 	return {root: root, fieldMap: fieldMap}
 }
 
+const auditEventsView = (events: api.AuditEvent[], showZone: boolean) => {
+	return dom.table(
+		dom.thead(
+			dom.tr(
+				dom.th('Age'),
+				dom.th('User'),
+				showZone ? dom.th('Zone') : [],
+				dom.th('Action'),
+				dom.th('Details'),
+			),
+		),
+		dom.tbody(
+			events.length ? [] : dom.tr(dom.td(attr.colspan(showZone ? '5' : '4'), 'No changes.', style({textAlign: 'left'}))),
+			events.map(ev =>
+				dom.tr(
+					dom.td(formatAge(ev.Time), attr.title(formatDate(ev.Time))),
+					dom.td(ev.Username || '-', ev.APIToken ? [' ', dom.span('(token '+ev.APIToken+')', attr.title('Change made with an API token.'))] : []),
+					showZone ? dom.td(ev.Zone ? dom.a(attr.href('#zones/'+trimDot(ev.Zone)), trimDot(ev.Zone)) : '-') : [],
+					dom.td(ev.Action),
+					dom.td(style({textAlign: 'left'}), ev.Details),
+				)
+			),
+		),
+	)
+}
+
+const isAdmin = () => currentUser.Role === api.Role.RoleAdmin
+
+const userEdit = (u: api.User | null, zones: api.Zone[], done: () => void) => {
+	let fieldset: HTMLFieldSetElement
+	let username: HTMLInputElement
+	let password: HTMLInputElement
+	let role: HTMLSelectElement
+	let zonesElem: HTMLElement
+	const zoneCheckboxes: {name: string, checkbox: HTMLInputElement}[] = []
+
+	const [close] = popup(
+		dom.h1(u ? 'Edit user' : 'Add user'),
+		dom.form(
+			async function submit(e: SubmitEvent) {
+				e.preventDefault()
+				e.stopPropagation()
+				const nu: api.User = {
+					ID: u ? u.ID : 0,
+					Created: u ? u.Created : new Date(),
+					Username: u ? u.Username : username.value,
+					Role: role.value as api.Role,
+					Zones: role.value === api.Role.RoleEditor ? zoneCheckboxes.filter(zc => zc.checkbox.checked).map(zc => zc.name) : [],
+					PasswordHash: '',
+					OIDCSubject: u ? u.OIDCSubject : '',
+				}
+				if (u) {
+					await check(fieldset, () => client.UserUpdate(nu))
+				} else {
+					await check(fieldset, () => client.UserAdd(nu, password.value))
+				}
+				close()
+				done()
+			},
+			fieldset=dom.fieldset(
+				style({display: 'flex', flexDirection: 'column', gap: '2ex'}),
+				dom.label(
+					dom.div('Username'),
+					username=dom.input(attr.required(''), u ? [attr.value(u.Username), attr.disabled('')] : []),
+				),
+				u ? [] : dom.label(
+					dom.div('Password'),
+					password=dom.input(attr.type('password'), attr.required(''), attr.autocomplete('new-password')),
+				),
+				dom.label(
+					dom.div('Role'),
+					role=dom.select(
+						dom.option('Viewer', attr.value(api.Role.RoleViewer), u?.Role === api.Role.RoleViewer ? attr.selected('') : []),
+						dom.option('Editor', attr.value(api.Role.RoleEditor), u?.Role === api.Role.RoleEditor ? attr.selected('') : []),
+						dom.option('Admin', attr.value(api.Role.RoleAdmin), u?.Role === api.Role.RoleAdmin ? attr.selected('') : []),
+						function change() {
+							zonesElem.style.display = role.value === api.Role.RoleEditor ? '' : 'none'
+						},
+					),
+					dom.div(style({fontStyle: 'italic'}), 'Viewers can see zones and records, but not secrets. Editors can change the selected zones. Admins can change everything, including provider configs and users.'),
+				),
+				zonesElem=dom.div(
+					u?.Role === api.Role.RoleEditor ? [] : style({display: 'none'}),
+					dom.div('Zones that can be changed'),
+					zones.map(z => {
+						const checkbox = dom.input(attr.type('checkbox'), (u?.Zones || []).includes(z.Name) ? attr.checked('') : [])
+						zoneCheckboxes.push({name: z.Name, checkbox: checkbox})
+						return dom.div(dom.label(checkbox, ' ', trimDot(z.Name)))
+					}),
+				),
+				dom.div(
+					dom.submitbutton(u ? 'Save' : 'Add user'),
+				),
+			),
+		),
+	)
+	if (!u) {
+		username.focus()
+	}
+}
+
+const apiTokenAdd = (zones: api.Zone[], done: () => void) => {
+	let fieldset: HTMLFieldSetElement
+	let name: HTMLInputElement
+	let readOnly: HTMLInputElement
+	let functions: HTMLInputElement
+	let expires: HTMLInputElement
+	const zoneCheckboxes: {name: string, checkbox: HTMLInputElement}[] = []
+
+	const [close] = popup(
+		dom.h1('Add API token'),
+		dom.form(
+			async function submit(e: SubmitEvent) {
+				e.preventDefault()
+				e.stopPropagation()
+				const t: api.APIToken = {
+					ID: 0,
+					Created: new Date(),
+					UserID: 0,
+					Name: name.value,
+					TokenHash: '',
+					Prefix: '',
+					ReadOnly: readOnly.checked,
+					Zones: zoneCheckboxes.filter(zc => zc.checkbox.checked).map(zc => zc.name),
+					Functions: functions.value.split(/[ ,]+/).filter(s => !!s),
+					Expires: expires.value ? new Date(expires.value) : null,
+				}
+				const [, token] = await check(fieldset, () => client.APITokenAdd(t))
+				close()
+				done()
+				popup(
+					dom.h1('API token added'),
+					dom.p('Copy the token now, it is not shown again.'),
+					dom.pre(token),
+					dom.p('Use it as bearer token, for example:'),
+					dom.pre('curl -H \'Authorization: Bearer '+token+'\' -H \'Content-Type: application/json\' -d \'{"params": []}\' '+location.origin+'/api/Zones'),
+				)
+			},
+			fieldset=dom.fieldset(
+				style({display: 'flex', flexDirection: 'column', gap: '2ex'}),
+				dom.label(
+					dom.div('Name'),
+					name=dom.input(attr.required(''), attr.placeholder('e.g. name of script using the token')),
+				),
+				dom.label(
+					readOnly=dom.input(attr.type('checkbox')), ' Read-only',
+					dom.div(style({fontStyle: 'italic'}), 'Only viewing, even if your role allows changes.'),
+				),
+				dom.div(
+					dom.div('Zones'),
+					zones.map(z => {
+						const checkbox = dom.input(attr.type('checkbox'))
+						zoneCheckboxes.push({name: z.Name, checkbox: checkbox})
+						return dom.div(dom.label(checkbox, ' ', trimDot(z.Name)))
+					}),
+					dom.div(style({fontStyle: 'italic'}), 'If any are selected, only these zones can be viewed and changed, and functions requiring an admin are not allowed.'),
+				),
+				dom.label(
+					dom.div('API functions'),
+					functions=dom.input(attr.placeholder('e.g. Zones, ZoneRecords, RecordSetUpdate')),
+					dom.div(style({fontStyle: 'italic'}), 'If set, only these functions can be called. See the API documentation at /api/.'),
+				),
+				dom.label(
+					dom.div('Expires'),
+					expires=dom.input(attr.type('datetime-local')),
+					dom.div(style({fontStyle: 'italic'}), 'Optional.'),
+				),
+				dom.div(
+					dom.submitbutton('Add API token'),
+				),
+			),
+		),
+	)
+	name.focus()
+}
+
+const passwordSet = (title: string, withCurrent: boolean, fn: (current: string, password: string) => Promise<void>) => {
+	let fieldset: HTMLFieldSetElement
+	let current: HTMLInputElement
+	let password: HTMLInputElement
+	let password2: HTMLInputElement
+
+	const [close] = popup(
+		dom.h1(title),
+		dom.form(
+			async function submit(e: SubmitEvent) {
+				e.preventDefault()
+				e.stopPropagation()
+				if (password.value !== password2.value) {
+					alert('Passwords do not match.')
+					return
+				}
+				await check(fieldset, () => fn(withCurrent ? current.value : '', password.value))
+				close()
+			},
+			fieldset=dom.fieldset(
+				style({display: 'flex', flexDirection: 'column', gap: '2ex'}),
+				withCurrent ? dom.label(
+					dom.div('Current password'),
+					current=dom.input(attr.type('password'), attr.required(''), attr.autocomplete('current-password')),
+				) : [],
+				dom.label(
+					dom.div('New password'),
+					password=dom.input(attr.type('password'), attr.required(''), attr.autocomplete('new-password')),
+				),
+				dom.label(
+					dom.div('New password again'),
+					password2=dom.input(attr.type('password'), attr.required(''), attr.autocomplete('new-password')),
+				),
+				dom.div(
+					dom.submitbutton('Set password'),
+				),
+			),
+		),
+	)
+	if (withCurrent) {
+		current!.focus()
+	} else {
+		password.focus()
+	}
+}
+
 const pageHome = async () => {
-	let [zones0] = await Promise.all([
+	let [zones0, health0, providerConfigs0, discoveries0, events0, users0, apiTokens0] = await Promise.all([
 		client.Zones(),
+		client.ProviderHealth(),
+		client.ProviderConfigs(),
+		isAdmin() ? client.ZoneDiscoveries() : Promise.resolve([]),
+		client.AuditEvents(''),
+		isAdmin() ? client.Users() : Promise.resolve([]),
+		client.APITokens(),
 	])
 	let zones = zones0 || []
+	const health = health0 || []
+	let providerConfigs = providerConfigs0 || []
+	let discoveries = discoveries0 || []
+	const events = events0 || []
+	let users = users0 || []
+	let apiTokens = apiTokens0 || []
 
 	dom._kids(crumbElem,
 		dom.a(attr.href('#'), 'Home'),
@@ -212,6 +545,9 @@ const pageHome = async () => {
 	document.title = 'Dnsclay'
 
 	let zonesTbody: HTMLElement
+	let providerConfigsTbody: HTMLElement
+	let usersTbody: HTMLElement
+	let apiTokensTbody: HTMLElement
 
 	const root = dom.div(
 		dom.div(
@@ -219,14 +555,23 @@ const pageHome = async () => {
 				let zone: HTMLInputElement
 				let refreshInterval: HTMLInputElement
 				let syncInterval: HTMLInputElement
+				let recordsFreshness: HTMLInputElement
+				let freshPrerequisites: HTMLInputElement
+				let queueUpdates: HTMLInputElement
+				let verifyNameservers: HTMLInputElement
+				let delayUpdateResponse: HTMLInputElement
 				let fieldset: HTMLFieldSetElement
 				let testResult: HTMLElement
 				let newProviderConfigName: HTMLInputElement
+				let retries: HTMLInputElement
+				let failureThreshold: HTMLInputElement
+				let rrsetUpdates: HTMLInputElement
 				let existingProviderConfigName: HTMLSelectElement
 
-				const [[stringEnums, providers], providerURLs] = await Promise.all([
+				const [[stringEnums, providers], providerURLs, capabilities] = await Promise.all([
 					availableProviders(),
 					client.ProviderURLs(),
+					client.ProviderCapabilities(),
 				])
 				const providerConfigs = await client.ProviderConfigs() || []
 
@@ -249,10 +594,26 @@ const pageHome = async () => {
 							dom.div('Name'),
 							dom.div(newProviderConfigName=dom.input(attr.required(''), attr.value(newProviderConfigName?.value || zone.value))),
 						),
+						dom.label(
+							dom.div('Retries', attr.title('Number of times a provider operation that failed with a transient error (timeout, connection error, HTTP 5xx) is retried. Adding records is not retried.')),
+							dom.div(retries=dom.input(attr.type('number'), attr.required(''), attr.value(retries?.value || '2'))),
+						),
+						dom.label(
+							dom.div('Failure threshold', attr.title('Number of consecutive transient failures after which the provider config is marked unhealthy. While unhealthy, operations fail immediately and automatic syncs are paused, with increasing pauses between attempts. 0 disables.')),
+							dom.div(failureThreshold=dom.input(attr.type('number'), attr.required(''), attr.value(failureThreshold?.value || '5'))),
+						),
+						dom.label(
+							rrsetUpdates=dom.input(attr.type('checkbox'), capabilities[providerName]?.RRSets ? attr.checked('') : []),
+							' Apply DNS UPDATEs as complete record sets',
+							attr.title('Changes from DNS UPDATEs are applied by setting the complete desired record set (name and type) with a single call, instead of adding and deleting individual records. Fewer API calls, and no partial record sets in between calls. Only for providers that replace record sets when setting records. Enabled by default for providers known to operate on record sets.'),
+						),
 						dom.div(
 							style({padding: '1em', border: '1px solid #ddd'}),
 							dom.h2('"'+providerName+'" fields'),
-							dom.p('Implemented through ', dom.a(attr.href('https://'+url), url, attr.rel('noreferrer noopener')), ', see ', dom.a(attr.href('https://pkg.go.dev/'+url), 'Go documentation', attr.rel('noreferrer noopener'))),
+							capabilities[providerName]?.Builtin ?
+								dom.p('Built into dnsclay, see ', dom.a(attr.href('https://'+url), 'documentation', attr.rel('noreferrer noopener'))) :
+								dom.p('Implemented through ', dom.a(attr.href('https://'+url), url, attr.rel('noreferrer noopener')), ', see ', dom.a(attr.href('https://pkg.go.dev/'+url), 'Go documentation', attr.rel('noreferrer noopener'))),
+							dom.p('Capabilities: ', capabilitiesText(capabilities[providerName])),
 							dom.div(
 								style({display: 'flex', flexDirection: 'column', gap: '2ex'}),
 								fields=providerFields(p, stringEnums, null),
@@ -288,7 +649,7 @@ const pageHome = async () => {
 										return
 									}
 									pName = pc.ProviderName
-									pcJSON = pc.ProviderConfigJSON
+									pcJSON = await check(fieldset, () => client.ProviderConfigReveal(pc.Name))
 								} else {
 									if (!fields) {
 										alert('No provider selected.')
@@ -316,6 +677,30 @@ const pageHome = async () => {
 									dom.div(dom.label('Sync interval (in seconds)'), attr.title('The zone is fetched in full during each sync.')),
 									syncInterval=dom.input(attr.type('number'), attr.required(''), attr.value('86400')),
 								),
+								dom.div(
+									dom.div(dom.label('Records freshness (in seconds)'), attr.title('Records fetched from the provider within this window are reused for DNS UPDATE/XFR and web API requests, instead of fetching them again. Useful for bursts of DNS UPDATEs.')),
+									recordsFreshness=dom.input(attr.type('number'), attr.required(''), attr.value('0')),
+									dom.div(style({fontStyle: 'italic'}), '0 fetches records for each request'),
+								),
+								dom.label(
+									freshPrerequisites=dom.input(attr.type('checkbox')),
+									' Always fetch records for DNS UPDATE prerequisites',
+								),
+								dom.label(
+									verifyNameservers=dom.input(attr.type('checkbox')),
+									' Verify propagation at authoritative name servers',
+									attr.title('After changes are seen through the provider, the authoritative name servers of the zone are queried until they all serve the changed records.'),
+								),
+								dom.label(
+									delayUpdateResponse=dom.input(attr.type('checkbox')),
+									' Delay DNS UPDATE response until propagated',
+									attr.title('Only respond to DNS UPDATEs after the changes have propagated, and respond with SERVFAIL if propagation failed. Clients may need a longer timeout. Not used when DNS UPDATEs are queued.'),
+								),
+								dom.label(
+									queueUpdates=dom.input(attr.type('checkbox')),
+									' Queue DNS UPDATEs',
+									attr.title('DNS UPDATEs are validated against the local records, acknowledged, and applied through the provider in the background, with retries. Useful when the provider is not always available.'),
+								),
 								dom.div(
 									dom.div(dom.label('Create new provider config')),
 									dom.div(
@@ -326,7 +711,8 @@ const pageHome = async () => {
 													return dom.div(
 														dom.label(
 															dom.input(attr.type('radio'), attr.name('provider'), attr.value(trimPrefix(p.Name, 'Provider_')), function change() { updateProviderConfig() }),
-															' ', trimPrefix(p.Name, 'Provider_')
+															' ', trimPrefix(p.Name, 'Provider_'),
+															attr.title(capabilitiesText(capabilities[trimPrefix(p.Name, 'Provider_')])),
 														),
 													)
 												})
@@ -360,9 +746,16 @@ const pageHome = async () => {
 												Name: newProviderConfigName.value,
 												ProviderName: providerName,
 												ProviderConfigJSON: providerConfigJSON(fields),
+												Retries: parseInt(retries.value),
+												FailureThreshold: parseInt(failureThreshold.value),
+												DiscoverInterval: 0,
+												RRSetUpdates: rrsetUpdates.checked,
+												MinTTL: 0,
+												ConfigManaged: false,
 											}
 											pc = await check(fieldset, () => client.ProviderConfigAdd(pc))
 											pcName = pc.Name
+											providerConfigs.push(pc)
 										}
 
 										const z: api.Zone = {
@@ -374,10 +767,17 @@ const pageHome = async () => {
 											RefreshInterval: parseInt(refreshInterval.value)*1000*1000*1000,
 											NextSync: new Date(),
 											NextRefresh: new Date(),
+											RecordsFreshness: parseInt(recordsFreshness.value)*1000*1000*1000,
+											FreshPrerequisites: freshPrerequisites.checked,
+											QueueUpdates: queueUpdates.checked,
+											VerifyNameservers: verifyNameservers.checked,
+											DelayUpdateResponse: delayUpdateResponse.checked,
+											ConfigManaged: false,
 										}
 										const nz = await check(fieldset, () => client.ZoneAdd(z, [])) // todo: allow specifying notifies
 										zones.push(nz)
 										render()
+										renderProviderConfigs()
 										close()
 									}),
 								),
@@ -404,8 +804,179 @@ const pageHome = async () => {
 			),
 			zonesTbody=dom.tbody(),
 		),
+		dom.br(),
+		dom.h1('Provider configs'),
+		dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th('Name'),
+					dom.th('Provider'),
+					dom.th('Zones'),
+					dom.th('Zone discovery', attr.title('Zones at the provider that are not configured (new), and configured zones no longer at the provider (vanished), as found during the last periodic or requested discovery.')),
+					dom.th('Action'),
+				),
+			),
+			providerConfigsTbody=dom.tbody(),
+		),
+		isAdmin() ? [
+			dom.br(),
+			dom.div(
+				style({display: 'flex', gap: '.5em', alignItems: 'baseline'}),
+				dom.h1('Users'), ' ',
+				dom.clickbutton('Add user', function click() {
+					userEdit(null, zones, async () => {
+						users = await client.Users() || []
+						renderUsers()
+					})
+				}),
+			),
+			dom.table(
+				dom.thead(
+					dom.tr(
+						dom.th('Username'),
+						dom.th('Role'),
+						dom.th('Zones'),
+						dom.th('Age'),
+						dom.th('Action'),
+					),
+				),
+				usersTbody=dom.tbody(),
+			),
+		] : [],
+		dom.br(),
+		dom.div(
+			style({display: 'flex', gap: '.5em', alignItems: 'baseline'}),
+			dom.h1('API tokens'), ' ',
+			dom.clickbutton('Add API token', function click() {
+				apiTokenAdd(zones, async () => {
+					apiTokens = await client.APITokens() || []
+					renderAPITokens()
+				})
+			}),
+		),
+		dom.p('API tokens let scripts call the API with limited access, as bearer token.'),
+		dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th('Name'),
+					dom.th('Token'),
+					isAdmin() ? dom.th('User') : [],
+					dom.th('Scope'),
+					dom.th('Age'),
+					dom.th('Last used'),
+					dom.th('Expires'),
+					dom.th('Action'),
+				),
+			),
+			apiTokensTbody=dom.tbody(),
+		),
+		dom.br(),
+		dom.h1('Recent changes'),
+		auditEventsView(events, true),
 	)
 
+	const discoverZones = async (btn: HTMLButtonElement, pc: api.ProviderConfig) => {
+		const [discovered, vanished] = await check(btn, () => client.ProviderConfigDiscoverZones(pc.Name))
+		discoveries = await client.ZoneDiscoveries() || []
+		renderProviderConfigs()
+
+		let fieldset: HTMLFieldSetElement
+		let notifyAddress: HTMLInputElement
+		let notifyProtocol: HTMLSelectElement
+		const selected: {name: string, checkbox: HTMLInputElement}[] = []
+
+		const [close] = popup(
+			dom.h1('Discover zones'),
+			dom.p('Zones at provider config ', dom.b(pc.Name), '. Selected zones are added with this provider config, default refresh and sync intervals, and a new TSIG credential.'),
+			vanished && vanished.length ? dom.p(style({color: 'red'}), 'Configured zones no longer at provider: ', vanished.map(name => trimDot(name)).join(', ')) : [],
+			dom.form(
+				async function submit(e: SubmitEvent) {
+					e.preventDefault()
+					e.stopPropagation()
+
+					const names = selected.filter(x => x.checkbox.checked).map(x => x.name)
+					if (!names.length) {
+						alert('No zones selected.')
+						return
+					}
+					const notifies: api.ZoneNotify[] = []
+					if (notifyAddress.value) {
+						notifies.push({ID: 0, Created: new Date(), Zone: '', Address: notifyAddress.value, Protocol: notifyProtocol.value, ConfigManaged: false})
+					}
+					const nzones = await check(fieldset, () => client.ZonesAdd(pc.Name, names, notifies)) || []
+					zones.push(...nzones)
+					discoveries = await client.ZoneDiscoveries() || []
+					render()
+					renderProviderConfigs()
+					close()
+				},
+				fieldset=dom.fieldset(
+					style({display: 'flex', flexDirection: 'column', gap: '2ex'}),
+					discovered && discovered.length ? dom.table(
+						dom.thead(
+							dom.tr(
+								dom.th(),
+								dom.th('Zone'),
+								dom.th('Configured with'),
+							),
+						),
+						dom.tbody(
+							discovered.map(dz => {
+								let checkbox: HTMLInputElement | undefined
+								if (!dz.ProviderConfigName) {
+									checkbox = dom.input(attr.type('checkbox'), attr.checked(''))
+									selected.push({name: dz.Name, checkbox: checkbox})
+								}
+								return dom.tr(
+									dom.td(checkbox || []),
+									dom.td(trimDot(dz.Name)),
+									dom.td(dz.ProviderConfigName || '-'),
+								)
+							}),
+						),
+					) : dom.p('No zones at provider.'),
+					dom.label(
+						dom.div('DNS NOTIFY address for added zones (optional)'),
+						dom.div(
+							notifyAddress=dom.input(attr.placeholder('127.0.0.1:53')), ' ',
+							notifyProtocol=dom.select(dom.option('tcp'), dom.option('udp')),
+						),
+					),
+					dom.div(
+						dom.submitbutton('Add selected zones'),
+					),
+				),
+			),
+		)
+	}
+
+	const renderProviderConfigs = () => {
+		dom._kids(providerConfigsTbody,
+			providerConfigs.length ? [] : dom.tr(dom.td(attr.colspan('5'), 'No provider configs.', style({textAlign: 'left'}))),
+			providerConfigs.map(pc => {
+				const zd = discoveries.find(zd => zd.ProviderConfigName === pc.Name)
+				return dom.tr(
+					dom.td(pc.Name, pc.ConfigManaged ? [' ', dom.span('(config file)', attr.title('Managed through configuration file.'))] : []),
+					dom.td(pc.ProviderName),
+					dom.td(''+zones.filter(z => z.ProviderConfigName === pc.Name).length),
+					dom.td(
+						!zd ? '-' : [
+							zd.New && zd.New.length ? dom.div('New: ', zd.New.map(name => trimDot(name)).join(', ')) : [],
+							zd.Vanished && zd.Vanished.length ? dom.div(style({color: 'red'}), 'Vanished: ', zd.Vanished.map(name => trimDot(name)).join(', ')) : [],
+							zd.Error ? dom.div(style({color: 'red'}), 'Error: ', zd.Error) : [],
+							zd.Last ? dom.div(style({fontStyle: 'italic'}), 'Checked ', formatAge(zd.Last), attr.title(formatDate(zd.Last))) : [],
+						],
+					),
+					dom.td(
+						dom.clickbutton('Discover zones', attr.title('List zones at the provider, and add zones that are not yet configured. Not all providers can list zones.'), async function click(e: MouseEvent) {
+							await discoverZones(e.target! as HTMLButtonElement, pc)
+						}),
+					),
+				)
+			}),
+		)
+	}
+
 	const render = () => {
 		const now = new Date()
 		dom._kids(zonesTbody,
@@ -413,7 +984,10 @@ const pageHome = async () => {
 			zones.map(z =>
 				dom.tr(
 					dom.td(dom.a(attr.href('#zones/'+trimDot(z.Name)), trimDot(z.Name))),
-					dom.td(z.ProviderConfigName),
+					dom.td(
+						z.ProviderConfigName,
+						health.filter(h => h.ProviderConfigName === z.ProviderConfigName && !h.Healthy).map(h => [' (', providerHealthView(h), ')']),
+					),
 					dom.td(z.LastSync ? [formatAge(z.LastSync), attr.title(formatDate(z.LastSync))] : []),
 					dom.td(z.LastRecordChange ? [formatAge(z.LastRecordChange), attr.title(formatDate(z.LastRecordChange))] : []),
 					dom.td(
@@ -440,7 +1014,79 @@ const pageHome = async () => {
 			),
 		)
 	}
+	const renderUsers = () => {
+		if (!usersTbody) {
+			return
+		}
+		dom._kids(usersTbody,
+			users.map(u =>
+				dom.tr(
+					dom.td(u.Username, u.ID === currentUser.ID ? ' (you)' : '', u.OIDCSubject ? [' ', dom.span('(single sign-on)', attr.title('Role and zones are updated from the identity provider at each login.'))] : []),
+					dom.td(u.Role),
+					dom.td(u.Role === api.Role.RoleEditor ? (u.Zones || []).map(z => trimDot(z)).join(', ') || '-' : 'all'),
+					dom.td(formatAge(u.Created), attr.title(formatDate(u.Created))),
+					dom.td(
+						dom.clickbutton('Edit', function click() {
+							userEdit(u, zones, async () => {
+								users = await client.Users() || []
+								renderUsers()
+							})
+						}), ' ',
+						dom.clickbutton('Set password', u.OIDCSubject ? attr.disabled('') : [], function click() {
+							passwordSet('Set password for '+u.Username, false, (_: string, password: string) => client.UserPasswordSet(u.ID, password))
+						}), ' ',
+						dom.clickbutton('Delete', async function click(e: {target: HTMLButtonElement}) {
+							if (!confirm('Are you sure you want to remove user '+u.Username+'?')) {
+								return
+							}
+							await check(e.target, () => client.UserDelete(u.ID))
+							users = users.filter(x => x !== u)
+							renderUsers()
+						}),
+					),
+				)
+			),
+		)
+	}
+
+	const renderAPITokens = () => {
+		dom._kids(apiTokensTbody,
+			apiTokens.length ? [] : dom.tr(dom.td(attr.colspan(isAdmin() ? '8' : '7'), 'No API tokens.', style({textAlign: 'left'}))),
+			apiTokens.map(t =>
+				dom.tr(
+					dom.td(t.Name),
+					dom.td(t.Prefix+'...'),
+					isAdmin() ? dom.td(users.find(u => u.ID === t.UserID)?.Username || '-') : [],
+					dom.td(
+						style({textAlign: 'left'}),
+						[
+							t.ReadOnly ? 'read-only' : '',
+							(t.Zones || []).length ? 'zones: '+(t.Zones || []).map(z => trimDot(z)).join(', ') : '',
+							(t.Functions || []).length ? 'functions: '+(t.Functions || []).join(', ') : '',
+						].filter(s => !!s).join('; ') || 'all',
+					),
+					dom.td(formatAge(t.Created), attr.title(formatDate(t.Created))),
+					dom.td(t.LastUsed ? [formatAge(t.LastUsed), attr.title(formatDate(t.LastUsed))] : 'never'),
+					dom.td(t.Expires ? [formatDate(t.Expires), t.Expires.getTime() < Date.now() ? ' (expired)' : ''] : 'never'),
+					dom.td(
+						dom.clickbutton('Revoke', async function click(e: {target: HTMLButtonElement}) {
+							if (!confirm('Are you sure you want to revoke API token '+t.Name+'? Scripts using it will no longer work.')) {
+								return
+							}
+							await check(e.target, () => client.APITokenRevoke(t.ID))
+							apiTokens = apiTokens.filter(x => x !== t)
+							renderAPITokens()
+						}),
+					),
+				)
+			),
+		)
+	}
+
 	render()
+	renderProviderConfigs()
+	renderUsers()
+	renderAPITokens()
 
 	return root
 }
@@ -716,6 +1362,9 @@ const pageZone = async (zonestr: string) => {
 	let notifies = notifies0 || []
 	let credentials = credentials0 || []
 	let sets = sets0 || []
+	const health = (await client.ProviderHealth() || []).find(h => h.ProviderConfigName === zone.ProviderConfigName)
+	let queued = await client.ZoneQueuedChanges(zone.Name) || []
+	const events = await client.AuditEvents(zone.Name) || []
 
 	dom._kids(crumbElem,
 		dom.a(attr.href('#'), 'Home'), ' / ',
@@ -740,6 +1389,43 @@ const pageZone = async (zonestr: string) => {
 	let showHistoric: HTMLInputElement
 	let showDNSSEC: HTMLInputElement
 	let recordsTbody: HTMLElement
+	const queuedTbody = dom.tbody()
+
+	const renderQueued = () => {
+		const change = (op: string, r: api.Record) => dom.div(op, ' ', relName(r.AbsName), ' ', ''+r.TTL, ' ', dnsTypeNames[r.Type] || (''+r.Type), ' ', r.Value)
+		dom._kids(queuedTbody,
+			queued.length ? [] : dom.tr(dom.td(attr.colspan('6'), 'No queued changes.', style({textAlign: 'left'}))),
+			queued.map(qc =>
+				dom.tr(
+					dom.td(formatAge(qc.Created), attr.title(formatDate(qc.Created))),
+					dom.td(
+						style({textAlign: 'left'}),
+						(qc.Add || []).map(r => change('add', r)),
+						(qc.Set || []).map(r => change('set', r)),
+						(qc.Delete || []).map(r => change('delete', r)),
+					),
+					dom.td(''+qc.Attempts),
+					dom.td(qc.Failed ? dom.span(style({color: 'red'}), 'Failed') : [formatAge(undefined, qc.NextAttempt), attr.title(formatDate(qc.NextAttempt))]),
+					dom.td(style({textAlign: 'left'}), qc.LastError),
+					dom.td(
+						dom.clickbutton('Retry', async function click(e: {target: HTMLButtonElement}) {
+							const nqc = await check(e.target, () => client.QueuedChangeRetry(qc.ID))
+							queued.splice(queued.indexOf(qc), 1, nqc)
+							renderQueued()
+						}), ' ',
+						dom.clickbutton('Delete', attr.title('Remove from the queue, the changes will not be applied.'), async function click(e: {target: HTMLButtonElement}) {
+							if (!confirm('Are you sure?')) {
+								return
+							}
+							await check(e.target, () => client.QueuedChangeDelete(qc.ID))
+							queued.splice(queued.indexOf(qc), 1)
+							renderQueued()
+						}),
+					),
+				)
+			),
+		)
+	}
 
 	const refresh = async (elem: {disabled: boolean}) => {
 		const [nzone, npc, nnotifies, ncredentials, nsets] = await check(elem, () => client.Zone(zone.Name))
@@ -753,13 +1439,19 @@ const pageZone = async (zonestr: string) => {
 
 	const root = dom.div(
 		dom.div(
-			dom.p(`Provider config: ${ zone.ProviderConfigName } (provider ${ providerConfig.ProviderName })`),
+			dom.p(`Provider config: ${ zone.ProviderConfigName } (provider ${ providerConfig.ProviderName })`, health ? [', ', providerHealthView(health)] : [], zone.ConfigManaged ? ', zone managed through configuration file' : ''),
 			dom.clickbutton(
 				'Edit zone config',
+				configManaged(zone.ConfigManaged),
 				async function click(e: {target: HTMLButtonElement}) {
 					let fieldset: HTMLFieldSetElement
 					let refreshival: HTMLInputElement
 					let syncival: HTMLInputElement
+					let freshness: HTMLInputElement
+					let freshPrerequisites: HTMLInputElement
+					let queueUpdates: HTMLInputElement
+					let verifyNameservers: HTMLInputElement
+					let delayUpdateResponse: HTMLInputElement
 					let providerConfigName: HTMLSelectElement
 
 					const providerConfigs = await check(e.target, () => client.ProviderConfigs()) || []
@@ -776,6 +1468,11 @@ const pageZone = async (zonestr: string) => {
 								nz.ProviderConfigName = providerConfigName.value
 								nz.RefreshInterval = 1000*1000*1000 * parseInt(refreshival.value)
 								nz.SyncInterval = 1000*1000*1000 * parseInt(syncival.value)
+								nz.RecordsFreshness = 1000*1000*1000 * parseInt(freshness.value)
+								nz.FreshPrerequisites = freshPrerequisites.checked
+								nz.QueueUpdates = queueUpdates.checked
+								nz.VerifyNameservers = verifyNameservers.checked
+								nz.DelayUpdateResponse = delayUpdateResponse.checked
 								zone = await check(fieldset, () => client.ZoneUpdate(nz))
 								close()
 							},
@@ -790,6 +1487,30 @@ const pageZone = async (zonestr: string) => {
 									dom.div('Sync interval (in seconds)', attr.title('The zone is fetched in full during each sync.')),
 									syncival=dom.input(attr.type('number'), attr.required(''), attr.value(''+(zone.SyncInterval/(1000*1000*1000)))),
 								),
+								dom.label(
+									dom.div('Records freshness (in seconds)', attr.title('Records fetched from the provider within this window are reused for DNS UPDATE/XFR and web API requests, instead of fetching them again. Useful for bursts of DNS UPDATEs.')),
+									freshness=dom.input(attr.type('number'), attr.required(''), attr.value(''+(zone.RecordsFreshness/(1000*1000*1000)))),
+									dom.div(style({fontStyle: 'italic'}), '0 fetches records for each request'),
+								),
+								dom.label(
+									freshPrerequisites=dom.input(attr.type('checkbox'), zone.FreshPrerequisites ? attr.checked('') : []),
+									' Always fetch records for DNS UPDATE prerequisites',
+								),
+								dom.label(
+									verifyNameservers=dom.input(attr.type('checkbox'), zone.VerifyNameservers ? attr.checked('') : []),
+									' Verify propagation at authoritative name servers',
+									attr.title('After changes are seen through the provider, the authoritative name servers of the zone are queried until they all serve the changed records.'),
+								),
+								dom.label(
+									delayUpdateResponse=dom.input(attr.type('checkbox'), zone.DelayUpdateResponse ? attr.checked('') : []),
+									' Delay DNS UPDATE response until propagated',
+									attr.title('Only respond to DNS UPDATEs after the changes have propagated, and respond with SERVFAIL if propagation failed. Clients may need a longer timeout. Not used when DNS UPDATEs are queued.'),
+								),
+								dom.label(
+									queueUpdates=dom.input(attr.type('checkbox'), zone.QueueUpdates ? attr.checked('') : []),
+									' Queue DNS UPDATEs',
+									attr.title('DNS UPDATEs are validated against the local records, acknowledged, and applied through the provider in the background, with retries. Useful when the provider is not always available.'),
+								),
 								dom.label(
 									dom.div('Provider config'),
 									providerConfigName=dom.select(
@@ -807,6 +1528,7 @@ const pageZone = async (zonestr: string) => {
 			), ' ',
 			dom.clickbutton(
 				'Edit provider config',
+				configManaged(providerConfig.ConfigManaged),
 				async function click(e: {target: HTMLButtonElement}) {
 					let fieldset: HTMLFieldSetElement
 
@@ -816,9 +1538,15 @@ const pageZone = async (zonestr: string) => {
 						alert('cannot find provider '+providerConfig.ProviderName)
 						return
 					}
+					const pcJSON = await check(e.target, () => client.ProviderConfigReveal(providerConfig.Name))
 
 					let testResult: HTMLElement
 					let fields: ProviderFields
+					let retries: HTMLInputElement
+					let failureThreshold: HTMLInputElement
+					let discoverInterval: HTMLInputElement
+					let rrsetUpdates: HTMLInputElement
+					let minTTL: HTMLInputElement
 
 					const [close] = popup(
 						dom.h1('Edit provider config'),
@@ -848,12 +1576,33 @@ const pageZone = async (zonestr: string) => {
 										),
 									),
 								),
+								dom.label(
+									dom.div('Retries', attr.title('Number of times a provider operation that failed with a transient error (timeout, connection error, HTTP 5xx) is retried. Adding records is not retried.')),
+									dom.div(retries=dom.input(attr.type('number'), attr.required(''), attr.value(''+providerConfig.Retries))),
+								),
+								dom.label(
+									dom.div('Failure threshold', attr.title('Number of consecutive transient failures after which the provider config is marked unhealthy. While unhealthy, operations fail immediately and automatic syncs are paused, with increasing pauses between attempts. 0 disables.')),
+									dom.div(failureThreshold=dom.input(attr.type('number'), attr.required(''), attr.value(''+providerConfig.FailureThreshold))),
+								),
+								dom.label(
+									dom.div('Zone discovery interval (in seconds)', attr.title('Interval for listing zones at the provider, flagging zones that are not configured and configured zones that vanished. Only for providers that can list zones. 0 disables.')),
+									dom.div(discoverInterval=dom.input(attr.type('number'), attr.required(''), attr.value(''+(providerConfig.DiscoverInterval/(1000*1000*1000))))),
+								),
+								dom.label(
+									rrsetUpdates=dom.input(attr.type('checkbox'), providerConfig.RRSetUpdates ? attr.checked('') : []),
+									' Apply DNS UPDATEs as complete record sets',
+									attr.title('Changes from DNS UPDATEs are applied by setting the complete desired record set (name and type) with a single call, instead of adding and deleting individual records. Fewer API calls, and no partial record sets in between calls. Only for providers that replace record sets when setting records.'),
+								),
+								dom.label(
+									dom.div('Minimum TTL (in seconds)', attr.title('Minimum TTL for records added or set through the provider, instead of the minimum known for the provider, e.g. after the DNS operator lowered it for the account. 0 uses the known minimum.')),
+									dom.div(minTTL=dom.input(attr.type('number'), attr.required(''), attr.min('0'), attr.value(''+providerConfig.MinTTL))),
+								),
 								dom.div(
 									style({padding: '1em', border: '1px solid #ddd'}),
 									dom.h2('Provider config'),
 									dom.div(
 										style({display: 'flex', flexDirection: 'column', gap: '2ex'}),
-										fields=providerFields(p, stringEnums, providerConfig.ProviderConfigJSON),
+										fields=providerFields(p, stringEnums, pcJSON),
 									),
 								),
 								dom.div(
@@ -866,6 +1615,12 @@ const pageZone = async (zonestr: string) => {
 											Name: providerConfig.Name, // todo: allow editing, need to rename it for all users in database.
 											ProviderName: providerConfig.ProviderName, // todo: allow changing too
 											ProviderConfigJSON: providerConfigJSON(fields),
+											Retries: parseInt(retries.value),
+											FailureThreshold: parseInt(failureThreshold.value),
+											DiscoverInterval: parseInt(discoverInterval.value)*1000*1000*1000,
+											RRSetUpdates: rrsetUpdates.checked,
+											MinTTL: parseInt(minTTL.value),
+											ConfigManaged: false,
 										}
 										providerConfig = await check(fieldset, () => client.ProviderConfigUpdate(npc))
 										close()
@@ -904,6 +1659,7 @@ const pageZone = async (zonestr: string) => {
 										Zone: zone.Name,
 										Protocol: (fieldset.querySelector('input[name=notifyprotocol]:checked') as HTMLInputElement)?.value || '',
 										Address: address.value,
+										ConfigManaged: false,
 									}
 									const nzn = await check(fieldset, () => client.ZoneNotifyAdd(zn))
 									notifies.push(nzn)
@@ -947,7 +1703,7 @@ const pageZone = async (zonestr: string) => {
 									dom.clickbutton('Notify', async function click(e: {target: HTMLButtonElement}) {
 										await check(e.target, () => client.ZoneNotify(n.ID))
 									}), ' ',
-									dom.clickbutton('Delete', async function click(e: {target: HTMLButtonElement}) {
+									dom.clickbutton('Delete', configManaged(n.ConfigManaged), async function click(e: {target: HTMLButtonElement}) {
 										if (!confirm('Are you sure?')) {
 											return
 										}
@@ -990,6 +1746,7 @@ const pageZone = async (zonestr: string) => {
 										Type: typ,
 										TSIGSecret: typ === 'tsig' ? key.value : '',
 										TLSPublicKey: typ === 'tlspubkey' ? key.value : '',
+										ConfigManaged: false,
 									}
 									const nc = await check(fieldset, () => client.ZoneCredentialAdd(zone.Name, c))
 									credentials.push(nc)
@@ -1038,13 +1795,14 @@ const pageZone = async (zonestr: string) => {
 								dom.td(c.Name),
 								dom.td(c.Type),
 								dom.td(c.Type === 'tsig' ?
-									dom.clickbutton('Show', function click(e: {target: HTMLButtonElement}) {
-										e.target.replaceWith(dom.span(c.TSIGSecret))
+									dom.clickbutton('Show', async function click(e: {target: HTMLButtonElement}) {
+										const secret = await check(e.target, () => client.ZoneCredentialReveal(c.ID))
+										e.target.replaceWith(dom.span(secret))
 									}) : c.TLSPublicKey,
 								),
 								dom.td(formatAge(c.Created), attr.title(formatDate(c.Created))),
 								dom.td(
-									dom.clickbutton('Delete', async function click(e: {target: HTMLButtonElement}) {
+									dom.clickbutton('Delete', configManaged(c.ConfigManaged), async function click(e: {target: HTMLButtonElement}) {
 										if (!confirm('Are you sure?')) {
 											return
 										}
@@ -1062,6 +1820,31 @@ const pageZone = async (zonestr: string) => {
 		),
 		dom.br(),
 
+		!zone.QueueUpdates && queued.length === 0 ? [] : [
+			dom.div(
+				style({display: 'flex', gap: '.5em', alignItems: 'baseline'}),
+				dom.h2('Queued changes'), ' ',
+				dom.clickbutton('Reload', async function click(e: {target: HTMLButtonElement}) {
+					queued = await check(e.target, () => client.ZoneQueuedChanges(zone.Name)) || []
+					renderQueued()
+				}),
+			),
+			dom.table(
+				dom.thead(
+					dom.tr(
+						dom.th('Age'),
+						dom.th('Changes'),
+						dom.th('Failed attempts'),
+						dom.th('Next attempt'),
+						dom.th('Last error'),
+						dom.th(),
+					),
+				),
+				queuedTbody,
+			),
+			dom.br(),
+		],
+
 		dom.div(
 			style({display: 'flex', gap: '.5em', alignItems: 'baseline'}),
 			dom.h2('Records'), ' ',
@@ -1164,8 +1947,11 @@ const pageZone = async (zonestr: string) => {
 			recordsTbody=dom.tbody(),
 		),
 		dom.br(),
+		dom.h2('Recent changes'),
+		auditEventsView(events, false),
+		dom.br(),
 		dom.h2('Danger'),
-		dom.clickbutton('Remove zone', attr.title('Remove zone from management in dnsclay. The zone and its records are not changed at the provider.'), async function click(e: {target: HTMLButtonElement}) {
+		dom.clickbutton('Remove zone', attr.title('Remove zone from management in dnsclay. The zone and its records are not changed at the provider.'), configManaged(zone.ConfigManaged), async function click(e: {target: HTMLButtonElement}) {
 			if (!confirm('Are you sure you want to remove this zone from management in dnsclay? The zone and its records are not changed at the provider.')) {
 				return
 			}
@@ -1282,6 +2068,7 @@ const pageZone = async (zonestr: string) => {
 	}
 
 	render()
+	renderQueued()
 
 	return root
 }
@@ -1310,15 +2097,25 @@ const hashchange = async (e?: HashChangeEvent) => {
 }
 
 const init = async () => {
-	[version, dnsTypeNames] = await Promise.all([
+	[version, dnsTypeNames, currentUser] = await Promise.all([
 		client.Version(),
 		client.DNSTypeNames(),
+		client.CurrentUser(),
 	])
 	const root = dom.div(
 		dom.div(
 			style({display: 'flex', justifyContent: 'space-between', marginBottom: '1ex', padding: '.5em 1em', backgroundColor: '#f8f8f8'}),
 			crumbElem,
 			dom.div(
+				currentUser.Username, ' (', currentUser.Role, ') ',
+				currentUser.OIDCSubject ? [] : dom.clickbutton('Change password', function click() {
+					passwordSet('Change password', true, (current: string, password: string) => client.PasswordChange(current, password))
+				}), ' ',
+				dom.clickbutton('Logout', async function click(e: {target: HTMLButtonElement}) {
+					await check(e.target, () => client.Logout())
+					localStorage.removeItem('dnsclaycsrftoken')
+					window.location.reload()
+				}), ' | ',
 				dom.a(attr.href('https://github.com/mjl-/dnsclay'), 'dnsclay'),
 				' ',
 				version,
@@ -74,19 +74,35 @@ parallel.
 
 	usage: dnsclay serve [flags]
 	       dnsclay genkey >privkey-ed25519.pkcs8.pem
+	       dnsclay gensecretkey >secretkeys
 	       dnsclay dns [flags] notify [flags] addr zone
 	       dnsclay dns [flags] update [flags] addr zone [add name type ttl value | del...] ...
 	       dnsclay dns [flags] xfr [flags] addr zone
+	       dnsclay config check dnsclay.conf
 	       dnsclay version
 	       dnsclay license
 
 # Usage for "dnsclay serve"
 
 	usage: dnsclay serve [flags]
+	  -adminacmedirectory string
+	    	acme directory url, for -adminacmedomain (default "https://acme-v02.api.letsencrypt.org/directory")
+	  -adminacmedomain string
+	    	if non-empty, domain to request a certificate for through acme for the admin interface, implies -admintls; the dns-01 challenge record is added through the provider of the managed zone containing the domain; renewed automatically
+	  -adminacmeemail string
+	    	optional contact email address for the acme account, for -adminacmedomain
 	  -adminaddr string
 	    	address to serve admin interface on (default "localhost:8053")
 	  -adminpasswordpath string
-	    	file with admin password for http basic auth; if absent, a random password is generated and written (default "adminpassword")
+	    	file with password for admin user "admin", only used when the database has no users; if absent, a random password is generated and written (default "adminpassword")
+	  -admintls
+	    	serve admin interface with https, also metrics if on the same address; certificate from -adminacmedomain, -admintlscertpem and -admintlskeypem, or -tlscertpem and -tlskeypem; certificate files are read again when they change
+	  -admintlscertpem string
+	    	path to pem file with certificate chain for the admin interface, implies -admintls
+	  -admintlskeypem string
+	    	path to pem file with private key for the admin interface, implies -admintls
+	  -config string
+	    	if non-empty, json file with provider configs, zones, notify addresses and credentials to reconcile into the database at startup and on sighup; config-managed objects cannot be changed in the admin interface
 	  -dns-notify-tcpaddr string
 	    	comma-separated tcp address to listen for dns notify messages on
 	  -dns-notify-tlsaddr string
@@ -97,16 +113,44 @@ parallel.
 	    	comma-separated tcp address to serve dns update and axfr requests on (default "localhost:1053")
 	  -dns-upxfr-tlsaddr string
 	    	comma-separated tls address to serve dns update and axfr requests on (default "localhost:1853")
+	  -logformat string
+	    	log format: text for logfmt-like lines, json for one json object per line; with json, dns traces from -trace are logged as json too (default "text")
 	  -loglevel value
 	    	log level: error, warn, info, debug (default INFO)
+	  -logtime
+	    	include timestamps in log lines, e.g. when not logging to a system that adds them
 	  -metricsaddr string
 	    	address to serve prometheus metrics on; can be same as adminaddr, no authentication needed (default "localhost:8053")
+	  -nameserverwaits string
+	    	comma-separated durations to wait before each check whether changes are served by the authoritative name servers, for zones that verify name servers (default "1s,2s,5s,10s,20s,30s,1m,2m")
+	  -oidcconfig string
+	    	if non-empty, json file with openid connect configuration, for logging in to the admin interface through an identity provider, with roles based on groups
+	  -otlpendpoint string
+	    	if non-empty, opentelemetry otlp/http url to export tracing spans to with json encoding, for dns requests, provider operations, syncs and dns notifies; path /v1/traces is used if url has no path, e.g. http://localhost:4318
+	  -otlpheaders string
+	    	comma-separated key=value http headers to add to otlp export requests, e.g. for authentication
+	  -propagationwaits string
+	    	comma-separated durations to wait before each check whether changes made through a provider are visible; if changes are still not visible after the last check, propagation has failed (default "100ms,1s,2s,3s")
+	  -providercommands string
+	    	comma-separated programs, by path, that provider configs may run as command, for the plugin provider and the reload command of the zonefile provider; admins can run any allowed program with arguments of their choosing, so only allow programs meant for it
+	  -secretkeyfile string
+	    	file with base64-encoded 32-byte keys, one per line, for encrypting provider configs and tsig secrets in the database; first key is used for encryption, others only for decryption; if empty, keys are read from environment variable DNSCLAY_SECRET_KEYS (comma-separated), and secrets are stored in plain text if absent
+	  -secretrefs string
+	    	comma-separated secret references to resolve in string values of provider configs: env:PREFIX for environment variables (env:NAME) starting with PREFIX, file:/dir for files (file:/path) in directory /dir, exec for commands (exec:command args); admins can reference any allowed secret in a provider config and have it sent to a provider of their choosing, including a webhook, so keep prefixes narrow and the secret key file out of allowed directories; exec allows admins to run any command, so enable with care
+	  -secretrefttl duration
+	    	how long to cache secrets resolved from references in provider configs before resolving again, for picking up rotated secrets (default 5m0s)
+	  -shutdowntimeout duration
+	    	at shutdown (sigterm or sigint), how long to wait for in-flight dns requests, api calls, propagation checks, dns notifies and syncs to finish before aborting them (default 30s)
 	  -tlscertpem string
 	    	path to pem file with one or more certificates; if empty, an ephemeral minimalistic certificate is generated for the private key
 	  -tlskeypem string
 	    	path to pem file with pkcs#8 private key file, for dns tls server; if empty an ephemeral tls key is generated at startup; if left at default, file is created if missing (default "server.privkey-ed25519.pkcs8.pem")
+	  -tlstransition duration
+	    	after the dns tls certificate or key files change (checked on sighup and new connections), keep offering the previous certificate for this long to clients that do not support the new certificate, e.g. due to a different key type (default 24h0m0s)
 	  -trace string
 	    	if non-empty, comma-separated formats to log dns request/response traces: text for textual format, json for json, jsonindent for multi-line indented json
+	  -user string
+	    	if non-empty, user to change to after opening sockets, before reading key files and opening the database; for binding to privileged ports as root without socket activation
 
 # Usage for "dnsclay dns notify"
 
@@ -35,6 +35,12 @@ func _checkf(err error, format string, args ...any) {
 		panic(&sherpa.Error{Code: "user:error", Message: xerr.Error()})
 	}
 
+	// Expected while a provider config is unhealthy, not worth a stack trace.
+	if errors.Is(err, errProviderUnhealthy) {
+		slog.Debug("sherpa server error", "err", xerr)
+		panic(&sherpa.Error{Code: "server:providerUnhealthy", Message: xerr.Error()})
+	}
+
 	m := msg.Error()
 	if m != "" {
 		m += ": "
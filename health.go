@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"regexp"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Provider operations that fail with a transient error (timeout, HTTP 5xx) are
+// retried according to the ProviderConfig. Consecutive transient failures are
+// tracked per provider config. After ProviderConfig.FailureThreshold failures, the
+// circuit breaker opens: the provider config is marked unhealthy, and operations
+// fail fast with errProviderUnhealthy without calling the provider, until the next
+// attempt is allowed. Each failed attempt doubles the pause. A successful
+// operation marks the provider config healthy again.
+
+var errProviderUnhealthy = errors.New("provider config unhealthy")
+
+const (
+	breakerFirstPause = 30 * time.Second
+	breakerMaxPause   = time.Hour
+	retryMaxDelay     = 8 * time.Second
+)
+
+// Delay before first retry. Changed during tests.
+var retryFirstDelay = time.Second
+
+// ProviderHealth is the health of a provider config, as tracked by the circuit
+// breaker. Only kept in memory, all provider configs start out healthy.
+type ProviderHealth struct {
+	ProviderConfigName string
+
+	// False after FailureThreshold consecutive transient failures, until an
+	// operation succeeds again.
+	Healthy bool
+
+	ConsecutiveFailures int
+	LastError           string
+	LastErrorTime       *time.Time
+	UnhealthySince      *time.Time
+
+	// While unhealthy, time until which operations fail without calling the
+	// provider, including automatic syncs.
+	NextAttempt *time.Time
+}
+
+type breaker struct {
+	ProviderHealth
+	pause time.Duration // Current pause while unhealthy.
+}
+
+var providerHealth = struct {
+	sync.Mutex
+	configs map[string]*breaker
+}{configs: map[string]*breaker{}}
+
+// providerBreaker returns the breaker for the provider config, creating it if
+// needed. Must be called with providerHealth locked.
+func providerBreaker(name string) *breaker {
+	b := providerHealth.configs[name]
+	if b == nil {
+		b = &breaker{ProviderHealth: ProviderHealth{ProviderConfigName: name, Healthy: true}}
+		providerHealth.configs[name] = b
+	}
+	return b
+}
+
+// providerAllow returns an error wrapping errProviderUnhealthy if an operation
+// for the provider config must fail fast. When the pause has passed, a single
+// attempt is allowed, and other operations keep failing fast until its result is
+// known.
+func providerAllow(pc ProviderConfig) error {
+	if pc.Name == "" {
+		return nil
+	}
+
+	providerHealth.Lock()
+	defer providerHealth.Unlock()
+
+	b := providerBreaker(pc.Name)
+	if b.Healthy {
+		return nil
+	}
+	now := time.Now()
+	if now.Before(*b.NextAttempt) {
+		return fmt.Errorf("%w: %q, after %d consecutive failures, next attempt in %s, last error: %s", errProviderUnhealthy, pc.Name, b.ConsecutiveFailures, b.NextAttempt.Sub(now).Round(time.Second), b.LastError)
+	}
+	next := now.Add(b.pause)
+	b.NextAttempt = &next
+	return nil
+}
+
+// providerResult updates the health of the provider config after an operation.
+func providerResult(pc ProviderConfig, err error) {
+	if pc.Name == "" || errors.Is(err, context.Canceled) {
+		return
+	}
+
+	providerHealth.Lock()
+	defer providerHealth.Unlock()
+
+	b := providerBreaker(pc.Name)
+	now := time.Now()
+
+	if err == nil || !isTransientError(err) {
+		if !b.Healthy {
+			slog.Info("provider config healthy again", "providerconfig", pc.Name, "unhealthysince", b.UnhealthySince)
+		}
+		b.Healthy = true
+		b.ConsecutiveFailures = 0
+		b.UnhealthySince = nil
+		b.NextAttempt = nil
+		b.pause = 0
+		metricProviderHealthy.WithLabelValues(pc.Name).Set(1)
+		return
+	}
+
+	b.ConsecutiveFailures++
+	b.LastError = err.Error()
+	b.LastErrorTime = &now
+	if pc.FailureThreshold <= 0 || b.ConsecutiveFailures < pc.FailureThreshold {
+		return
+	}
+	if b.Healthy {
+		b.Healthy = false
+		b.UnhealthySince = &now
+		b.pause = breakerFirstPause
+		slog.Warn("provider config unhealthy, pausing operations", "providerconfig", pc.Name, "failures", b.ConsecutiveFailures, "pause", b.pause, "err", err)
+	} else {
+		b.pause = min(2*b.pause, breakerMaxPause)
+		slog.Info("provider config still unhealthy", "providerconfig", pc.Name, "failures", b.ConsecutiveFailures, "pause", b.pause, "err", err)
+	}
+	next := now.Add(b.pause)
+	b.NextAttempt = &next
+	metricProviderHealthy.WithLabelValues(pc.Name).Set(0)
+}
+
+// providerPaused returns whether automatic operations for the provider config are
+// paused, and until when.
+func providerPaused(name string) (time.Time, bool) {
+	providerHealth.Lock()
+	defer providerHealth.Unlock()
+
+	b := providerHealth.configs[name]
+	if b == nil || b.Healthy || !time.Now().Before(*b.NextAttempt) {
+		return time.Time{}, false
+	}
+	return *b.NextAttempt, true
+}
+
+// providerHealthReset forgets the health of a provider config, e.g. after its
+// configuration was changed.
+func providerHealthReset(name string) {
+	providerHealth.Lock()
+	defer providerHealth.Unlock()
+
+	delete(providerHealth.configs, name)
+	metricProviderHealthy.DeleteLabelValues(name)
+}
+
+// providerHealthList returns the health of provider configs that have been used,
+// sorted by name.
+func providerHealthList() []ProviderHealth {
+	providerHealth.Lock()
+	defer providerHealth.Unlock()
+
+	l := make([]ProviderHealth, 0, len(providerHealth.configs))
+	for _, b := range providerHealth.configs {
+		l = append(l, b.ProviderHealth)
+	}
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].ProviderConfigName < l[j].ProviderConfigName
+	})
+	return l
+}
+
+// Most libdns providers don't return typed errors for HTTP responses, but include
+// the status code in the error message, e.g. "unexpected status code 503",
+// "HTTP/1.1 502" or "504 Gateway Timeout".
+var httpServerErrorRegexp = regexp.MustCompile(`(?i)(status|http|code)(/[0-9.]+)?[^0-9]{0,20}5[0-9][0-9]\b|\b5[0-9][0-9] (internal server error|bad gateway|service unavailable|gateway time-?out)`)
+
+// Errors of some providers, e.g. those based on the AWS SDK, expose the HTTP
+// status code.
+type httpStatusCoder interface {
+	HTTPStatusCode() int
+}
+
+// isTransientError returns whether err is likely to be resolved by trying again
+// later: timeouts, connection errors and HTTP 5xx responses.
+func isTransientError(err error) bool {
+	if errors.Is(err, errProviderUnhealthy) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var statusErr httpStatusCoder
+	if errors.As(err, &statusErr) {
+		return statusErr.HTTPStatusCode()/100 == 5
+	}
+	return httpServerErrorRegexp.MatchString(err.Error())
+}
+
+// retryDelay returns the delay before the next retry after a failed attempt
+// (starting at 0).
+func retryDelay(attempt int) time.Duration {
+	return min(retryFirstDelay<<attempt, retryMaxDelay)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestTransientError(t *testing.T) {
+	tcompare(t, isTransientError(context.DeadlineExceeded), true)
+	tcompare(t, isTransientError(fmt.Errorf("get records: %w", context.DeadlineExceeded)), true)
+	tcompare(t, isTransientError(errors.New("unexpected status code 503: service unavailable")), true)
+	tcompare(t, isTransientError(errors.New("HTTP 502 Bad Gateway")), true)
+	tcompare(t, isTransientError(errors.New("unexpected status code 404")), false)
+	tcompare(t, isTransientError(errors.New("invalid record 500.example")), false)
+	tcompare(t, isTransientError(fmt.Errorf("%w: status 503", errProviderUnhealthy)), false)
+
+	// Error strings as returned by providers.
+	tcompare(t, isTransientError(errors.New("get records: HTTP/1.1 502 Bad Gateway")), true)
+	tcompare(t, isTransientError(errors.New(`unexpected status code 503: {"detail": "Service unavailable"}`)), true)
+	tcompare(t, isTransientError(errors.New("request failed: 504 Gateway Timeout: upstream request timeout")), true)
+	tcompare(t, isTransientError(errors.New("got error status: HTTP 500: internal error")), true)
+	tcompare(t, isTransientError(errors.New("HTTP/1.1 401 Unauthorized")), false)
+	tcompare(t, isTransientError(errors.New("invalid ttl 500 for record")), false)
+
+	// Status code exposed by the error.
+	tcompare(t, isTransientError(fmt.Errorf("list records: %w", testStatusError(503))), true)
+	tcompare(t, isTransientError(fmt.Errorf("list records: %w", testStatusError(403))), false)
+}
+
+// testStatusError exposes an HTTP status code, like errors of the AWS SDK.
+type testStatusError int
+
+func (e testStatusError) Error() string       { return "operation error" }
+func (e testStatusError) HTTPStatusCode() int { return int(e) }
+
+func TestProviderHealth(t *testing.T) {
+	defer func(d time.Duration) {
+		retryFirstDelay = d
+	}(retryFirstDelay)
+	retryFirstDelay = time.Millisecond
+
+	// errUser prevents stack traces for sherpa server errors.
+	errUnavailable := fmt.Errorf("%w: unexpected status code 503", errUser)
+
+	testDNS(t, func(te testEnv, z Zone) {
+		defer providerHealthReset(te.z0.pc.Name)
+
+		pc := te.z0.pc
+		pc.Retries = 1
+		pc.FailureThreshold = 2
+		te.api.ProviderConfigUpdate(ctxbg, pc)
+
+		// Transient error is retried.
+		te.z0.p.Errors = []error{errUnavailable}
+		te.api.ZoneRefresh(ctxbg, z.Name)
+		tcompare(t, len(te.z0.p.Errors), 0)
+
+		// Failing twice, including retry, counts as one failure.
+		te.z0.p.Errors = []error{errUnavailable, errUnavailable}
+		te.sherpaError("user:error", func() {
+			te.api.ZoneRefresh(ctxbg, z.Name)
+		})
+		health := func() ProviderHealth {
+			for _, h := range te.api.ProviderHealth(ctxbg) {
+				if h.ProviderConfigName == pc.Name {
+					return h
+				}
+			}
+			t.Fatalf("no health for provider config")
+			return ProviderHealth{}
+		}
+		h := health()
+		tcompare(t, h.Healthy, true)
+		tcompare(t, h.ConsecutiveFailures, 1)
+
+		// Second failure marks provider config unhealthy.
+		te.z0.p.Errors = []error{errUnavailable, errUnavailable}
+		te.sherpaError("user:error", func() {
+			te.api.ZoneRefresh(ctxbg, z.Name)
+		})
+		tcompare(t, health().Healthy, false)
+		_, paused := providerPaused(pc.Name)
+		tcompare(t, paused, true)
+
+		// Operations now fail fast, without calling the provider.
+		te.sherpaError("server:providerUnhealthy", func() {
+			te.api.ZoneRefresh(ctxbg, z.Name)
+		})
+		c := dns.Client{Net: "tcp-tls", TLSConfig: te.z0.tlsConfig}
+		tdc := dnsclient{t, &c, te.tlsaddr}
+		om := msgAXFR(z.Name)
+		im := tdc.exchange(om, nil, dns.RcodeServerFailure)
+		if testUseEDNS0 {
+			opt := im.IsEdns0()
+			tcompare(t, opt != nil && len(opt.Option) == 1, true)
+			tcompare(t, opt.Option[0].(*dns.EDNS0_EDE).InfoCode, dns.ExtendedErrorCodeNotReady)
+		}
+
+		// After the pause, an attempt is made again. Success makes it healthy again.
+		providerHealth.Lock()
+		past := time.Now().Add(-time.Second)
+		providerHealth.configs[pc.Name].NextAttempt = &past
+		providerHealth.Unlock()
+		te.api.ZoneRefresh(ctxbg, z.Name)
+		h = health()
+		tcompare(t, h.Healthy, true)
+		tcompare(t, h.ConsecutiveFailures, 0)
+	})
+}
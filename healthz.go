@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/mjl-/bstore"
+)
+
+// Probes at /healthz and /readyz on the metrics listener. Both respond with a
+// JSON status, "ok" or "degraded" with the reasons. Readiness fails until the
+// database is open and all listeners are serving, and again during shutdown.
+// Health only fails when the refresher has stalled, which a restart may fix; a
+// failing provider does not, so it only degrades the status.
+
+// Time dnsclay became ready, as unix nanoseconds. Zero while starting up.
+var readySince atomic.Int64
+
+// Time of the last iteration of the refresher loop, as unix nanoseconds. The
+// refresher wakes up at least every refresherHeartbeat.
+var refresherActive atomic.Int64
+
+const refresherHeartbeat = time.Minute
+const refresherStallTimeout = 5 * refresherHeartbeat
+
+// Zones are stale when they haven't synced for this many sync intervals.
+const zoneStaleIntervals = 3
+
+// HealthStatus is the response of the health and readiness endpoints.
+type HealthStatus struct {
+	Status   string   // "starting", "ok", "degraded", "stopping".
+	Problems []string // Reasons for degraded status.
+
+	RefresherActive  *time.Time
+	RefresherStalled bool
+
+	Providers []ProviderHealth
+	Zones     []ZoneHealth
+}
+
+// ZoneHealth is the sync status of a zone.
+type ZoneHealth struct {
+	Name               string
+	ProviderConfigName string
+	LastSync           *time.Time
+	SyncInterval       time.Duration
+
+	// Not synced within zoneStaleIntervals sync intervals. Zones that haven't synced
+	// yet aren't stale until that time has passed since startup.
+	Stale bool
+}
+
+// healthStatus gathers the status from the database and provider health.
+func healthStatus(ctx context.Context) (HealthStatus, error) {
+	since := readySince.Load()
+	if shuttingDown() {
+		return HealthStatus{Status: "stopping"}, nil
+	} else if since == 0 {
+		return HealthStatus{Status: "starting"}, nil
+	}
+
+	hs := HealthStatus{Status: "ok"}
+	now := time.Now()
+
+	if active := refresherActive.Load(); active != 0 {
+		t := time.Unix(0, active)
+		hs.RefresherActive = &t
+		hs.RefresherStalled = now.Sub(t) > refresherStallTimeout
+	} else {
+		hs.RefresherStalled = now.Sub(time.Unix(0, since)) > refresherStallTimeout
+	}
+	if hs.RefresherStalled {
+		hs.Problems = append(hs.Problems, "refresher stalled")
+	}
+
+	var pcl []ProviderConfig
+	var zones []Zone
+	err := database.Read(ctx, func(tx *bstore.Tx) error {
+		var err error
+		pcl, err = bstore.QueryTx[ProviderConfig](tx).SortAsc("Name").List()
+		if err != nil {
+			return fmt.Errorf("listing provider configs: %v", err)
+		}
+		zones, err = bstore.QueryTx[Zone](tx).SortAsc("Name").List()
+		if err != nil {
+			return fmt.Errorf("listing zones: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return HealthStatus{}, err
+	}
+
+	// Provider configs that haven't been used yet are healthy.
+	health := map[string]ProviderHealth{}
+	for _, ph := range providerHealthList() {
+		health[ph.ProviderConfigName] = ph
+	}
+	for _, pc := range pcl {
+		ph, ok := health[pc.Name]
+		if !ok {
+			ph = ProviderHealth{ProviderConfigName: pc.Name, Healthy: true}
+		}
+		if !ph.Healthy {
+			hs.Problems = append(hs.Problems, fmt.Sprintf("provider config %s unhealthy: %s", pc.Name, ph.LastError))
+		}
+		hs.Providers = append(hs.Providers, ph)
+	}
+
+	for _, z := range zones {
+		zh := ZoneHealth{z.Name, z.ProviderConfigName, z.LastSync, z.SyncInterval, false}
+		staleAfter := zoneStaleIntervals * max(z.SyncInterval, time.Minute)
+		if z.LastSync != nil {
+			zh.Stale = now.Sub(*z.LastSync) > staleAfter
+		} else {
+			zh.Stale = now.Sub(time.Unix(0, since)) > staleAfter
+		}
+		if zh.Stale {
+			hs.Problems = append(hs.Problems, fmt.Sprintf("zone %s not synced within %d sync intervals", z.Name, zoneStaleIntervals))
+		}
+		hs.Zones = append(hs.Zones, zh)
+	}
+
+	if len(hs.Problems) > 0 {
+		hs.Status = "degraded"
+	}
+	return hs, nil
+}
+
+// healthzHandle responds with the status, failing only if the refresher stalled.
+func healthzHandle(w http.ResponseWriter, r *http.Request) {
+	healthRespond(w, r, func(hs HealthStatus) bool {
+		return !hs.RefresherStalled
+	})
+}
+
+// readyzHandle responds with the status, failing while starting or stopping.
+func readyzHandle(w http.ResponseWriter, r *http.Request) {
+	healthRespond(w, r, func(hs HealthStatus) bool {
+		return hs.Status == "ok" || hs.Status == "degraded"
+	})
+}
+
+func healthRespond(w http.ResponseWriter, r *http.Request, good func(hs HealthStatus) bool) {
+	log := cidlog(r.Context())
+
+	hs, err := healthStatus(r.Context())
+	if err != nil {
+		log.Error("gathering health status", "err", err)
+		http.Error(w, "500 - internal server error - gathering health status", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !good(hs) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	err = enc.Encode(hs)
+	if err != nil && !isClosed(err) {
+		log.Error("writing health status", "err", err)
+	}
+}
+
+// serveReadyMark marks dnsclay as ready, once the database is open and all
+// listeners are serving.
+func serveReadyMark() {
+	readySince.Store(time.Now().UnixNano())
+	slog.Debug("ready")
+}
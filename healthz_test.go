@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthz(t *testing.T) {
+	defer func() {
+		readySince.Store(0)
+		refresherActive.Store(0)
+	}()
+
+	testDNS(t, func(te testEnv, z Zone) {
+		check := func(handle http.HandlerFunc, expCode int, expStatus string) HealthStatus {
+			t.Helper()
+			rec := httptest.NewRecorder()
+			handle(rec, httptest.NewRequest("GET", "/", nil))
+			tcompare(t, rec.Code, expCode)
+			var hs HealthStatus
+			err := json.Unmarshal(rec.Body.Bytes(), &hs)
+			tcheck(t, err, "parsing health status")
+			tcompare(t, hs.Status, expStatus)
+			return hs
+		}
+
+		// Not ready yet.
+		readySince.Store(0)
+		check(readyzHandle, http.StatusServiceUnavailable, "starting")
+		check(healthzHandle, http.StatusOK, "starting")
+
+		serveReadyMark()
+		refresherActive.Store(time.Now().UnixNano())
+		hs := check(readyzHandle, http.StatusOK, "ok")
+		tcompare(t, len(hs.Providers) > 0, true)
+		tcompare(t, len(hs.Zones) > 0, true)
+		check(healthzHandle, http.StatusOK, "ok")
+
+		// Zone that hasn't synced for a long time degrades, but is still ready.
+		err := database.Get(ctxbg, &z)
+		tcheck(t, err, "get zone")
+		lastSync := time.Now().Add(-zoneStaleIntervals*max(z.SyncInterval, time.Minute) - time.Minute)
+		z.LastSync = &lastSync
+		err = database.Update(ctxbg, &z)
+		tcheck(t, err, "update zone")
+		hs = check(readyzHandle, http.StatusOK, "degraded")
+		tcompare(t, len(hs.Problems), 1)
+		for _, zh := range hs.Zones {
+			tcompare(t, zh.Stale, zh.Name == z.Name)
+		}
+
+		// Stalled refresher fails the health check.
+		refresherActive.Store(time.Now().Add(-2 * refresherStallTimeout).UnixNano())
+		hs = check(healthzHandle, http.StatusServiceUnavailable, "degraded")
+		tcompare(t, hs.RefresherStalled, true)
+	})
+}
@@ -157,7 +157,7 @@ func testHist(t *testing.T, inputs []input, outputs []output) {
 		}
 	}()
 
-	pc := ProviderConfig{"test", "fake", "{}"}
+	pc := ProviderConfig{Name: "test", ProviderName: "fake", ProviderConfigJSON: "{}"}
 	err = tx.Insert(&pc)
 	tcheck(t, err, "insert providerconfig")
 	z := Zone{Name: zone, ProviderConfigName: pc.Name}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// InMemoryProvider keeps records in memory, for testing integrations and the
+// propagation and sync logic of dnsclay without an account at a DNS operator.
+// Records are lost on restart. A zone is created on first use, with a SOA record
+// whose serial is incremented on each change.
+//
+// Faults can be injected deterministically: latency, eventual consistency,
+// fixed SOA serials (like Route53), periodic errors and unsupported record types.
+type InMemoryProvider struct {
+	Store            string `json:"store,omitempty"`             // Name of store with zones. Provider configs with the same store share zones.
+	LatencyMillis    int    `json:"latency_ms,omitempty"`        // Delay for each operation.
+	PropagationDelay int    `json:"propagation_delay,omitempty"` // Number of GetRecords calls after a change that still return the old records.
+	FixedSerial      bool   `json:"fixed_serial,omitempty"`      // Return SOA records with serial 1, like Route53.
+	ErrorEvery       int    `json:"error_every,omitempty"`       // Fail every n-th operation, for a zone.
+	ErrorTransient   bool   `json:"error_transient,omitempty"`   // Make the injected errors look like server errors, causing retries.
+	UnsupportedTypes string `json:"unsupported_types,omitempty"` // Comma-separated record types that cannot be added, e.g. "CAA,TLSA".
+}
+
+var _ libdnsProvider = (*InMemoryProvider)(nil)
+var _ libdns.ZoneLister = (*InMemoryProvider)(nil)
+
+type inmemoryZone struct {
+	records []libdns.Record // Current records, SOA first.
+	visible []libdns.Record // Returned by GetRecords, lags during propagation delay.
+	pending int             // Remaining GetRecords calls returning visible records.
+	ops     int             // Number of operations, for injecting errors.
+	nextID  int
+}
+
+var inmemory = struct {
+	sync.Mutex
+	stores map[string]map[string]*inmemoryZone // Store name, zone name.
+}{stores: map[string]map[string]*inmemoryZone{}}
+
+// op waits for the configured latency, and returns the zone, with the lock held,
+// or an injected error.
+func (p *InMemoryProvider) op(ctx context.Context, zone string) (*inmemoryZone, error) {
+	if p.LatencyMillis > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Duration(p.LatencyMillis) * time.Millisecond):
+		}
+	}
+
+	inmemory.Lock()
+	zones := inmemory.stores[p.Store]
+	if zones == nil {
+		zones = map[string]*inmemoryZone{}
+		inmemory.stores[p.Store] = zones
+	}
+	z := zones[zone]
+	if z == nil {
+		soa := libdns.Record{ID: "1", Type: "SOA", Name: "", Value: fmt.Sprintf("ns0.%s hostmaster.%s 1 3600 300 1209600 300", zone, zone), TTL: 5 * time.Minute}
+		z = &inmemoryZone{records: []libdns.Record{soa}, visible: []libdns.Record{soa}, nextID: 2}
+		zones[zone] = z
+	}
+
+	z.ops++
+	if p.ErrorEvery > 0 && z.ops%p.ErrorEvery == 0 {
+		inmemory.Unlock()
+		if p.ErrorTransient {
+			return nil, errors.New("inmemory: injected error: http status 503")
+		}
+		return nil, errors.New("inmemory: injected error")
+	}
+	return z, nil
+}
+
+// changed bumps the SOA serial and starts the propagation delay.
+func (p *InMemoryProvider) changed(z *inmemoryZone) {
+	soa := &z.records[0]
+	t := strings.Split(soa.Value, " ")
+	if serial, err := strconv.ParseUint(t[2], 10, 32); err == nil {
+		t[2] = fmt.Sprintf("%d", uint32(serial+1))
+		soa.Value = strings.Join(t, " ")
+	}
+	if p.PropagationDelay > 0 {
+		z.pending = p.PropagationDelay
+	} else {
+		z.visible = slices.Clone(z.records)
+	}
+}
+
+func (p *InMemoryProvider) check(recs []libdns.Record) error {
+	for _, r := range recs {
+		for _, t := range strings.Split(p.UnsupportedTypes, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), r.Type) {
+				return fmt.Errorf("inmemory: record type %s not supported", r.Type)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *InMemoryProvider) GetRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
+	z, err := p.op(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	defer inmemory.Unlock()
+
+	if z.pending > 0 {
+		z.pending--
+	} else {
+		z.visible = slices.Clone(z.records)
+	}
+	l := slices.Clone(z.visible)
+	if p.FixedSerial {
+		t := strings.Split(l[0].Value, " ")
+		t[2] = "1"
+		l[0].Value = strings.Join(t, " ")
+	}
+	return l, nil
+}
+
+func inmemoryMatch(a, b libdns.Record) bool {
+	if b.ID != "" {
+		return a.ID == b.ID
+	}
+	return strings.EqualFold(a.Name, b.Name) && strings.EqualFold(a.Type, b.Type) && a.Value == b.Value && (b.TTL == 0 || a.TTL == b.TTL)
+}
+
+func (p *InMemoryProvider) AppendRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	if err := p.check(recs); err != nil {
+		return nil, err
+	}
+	z, err := p.op(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	defer inmemory.Unlock()
+
+	var added []libdns.Record
+	for _, r := range recs {
+		r.ID = fmt.Sprintf("%d", z.nextID)
+		z.nextID++
+		z.records = append(z.records, r)
+		added = append(added, r)
+	}
+	if len(added) > 0 {
+		p.changed(z)
+	}
+	return added, nil
+}
+
+// SetRecords replaces the records of the rrsets (name and type) of recs. A SOA
+// record replaces the SOA record, including its serial.
+func (p *InMemoryProvider) SetRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	if err := p.check(recs); err != nil {
+		return nil, err
+	}
+	z, err := p.op(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	defer inmemory.Unlock()
+
+	var soa *libdns.Record
+	for _, r := range recs {
+		z.records = slices.DeleteFunc(z.records, func(x libdns.Record) bool {
+			return x.Type != "SOA" && strings.EqualFold(x.Name, r.Name) && strings.EqualFold(x.Type, r.Type)
+		})
+	}
+	var l []libdns.Record
+	for _, r := range recs {
+		r.ID = fmt.Sprintf("%d", z.nextID)
+		z.nextID++
+		if strings.EqualFold(r.Type, "SOA") && r.Name == "" {
+			soa = &r
+		} else {
+			z.records = append(z.records, r)
+		}
+		l = append(l, r)
+	}
+	if len(l) == 0 {
+		return nil, nil
+	}
+	p.changed(z)
+	if soa != nil {
+		z.records[0] = *soa
+		if p.PropagationDelay == 0 {
+			z.visible = slices.Clone(z.records)
+		}
+	}
+	return l, nil
+}
+
+func (p *InMemoryProvider) DeleteRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	z, err := p.op(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	defer inmemory.Unlock()
+
+	var deleted []libdns.Record
+	for _, r := range recs {
+		i := slices.IndexFunc(z.records[1:], func(x libdns.Record) bool { return inmemoryMatch(x, r) })
+		if i >= 0 {
+			deleted = append(deleted, z.records[1+i])
+			z.records = slices.Delete(z.records, 1+i, 2+i)
+		}
+	}
+	if len(deleted) > 0 {
+		p.changed(z)
+	}
+	return deleted, nil
+}
+
+// ListZones returns the zones in the store that have been used.
+func (p *InMemoryProvider) ListZones(ctx context.Context) ([]libdns.Zone, error) {
+	inmemory.Lock()
+	defer inmemory.Unlock()
+	var l []libdns.Zone
+	for name := range inmemory.stores[p.Store] {
+		l = append(l, libdns.Zone{Name: name})
+	}
+	slices.SortFunc(l, func(a, b libdns.Zone) int { return strings.Compare(a.Name, b.Name) })
+	return l, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/libdns/libdns"
+)
+
+func TestInMemory(t *testing.T) {
+	zone := "example.com."
+
+	p, err := providerForConfig("inmemory", `{"store": "test", "propagation_delay": 2, "fixed_serial": true, "unsupported_types": "CAA"}`)
+	tcheck(t, err, "provider for config")
+
+	soa := func(serial string) libdns.Record {
+		return ldr("1", "", 300, "SOA", "ns0.example.com. hostmaster.example.com. "+serial+" 3600 300 1209600 300")
+	}
+
+	recs, err := p.GetRecords(ctxbg, zone)
+	tcheck(t, err, "get records")
+	tcompare(t, recs, []libdns.Record{soa("1")})
+
+	added, err := p.AppendRecords(ctxbg, zone, []libdns.Record{ldr("", "testhost", 300, "A", "10.0.0.1")})
+	tcheck(t, err, "append records")
+	tcompare(t, added, []libdns.Record{ldr("2", "testhost", 300, "A", "10.0.0.1")})
+
+	// Change becomes visible after the propagation delay.
+	for range 2 {
+		recs, err = p.GetRecords(ctxbg, zone)
+		tcheck(t, err, "get records")
+		tcompare(t, len(recs), 1)
+	}
+	recs, err = p.GetRecords(ctxbg, zone)
+	tcheck(t, err, "get records")
+	tcompare(t, recs, []libdns.Record{soa("1"), ldr("2", "testhost", 300, "A", "10.0.0.1")})
+
+	// Without fixed serial, the serial was incremented. Stores are shared.
+	p2, err := providerForConfig("inmemory", `{"store": "test"}`)
+	tcheck(t, err, "provider for config")
+	recs, err = p2.GetRecords(ctxbg, zone)
+	tcheck(t, err, "get records")
+	tcompare(t, recs[0], soa("2"))
+
+	// Unsupported types.
+	_, err = p.AppendRecords(ctxbg, zone, []libdns.Record{ldr("", "", 300, "CAA", `0 issue "letsencrypt.org"`)})
+	if err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Fatalf("got err %v, expected unsupported type error", err)
+	}
+
+	// Set and delete.
+	_, err = p2.SetRecords(ctxbg, zone, []libdns.Record{ldr("", "testhost", 300, "A", "10.0.0.2")})
+	tcheck(t, err, "set records")
+	deleted, err := p2.DeleteRecords(ctxbg, zone, []libdns.Record{ldr("", "testhost", 0, "A", "10.0.0.2")})
+	tcheck(t, err, "delete records")
+	tcompare(t, len(deleted), 1)
+	recs, err = p2.GetRecords(ctxbg, zone)
+	tcheck(t, err, "get records")
+	tcompare(t, recs, []libdns.Record{soa("4")})
+
+	zones, err := p2.ListZones(ctxbg)
+	tcheck(t, err, "list zones")
+	tcompare(t, zones, []libdns.Zone{{Name: zone}})
+
+	// Injected errors, every third operation for the zone.
+	p3, err := providerForConfig("inmemory", `{"store": "errors", "error_every": 3, "error_transient": true}`)
+	tcheck(t, err, "provider for config")
+	for i := range 6 {
+		_, err := p3.GetRecords(ctxbg, zone)
+		if (i%3 == 2) != (err != nil) {
+			t.Fatalf("operation %d: got err %v", i, err)
+		}
+		if err != nil && !isTransientError(err) {
+			t.Fatalf("got err %v, expected transient error", err)
+		}
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"crypto"
 	"crypto/ed25519"
 	cryptorand "crypto/rand"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/pem"
 	"errors"
 	"fmt"
@@ -13,6 +15,8 @@ import (
 	"log/slog"
 	"math/big"
 	"os"
+	"slices"
+	"sync"
 	"time"
 )
 
@@ -93,18 +97,164 @@ func xreadcert(path string, privKey crypto.Signer) tls.Certificate {
 // xminimalCert generates a minimal certificate for the private key, with no fields
 // other than required certificate serial (so no expires, constraints, names).
 func xminimalCert(privKey crypto.Signer) tls.Certificate {
+	c, err := minimalCert(privKey)
+	xcheckf(err, "creating minimal certificate")
+	return c
+}
+
+func minimalCert(privKey crypto.Signer) (tls.Certificate, error) {
 	template := &x509.Certificate{
 		// Required field.
 		SerialNumber: big.NewInt(time.Now().Unix()),
 	}
 	certBuf, err := x509.CreateCertificate(cryptorand.Reader, template, template, privKey.Public(), privKey)
-	xcheckf(err, "creating minimal certificate")
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("creating certificate: %v", err)
+	}
 	cert, err := x509.ParseCertificate(certBuf)
-	xcheckf(err, "parsing certificate")
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("parsing certificate: %v", err)
+	}
 	c := tls.Certificate{
 		Certificate: [][]byte{certBuf},
 		PrivateKey:  privKey,
 		Leaf:        cert,
 	}
-	return c
+	return c, nil
+}
+
+// loadCertFiles returns a function that reads a certificate and private key from
+// pem files. If certPath is empty, a minimal certificate is generated for the
+// private key, which must be pkcs#8.
+func loadCertFiles(certPath, keyPath string) func() (tls.Certificate, error) {
+	return func() (tls.Certificate, error) {
+		if certPath != "" {
+			return tls.LoadX509KeyPair(certPath, keyPath)
+		}
+		keypem, err := os.ReadFile(keyPath)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		b, _ := pem.Decode(keypem)
+		if b == nil || b.Type != "PRIVATE KEY" {
+			return tls.Certificate{}, fmt.Errorf("no pkcs#8 private key in %s", keyPath)
+		}
+		privKey, err := x509.ParsePKCS8PrivateKey(b.Bytes)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("parsing pkcs#8 private key: %v", err)
+		}
+		signer, ok := privKey.(crypto.Signer)
+		if !ok {
+			return tls.Certificate{}, fmt.Errorf("private key is %T, not a signer", privKey)
+		}
+		return minimalCert(signer)
+	}
+}
+
+// tlsPublicKeyHash returns the base64url-encoded sha256 hash of the public key of
+// the certificate, as used for pinning by clients.
+func tlsPublicKeyHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// How often certReloader checks if certificate files have changed.
+var certReloadInterval = 10 * time.Second
+
+// certReloader provides a certificate for tls.Config.GetCertificate, loaded from
+// files, and loaded again when the files change. After a change, the previous
+// certificate can still be offered for a transition period, to clients that
+// cannot use the new certificate, e.g. because its key type changed.
+type certReloader struct {
+	paths      []string // Files checked for changes.
+	load       func() (tls.Certificate, error)
+	transition time.Duration
+
+	sync.Mutex
+	cert          *tls.Certificate // Nil if not yet loaded, e.g. while waiting for acme.
+	previous      *tls.Certificate
+	previousUntil time.Time
+	checked       time.Time
+	modTimes      []time.Time
+}
+
+// newCertReloader returns a certReloader that calls load when any of the paths
+// changes. If allowMissing is set, absent files are not an error, and a
+// certificate will be loaded once they appear.
+func newCertReloader(paths []string, load func() (tls.Certificate, error), transition time.Duration, allowMissing bool) (*certReloader, error) {
+	cr := &certReloader{paths: paths, load: load, transition: transition, checked: time.Now()}
+	if err := cr.reload(); err != nil && (!allowMissing || !errors.Is(err, fs.ErrNotExist)) {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// reload loads the certificate if the files have been modified since the last
+// load. Must be called with lock held.
+func (cr *certReloader) reload() error {
+	var modTimes []time.Time
+	for _, p := range cr.paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		modTimes = append(modTimes, fi.ModTime())
+	}
+	if cr.cert != nil && slices.Equal(modTimes, cr.modTimes) {
+		return nil
+	}
+	cert, err := cr.load()
+	if err != nil {
+		return fmt.Errorf("loading certificate: %w", err)
+	}
+	if cr.cert != nil && cr.transition > 0 {
+		cr.previous = cr.cert
+		cr.previousUntil = time.Now().Add(cr.transition)
+	}
+	cr.cert = &cert
+	cr.modTimes = modTimes
+	slog.Info("loaded tls certificate", "path", cr.paths[0], "names", cert.Leaf.DNSNames, "notafter", cert.Leaf.NotAfter, "tlspubkeyhash", tlsPublicKeyHash(cert.Leaf))
+	return nil
+}
+
+// GetCertificate returns the current certificate, loading it again if the files
+// have changed. If loading fails, the previous certificate is kept.
+func (cr *certReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.Lock()
+	defer cr.Unlock()
+	if time.Since(cr.checked) >= certReloadInterval {
+		cr.checked = time.Now()
+		if err := cr.reload(); err != nil {
+			slog.Error("reloading tls certificate, keeping previous", "err", err, "path", cr.paths[0])
+		}
+	}
+	if cr.cert == nil {
+		return nil, fmt.Errorf("no tls certificate available yet")
+	}
+	if cr.previous != nil && time.Now().After(cr.previousUntil) {
+		cr.previous = nil
+	}
+	if cr.previous != nil && hello != nil && hello.SupportsCertificate(cr.cert) != nil && hello.SupportsCertificate(cr.previous) == nil {
+		return cr.previous, nil
+	}
+	return cr.cert, nil
+}
+
+// leaf returns the current certificate, or nil.
+func (cr *certReloader) leaf() *x509.Certificate {
+	cr.Lock()
+	defer cr.Unlock()
+	if cr.cert == nil {
+		return nil
+	}
+	return cr.cert.Leaf
+}
+
+// check loads the certificate if the files changed, without waiting for the
+// reload interval.
+func (cr *certReloader) check() error {
+	cr.Lock()
+	defer cr.Unlock()
+	cr.checked = time.Now()
+	return cr.reload()
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate and its key to a single file.
+func writeTestCert(t *testing.T, path, name string, key crypto.Signer, modTime time.Time) {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(cryptorand.Reader, template, template, key.Public(), key)
+	tcheck(t, err, "create certificate")
+	keyder, err := x509.MarshalPKCS8PrivateKey(key)
+	tcheck(t, err, "marshal key")
+	buf := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyder})
+	buf = append(buf, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	err = os.WriteFile(path, buf, 0600)
+	tcheck(t, err, "write cert")
+	err = os.Chtimes(path, modTime, modTime)
+	tcheck(t, err, "set mtime")
+}
+
+func TestCertReloader(t *testing.T) {
+	certReloadInterval = 0
+	defer func() { certReloadInterval = 10 * time.Second }()
+
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	tcheck(t, err, "generate key")
+	_, edKey, err := ed25519.GenerateKey(cryptorand.Reader)
+	tcheck(t, err, "generate key")
+
+	_, err = newCertReloader([]string{path}, loadCertFiles(path, path), 0, false)
+	if err == nil {
+		t.Fatalf("missing cert file not an error")
+	}
+	cr, err := newCertReloader([]string{path}, loadCertFiles(path, path), time.Hour, true)
+	tcheck(t, err, "cert reloader with missing files")
+	_, err = cr.GetCertificate(nil)
+	if err == nil {
+		t.Fatalf("got certificate before it was written")
+	}
+
+	now := time.Now()
+	writeTestCert(t, path, "a.example", edKey, now.Add(-time.Minute))
+	cert, err := cr.GetCertificate(nil)
+	tcheck(t, err, "get certificate")
+	tcompare(t, cert.Leaf.DNSNames, []string{"a.example"})
+
+	// Changed file is loaded again.
+	writeTestCert(t, path, "b.example", ecKey, now)
+	cert, err = cr.GetCertificate(nil)
+	tcheck(t, err, "get certificate")
+	tcompare(t, cert.Leaf.DNSNames, []string{"b.example"})
+
+	// During the transition period, clients that only support the previous key type
+	// get the previous certificate.
+	edOnly := &tls.ClientHelloInfo{SupportedVersions: []uint16{tls.VersionTLS13}, SignatureSchemes: []tls.SignatureScheme{tls.Ed25519}}
+	cert, err = cr.GetCertificate(edOnly)
+	tcheck(t, err, "get certificate")
+	tcompare(t, cert.Leaf.DNSNames, []string{"a.example"})
+	cr.previousUntil = time.Now().Add(-time.Second)
+	cert, err = cr.GetCertificate(edOnly)
+	tcheck(t, err, "get certificate")
+	tcompare(t, cert.Leaf.DNSNames, []string{"b.example"})
+
+	// Bad file keeps the previous certificate.
+	err = os.WriteFile(path, []byte("bogus"), 0600)
+	tcheck(t, err, "write bad cert")
+	err = os.Chtimes(path, now.Add(time.Minute), now.Add(time.Minute))
+	tcheck(t, err, "set mtime")
+	cert, err = cr.GetCertificate(nil)
+	tcheck(t, err, "get certificate")
+	tcompare(t, cert.Leaf.DNSNames, []string{"b.example"})
+
+	// Without certificate file, a minimal certificate is made for the key.
+	keyPath := filepath.Join(t.TempDir(), "key.pem")
+	writeTestCert(t, keyPath, "c.example", edKey, now)
+	cr, err = newCertReloader([]string{keyPath}, loadCertFiles("", keyPath), 0, false)
+	tcheck(t, err, "cert reloader for key")
+	cert, err = cr.GetCertificate(nil)
+	tcheck(t, err, "get certificate")
+	tcompare(t, len(cert.Leaf.DNSNames), 0)
+	minimal := xminimalCert(edKey)
+	tcompare(t, tlsPublicKeyHash(cert.Leaf), tlsPublicKeyHash(minimal.Leaf))
+}
@@ -4,22 +4,25 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/libdns/libdns"
 )
 
 // getRecords gets records from the provider and adds a SOA fetched directly from
-// the authoritative name server if not present in the records from the provider.
-// The separately fetched SOA record is not DNSSEC-verified.
+// the authoritative name server if not present in the records from the provider,
+// or if the provider is known to not return one (NoSOA capability). The
+// separately fetched SOA record is not DNSSEC-verified.
 func getRecords(ctx context.Context, log *slog.Logger, provider Provider, zone string, needSOA bool) ([]libdns.Record, error) {
 	records, err := provider.GetRecords(ctx, zone)
 	if err != nil {
 		return nil, fmt.Errorf("get records: %w", err)
 	}
 
-	if !needSOA {
+	if !needSOA && !providerCapabilities[provider.name].NoSOA {
 		for _, r := range records {
 			if r.Name == "" && strings.EqualFold(r.Type, "SOA") {
 				return records, nil
@@ -42,3 +45,74 @@ func getRecords(ctx context.Context, log *slog.Logger, provider Provider, zone s
 	records = append(records, lrsoa)
 	return records, nil
 }
+
+// Records recently fetched from the provider, per zone. Only used for zones with a
+// RecordsFreshness window. Entries are removed when records for a zone are
+// changed through the provider.
+var recordsCache = struct {
+	sync.Mutex
+	zones map[string]cachedRecords
+}{zones: map[string]cachedRecords{}}
+
+type cachedRecords struct {
+	ProviderConfigName string
+	Fetched            time.Time // Start of the fetch.
+	Records            []libdns.Record
+}
+
+// getZoneRecords returns the latest records for a zone. If fresh is false and
+// records were fetched from the provider within the RecordsFreshness window of the
+// zone, those records are returned without contacting the provider. Otherwise,
+// records are fetched with getRecords and remembered for later calls.
+//
+// Must be called with the zone lock held. Concurrent requests for a zone wait for
+// the lock, and can then reuse the records fetched by the request that held it.
+func getZoneRecords(ctx context.Context, log *slog.Logger, provider Provider, z Zone, fresh bool) ([]libdns.Record, error) {
+	if !fresh && z.RecordsFreshness > 0 {
+		recordsCache.Lock()
+		cr, ok := recordsCache.zones[z.Name]
+		recordsCache.Unlock()
+		if ok && cr.ProviderConfigName == z.ProviderConfigName && time.Since(cr.Fetched) < z.RecordsFreshness {
+			log.Debug("using cached records", "zone", z.Name, "age", time.Since(cr.Fetched))
+			metricRecordsCacheHits.Inc()
+			return slices.Clone(cr.Records), nil
+		}
+	}
+
+	t0 := time.Now()
+	records, err := getRecords(ctx, log, provider, z.Name, false)
+	if err != nil {
+		metricZoneSyncErrors.WithLabelValues(z.Name).Inc()
+		recordsCacheClear(z.Name)
+		return nil, err
+	}
+
+	recordsCache.Lock()
+	defer recordsCache.Unlock()
+	if z.RecordsFreshness > 0 {
+		recordsCache.zones[z.Name] = cachedRecords{z.ProviderConfigName, t0, slices.Clone(records)}
+	} else {
+		delete(recordsCache.zones, z.Name)
+	}
+	return records, nil
+}
+
+// recordsCacheClear removes cached records for the zone, e.g. after changing
+// records through the provider.
+func recordsCacheClear(zone string) {
+	recordsCache.Lock()
+	defer recordsCache.Unlock()
+	delete(recordsCache.zones, zone)
+}
+
+// recordsCacheClearProviderConfig removes cached records for all zones of the
+// provider config, e.g. after its credentials changed.
+func recordsCacheClearProviderConfig(name string) {
+	recordsCache.Lock()
+	defer recordsCache.Unlock()
+	for zone, cr := range recordsCache.zones {
+		if cr.ProviderConfigName == name {
+			delete(recordsCache.zones, zone)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Sockets passed through systemd socket activation, by name. The names, set with
+// FileDescriptorName in the socket unit, are the names of the flags for the
+// addresses. Sockets are removed when used, so unused sockets can be detected.
+var activatedFiles map[string][]*os.File
+
+// Names of flags of addresses that can be passed through socket activation.
+var activationNames = []string{"dns-udpaddr", "dns-upxfr-tcpaddr", "dns-notify-tcpaddr", "dns-upxfr-tlsaddr", "dns-notify-tlsaddr", "adminaddr", "metricsaddr"}
+
+// activated returns whether sockets were passed for the flag name.
+func activated(name string) bool {
+	return len(activatedFiles[name]) > 0
+}
+
+// xlistenTCP returns listeners for the sockets passed for the flag name through
+// socket activation, or listens on each of the comma-separated addresses.
+func xlistenTCP(name, addrs string) []net.Listener {
+	var l []net.Listener
+	if files, ok := activatedFiles[name]; ok {
+		delete(activatedFiles, name)
+		for _, f := range files {
+			conn, err := net.FileListener(f)
+			xcheckf(err, "tcp listener from socket activation for %s", name)
+			f.Close()
+			slog.Debug("using socket from socket activation", "name", name, "addr", conn.Addr())
+			l = append(l, conn)
+		}
+		return l
+	}
+	if addrs == "" {
+		return nil
+	}
+	for _, addr := range strings.Split(addrs, ",") {
+		conn, err := net.Listen("tcp", addr)
+		xcheckf(err, "listen on tcp %s for %s", addr, name)
+		l = append(l, conn)
+	}
+	return l
+}
+
+// xlistenUDP is like xlistenTCP, but for UDP.
+func xlistenUDP(name, addrs string) []net.PacketConn {
+	var l []net.PacketConn
+	if files, ok := activatedFiles[name]; ok {
+		delete(activatedFiles, name)
+		for _, f := range files {
+			conn, err := net.FilePacketConn(f)
+			xcheckf(err, "udp socket from socket activation for %s", name)
+			f.Close()
+			slog.Debug("using socket from socket activation", "name", name, "addr", conn.LocalAddr())
+			l = append(l, conn)
+		}
+		return l
+	}
+	if addrs == "" {
+		return nil
+	}
+	for _, addr := range strings.Split(addrs, ",") {
+		conn, err := net.ListenPacket("udp", addr)
+		xcheckf(err, "listen on udp %s for %s", addr, name)
+		l = append(l, conn)
+	}
+	return l
+}
+
+// activationCheckUnused returns an error if sockets were passed through socket
+// activation that were not used, typically due to a misspelled name.
+func activationCheckUnused() error {
+	if len(activatedFiles) == 0 {
+		return nil
+	}
+	names := slices.Sorted(maps.Keys(activatedFiles))
+	return fmt.Errorf("unknown names %s for sockets from socket activation, set FileDescriptorName in the socket unit to one of %s", strings.Join(names, ", "), strings.Join(activationNames, ", "))
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// Socket activation is systemd-specific.
+func socketActivation() (map[string][]*os.File, error) {
+	return nil, nil
+}
+
+func dropPrivileges(username string) error {
+	return errors.New("changing user not supported on this platform")
+}
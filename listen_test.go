@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net"
+	"os"
+	"testing"
+)
+
+func TestListenActivated(t *testing.T) {
+	defer func() {
+		activatedFiles = nil
+	}()
+
+	// Socket as systemd would pass it.
+	ln, err := net.Listen("tcp", "localhost:0")
+	tcheck(t, err, "listen")
+	defer ln.Close()
+	f, err := ln.(*net.TCPListener).File()
+	tcheck(t, err, "file for listener")
+	activatedFiles = map[string][]*os.File{
+		"adminaddr": {f},
+		"bogus":     {},
+	}
+
+	tcompare(t, activated("adminaddr"), true)
+	tcompare(t, activated("metricsaddr"), false)
+
+	// Address is ignored for activated socket.
+	l := xlistenTCP("adminaddr", "localhost:1")
+	tcompare(t, len(l), 1)
+	defer l[0].Close()
+	tcompare(t, l[0].Addr().String(), ln.Addr().String())
+	tcompare(t, activated("adminaddr"), false)
+
+	// Not activated, so listen on the address.
+	l = xlistenTCP("metricsaddr", "localhost:0")
+	tcompare(t, len(l), 1)
+	l[0].Close()
+
+	// Unused name.
+	if err := activationCheckUnused(); err == nil {
+		t.Fatalf("no error for unused socket name")
+	}
+	delete(activatedFiles, "bogus")
+	err = activationCheckUnused()
+	tcheck(t, err, "checking unused sockets")
+}
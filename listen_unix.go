@@ -0,0 +1,78 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// socketActivation returns the sockets passed by systemd through the LISTEN_PID,
+// LISTEN_FDS and LISTEN_FDNAMES environment variables, see sd_listen_fds(3). The
+// environment variables are cleared so they aren't passed to commands we run.
+func socketActivation() (map[string][]*os.File, error) {
+	pid := os.Getenv("LISTEN_PID")
+	fds := os.Getenv("LISTEN_FDS")
+	fdnames := os.Getenv("LISTEN_FDNAMES")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if fds == "" || pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("bad LISTEN_FDS %q", fds)
+	}
+	names := strings.Split(fdnames, ":")
+	if fdnames == "" || len(names) != n {
+		return nil, fmt.Errorf("LISTEN_FDNAMES %q does not have a name for each of the %d sockets, set FileDescriptorName in the socket unit", fdnames, n)
+	}
+	files := map[string][]*os.File{}
+	for i, name := range names {
+		// First passed file descriptor is 3, after stdin, stdout and stderr.
+		fd := 3 + i
+		syscall.CloseOnExec(fd)
+		files[name] = append(files[name], os.NewFile(uintptr(fd), name))
+	}
+	return files, nil
+}
+
+// dropPrivileges changes to the uid and gid of the user, after opening sockets
+// on privileged ports as root. If already running as the user, nothing changes.
+func dropPrivileges(username string) error {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return fmt.Errorf("looking up user: %v", err)
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("parsing uid %q: %v", u.Uid, err)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return fmt.Errorf("parsing gid %q: %v", u.Gid, err)
+	}
+	if os.Getuid() == uid {
+		return nil
+	} else if os.Getuid() != 0 {
+		return fmt.Errorf("must be root to change to user %q, running as uid %d", username, os.Getuid())
+	}
+
+	// Group first, we can't change it after changing the user.
+	if err := syscall.Setgroups([]int{gid}); err != nil {
+		return fmt.Errorf("setgroups: %v", err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("setgid: %v", err)
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("setuid: %v", err)
+	}
+	return nil
+}
@@ -30,43 +30,93 @@ import (
 
 type Provider struct {
 	name string
+
+	// Set for providers for a stored provider config, used for retries and circuit
+	// breaker. Zero when testing a config.
+	config ProviderConfig
+
 	libdnsProvider
 }
 
-func (p Provider) withMetric(op string, fn func() ([]libdns.Record, error)) ([]libdns.Record, error) {
-	t0 := time.Now()
-	l, err := fn()
-	metricProviderOp.WithLabelValues(p.name, op).Observe(float64(time.Since(t0) / time.Second))
-	if err != nil {
+// withMetric calls fn for a provider operation, with metrics, tracing, retries and
+// circuit breaker.
+func withMetric[T any](ctx context.Context, p Provider, op string, fn func() (T, error)) (l T, err error) {
+	_, span := spanStart(ctx, "provider "+op, spanClient, "provider", p.name, "providerconfig", p.config.Name)
+	var attempt int
+	defer func() {
+		span.finish(err, "attempts", attempt+1)
+	}()
+
+	if err := providerAllow(p.config); err != nil {
 		metricProviderOpErrors.WithLabelValues(p.name, op).Inc()
+		return l, err
+	}
+
+	for ; ; attempt++ {
+		t0 := time.Now()
+		l, err = fn()
+		metricProviderOp.WithLabelValues(p.name, op).Observe(float64(time.Since(t0) / time.Second))
+		if err != nil {
+			metricProviderOpErrors.WithLabelValues(p.name, op).Inc()
+		}
+		if err == nil || attempt >= p.config.Retries || op == "append" || !isTransientError(err) {
+			break
+		}
+
+		slog.Debug("retrying provider operation after transient error", "providerconfig", p.config.Name, "op", op, "attempt", attempt, "err", err)
+		metricProviderOpRetries.WithLabelValues(p.name, op).Inc()
+		select {
+		case <-ctx.Done():
+			providerResult(p.config, err)
+			return l, err
+		case <-time.After(retryDelay(attempt)):
+		}
 	}
+	providerResult(p.config, err)
 	return l, err
 }
 
 func (p Provider) AppendRecords(ctx context.Context, zone string, recs []libdns.Record) (l []libdns.Record, err error) {
-	return p.withMetric("append", func() ([]libdns.Record, error) {
+	defer recordsCacheClear(zone)
+	return withMetric(ctx, p, "append", func() ([]libdns.Record, error) {
 		return p.libdnsProvider.AppendRecords(ctx, zone, recs)
 	})
 }
 
 func (p Provider) DeleteRecords(ctx context.Context, zone string, recs []libdns.Record) (l []libdns.Record, err error) {
-	return p.withMetric("delete", func() ([]libdns.Record, error) {
+	defer recordsCacheClear(zone)
+	return withMetric(ctx, p, "delete", func() ([]libdns.Record, error) {
 		return p.libdnsProvider.DeleteRecords(ctx, zone, recs)
 	})
 }
 
 func (p Provider) SetRecords(ctx context.Context, zone string, recs []libdns.Record) (l []libdns.Record, err error) {
-	return p.withMetric("set", func() ([]libdns.Record, error) {
+	defer recordsCacheClear(zone)
+	return withMetric(ctx, p, "set", func() ([]libdns.Record, error) {
 		return p.libdnsProvider.SetRecords(ctx, zone, recs)
 	})
 }
 
 func (p Provider) GetRecords(ctx context.Context, zone string) (l []libdns.Record, err error) {
-	return p.withMetric("get", func() ([]libdns.Record, error) {
+	return withMetric(ctx, p, "get", func() ([]libdns.Record, error) {
 		return p.libdnsProvider.GetRecords(ctx, zone)
 	})
 }
 
+var errZoneListUnsupported = errors.New("provider cannot list zones")
+
+// ListZones returns the zones at the provider, if the provider implements
+// libdns.ZoneLister.
+func (p Provider) ListZones(ctx context.Context) ([]libdns.Zone, error) {
+	zl, ok := p.libdnsProvider.(libdns.ZoneLister)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", errZoneListUnsupported, p.name)
+	}
+	return withMetric(ctx, p, "listzones", func() ([]libdns.Zone, error) {
+		return zl.ListZones(ctx)
+	})
+}
+
 type libdnsProvider interface {
 	libdns.RecordAppender
 	libdns.RecordDeleter
@@ -93,9 +143,11 @@ func main() {
 	flag.Usage = func() {
 		log.Printf("usage: dnsclay serve [flags]")
 		log.Printf("       dnsclay genkey >privkey-ed25519.pkcs8.pem")
+		log.Printf("       dnsclay gensecretkey >secretkeys")
 		log.Printf("       dnsclay dns [flags] notify [flags] addr zone")
 		log.Printf("       dnsclay dns [flags] update [flags] addr zone [add name type ttl value | del...] ...")
 		log.Printf("       dnsclay dns [flags] xfr [flags] addr zone")
+		log.Printf("       dnsclay config check dnsclay.conf")
 		log.Printf("       dnsclay version")
 		log.Printf("       dnsclay license")
 		flag.PrintDefaults()
@@ -113,12 +165,18 @@ func main() {
 	case "genkey":
 		cmdGenkey(args)
 
+	case "gensecretkey":
+		cmdGensecretkey(args)
+
 	case "serve":
 		cmdServe(args)
 
 	case "dns":
 		cmdDNS(args)
 
+	case "config":
+		cmdConfig(args)
+
 	case "version":
 		if len(args) != 0 {
 			flag.Usage()
@@ -236,18 +294,24 @@ func _cleanAbsName(s string) string {
 
 var errProviderUserError = errors.New("bad provider")
 
-// providerForConfig parses a JSON config into a provider.
+// providerForConfig parses a JSON config into a provider. Secret references in
+// string values are resolved.
 func providerForConfig(name string, configJSON string) (Provider, error) {
 	p, ok := providers[name]
 	if !ok {
 		return Provider{}, fmt.Errorf("%w: unknown provider %q", errProviderUserError, name)
 	}
 
+	configJSON, err := secretRefsResolve(configJSON)
+	if err != nil {
+		return Provider{}, fmt.Errorf("%w: %v", errProviderUserError, err)
+	}
+
 	t := reflect.TypeOf(p)
 	v := reflect.New(t)
 	dec := json.NewDecoder(strings.NewReader(configJSON))
 	dec.DisallowUnknownFields()
-	err := dec.Decode(v.Interface())
+	err = dec.Decode(v.Interface())
 	if err != nil {
 		return Provider{}, fmt.Errorf("%w: parsing provider config: %v", errProviderUserError, err)
 	}
@@ -256,7 +320,33 @@ func providerForConfig(name string, configJSON string) (Provider, error) {
 	if !ok {
 		return Provider{}, fmt.Errorf("provider %q with type %T does not implement provider interface", name, p)
 	}
-	return Provider{name, provider}, nil
+	if c, ok := p.(providerConfigChecker); ok {
+		if err := c.checkConfig(); err != nil {
+			return Provider{}, fmt.Errorf("%w: checking provider config: %v", errProviderUserError, err)
+		}
+	}
+	return Provider{name: name, libdnsProvider: provider}, nil
+}
+
+// providerConfigChecker is implemented by providers that check their config
+// when it is parsed, instead of failing on first use.
+type providerConfigChecker interface {
+	checkConfig() error
+}
+
+// configProvider returns a provider for a stored provider config, with retries and
+// circuit breaker. The provider config is decrypted if needed.
+func configProvider(pc ProviderConfig) (Provider, error) {
+	configJSON, err := secretDecrypt(pc.ProviderConfigJSON)
+	if err != nil {
+		return Provider{}, fmt.Errorf("provider config %q: %w", pc.Name, err)
+	}
+	p, err := providerForConfig(pc.ProviderName, configJSON)
+	if err != nil {
+		return Provider{}, err
+	}
+	p.config = pc
+	return p, nil
 }
 
 func zoneProvider(tx *bstore.Tx, zone string) (Zone, Provider, error) {
@@ -270,7 +360,7 @@ func zoneProvider(tx *bstore.Tx, zone string) (Zone, Provider, error) {
 		return Zone{}, Provider{}, err
 	}
 
-	p, err := providerForConfig(pc.ProviderName, pc.ProviderConfigJSON)
+	p, err := configProvider(pc)
 	if err != nil {
 		return Zone{}, Provider{}, err
 	}
@@ -13,6 +13,7 @@ import (
 	"net"
 	"os"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -52,9 +53,13 @@ type fakeProvider struct {
 	AbsNames    bool // If set, GetRecords returns absolute names.
 	FixedSerial bool // If set, serial is fixed, always 1.
 	NoSOA       bool // If set, GetRecords does not return a SOA record.
+	RRSets      bool // If set, SetRecords replaces the rrsets (name and type) of the records.
 
 	sync.Mutex
 	Records []libdns.Record
+	Errors  []error  `json:"-"` // Returned by the next operations, one per operation.
+	Zones   []string `json:"-"` // Returned by ListZones.
+	Ops     []string `json:"-"` // Changing operations called: "append", "set", "delete".
 }
 
 var _ libdnsProvider = (*fakeProvider)(nil)
@@ -84,6 +89,9 @@ func (pp *fakeProvider) GetRecords(ctx context.Context, zone string) ([]libdns.R
 	}
 	p.Lock()
 	defer p.Unlock()
+	if err := p.nextError(); err != nil {
+		return nil, err
+	}
 	l := make([]libdns.Record, 0, len(p.Records))
 	for _, r := range p.Records {
 		if p.NoSOA && r.Type == "SOA" {
@@ -103,6 +111,33 @@ func (pp *fakeProvider) GetRecords(ctx context.Context, zone string) ([]libdns.R
 	return l, nil
 }
 
+func (pp *fakeProvider) ListZones(ctx context.Context) ([]libdns.Zone, error) {
+	p, err := getProvider(pp)
+	if err != nil {
+		return nil, err
+	}
+	p.Lock()
+	defer p.Unlock()
+	if err := p.nextError(); err != nil {
+		return nil, err
+	}
+	var l []libdns.Zone
+	for _, name := range p.Zones {
+		l = append(l, libdns.Zone{Name: name})
+	}
+	return l, nil
+}
+
+// nextError returns the error to fail the current operation with, if any.
+func (p *fakeProvider) nextError() error {
+	if len(p.Errors) == 0 {
+		return nil
+	}
+	err := p.Errors[0]
+	p.Errors = p.Errors[1:]
+	return err
+}
+
 func (p *fakeProvider) remove(r libdns.Record) bool {
 	i := p.find(r)
 	if i >= 0 {
@@ -163,6 +198,10 @@ func (pp *fakeProvider) DeleteRecords(ctx context.Context, zone string, l []libd
 	}
 	p.Lock()
 	defer p.Unlock()
+	if err := p.nextError(); err != nil {
+		return nil, err
+	}
+	p.Ops = append(p.Ops, "delete")
 	var deleted []libdns.Record
 	for _, r := range l {
 		if p.remove(r) {
@@ -183,6 +222,10 @@ func (pp *fakeProvider) AppendRecords(ctx context.Context, zone string, l []libd
 	}
 	p.Lock()
 	defer p.Unlock()
+	if err := p.nextError(); err != nil {
+		return nil, err
+	}
+	p.Ops = append(p.Ops, "append")
 	p.Records = append(p.Records, l...)
 	if len(l) > 0 {
 		p.changeSerial()
@@ -198,6 +241,20 @@ func (pp *fakeProvider) SetRecords(ctx context.Context, zone string, l []libdns.
 	}
 	p.Lock()
 	defer p.Unlock()
+	if err := p.nextError(); err != nil {
+		return nil, err
+	}
+	p.Ops = append(p.Ops, "set")
+	if p.RRSets {
+		for _, r := range l {
+			p.Records = slices.DeleteFunc(p.Records, func(x libdns.Record) bool {
+				return strings.EqualFold(x.Name, r.Name) && x.Type == r.Type
+			})
+		}
+		p.Records = append(p.Records, l...)
+		p.changeSerial()
+		return l, nil
+	}
 	var changed bool
 	for _, r := range l {
 		if r.Type == "CNAME" {
@@ -419,9 +476,10 @@ func testDNSProvider(t *testing.T, fn func(te testEnv, z Zone), absNames, fixedS
 	pc0 := ProviderConfig{Name: "z0.example", ProviderName: "fake", ProviderConfigJSON: `{"ID": "z0"}`}
 	pc0 = api.ProviderConfigAdd(ctxbg, pc0)
 	z0 := Zone{Name: "z0.example.", RefreshInterval: time.Hour, SyncInterval: 24 * time.Hour, ProviderConfigName: pc0.Name}
-	z0 = api.ZoneAdd(ctxbg, z0, []ZoneNotify{{0, time.Time{}, z0.Name, z0n.addr, "tcp"}})
+	z0 = api.ZoneAdd(ctxbg, z0, []ZoneNotify{{0, time.Time{}, z0.Name, z0n.addr, "tcp", false}})
 	z0n.wait()
 	z0, _, _, creds0, sets0 := api.Zone(ctxbg, z0.Name)
+	creds0[0].TSIGSecret = api.ZoneCredentialReveal(ctxbg, creds0[0].ID)
 	tcompare(t, len(sets0), 2)
 	rl0 := api.ZoneRecords(ctxbg, z0.Name)
 	tcompare(t, len(rl0), 3)
@@ -430,9 +488,10 @@ func testDNSProvider(t *testing.T, fn func(te testEnv, z Zone), absNames, fixedS
 	pc1 := ProviderConfig{Name: "z1.example", ProviderName: "fake", ProviderConfigJSON: `{"ID": "z1"}`}
 	pc1 = api.ProviderConfigAdd(ctxbg, pc1)
 	z1 := Zone{Name: "z1.example.", RefreshInterval: time.Hour, SyncInterval: 24 * time.Hour, ProviderConfigName: pc1.Name}
-	z1 = api.ZoneAdd(ctxbg, z1, []ZoneNotify{{0, time.Time{}, z1.Name, z1n.addr, "tcp"}})
+	z1 = api.ZoneAdd(ctxbg, z1, []ZoneNotify{{0, time.Time{}, z1.Name, z1n.addr, "tcp", false}})
 	z1n.wait()
 	z1, _, _, creds1, sets1 := api.Zone(ctxbg, z1.Name)
+	creds1[0].TSIGSecret = api.ZoneCredentialReveal(ctxbg, creds1[0].ID)
 	tcompare(t, len(sets1), 2) // SOA should have been created.
 	rl1 := api.ZoneRecords(ctxbg, z1.Name)
 	tcompare(t, len(rl1), 2)
@@ -458,7 +517,7 @@ func testDNSProvider(t *testing.T, fn func(te testEnv, z Zone), absNames, fixedS
 		r := sha256.Sum256(cert.Leaf.RawSubjectPublicKeyInfo)
 		fp := base64.RawURLEncoding.EncodeToString(r[:])
 
-		tlspubkey := api.ZoneCredentialAdd(ctxbg, z.Name, Credential{0, time.Time{}, name, "tlspubkey", "", fp})
+		tlspubkey := api.ZoneCredentialAdd(ctxbg, z.Name, Credential{0, time.Time{}, name, "tlspubkey", "", fp, false})
 		config := tls.Config{
 			InsecureSkipVerify: true,
 			Certificates:       []tls.Certificate{cert},
@@ -720,9 +779,7 @@ func TestMain(m *testing.M) {
 		return rr.(*dns.SOA), nil
 	}
 
-	propagationFirstWait = time.Second / 100
-
-	adminpassword = genpassword()
+	propagationWaits = []time.Duration{time.Second / 100, time.Second, 2 * time.Second, 3 * time.Second}
 
 	tlskeypemDefault = "testdata/dnsclay-test.ed25519.privkey-pkcs8.pem"
 	os.Remove(tlskeypemDefault)
@@ -733,7 +790,7 @@ func TestMain(m *testing.M) {
 	xcheckf(err, "writing cert")
 	tlsCert = xreadcert("testdata/dnsclay-test.ed25519.cert.pem", tlsPrivKey)
 
-	tlsConfig = tlsServerConfig(tlsCert)
+	tlsConfig = tlsServerConfig(func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return &tlsCert, nil })
 
 	testtcpconn, err = net.Listen("tcp", "127.0.0.1:0")
 	xcheckf(err, "listen tcp")
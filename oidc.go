@@ -0,0 +1,584 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+
+	"github.com/mjl-/bstore"
+)
+
+// With "serve -oidcconfig", users can log in to the admin web interface through
+// an OpenID Connect identity provider, using the authorization code flow with
+// PKCE. Users are created on first login, with a role and zones based on the
+// groups in the ID token. The role and zones are updated at each login.
+//
+// /auth/oidc/login redirects to the identity provider, which redirects back to
+// /auth/oidc/callback. The callback starts a session like a password login, and
+// responds with a page that stores the CSRF token for the frontend.
+
+const cookieOIDC = "dnsclayoidc"
+
+// oidcConfig is the JSON file with the OpenID Connect configuration.
+type oidcConfig struct {
+	Issuer        string   // E.g. "https://idp.example.com", must match the "iss" claim. Used for discovery of the endpoints.
+	ClientID      string   // As registered at the identity provider.
+	ClientSecret  string   // Optional, for confidential clients. Can be a secret reference (env:, file:, exec:), resolved regardless of -secretrefs.
+	RedirectURL   string   // URL of /auth/oidc/callback at the admin interface, as registered at the identity provider.
+	Scopes        []string // Requested in addition to "openid", "profile" and "email", e.g. "groups".
+	UsernameClaim string   // Claim with the username, default "preferred_username", falling back to "email".
+	GroupsClaim   string   // Claim with a list of groups, default "groups".
+	Roles         []oidcRole
+}
+
+// oidcRole maps a group to a role. If a user is in multiple groups, the role with
+// the most privileges is used, and editors get the zones of all their groups.
+// Users without a matching group cannot log in.
+type oidcRole struct {
+	Group string // Group in the groups claim. Empty matches all users.
+	Role  Role
+	Zones []string // For role editor.
+}
+
+// oidc is set when OpenID Connect is configured.
+var oidc *oidcClient
+
+type oidcClient struct {
+	config oidcConfig
+
+	sync.Mutex
+	metadata    *oidcMetadata
+	keys        map[string]any // Public keys by key ID, from the JWKS.
+	keysFetched time.Time
+	logins      map[string]oidcLogin // Pending logins, by state.
+}
+
+// oidcMetadata is the part of the discovery document we use.
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcLogin struct {
+	verifier string // PKCE.
+	nonce    string
+	expires  time.Time
+}
+
+// oidcParse reads and checks the OpenID Connect configuration file.
+func oidcParse(path string) (oidcConfig, error) {
+	var c oidcConfig
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return c, fmt.Errorf("reading oidc config file: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return c, fmt.Errorf("parsing oidc config file %s: %w", path, err)
+	}
+
+	var errs []error
+	if c.Issuer == "" {
+		errs = append(errs, errors.New("missing issuer"))
+	}
+	if c.ClientID == "" {
+		errs = append(errs, errors.New("missing client id"))
+	}
+	if c.RedirectURL == "" {
+		errs = append(errs, errors.New("missing redirect url"))
+	}
+	if len(c.Roles) == 0 {
+		errs = append(errs, errors.New("missing roles, no user would be able to log in"))
+	}
+	for i, r := range c.Roles {
+		switch r.Role {
+		case RoleViewer, RoleAdmin:
+			if len(r.Zones) > 0 {
+				errs = append(errs, fmt.Errorf("role %d: zones only allowed for role editor", i))
+			}
+		case RoleEditor:
+			for j, zone := range r.Zones {
+				c.Roles[i].Zones[j] = strings.ToLower(strings.TrimSuffix(zone, ".") + ".")
+			}
+		default:
+			errs = append(errs, fmt.Errorf("role %d: unknown role %q", i, r.Role))
+		}
+	}
+	if c.UsernameClaim == "" {
+		c.UsernameClaim = "preferred_username"
+	}
+	if c.GroupsClaim == "" {
+		c.GroupsClaim = "groups"
+	}
+	return c, errors.Join(errs...)
+}
+
+func newOIDCClient(c oidcConfig) *oidcClient {
+	return &oidcClient{config: c, logins: map[string]oidcLogin{}}
+}
+
+// discover returns the metadata of the identity provider, fetching it on first
+// use.
+func (o *oidcClient) discover(ctx context.Context) (oidcMetadata, error) {
+	o.Lock()
+	md := o.metadata
+	o.Unlock()
+	if md != nil {
+		return *md, nil
+	}
+
+	var nmd oidcMetadata
+	url := strings.TrimSuffix(o.config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := oidcGetJSON(ctx, url, &nmd); err != nil {
+		return nmd, fmt.Errorf("fetching discovery document: %w", err)
+	}
+	if nmd.Issuer != o.config.Issuer {
+		return nmd, fmt.Errorf("discovery document has issuer %q, expected %q", nmd.Issuer, o.config.Issuer)
+	}
+	if nmd.AuthorizationEndpoint == "" || nmd.TokenEndpoint == "" || nmd.JWKSURI == "" {
+		return nmd, errors.New("discovery document is missing endpoints")
+	}
+	o.Lock()
+	o.metadata = &nmd
+	o.Unlock()
+	return nmd, nil
+}
+
+func oidcGetJSON(ctx context.Context, url string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("response status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (o *oidcClient) oauth2Config(md oidcMetadata) (*oauth2.Config, error) {
+	// The config file is managed by the operator, not through the admin interface, so
+	// references are always resolved.
+	secret := o.config.ClientSecret
+	if kind, _, ok := strings.Cut(secret, ":"); ok && slices.Contains(secretRefKinds, kind) {
+		var err error
+		secret, err = secretRefResolve(secret)
+		if err != nil {
+			return nil, fmt.Errorf("resolving client secret: %w", err)
+		}
+	}
+	return &oauth2.Config{
+		ClientID:     o.config.ClientID,
+		ClientSecret: secret,
+		Endpoint:     oauth2.Endpoint{AuthURL: md.AuthorizationEndpoint, TokenURL: md.TokenEndpoint},
+		RedirectURL:  o.config.RedirectURL,
+		Scopes:       append([]string{"openid", "profile", "email"}, o.config.Scopes...),
+	}, nil
+}
+
+// key returns the public key with id kid, fetching the keys again if the key is
+// not known, e.g. after key rotation at the identity provider.
+func (o *oidcClient) key(ctx context.Context, md oidcMetadata, kid string) (any, error) {
+	o.Lock()
+	k, ok := o.keys[kid]
+	if ok || time.Since(o.keysFetched) < time.Minute {
+		o.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		return k, nil
+	}
+	o.Unlock()
+
+	var jwks struct {
+		Keys []oidcJWK `json:"keys"`
+	}
+	if err := oidcGetJSON(ctx, md.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("fetching keys: %w", err)
+	}
+	keys := map[string]any{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		pk, err := jwk.publicKey()
+		if err != nil {
+			slog.Debug("skipping key from identity provider", "kid", jwk.KID, "err", err)
+			continue
+		}
+		keys[jwk.KID] = pk
+	}
+	o.Lock()
+	o.keys = keys
+	o.keysFetched = time.Now()
+	o.Unlock()
+
+	k, ok = keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return k, nil
+}
+
+// oidcJWK is a public key in a JSON Web Key Set, RFC 7517.
+type oidcJWK struct {
+	KTY string `json:"kty"`
+	KID string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`   // RSA
+	E   string `json:"e"`   // RSA
+	CRV string `json:"crv"` // EC, OKP
+	X   string `json:"x"`   // EC, OKP
+	Y   string `json:"y"`   // EC
+}
+
+func (k oidcJWK) publicKey() (any, error) {
+	num := func(s string) (*big.Int, error) {
+		buf, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(buf), nil
+	}
+
+	switch k.KTY {
+	case "RSA":
+		n, err := num(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("parsing modulus: %v", err)
+		}
+		e, err := num(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, fmt.Errorf("parsing exponent: %v", err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.CRV {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unknown curve %q", k.CRV)
+		}
+		x, err := num(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("parsing x: %v", err)
+		}
+		y, err := num(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("parsing y: %v", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.CRV != "Ed25519" {
+			return nil, fmt.Errorf("unknown curve %q", k.CRV)
+		}
+		buf, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(buf) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("parsing ed25519 key: %v", err)
+		}
+		return ed25519.PublicKey(buf), nil
+	}
+	return nil, fmt.Errorf("unknown key type %q", k.KTY)
+}
+
+// verifyIDToken checks the signature and claims of the ID token, and returns the
+// claims.
+func (o *oidcClient) verifyIDToken(ctx context.Context, md oidcMetadata, raw, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	keyfunc := func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return o.key(ctx, md, kid)
+	}
+	_, err := jwt.ParseWithClaims(raw, claims, keyfunc,
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(o.config.Issuer),
+		jwt.WithAudience(o.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := claims["nonce"].(string); subtle.ConstantTimeCompare([]byte(n), []byte(nonce)) != 1 {
+		return nil, errors.New("nonce mismatch")
+	}
+	return claims, nil
+}
+
+// mapRole returns the role and zones for the groups, and false if no role
+// matches.
+func (o *oidcClient) mapRole(groups []string) (Role, []string, bool) {
+	var role Role
+	var zones []string
+	rank := map[Role]int{RoleViewer: 1, RoleEditor: 2, RoleAdmin: 3}
+	for _, r := range o.config.Roles {
+		if r.Group != "" && !slices.Contains(groups, r.Group) {
+			continue
+		}
+		if rank[r.Role] > rank[role] {
+			role = r.Role
+		}
+		if r.Role == RoleEditor {
+			zones = append(zones, r.Zones...)
+		}
+	}
+	if role != RoleEditor {
+		zones = nil
+	}
+	slices.Sort(zones)
+	return role, slices.Compact(zones), role != ""
+}
+
+// claimStrings returns a claim that is a string or list of strings.
+func claimStrings(claims jwt.MapClaims, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var l []string
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				l = append(l, s)
+			}
+		}
+		return l
+	}
+	return nil
+}
+
+// oidcLoginHandler starts a login at the identity provider.
+func oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	log := cidlog(r.Context())
+	if oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	md, err := oidc.discover(r.Context())
+	if err != nil {
+		log.Error("oidc discovery", "err", err)
+		http.Error(w, "500 - internal server error - contacting identity provider", http.StatusInternalServerError)
+		return
+	}
+	oc, err := oidc.oauth2Config(md)
+	if err != nil {
+		log.Error("oidc config", "err", err)
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	state := genToken()
+	login := oidcLogin{oauth2.GenerateVerifier(), genToken(), time.Now().Add(10 * time.Minute)}
+	oidc.Lock()
+	for k, l := range oidc.logins {
+		if time.Now().After(l.expires) {
+			delete(oidc.logins, k)
+		}
+	}
+	oidc.logins[state] = login
+	oidc.Unlock()
+
+	// Lax, the identity provider redirects back to us with a top-level navigation.
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieOIDC,
+		Value:    state,
+		Path:     "/auth/oidc/",
+		MaxAge:   10 * 60,
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	url := oc.AuthCodeURL(state, oauth2.S256ChallengeOption(login.verifier), oauth2.SetAuthURLParam("nonce", login.nonce))
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// The session cookie is SameSite=Strict, and would not be sent when we redirect
+// from a navigation started at the identity provider. So we navigate with
+// JavaScript. The page also stores the CSRF token, like the login form does.
+var oidcDoneTemplate = template.Must(template.New("oidcdone").Parse(`<!doctype html>
+<html>
+	<head>
+		<meta charset="utf-8" />
+		<title>Dnsclay - Logged in</title>
+	</head>
+	<body>
+		<p>Logged in, continue to <a href="/">dnsclay</a>.</p>
+		<script>
+localStorage.setItem('dnsclaycsrftoken', {{ . }})
+location.href = '/'
+		</script>
+	</body>
+</html>
+`))
+
+// oidcCallbackHandler completes a login at the identity provider, and starts a
+// session.
+func oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	log := cidlog(r.Context())
+	if oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		log.Info("oidc login failed at identity provider", "error", e, "description", q.Get("error_description"))
+		http.Error(w, "400 - bad request - login failed at identity provider: "+e, http.StatusBadRequest)
+		return
+	}
+
+	state := q.Get("state")
+	c, err := r.Cookie(cookieOIDC)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(c.Value), []byte(state)) != 1 {
+		http.Error(w, "400 - bad request - missing or mismatched state, try again", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: cookieOIDC, Path: "/auth/oidc/", MaxAge: -1})
+	oidc.Lock()
+	login, ok := oidc.logins[state]
+	delete(oidc.logins, state)
+	oidc.Unlock()
+	if !ok || time.Now().After(login.expires) {
+		http.Error(w, "400 - bad request - login expired, try again", http.StatusBadRequest)
+		return
+	}
+
+	u, err := oidcExchange(r.Context(), log, q.Get("code"), login)
+	if err != nil {
+		log.Info("oidc login failed", "err", err)
+		code := http.StatusForbidden
+		if !errors.Is(err, errForbidden) {
+			code = http.StatusBadRequest
+		}
+		http.Error(w, fmt.Sprintf("%d - %s - login failed: %v", code, strings.ToLower(http.StatusText(code)), err), code)
+		return
+	}
+
+	csrfToken, err := sessionStart(r.Context(), w, r, u)
+	if err != nil {
+		log.Error("starting session", "err", err)
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	}
+	log.Info("login through oidc", "username", u.Username, "role", u.Role)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	err = oidcDoneTemplate.Execute(w, csrfToken)
+	logCheck(log, err, "writing oidc login response")
+}
+
+// oidcExchange exchanges the authorization code for tokens, verifies the ID
+// token, and returns the added or updated user.
+func oidcExchange(ctx context.Context, log *slog.Logger, code string, login oidcLogin) (User, error) {
+	md, err := oidc.discover(ctx)
+	if err != nil {
+		return User{}, err
+	}
+	oc, err := oidc.oauth2Config(md)
+	if err != nil {
+		return User{}, err
+	}
+	tok, err := oc.Exchange(ctx, code, oauth2.VerifierOption(login.verifier))
+	if err != nil {
+		return User{}, fmt.Errorf("exchanging code for token: %w", err)
+	}
+	raw, _ := tok.Extra("id_token").(string)
+	if raw == "" {
+		return User{}, errors.New("no id token in response")
+	}
+	claims, err := oidc.verifyIDToken(ctx, md, raw, login.nonce)
+	if err != nil {
+		return User{}, fmt.Errorf("verifying id token: %w", err)
+	}
+
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return User{}, errors.New("missing subject in id token")
+	}
+	username, _ := claims[oidc.config.UsernameClaim].(string)
+	if username == "" {
+		username, _ = claims["email"].(string)
+	}
+	if username == "" {
+		return User{}, errors.New("missing username in id token")
+	}
+	groups := claimStrings(claims, oidc.config.GroupsClaim)
+	role, zones, ok := oidc.mapRole(groups)
+	if !ok {
+		return User{}, fmt.Errorf("%w: no role for groups %v", errForbidden, groups)
+	}
+
+	var u User
+	err = database.Write(ctx, func(tx *bstore.Tx) error {
+		// Only zones that exist, so removed zones don't cause failed logins.
+		var nzones []string
+		for _, zone := range zones {
+			if err := tx.Get(&Zone{Name: zone}); err == nil {
+				nzones = append(nzones, zone)
+			} else if err == bstore.ErrAbsent {
+				log.Debug("ignoring unknown zone for oidc user", "zone", zone, "username", username)
+			} else {
+				return fmt.Errorf("get zone: %v", err)
+			}
+		}
+
+		subject := oidc.config.Issuer + " " + sub
+		u, err = bstore.QueryTx[User](tx).FilterNonzero(User{OIDCSubject: subject}).Get()
+		if err == bstore.ErrAbsent {
+			u = User{Username: username, OIDCSubject: subject}
+		} else if err != nil {
+			return fmt.Errorf("get user: %v", err)
+		}
+		if u.ID == 0 || u.Username != username {
+			exists, err := bstore.QueryTx[User](tx).FilterNonzero(User{Username: username}).Exists()
+			if err != nil {
+				return fmt.Errorf("checking username: %v", err)
+			} else if exists {
+				return fmt.Errorf("username %q already in use by another user", username)
+			}
+			u.Username = username
+		}
+		u.Role = role
+		u.Zones = nzones
+		if u.ID == 0 {
+			return tx.Insert(&u)
+		}
+		return tx.Update(&u)
+	})
+	return u, err
+}
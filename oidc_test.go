@@ -0,0 +1,233 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/miekg/dns"
+)
+
+// testIdP is a minimal OpenID Connect identity provider. It authorizes without
+// asking, for the user in its fields.
+type testIdP struct {
+	t        *testing.T
+	srv      *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+
+	sync.Mutex
+	sub      string
+	username string
+	groups   []string
+	badNonce bool
+	codes    map[string]testIdPCode
+}
+
+type testIdPCode struct {
+	challenge string
+	nonce     string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	tcheck(t, err, "generate key")
+	idp := &testIdP{t: t, key: key, clientID: "dnsclay", codes: map[string]testIdPCode{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.srv.URL,
+			"authorization_endpoint": idp.srv.URL + "/authorize",
+			"token_endpoint":         idp.srv.URL + "/token",
+			"jwks_uri":               idp.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		b64 := base64.RawURLEncoding.EncodeToString
+		jwk := map[string]string{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   b64(key.N.Bytes()),
+			"e":   b64(big.NewInt(int64(key.E)).Bytes()),
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": []any{jwk}})
+	})
+	mux.HandleFunc("GET /authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("response_type") != "code" || q.Get("client_id") != idp.clientID || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+			http.Error(w, "bad authorize request", http.StatusBadRequest)
+			return
+		}
+		code := genToken()
+		idp.Lock()
+		idp.codes[code] = testIdPCode{q.Get("code_challenge"), q.Get("nonce")}
+		idp.Unlock()
+		http.Redirect(w, r, q.Get("redirect_uri")+"?"+url.Values{"code": {code}, "state": {q.Get("state")}}.Encode(), http.StatusFound)
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		idp.Lock()
+		defer idp.Unlock()
+		code, ok := idp.codes[r.FormValue("code")]
+		delete(idp.codes, r.FormValue("code"))
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != code.challenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		nonce := code.nonce
+		if idp.badNonce {
+			nonce = "bogus"
+		}
+		claims := jwt.MapClaims{
+			"iss":                idp.srv.URL,
+			"sub":                idp.sub,
+			"aud":                idp.clientID,
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"iat":                time.Now().Unix(),
+			"nonce":              nonce,
+			"preferred_username": idp.username,
+			"groups":             idp.groups,
+		}
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		tok.Header["kid"] = "k1"
+		idToken, err := tok.SignedString(key)
+		tcheck(t, err, "sign id token")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "x", "token_type": "Bearer", "expires_in": 3600, "id_token": idToken})
+	})
+	idp.srv = httptest.NewServer(mux)
+	return idp
+}
+
+func (idp *testIdP) user(sub, username string, groups ...string) {
+	idp.Lock()
+	defer idp.Unlock()
+	idp.sub, idp.username, idp.groups = sub, username, groups
+}
+
+// oidcLogin logs in through the identity provider, following redirects, and
+// returns the CSRF token.
+func (wc *webClient) oidcLogin(expStatus int) {
+	t := wc.t
+	t.Helper()
+	resp, err := wc.c.Get(wc.url + "/auth/oidc/login")
+	tcheck(t, err, "oidc login")
+	defer resp.Body.Close()
+	buf, err := io.ReadAll(resp.Body)
+	tcheck(t, err, "read response")
+	if resp.StatusCode != expStatus {
+		t.Fatalf("oidc login: got status %d, expected %d (%s)", resp.StatusCode, expStatus, buf)
+	}
+	if expStatus != http.StatusOK {
+		return
+	}
+	m := regexp.MustCompile(`setItem\('dnsclaycsrftoken', "([^"]+)"\)`).FindSubmatch(buf)
+	if m == nil {
+		t.Fatalf("no csrf token in oidc login response: %s", buf)
+	}
+	wc.csrf = string(m[1])
+}
+
+func TestOIDC(t *testing.T) {
+	loginFailureDelay = 0
+	defer func() { loginFailureDelay = time.Second }()
+
+	idp := newTestIdP(t)
+	defer idp.srv.Close()
+
+	mux := testAdminMux()
+
+	testDNS(t, func(te testEnv, z Zone) {
+		ts := httptest.NewServer(mux)
+		defer ts.Close()
+
+		anon := newWebClient(t, ts.URL)
+		var enabled bool
+		anon.call("", &enabled, "OIDCEnabled")
+		tcompare(t, enabled, false)
+		anon.get("/auth/oidc/login", http.StatusNotFound)
+
+		oidc = newOIDCClient(oidcConfig{
+			Issuer:        idp.srv.URL,
+			ClientID:      idp.clientID,
+			RedirectURL:   ts.URL + "/auth/oidc/callback",
+			UsernameClaim: "preferred_username",
+			GroupsClaim:   "groups",
+			Roles: []oidcRole{
+				{"dnsadmins", RoleAdmin, nil},
+				{"dnseditors", RoleEditor, []string{z.Name, "removed.example."}},
+				{"staff", RoleViewer, nil},
+			},
+		})
+		defer func() { oidc = nil }()
+
+		anon.call("", &enabled, "OIDCEnabled")
+		tcompare(t, enabled, true)
+
+		// Callback needs the state from the cookie.
+		anon.get("/auth/oidc/callback?code=x&state=bogus", http.StatusBadRequest)
+
+		// User without matching group is refused.
+		idp.user("sub1", "alice", "other")
+		anon.oidcLogin(http.StatusForbidden)
+
+		// Editor, for zones that exist.
+		idp.user("sub1", "alice", "staff", "dnseditors")
+		editor := newWebClient(t, ts.URL)
+		editor.oidcLogin(http.StatusOK)
+		var u User
+		editor.call("", &u, "CurrentUser")
+		tcompare(t, u.Username, "alice")
+		tcompare(t, u.Role, RoleEditor)
+		tcompare(t, u.Zones, []string{z.Name})
+
+		rsc := RecordSetChange{"testhost2", 300, Type(dns.TypeA), []string{"10.0.0.3"}}
+		editor.call("user:forbidden", nil, "RecordSetAdd", te.z1.z.Name, rsc)
+		editor.call("", nil, "RecordSetAdd", z.Name, rsc)
+
+		// No password login for oidc users.
+		anon.login("alice", "", "user:loginFailed")
+
+		// Role is updated at next login, for the same user.
+		idp.user("sub1", "alice", "dnsadmins")
+		admin := newWebClient(t, ts.URL)
+		admin.oidcLogin(http.StatusOK)
+		var nu User
+		admin.call("", &nu, "CurrentUser")
+		tcompare(t, nu.ID, u.ID)
+		tcompare(t, nu.Role, RoleAdmin)
+		tcompare(t, len(nu.Zones), 0)
+
+		// Username of another user is refused.
+		var local User
+		admin.call("", &local, "UserAdd", User{Username: "bob", Role: RoleViewer}, "bobpassword")
+		idp.user("sub2", "bob", "staff")
+		anon.oidcLogin(http.StatusBadRequest)
+
+		// Nonce must match.
+		idp.user("sub1", "alice", "staff")
+		idp.Lock()
+		idp.badNonce = true
+		idp.Unlock()
+		anon.oidcLogin(http.StatusBadRequest)
+		idp.Lock()
+		idp.badNonce = false
+		idp.Unlock()
+	})
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// PluginProvider is a provider implemented by an external program, for DNS
+// operators without a libdns provider, or in-house DNS systems.
+//
+// For each operation, a JSON request is sent to the plugin, and a JSON response is
+// read. With Command, the program is started for each operation, the request is
+// written to its stdin and the response is read from its stdout. With Socket, a
+// connection is made to a unix domain socket, the request is written as a single
+// line, and the response is read from the connection.
+//
+// Request: {"Version": 1, "Method": "GetRecords", "Zone": "example.com.", "Records": [...], "Config": "..."}.
+// Methods are GetRecords, AppendRecords, SetRecords, DeleteRecords and ListZones.
+//
+// Response: {"Records": [...], "Zones": ["example.com."], "Error": ""}. Records
+// are returned for all methods but ListZones, and are the records in the zone
+// (GetRecords), or the records that were added/set/deleted. If Error is non-empty,
+// the operation failed.
+//
+// Records: {"ID": "...", "Type": "A", "Name": "www", "Value": "10.0.0.1", "TTL": 300, "Priority": 0, "Weight": 0, "Target": ""}.
+// Names are relative to the zone, "@" or empty for the zone apex. TTL is in
+// seconds.
+type PluginProvider struct {
+	Command string `json:"command,omitempty"` // Program and arguments, separated by whitespace. Started for each operation. Not run through a shell. The program must be allowed with -providercommands.
+	Socket  string `json:"socket,omitempty"`  // Path to unix domain socket to connect to for each operation.
+	Config  string `json:"config,omitempty"`  // Passed as is in each request, e.g. for credentials or plugin-specific settings.
+}
+
+const pluginVersion = 1
+
+// Programs that provider configs may run as command, from -providercommands.
+// Provider configs are entered in the admin interface, so no commands are allowed
+// by default.
+var providerCommandsAllowed []string
+
+// providerCommandArgs returns the program and arguments of command, or an error if
+// command is empty or its program is not allowed. Called when running the
+// command, not when checking a provider config: "dnsclay config check" doesn't
+// know the -providercommands of "dnsclay serve".
+func providerCommandArgs(command string) ([]string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%w: empty command", errProviderUserError)
+	} else if !slices.Contains(providerCommandsAllowed, args[0]) {
+		return nil, fmt.Errorf("%w: program %q not allowed by -providercommands", errProviderUserError, args[0])
+	}
+	return args, nil
+}
+
+type pluginRecord struct {
+	ID       string `json:",omitempty"`
+	Type     string
+	Name     string
+	Value    string
+	TTL      int64  // Seconds.
+	Priority uint   `json:",omitempty"`
+	Weight   uint   `json:",omitempty"`
+	Target   string `json:",omitempty"`
+}
+
+type pluginRequest struct {
+	Version int
+	Method  string
+	Zone    string         `json:",omitempty"`
+	Records []pluginRecord `json:",omitempty"`
+	Config  string         `json:",omitempty"`
+}
+
+type pluginResponse struct {
+	Records []pluginRecord
+	Zones   []string
+	Error   string
+}
+
+var _ libdnsProvider = (*PluginProvider)(nil)
+var _ libdns.ZoneLister = (*PluginProvider)(nil)
+var _ providerConfigChecker = (*PluginProvider)(nil)
+
+func (p *PluginProvider) checkConfig() error {
+	switch {
+	case p.Command != "" && p.Socket != "":
+		return errors.New("plugin provider config must have only one of command and socket")
+	case p.Command != "" && len(strings.Fields(p.Command)) == 0:
+		return errors.New("plugin provider config has empty command")
+	case p.Command != "", p.Socket != "":
+		return nil
+	default:
+		return errors.New("plugin provider config needs command or socket")
+	}
+}
+
+// call sends a request to the plugin and returns its response.
+func (p *PluginProvider) call(ctx context.Context, req pluginRequest) (pluginResponse, error) {
+	req.Version = pluginVersion
+	req.Config = p.Config
+	reqbuf, err := json.Marshal(req)
+	if err != nil {
+		return pluginResponse{}, fmt.Errorf("marshal plugin request: %v", err)
+	}
+
+	var respbuf []byte
+	switch {
+	case p.Command != "" && p.Socket != "":
+		return pluginResponse{}, errors.New("plugin provider config must have only one of command and socket")
+	case p.Command != "":
+		args, err := providerCommandArgs(p.Command)
+		if err != nil {
+			return pluginResponse{}, err
+		}
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stdin = bytes.NewReader(reqbuf)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			errmsg := strings.TrimSpace(stderr.String())
+			if len(errmsg) > 1024 {
+				errmsg = errmsg[:1024] + "..."
+			}
+			return pluginResponse{}, fmt.Errorf("running plugin %q: %w (%s)", args[0], err, errmsg)
+		}
+		respbuf = stdout.Bytes()
+	case p.Socket != "":
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "unix", p.Socket)
+		if err != nil {
+			return pluginResponse{}, fmt.Errorf("connecting to plugin: %w", err)
+		}
+		defer conn.Close()
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		} else {
+			conn.SetDeadline(time.Now().Add(time.Minute))
+		}
+		if _, err := conn.Write(append(reqbuf, '\n')); err != nil {
+			return pluginResponse{}, fmt.Errorf("writing plugin request: %w", err)
+		}
+		var resp pluginResponse
+		if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+			return pluginResponse{}, fmt.Errorf("reading plugin response: %w", err)
+		}
+		return resp, pluginError(resp)
+	default:
+		return pluginResponse{}, errors.New("plugin provider config needs command or socket")
+	}
+
+	var resp pluginResponse
+	if err := json.Unmarshal(respbuf, &resp); err != nil {
+		return pluginResponse{}, fmt.Errorf("parsing plugin response: %v", err)
+	}
+	return resp, pluginError(resp)
+}
+
+func pluginError(resp pluginResponse) error {
+	if resp.Error != "" {
+		return fmt.Errorf("plugin: %s", resp.Error)
+	}
+	return nil
+}
+
+func (p *PluginProvider) records(ctx context.Context, method, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	var prl []pluginRecord
+	for _, r := range recs {
+		prl = append(prl, pluginRecord{r.ID, r.Type, r.Name, r.Value, int64(r.TTL / time.Second), r.Priority, r.Weight, r.Target})
+	}
+	resp, err := p.call(ctx, pluginRequest{Method: method, Zone: zone, Records: prl})
+	if err != nil {
+		return nil, err
+	}
+	var l []libdns.Record
+	for _, r := range resp.Records {
+		l = append(l, libdns.Record{ID: r.ID, Type: r.Type, Name: r.Name, Value: r.Value, TTL: time.Duration(r.TTL) * time.Second, Priority: r.Priority, Weight: r.Weight, Target: r.Target})
+	}
+	return l, nil
+}
+
+func (p *PluginProvider) GetRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
+	return p.records(ctx, "GetRecords", zone, nil)
+}
+
+func (p *PluginProvider) AppendRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	return p.records(ctx, "AppendRecords", zone, recs)
+}
+
+func (p *PluginProvider) SetRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	return p.records(ctx, "SetRecords", zone, recs)
+}
+
+func (p *PluginProvider) DeleteRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	return p.records(ctx, "DeleteRecords", zone, recs)
+}
+
+func (p *PluginProvider) ListZones(ctx context.Context) ([]libdns.Zone, error) {
+	resp, err := p.call(ctx, pluginRequest{Method: "ListZones"})
+	if err != nil {
+		return nil, err
+	}
+	var l []libdns.Zone
+	for _, z := range resp.Zones {
+		l = append(l, libdns.Zone{Name: z})
+	}
+	return l, nil
+}
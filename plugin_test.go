@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// pluginServe handles a plugin request with a fake provider as backend.
+func pluginServe(backend *fakeProvider, req pluginRequest) (resp pluginResponse) {
+	var recs []libdns.Record
+	for _, r := range req.Records {
+		recs = append(recs, libdns.Record{ID: r.ID, Type: r.Type, Name: r.Name, Value: r.Value, TTL: time.Duration(r.TTL) * time.Second})
+	}
+	var err error
+	switch req.Method {
+	case "GetRecords":
+		recs, err = backend.GetRecords(ctxbg, req.Zone)
+	case "AppendRecords":
+		recs, err = backend.AppendRecords(ctxbg, req.Zone, recs)
+	case "SetRecords":
+		recs, err = backend.SetRecords(ctxbg, req.Zone, recs)
+	case "DeleteRecords":
+		recs, err = backend.DeleteRecords(ctxbg, req.Zone, recs)
+	case "ListZones":
+		var zones []libdns.Zone
+		zones, err = backend.ListZones(ctxbg)
+		for _, z := range zones {
+			resp.Zones = append(resp.Zones, z.Name)
+		}
+		recs = nil
+	default:
+		err = errors.New("unknown method")
+	}
+	if err != nil {
+		resp.Error = err.Error()
+		return
+	}
+	for _, r := range recs {
+		resp.Records = append(resp.Records, pluginRecord{r.ID, r.Type, r.Name, r.Value, int64(r.TTL / time.Second), 0, 0, ""})
+	}
+	return
+}
+
+// TestPluginHelper is run as plugin command by TestPlugin.
+func TestPluginHelper(t *testing.T) {
+	if os.Getenv("DNSCLAY_TEST_PLUGIN") == "" {
+		return
+	}
+	var req pluginRequest
+	err := json.NewDecoder(os.Stdin).Decode(&req)
+	tcheck(t, err, "read request")
+	backend := &fakeProvider{
+		ID:      "helper",
+		Records: []libdns.Record{ldr("1", "testhost", 300, "A", "10.0.0.1")},
+	}
+	newFakeProvider(backend)
+	if req.Config != "config" {
+		backend.Errors = []error{errors.New("bad config")}
+	}
+	err = json.NewEncoder(os.Stdout).Encode(pluginServe(backend, req))
+	tcheck(t, err, "write response")
+	os.Exit(0)
+}
+
+func TestPlugin(t *testing.T) {
+	zone := "example.com."
+
+	// Program started for each operation, if allowed.
+	t.Setenv("DNSCLAY_TEST_PLUGIN", "1")
+	p, err := providerForConfig("plugin", `{"command": "`+os.Args[0]+` -test.run=^TestPluginHelper$", "config": "config"}`)
+	tcheck(t, err, "provider for config")
+	_, err = p.GetRecords(ctxbg, zone)
+	if !errors.Is(err, errProviderUserError) {
+		t.Fatalf("got err %v, expected user error for program not allowed", err)
+	}
+	defer func(l []string) {
+		providerCommandsAllowed = l
+	}(providerCommandsAllowed)
+	providerCommandsAllowed = []string{os.Args[0]}
+	recs, err := p.GetRecords(ctxbg, zone)
+	tcheck(t, err, "get records")
+	tcompare(t, recs, []libdns.Record{ldr("1", "testhost", 300, "A", "10.0.0.1")})
+
+	p, err = providerForConfig("plugin", `{"command": "`+os.Args[0]+` -test.run=^TestPluginHelper$", "config": "other"}`)
+	tcheck(t, err, "provider for config")
+	_, err = p.GetRecords(ctxbg, zone)
+	if err == nil || err.Error() != "plugin: bad config" {
+		t.Fatalf("got err %v, expected plugin error", err)
+	}
+
+	// Unix domain socket.
+	backend := &fakeProvider{
+		ID:      "pluginsocket",
+		Records: []libdns.Record{ldr("1", "testhost", 300, "A", "10.0.0.1")},
+		Zones:   []string{zone},
+	}
+	newFakeProvider(backend)
+
+	path := filepath.Join(t.TempDir(), "plugin.sock")
+	ln, err := net.Listen("unix", path)
+	tcheck(t, err, "listen")
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			var req pluginRequest
+			if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err == nil {
+				json.NewEncoder(conn).Encode(pluginServe(backend, req))
+			}
+			conn.Close()
+		}
+	}()
+
+	p, err = providerForConfig("plugin", `{"socket": "`+path+`"}`)
+	tcheck(t, err, "provider for config")
+
+	added, err := p.AppendRecords(ctxbg, zone, []libdns.Record{ldr("", "other", 60, "TXT", "hi")})
+	tcheck(t, err, "append records")
+	tcompare(t, len(added), 1)
+	recs, err = p.GetRecords(ctxbg, zone)
+	tcheck(t, err, "get records")
+	tcompare(t, len(recs), 2)
+
+	_, err = p.DeleteRecords(ctxbg, zone, []libdns.Record{recs[0]})
+	tcheck(t, err, "delete records")
+	recs, err = p.GetRecords(ctxbg, zone)
+	tcheck(t, err, "get records")
+	tcompare(t, len(recs), 1)
+
+	zones, err := p.ListZones(ctxbg)
+	tcheck(t, err, "list zones")
+	tcompare(t, zones, []libdns.Zone{{Name: zone}})
+
+	// Invalid configs.
+	for _, config := range []string{`{}`, `{"command": " "}`, `{"command": "x", "socket": "x"}`} {
+		_, err = providerForConfig("plugin", config)
+		if !errors.Is(err, errProviderUserError) {
+			t.Fatalf("got err %v, expected user error for config %s", err, config)
+		}
+	}
+}
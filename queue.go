@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/libdns/libdns"
+
+	"github.com/mjl-/bstore"
+)
+
+// For zones with QueueUpdates, changes from DNS UPDATEs are stored as
+// QueuedChange and applied by a background worker. Changes for a zone are applied
+// one at a time, in order, in a goroutine per zone, so a slow provider or
+// propagation check for one zone doesn't hold up other zones. On failure, another
+// attempt is made after a delay that doubles with each attempt. After
+// queueMaxAttempts, the change is marked as failed. Later changes for the zone
+// are not applied until the failed change is retried or deleted through the admin
+// interface.
+
+const (
+	queueMaxAttempts = 10
+	queueFirstDelay  = 30 * time.Second
+	queueMaxDelay    = time.Hour
+)
+
+var queueReschedule = make(chan struct{}, 1)
+
+// Zones for which a goroutine is applying a queued change.
+var queueBusy = struct {
+	sync.Mutex
+	zones map[string]bool
+}{zones: map[string]bool{}}
+
+// For tests, to wait for goroutines applying queued changes.
+var queueActive sync.WaitGroup
+
+// queueKick makes the worker look for queued changes to apply.
+func queueKick() {
+	select {
+	case queueReschedule <- struct{}{}:
+	default:
+	}
+}
+
+// queueWorker applies queued changes, sleeping until the next attempt is due or a
+// change is queued.
+func queueWorker() {
+	log := slog.Default()
+
+	timer := time.NewTimer(0)
+	for {
+		select {
+		case <-shutdownCtx.Done():
+			return
+		case <-timer.C:
+		case <-queueReschedule:
+		}
+		if shuttingDown() {
+			return
+		}
+
+		opDone := operationStart("scheduling queued changes")
+		next := queueRun(log)
+		opDone()
+		if next.IsZero() {
+			timer.Stop()
+		} else {
+			timer.Reset(time.Until(next))
+		}
+	}
+}
+
+// queueRun starts a goroutine applying the first pending change of each zone, if
+// it is due and no change is being applied for the zone. Zones with a failed
+// change are skipped. queueRun returns when the worker should run again, or the
+// zero time if no changes are due later. Goroutines kick the worker when done.
+func queueRun(log *slog.Logger) (next time.Time) {
+	var first []QueuedChange
+	var npending, nfailed int
+	err := database.Read(shutdownCtx, func(tx *bstore.Tx) error {
+		seen := map[string]bool{}
+		return bstore.QueryTx[QueuedChange](tx).SortAsc("ID").ForEach(func(qc QueuedChange) error {
+			if qc.Failed {
+				nfailed++
+			} else {
+				npending++
+			}
+			if !seen[qc.Zone] {
+				seen[qc.Zone] = true
+				if !qc.Failed {
+					first = append(first, qc)
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		logCheck(log, err, "listing queued changes")
+		return time.Now().Add(queueFirstDelay)
+	}
+	metricQueuedChanges.WithLabelValues("pending").Set(float64(npending))
+	metricQueuedChanges.WithLabelValues("failed").Set(float64(nfailed))
+
+	now := time.Now()
+	for _, qc := range first {
+		if qc.NextAttempt.After(now) {
+			if next.IsZero() || qc.NextAttempt.Before(next) {
+				next = qc.NextAttempt
+			}
+			continue
+		}
+
+		queueBusy.Lock()
+		busy := queueBusy.zones[qc.Zone]
+		queueBusy.zones[qc.Zone] = true
+		queueBusy.Unlock()
+		if busy {
+			continue
+		}
+
+		opDone := operationStart("applying queued change for zone %s", qc.Zone)
+		queueActive.Add(1)
+		go func() {
+			defer queueActive.Done()
+			defer opDone()
+			defer func() {
+				queueBusy.Lock()
+				delete(queueBusy.zones, qc.Zone)
+				queueBusy.Unlock()
+
+				// More changes may be pending for the zone, and counts must be updated.
+				queueKick()
+			}()
+			defer recoverPanic(log, "applying queued change")
+
+			queueApply(log, qc)
+		}()
+	}
+	return next
+}
+
+// queueApply makes an attempt at applying a queued change. On failure, the next
+// attempt is scheduled, or the change is marked as failed.
+func queueApply(log *slog.Logger, qc QueuedChange) {
+	log = log.With("queuedchange", qc.ID, "zone", qc.Zone)
+
+	unlock := lockZone(qc.Zone)
+	defer unlock()
+
+	var z Zone
+	var provider Provider
+	var soa Record
+	err := database.Read(shutdownCtx, func(tx *bstore.Tx) error {
+		// Get again, it may have been changed or removed in the mean time.
+		if err := tx.Get(&qc); err != nil {
+			return fmt.Errorf("get queued change: %w", err)
+		}
+
+		var err error
+		z, provider, err = zoneProvider(tx, qc.Zone)
+		if err != nil {
+			return fmt.Errorf("get zone and provider: %w", err)
+		}
+		soa = zoneSOA(log, tx, z.Name)
+		return nil
+	})
+	if errors.Is(err, bstore.ErrAbsent) {
+		log.Debug("queued change or zone gone", "err", err)
+		return
+	} else if err == nil && qc.Failed {
+		return
+	}
+
+	var adds []Record
+	var removes []Record
+	if err == nil {
+		ctx, cancel := context.WithTimeout(shutdownCtx, 30*time.Second)
+		defer cancel()
+
+		// Apply each kind of change, storing progress so a next attempt only applies what
+		// remains.
+		apply := func(what string, l *[]Record, fn func(context.Context, *slog.Logger, Provider, string, []libdns.Record) ([]libdns.Record, error)) error {
+			if len(*l) == 0 {
+				return nil
+			}
+			if _, err := fn(ctx, log, provider, z.Name, libdnsRecords(*l)); err != nil {
+				return fmt.Errorf("%s records: %w", what, err)
+			}
+			for _, r := range *l {
+				if what == "deleting" {
+					removes = append(removes, r)
+				} else {
+					adds = append(adds, r)
+				}
+			}
+			*l = nil
+			if err := database.Update(shutdownCtx, &qc); err != nil {
+				return fmt.Errorf("storing progress of queued change: %w", err)
+			}
+			return nil
+		}
+
+		log.Debug("applying queued change", "add", qc.Add, "set", qc.Set, "delete", qc.Delete)
+		err = apply("adding", &qc.Add, appendRecords)
+		if err == nil {
+			err = apply("setting", &qc.Set, setRecords)
+		}
+		if err == nil {
+			err = apply("deleting", &qc.Delete, deleteRecords)
+		}
+	}
+
+	if err == nil {
+		if err := database.Delete(shutdownCtx, &qc); err != nil {
+			logCheck(log, err, "removing applied queued change")
+		}
+		log.Info("queued change applied")
+
+		_, _, err := ensurePropagate(shutdownCtx, log, provider, z, adds, removes, soa.SerialFirst, nil)
+		logCheck(log, err, "ensuring propagation of queued change")
+		return
+	}
+
+	metricQueuedChangeErrors.Inc()
+	now := time.Now()
+	qc.LastAttempt = &now
+	qc.LastError = err.Error()
+	if next, paused := providerPaused(z.ProviderConfigName); errors.Is(err, errProviderUnhealthy) && paused {
+		// Not counted as attempt, we wait until the provider config may be healthy again.
+		qc.NextAttempt = next
+	} else {
+		qc.Attempts++
+		if qc.Attempts >= queueMaxAttempts {
+			qc.Failed = true
+		} else {
+			qc.NextAttempt = now.Add(min(queueFirstDelay<<(qc.Attempts-1), queueMaxDelay))
+		}
+	}
+	if qc.Failed {
+		log.Error("applying queued change failed, giving up", "attempts", qc.Attempts, "err", err)
+	} else {
+		log.Info("applying queued change failed, will try again", "attempts", qc.Attempts, "next", qc.NextAttempt, "err", err)
+	}
+	if err := database.Update(shutdownCtx, &qc); err != nil {
+		logCheck(log, err, "storing failed attempt for queued change")
+	}
+}
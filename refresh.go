@@ -19,12 +19,17 @@ func refreshKick() {
 }
 
 // Refresher sleeps most of the time, until it is time to poll for a new SOA record
-// with a remote zone, or for a schedule full sync of the records.
+// with a remote zone, or for a schedule full sync of the records. It also renews
+// the acme certificate for the admin interface, if configured.
 func refresher() {
 	log := slog.Default()
 
 	sync := time.NewTimer(0)
 	soaCheck := time.NewTimer(0)
+	acmeCheck := time.NewTimer(0)
+	if adminACME == nil {
+		acmeCheck.Stop()
+	}
 
 	// Reschedule based on the first next time to check for a new SOA record or do a full sync.
 	reschedule := func() {
@@ -51,8 +56,17 @@ func refresher() {
 
 	reschedule()
 
+	// Wake up periodically, so a stalled loop can be detected by the health check.
+	heartbeat := time.NewTicker(refresherHeartbeat)
+	defer heartbeat.Stop()
+
 	for {
+		refresherActive.Store(time.Now().UnixNano())
+
 		select {
+		case <-heartbeat.C:
+			// Only for updating refresherActive.
+
 		case <-sync.C:
 			// Should be time to do a sync. Multiple zones may be ready. Fetch them and update
 			// their next times to sync.
@@ -60,13 +74,21 @@ func refresher() {
 			err := database.Write(shutdownCtx, func(tx *bstore.Tx) error {
 				now := time.Now()
 				var err error
-				zones, err = bstore.QueryTx[Zone](tx).FilterLessEqual("NextSync", now).List()
+				l, err := bstore.QueryTx[Zone](tx).FilterLessEqual("NextSync", now).List()
 				if err != nil {
 					return fmt.Errorf("listing zones to sync: %w", err)
 				}
 
-				for _, z := range zones {
-					z.NextSync = now.Add(max(z.SyncInterval, time.Minute))
+				for _, z := range l {
+					// While the provider config is unhealthy, we postpone the sync until the next
+					// attempt is allowed.
+					if next, paused := providerPaused(z.ProviderConfigName); paused {
+						log.Debug("postponing automatic sync for zone with unhealthy provider config", "zone", z.Name, "next", next)
+						z.NextSync = next
+					} else {
+						z.NextSync = now.Add(max(z.SyncInterval, time.Minute))
+						zones = append(zones, z)
+					}
 					if err := tx.Update(&z); err != nil {
 						return fmt.Errorf("setting next automatic sync for zone: %w", err)
 					}
@@ -86,7 +108,12 @@ func refresher() {
 					if i > 0 {
 						time.Sleep(2 * time.Second)
 					}
+					if shuttingDown() {
+						return
+					}
+					opDone := operationStart("automatic sync of zone %s", z.Name)
 					go func() {
+						defer opDone()
 						defer recoverPanic(log, "automatic zone refresh")
 						err := refreshZoneSync(log, z)
 						logCheck(log, err, "automatic zone refresh", "zone", z)
@@ -142,7 +169,12 @@ func refresher() {
 					if i > 0 {
 						time.Sleep(2 * time.Second)
 					}
+					if shuttingDown() {
+						return
+					}
+					opDone := operationStart("automatic soa check of zone %s", z.Name)
 					go func() {
+						defer opDone()
 						defer recoverPanic(log, "automatic zone refresh")
 						err := refreshZoneSOACheck(log, z)
 						logCheck(log, err, "automatic zone refresh", "zone", z)
@@ -150,6 +182,23 @@ func refresher() {
 				}
 			}()
 
+		case <-acmeCheck.C:
+			// Checking hourly is cheap, and retries soon enough after failures without
+			// hitting rate limits.
+			acmeCheck.Reset(time.Hour)
+			if shuttingDown() {
+				break
+			}
+			// Registered so a shutdown waits for an ACME order in progress, and its
+			// challenge record is removed.
+			opDone := operationStart("acme certificate renewal")
+			go func() {
+				defer opDone()
+				defer recoverPanic(log, "acme certificate renewal")
+				err := acmeRenew(shutdownCtx, log, adminACME)
+				logCheck(log, err, "acme certificate renewal")
+			}()
+
 		case <-refreshReschedule:
 			// Something changed about a zone, e.g. zone added/removed, sync done.
 			reschedule()
@@ -158,24 +207,29 @@ func refresher() {
 }
 
 // refreshZoneSync fetches new records from the provider and updates local records.
-func refreshZoneSync(log *slog.Logger, z Zone) error {
+func refreshZoneSync(log *slog.Logger, z Zone) (rerr error) {
+	ctx, span := spanStart(shutdownCtx, "zone sync", spanInternal, "zone", z.Name)
+	defer func() {
+		span.finish(rerr)
+	}()
+
 	pc := ProviderConfig{Name: z.ProviderConfigName}
 	if err := database.Get(shutdownCtx, &pc); err != nil {
 		return fmt.Errorf("get provider config: %v", err)
 	}
-	provider, err := providerForConfig(pc.ProviderName, pc.ProviderConfigJSON)
+	provider, err := configProvider(pc)
 	if err != nil {
 		return fmt.Errorf("making provider for zone: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(shutdownCtx, 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	unlock := lockZone(z.Name)
 	defer unlock()
 
 	// Get latest.
-	latest, err := getRecords(ctx, log, provider, z.Name, false)
+	latest, err := getZoneRecords(ctx, log, provider, z, true)
 	if err != nil {
 		return fmt.Errorf("get latest records: %w", err)
 	}
@@ -194,8 +248,19 @@ func refreshZoneSync(log *slog.Logger, z Zone) error {
 
 // refreshZoneSOACheck fetches the zone SOA record directly from the public
 // authoritative servers for a zone. If it has a different serial from what we
-// think the remote zone has, it starts a full record sync.
+// think the remote zone has, it starts a full record sync. For providers with a
+// fixed serial, the serial doesn't indicate changes, and a full sync is always
+// done.
 func refreshZoneSOACheck(log *slog.Logger, z Zone) error {
+	pc := ProviderConfig{Name: z.ProviderConfigName}
+	if err := database.Get(shutdownCtx, &pc); err != nil {
+		return fmt.Errorf("get provider config: %v", err)
+	}
+	if providerCapabilities[pc.ProviderName].FixedSerial {
+		log.Debug("provider has fixed serial, refresh does full sync", "zone", z.Name)
+		return refreshZoneSync(log, z)
+	}
+
 	ctx, cancel := context.WithTimeout(shutdownCtx, 30*time.Second)
 	defer cancel()
 	lsoa, err := getSOA(ctx, log, z.Name)
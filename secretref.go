@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// String values in provider config JSON can reference a secret instead of holding
+// it, so API tokens don't have to be entered in the admin interface or stored in
+// the database:
+//
+//	env:NAME	value of environment variable NAME
+//	file:/path	contents of file, without trailing newlines
+//	exec:cmd args	standard output of command (not through a shell), without trailing newlines
+//
+// References are resolved when making a Provider, and the resolved values are
+// cached for secretRefTTL, so rotated secrets are picked up. The cache is also
+// cleared on SIGHUP.
+//
+// Provider configs are entered in the admin interface, and a resolved secret is
+// sent to the provider, possibly a webhook with any URL. So only references
+// allowed by secretRefsAllowed (from -secretrefs, empty by default) are resolved:
+// "env:PREFIX" for environment variables starting with PREFIX, "file:/dir/" for
+// files in a directory, and "exec" for any command. References of kinds not
+// allowed at all are used as is, references of an allowed kind but not matching
+// an allowed prefix fail. The environment variable with secret keys for the
+// database is never resolved.
+
+var (
+	secretRefKinds    = []string{"env", "file", "exec"}
+	secretRefsAllowed []string
+	secretRefTTL      = 5 * time.Minute
+)
+
+// parseSecretRefsAllowed parses a comma-separated list of allowed secret
+// references for secretRefsAllowed. Directories of file references are cleaned
+// and end with a slash.
+func parseSecretRefsAllowed(s string) ([]string, error) {
+	var l []string
+	for _, e := range strings.Split(s, ",") {
+		kind, prefix, ok := strings.Cut(e, ":")
+		switch {
+		case e == "":
+			continue
+		case kind == "env" && ok:
+		case kind == "file" && ok && filepath.IsAbs(prefix):
+			e = "file:" + strings.TrimSuffix(filepath.Clean(prefix), "/") + "/"
+		case e == "exec":
+		default:
+			return nil, fmt.Errorf("invalid allowed secret reference %q, must be env:PREFIX, file:/dir or exec", e)
+		}
+		l = append(l, e)
+	}
+	return l, nil
+}
+
+type secretRefValue struct {
+	value   string
+	expires time.Time
+}
+
+var secretRefCache = struct {
+	sync.Mutex
+	values map[string]secretRefValue
+}{values: map[string]secretRefValue{}}
+
+// secretRefsClear removes all resolved secrets from the cache, causing them to be
+// resolved again when next needed.
+func secretRefsClear() {
+	secretRefCache.Lock()
+	defer secretRefCache.Unlock()
+	clear(secretRefCache.values)
+}
+
+// secretRefKind returns the kind of reference of s, or empty if s is not a
+// reference of a kind that is allowed.
+func secretRefKind(s string) string {
+	kind, _, ok := strings.Cut(s, ":")
+	if !ok {
+		return ""
+	}
+	for _, a := range secretRefsAllowed {
+		if a == kind || strings.HasPrefix(a, kind+":") {
+			return kind
+		}
+	}
+	return ""
+}
+
+// secretRefAllowed returns whether the reference, of kind with remainder rem, is
+// allowed by secretRefsAllowed.
+func secretRefAllowed(kind, rem string) bool {
+	if kind == "env" && rem == "DNSCLAY_SECRET_KEYS" {
+		return false
+	}
+	for _, a := range secretRefsAllowed {
+		akind, prefix, _ := strings.Cut(a, ":")
+		if akind != kind {
+			continue
+		}
+		switch kind {
+		case "env":
+			if strings.HasPrefix(rem, prefix) {
+				return true
+			}
+		case "file":
+			if filepath.IsAbs(rem) && strings.HasPrefix(filepath.Clean(rem), prefix) {
+				return true
+			}
+		case "exec":
+			return true
+		}
+	}
+	return false
+}
+
+// secretRefResolve returns the secret referenced by ref, from the cache if possible.
+// The caller must check if the reference is allowed.
+func secretRefResolve(ref string) (string, error) {
+	secretRefCache.Lock()
+	v, ok := secretRefCache.values[ref]
+	secretRefCache.Unlock()
+	if ok && time.Now().Before(v.expires) {
+		return v.value, nil
+	}
+
+	kind, rem, _ := strings.Cut(ref, ":")
+	var value string
+	switch kind {
+	case "env":
+		var ok bool
+		value, ok = os.LookupEnv(rem)
+		if !ok {
+			return "", fmt.Errorf("environment variable %q not set", rem)
+		}
+	case "file":
+		buf, err := os.ReadFile(rem)
+		if err != nil {
+			return "", fmt.Errorf("reading secret file: %v", err)
+		}
+		value = strings.TrimRight(string(buf), "\r\n")
+	case "exec":
+		args := strings.Fields(rem)
+		if len(args) == 0 {
+			return "", errors.New("missing command")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stderr = &stderr
+		buf, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("running secret command %q: %v (%s)", args[0], err, strings.TrimSpace(stderr.String()))
+		}
+		value = strings.TrimRight(string(buf), "\r\n")
+	default:
+		return "", fmt.Errorf("unknown secret reference kind %q", kind)
+	}
+
+	secretRefCache.Lock()
+	secretRefCache.values[ref] = secretRefValue{value, time.Now().Add(secretRefTTL)}
+	secretRefCache.Unlock()
+	return value, nil
+}
+
+// secretRefsResolve returns configJSON with all string values that are secret
+// references replaced by their secrets. If there are no references, configJSON is
+// returned as is.
+func secretRefsResolve(configJSON string) (string, error) {
+	var v any
+	dec := json.NewDecoder(strings.NewReader(configJSON))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		// Let the regular parsing of the config return an error.
+		return configJSON, nil
+	}
+
+	var found bool
+	var resolve func(v any) (any, error)
+	resolve = func(v any) (any, error) {
+		switch x := v.(type) {
+		case string:
+			if secretRefKind(x) == "" {
+				return x, nil
+			}
+			found = true
+			if kind, rem, _ := strings.Cut(x, ":"); !secretRefAllowed(kind, rem) {
+				return nil, fmt.Errorf("secret reference %q not allowed by -secretrefs", x)
+			}
+			s, err := secretRefResolve(x)
+			if err != nil {
+				return nil, fmt.Errorf("resolving secret reference %q: %w", x, err)
+			}
+			return s, nil
+		case map[string]any:
+			for k, e := range x {
+				nv, err := resolve(e)
+				if err != nil {
+					return nil, err
+				}
+				x[k] = nv
+			}
+		case []any:
+			for i, e := range x {
+				nv, err := resolve(e)
+				if err != nil {
+					return nil, err
+				}
+				x[i] = nv
+			}
+		}
+		return v, nil
+	}
+	v, err := resolve(v)
+	if err != nil {
+		return "", err
+	} else if !found {
+		return configJSON, nil
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("marshal config with resolved secrets: %v", err)
+	}
+	return string(buf), nil
+}
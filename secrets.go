@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/mjl-/bstore"
+)
+
+// Provider configs (with API tokens) and TSIG secrets can be encrypted in the
+// database with AES-256-GCM, with keys from a file (-secretkeyfile) or the
+// DNSCLAY_SECRET_KEYS environment variable. Encrypted values are stored as
+// "enc:v1:<keyid>:<base64 nonce and ciphertext>", and are decrypted only when
+// needed, e.g. when making a Provider or verifying TSIG.
+//
+// Multiple keys can be configured. The first is used for encrypting, the others
+// only for decrypting. For key rotation, add a new key as first key and restart:
+// at startup, plain text secrets and secrets encrypted with other keys are
+// encrypted with the first key. Old keys can be removed afterwards.
+
+const secretPrefix = "enc:v1:"
+
+type secretKey struct {
+	id   string // First 8 bytes of SHA-256 of key, hex-encoded.
+	aead cipher.AEAD
+}
+
+// secretKeys are the keys for encrypting/decrypting secrets. The first is used for
+// encryption. If empty, secrets are stored in plain text.
+var secretKeys []secretKey
+
+// parseSecretKeys parses base64-encoded 32-byte keys, separated by newlines or
+// commas. Empty lines and lines starting with # are ignored.
+func parseSecretKeys(s string) ([]secretKey, error) {
+	var keys []secretKey
+	for _, line := range strings.FieldsFunc(s, func(c rune) bool { return c == '\n' || c == ',' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		buf, err := base64.StdEncoding.DecodeString(line)
+		if err == nil && len(buf) != 32 {
+			err = fmt.Errorf("got %d bytes, need 32", len(buf))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing secret key %d: %v", len(keys), err)
+		}
+		block, err := aes.NewCipher(buf)
+		if err != nil {
+			return nil, fmt.Errorf("new aes cipher: %v", err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("new gcm: %v", err)
+		}
+		sum := sha256.Sum256(buf)
+		keys = append(keys, secretKey{hex.EncodeToString(sum[:8]), aead})
+	}
+	return keys, nil
+}
+
+// loadSecretKeys reads the keys from path, or if empty, from environment variable
+// DNSCLAY_SECRET_KEYS.
+func loadSecretKeys(path string) ([]secretKey, error) {
+	if path == "" {
+		return parseSecretKeys(os.Getenv("DNSCLAY_SECRET_KEYS"))
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading secret key file: %v", err)
+	}
+	keys, err := parseSecretKeys(string(buf))
+	if err == nil && len(keys) == 0 {
+		err = fmt.Errorf("no keys in secret key file %s", path)
+	}
+	return keys, err
+}
+
+func cmdGensecretkey(args []string) {
+	if len(args) != 0 {
+		flag.Usage()
+	}
+	buf := make([]byte, 32)
+	_, err := io.ReadFull(cryptorand.Reader, buf)
+	xcheckf(err, "read random")
+	fmt.Println(base64.StdEncoding.EncodeToString(buf))
+}
+
+func secretEncrypted(s string) bool {
+	return strings.HasPrefix(s, secretPrefix)
+}
+
+// secretEncrypt encrypts s with the first key. Without keys, s is returned as is.
+// Already encrypted values are not encrypted again.
+func secretEncrypt(s string) (string, error) {
+	if len(secretKeys) == 0 || s == "" || secretEncrypted(s) {
+		return s, nil
+	}
+	k := secretKeys[0]
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := io.ReadFull(cryptorand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("read random nonce: %v", err)
+	}
+	buf := k.aead.Seal(nonce, nonce, []byte(s), []byte(k.id))
+	return secretPrefix + k.id + ":" + base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// secretDecrypt decrypts s if it is encrypted, otherwise returns it as is.
+func secretDecrypt(s string) (string, error) {
+	if !secretEncrypted(s) {
+		return s, nil
+	}
+	id, data, ok := strings.Cut(strings.TrimPrefix(s, secretPrefix), ":")
+	if !ok {
+		return "", errors.New("malformed encrypted secret")
+	}
+	for _, k := range secretKeys {
+		if k.id != id {
+			continue
+		}
+		buf, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return "", fmt.Errorf("decoding encrypted secret: %v", err)
+		}
+		ns := k.aead.NonceSize()
+		if len(buf) < ns {
+			return "", errors.New("encrypted secret too short")
+		}
+		plain, err := k.aead.Open(nil, buf[:ns], buf[ns:], []byte(k.id))
+		if err != nil {
+			return "", fmt.Errorf("decrypting secret: %v", err)
+		}
+		return string(plain), nil
+	}
+	return "", fmt.Errorf("secret encrypted with unknown key %s", id)
+}
+
+// _secretDecrypt is like secretDecrypt, but panics with a sherpa error.
+func _secretDecrypt(s string) string {
+	plain, err := secretDecrypt(s)
+	_checkf(err, "decrypting secret")
+	return plain
+}
+
+// _secretEncrypt is like secretEncrypt, but panics with a sherpa error.
+func _secretEncrypt(s string) string {
+	enc, err := secretEncrypt(s)
+	_checkf(err, "encrypting secret")
+	return enc
+}
+
+// secretsReencrypt ensures all secrets in the database are encrypted with the
+// first key, encrypting plain text secrets and secrets encrypted with other keys.
+// Without keys, it only checks that no secrets are encrypted.
+func secretsReencrypt(ctx context.Context, log *slog.Logger) error {
+	var n int
+	reencrypt := func(s string) (string, bool, error) {
+		if len(secretKeys) == 0 {
+			if secretEncrypted(s) {
+				return "", false, errors.New("database has encrypted secrets, but no secret keys configured")
+			}
+			return s, false, nil
+		}
+		if s == "" || strings.HasPrefix(s, secretPrefix+secretKeys[0].id+":") {
+			return s, false, nil
+		}
+		plain, err := secretDecrypt(s)
+		if err != nil {
+			return "", false, err
+		}
+		enc, err := secretEncrypt(plain)
+		return enc, true, err
+	}
+
+	err := database.Write(ctx, func(tx *bstore.Tx) error {
+		pcs, err := bstore.QueryTx[ProviderConfig](tx).List()
+		if err != nil {
+			return fmt.Errorf("listing provider configs: %w", err)
+		}
+		for _, pc := range pcs {
+			s, changed, err := reencrypt(pc.ProviderConfigJSON)
+			if err != nil {
+				return fmt.Errorf("provider config %q: %w", pc.Name, err)
+			} else if changed {
+				n++
+				pc.ProviderConfigJSON = s
+				if err := tx.Update(&pc); err != nil {
+					return fmt.Errorf("updating provider config: %w", err)
+				}
+			}
+		}
+
+		creds, err := bstore.QueryTx[Credential](tx).List()
+		if err != nil {
+			return fmt.Errorf("listing credentials: %w", err)
+		}
+		for _, c := range creds {
+			s, changed, err := reencrypt(c.TSIGSecret)
+			if err != nil {
+				return fmt.Errorf("credential %q: %w", c.Name, err)
+			} else if changed {
+				n++
+				c.TSIGSecret = s
+				if err := tx.Update(&c); err != nil {
+					return fmt.Errorf("updating credential: %w", err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Info("encrypted secrets in database with current key", "count", n, "keyid", secretKeys[0].id)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/mjl-/bstore"
+)
+
+func TestSecrets(t *testing.T) {
+	oldKey := "UdUPNzI4Eh3y8NfKyPoG/ZGR1wVYQKsRe3yZp8CjHxc="
+	newKey := "v5TY6p6M1N8m1Eb2AXPgkyOFyVf0Xw4Xsl7BKWOsbp0="
+
+	keys, err := parseSecretKeys("# comment\n" + oldKey + "\n")
+	tcheck(t, err, "parse secret keys")
+	tcompare(t, len(keys), 1)
+	_, err = parseSecretKeys("dGVzdA==")
+	if err == nil {
+		t.Fatalf("expected error for short key")
+	}
+
+	secretKeys = keys
+	defer func() {
+		secretKeys = nil
+	}()
+
+	enc, err := secretEncrypt("secret")
+	tcheck(t, err, "encrypt")
+	tcompare(t, secretEncrypted(enc), true)
+	plain, err := secretDecrypt(enc)
+	tcheck(t, err, "decrypt")
+	tcompare(t, plain, "secret")
+
+	testDNS(t, func(te testEnv, z Zone) {
+		// Test zones were set up with the current first key. After rotation, z1 has
+		// secrets encrypted with the new key.
+		setupKeys := secretKeys
+		defer func() {
+			secretKeys, err = parseSecretKeys(newKey + "," + oldKey)
+			tcheck(t, err, "parse secret keys")
+		}()
+
+		rawSecrets := func() (pcJSON, tsigSecret string) {
+			t.Helper()
+			pc := ProviderConfig{Name: te.z0.pc.Name}
+			err := database.Get(ctxbg, &pc)
+			tcheck(t, err, "get provider config")
+			cred := Credential{ID: te.z0.credTSIG.ID}
+			err = database.Get(ctxbg, &cred)
+			tcheck(t, err, "get credential")
+			return pc.ProviderConfigJSON, cred.TSIGSecret
+		}
+
+		// Secrets are stored encrypted, and decrypted for the API.
+		pcJSON, tsigSecret := rawSecrets()
+		tcompare(t, strings.HasPrefix(pcJSON, secretPrefix+setupKeys[0].id+":"), true)
+		tcompare(t, strings.HasPrefix(tsigSecret, secretPrefix+setupKeys[0].id+":"), true)
+		_, pc, _, creds, _ := te.api.Zone(ctxbg, z.Name)
+		tcompare(t, pc.ProviderConfigJSON, "")
+		for _, c := range creds {
+			tcompare(t, c.TSIGSecret, "")
+		}
+		tcompare(t, te.api.ProviderConfigReveal(ctxbg, te.z0.pc.Name), te.z0.pc.ProviderConfigJSON)
+		tcompare(t, te.api.ZoneCredentialReveal(ctxbg, te.z0.credTSIG.ID), te.z0.credTSIG.TSIGSecret)
+
+		// Provider is made from the decrypted config.
+		te.api.ZoneRefresh(ctxbg, z.Name)
+
+		// TSIG is verified with the decrypted secret.
+		tdc := dnsclient{t, &dns.Client{Net: "tcp", TsigSecret: map[string]string{te.z0.credTSIG.Name + ".": te.z0.credTSIG.TSIGSecret}}, te.tcpaddr}
+		tdc.exchange(msgAXFRTSIG(z.Name, te.z0.credTSIG.Name, time.Now()), nil, dns.RcodeSuccess)
+
+		// Key rotation: new key first, old key still available for decrypting.
+		secretKeys, err = parseSecretKeys(newKey + "," + oldKey)
+		tcheck(t, err, "parse secret keys")
+		err = secretsReencrypt(ctxbg, slog.Default())
+		tcheck(t, err, "reencrypt secrets")
+		pcJSON, tsigSecret = rawSecrets()
+		tcompare(t, strings.HasPrefix(pcJSON, secretPrefix+secretKeys[0].id+":"), true)
+		tcompare(t, strings.HasPrefix(tsigSecret, secretPrefix+secretKeys[0].id+":"), true)
+		tdc.exchange(msgAXFRTSIG(z.Name, te.z0.credTSIG.Name, time.Now()), nil, dns.RcodeSuccess)
+
+		// Without the new key, the secrets cannot be decrypted.
+		secretKeys, err = parseSecretKeys(oldKey)
+		tcheck(t, err, "parse secret keys")
+		_, err = secretDecrypt(pcJSON)
+		if err == nil {
+			t.Fatalf("expected error decrypting with removed key")
+		}
+		secretKeys = nil
+		err = secretsReencrypt(ctxbg, slog.Default())
+		if err == nil {
+			t.Fatalf("expected error for encrypted secrets without keys")
+		}
+
+		// Database export keeps encrypted secrets, and omits plain text secrets.
+		secretKeys, err = parseSecretKeys(newKey)
+		tcheck(t, err, "parse secret keys")
+		err = database.Write(ctxbg, func(tx *bstore.Tx) error {
+			cred := Credential{ID: te.z0.credTSIG.ID}
+			if err := tx.Get(&cred); err != nil {
+				return err
+			}
+			cred.TSIGSecret = te.z0.credTSIG.TSIGSecret
+			return tx.Update(&cred)
+		})
+		tcheck(t, err, "storing plain text tsig secret")
+		plainpc := ProviderConfig{Name: "plain", ProviderName: "fake", ProviderConfigJSON: `{"ID": "plaintexttoken1234"}`}
+		err = database.Insert(ctxbg, &plainpc)
+		tcheck(t, err, "storing plain text provider config")
+
+		rec := httptest.NewRecorder()
+		exportDatabase(rec, httptest.NewRequest("GET", "/dnsclay.db", nil))
+		tcompare(t, rec.Code, 200)
+		for _, secret := range []string{te.z0.credTSIG.TSIGSecret, "plaintexttoken1234"} {
+			if bytes.Contains(rec.Body.Bytes(), []byte(secret)) {
+				t.Fatalf("export contains plain text secret %q", secret)
+			}
+		}
+		path := filepath.Join(t.TempDir(), "export.db")
+		err = os.WriteFile(path, rec.Body.Bytes(), 0600)
+		tcheck(t, err, "write export")
+		db, err := bstore.Open(ctxbg, path, nil, databaseTypes...)
+		tcheck(t, err, "open export")
+		defer db.Close()
+		epc := ProviderConfig{Name: te.z0.pc.Name}
+		err = db.Get(ctxbg, &epc)
+		tcheck(t, err, "get exported provider config")
+		tcompare(t, epc.ProviderConfigJSON, pcJSON)
+		ecred := Credential{ID: te.z0.credTSIG.ID}
+		err = db.Get(ctxbg, &ecred)
+		tcheck(t, err, "get exported credential")
+		tcompare(t, ecred.TSIGSecret, "")
+		eplainpc := ProviderConfig{Name: plainpc.Name}
+		err = db.Get(ctxbg, &eplainpc)
+		tcheck(t, err, "get exported plain text provider config")
+		tcompare(t, eplainpc.ProviderConfigJSON, "")
+		nrecords, err := bstore.QueryDB[Record](ctxbg, database).Count()
+		tcheck(t, err, "count records")
+		nexported, err := bstore.QueryDB[Record](ctxbg, db).Count()
+		tcheck(t, err, "count exported records")
+		tcompare(t, nexported, nrecords)
+	})
+}
+
+func TestSecretRefs(t *testing.T) {
+	defer func(allowed []string, ttl time.Duration) {
+		secretRefsAllowed = allowed
+		secretRefTTL = ttl
+		secretRefsClear()
+	}(secretRefsAllowed, secretRefTTL)
+	dir := t.TempDir()
+	allowed, err := parseSecretRefsAllowed("env:DNSCLAY_TEST_,file:" + dir + ",exec")
+	tcheck(t, err, "parse allowed secret references")
+	tcompare(t, allowed, []string{"env:DNSCLAY_TEST_", "file:" + dir + "/", "exec"})
+	secretRefsAllowed = allowed
+	providers["fake"] = fakeProvider{}
+
+	for _, s := range []string{"env", "file", "file:relative", "other:x"} {
+		if _, err := parseSecretRefsAllowed(s); err == nil {
+			t.Fatalf("expected error parsing allowed secret reference %q", s)
+		}
+	}
+
+	providerID := func(configJSON string) string {
+		t.Helper()
+		p, err := providerForConfig("fake", configJSON)
+		tcheck(t, err, "provider for config")
+		return p.libdnsProvider.(*fakeProvider).ID
+	}
+
+	t.Setenv("DNSCLAY_TEST_SECRET", "fromenv")
+	tcompare(t, providerID(`{"ID": "env:DNSCLAY_TEST_SECRET", "AbsNames": true}`), "fromenv")
+
+	path := filepath.Join(dir, "secret")
+	err = os.WriteFile(path, []byte("fromfile\n"), 0600)
+	tcheck(t, err, "write secret file")
+	tcompare(t, providerID(`{"ID": "file:`+path+`"}`), "fromfile")
+
+	tcompare(t, providerID(`{"ID": "exec:echo fromexec"}`), "fromexec")
+
+	// Values are cached until cleared or expired.
+	err = os.WriteFile(path, []byte("rotated"), 0600)
+	tcheck(t, err, "write secret file")
+	tcompare(t, providerID(`{"ID": "file:`+path+`"}`), "fromfile")
+	secretRefsClear()
+	tcompare(t, providerID(`{"ID": "file:`+path+`"}`), "rotated")
+
+	// References outside the allowed prefixes fail, also for the secret keys.
+	t.Setenv("DNSCLAY_SECRET_KEYS", "")
+	for _, ref := range []string{"env:HOME", "file:" + dir + "/../secret", "file:/etc/passwd"} {
+		_, err = providerForConfig("fake", `{"ID": "`+ref+`"}`)
+		if !errors.Is(err, errProviderUserError) {
+			t.Fatalf("got err %v, expected user error for reference %q not allowed", err, ref)
+		}
+	}
+	secretRefsAllowed = []string{"env:"}
+	_, err = providerForConfig("fake", `{"ID": "env:DNSCLAY_SECRET_KEYS"}`)
+	if !errors.Is(err, errProviderUserError) {
+		t.Fatalf("got err %v, expected user error for secret keys reference", err)
+	}
+
+	// Plain values and disabled kinds are used as is.
+	tcompare(t, providerID(`{"ID": "plain"}`), "plain")
+	tcompare(t, providerID(`{"ID": "exec:echo fromexec"}`), "exec:echo fromexec")
+
+	_, err = providerForConfig("fake", `{"ID": "env:DNSCLAY_TEST_MISSING"}`)
+	if !errors.Is(err, errProviderUserError) {
+		t.Fatalf("got err %v, expected user error for missing environment variable", err)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"os/signal"
 	"slices"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -59,10 +60,22 @@ var shutdownCancel func()
 
 var logLevel slog.LevelVar
 
+// Log format, "text" or "json", and whether log lines have a timestamp.
+var logFormat = "text"
+var logTime bool
+
 var database *bstore.DB
-var databaseTypes = []any{Zone{}, ProviderConfig{}, Record{}, ZoneNotify{}, Credential{}, ZoneCredential{}}
+var databaseTypes = []any{Zone{}, ProviderConfig{}, Record{}, ZoneNotify{}, Credential{}, ZoneCredential{}, QueuedChange{}, PropagationCheck{}, ZoneDiscovery{}, User{}, Session{}, APIToken{}, AuditEvent{}}
+
+// Schedule for checking if changes made through a provider are visible. Each
+// duration is the wait before the next check. Can be changed with a flag. Shorter
+// during tests.
+var propagationWaits = []time.Duration{time.Second / 10, time.Second, 2 * time.Second, 3 * time.Second}
 
-var propagationFirstWait = time.Second / 10 // Set to 0 during testing.
+// Schedule for checking if changes are served by the authoritative name servers,
+// for zones with VerifyNameservers, after the changes are visible through the
+// provider. Can be changed with a flag.
+var nameserverWaits = []time.Duration{time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 20 * time.Second, 30 * time.Second, time.Minute, 2 * time.Minute}
 
 // default file, created if absent
 var tlskeypemDefault = "server.privkey-ed25519.pkcs8.pem"
@@ -88,6 +101,9 @@ func cidlog(cidctx context.Context) *slog.Logger {
 	if cid != nil {
 		log = log.With("cid", cid)
 	}
+	if u := requestUser(cidctx); u != nil {
+		log = log.With("user", u.Username)
+	}
 	return log
 }
 
@@ -108,6 +124,15 @@ var (
 			Help: "Number of errors during processing updated records during sync.",
 		},
 	)
+	metricZoneSyncErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dnsclay_zone_sync_errors_total",
+			Help: "Number of errors getting the latest records of a zone from the provider, or processing them.",
+		},
+		[]string{
+			"zone",
+		},
+	)
 	metricPropagateErrors = promauto.NewCounter(
 		prometheus.CounterOpts{
 			Name: "dnsclay_propagate_errors_total",
@@ -122,7 +147,7 @@ var (
 		},
 		[]string{
 			"provider",
-			"op", // "get", "append", "set", "delete"
+			"op", // "get", "append", "set", "delete", "listzones"
 		},
 	)
 	metricProviderOpErrors = promauto.NewCounterVec(
@@ -132,7 +157,51 @@ var (
 		},
 		[]string{
 			"provider",
-			"op", // "get", "append", "set", "delete"
+			"op", // "get", "append", "set", "delete", "listzones"
+		},
+	)
+	metricProviderOpRetries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dnsclay_provider_op_retries_total",
+			Help: "Provider operations retried after a transient error.",
+		},
+		[]string{
+			"provider",
+			"op",
+		},
+	)
+	metricProviderHealthy = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "dnsclay_provider_config_healthy",
+			Help: "Whether a provider config is healthy (1) or marked unhealthy after repeated transient failures (0).",
+		},
+		[]string{
+			"providerconfig",
+		},
+	)
+	metricQueuedChanges = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "dnsclay_queued_changes",
+			Help: "Number of changes from DNS UPDATEs in the queue.",
+		},
+		[]string{
+			"state", // "pending", "failed"
+		},
+	)
+	metricQueuedChangeErrors = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "dnsclay_queued_change_errors_total",
+			Help: "Number of failed attempts to apply a queued change.",
+		},
+	)
+	metricDiscoveredZones = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "dnsclay_provider_config_discovered_zones",
+			Help: "Number of zones found during periodic zone discovery that are new (not configured) or vanished (configured, but no longer at provider).",
+		},
+		[]string{
+			"providerconfig",
+			"state", // "new", "vanished"
 		},
 	)
 	metricSOAGet = promauto.NewCounter(
@@ -147,6 +216,12 @@ var (
 			Help: "Number of errors for requests for a soa record directly to authoritative name servers.",
 		},
 	)
+	metricRecordsCacheHits = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "dnsclay_records_cache_hit_total",
+			Help: "Number of times records fetched from a provider within the zone freshness window were reused instead of fetched again.",
+		},
+	)
 	metricPanics = promauto.NewCounter(
 		prometheus.CounterOpts{
 			Name: "dnsclay_panics_total",
@@ -155,9 +230,6 @@ var (
 	)
 )
 
-// Read from file at startup, or generated & written if the file is missing. Always set during operation.
-var adminpassword string
-
 func genpassword() string {
 	var seed [32]byte
 	_, err := cryptorand.Read(seed[:])
@@ -178,7 +250,7 @@ func slogInit() {
 	slogOpts := slog.HandlerOptions{
 		Level: &logLevel,
 		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
-			if a.Key == "time" {
+			if a.Key == "time" && !logTime && len(groups) == 0 {
 				return slog.Attr{}
 			}
 			if a.Key == "cid" && a.Value.Kind() == slog.KindInt64 {
@@ -187,8 +259,13 @@ func slogInit() {
 			return a
 		},
 	}
-	logger := slog.New(slog.NewTextHandler(os.Stderr, &slogOpts))
-	slog.SetDefault(logger)
+	var handler slog.Handler
+	if logFormat == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, &slogOpts)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, &slogOpts)
+	}
+	slog.SetDefault(slog.New(handler))
 }
 
 // Allowed operations for a connection/request. We don't do updates/xfr over UDP.
@@ -204,11 +281,11 @@ var serveTraceDNS []traceDNS
 
 var errUnknownTLSPublicKey = errors.New("unknown tls public key")
 
-func tlsServerConfig(cert tls.Certificate) tls.Config {
+func tlsServerConfig(getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) tls.Config {
 	return tls.Config{
-		Certificates: []tls.Certificate{cert},
-		ClientAuth:   tls.RequestClientCert,
-		NextProtos:   []string{"dot"},
+		GetCertificate: getCertificate,
+		ClientAuth:     tls.RequestClientCert,
+		NextProtos:     []string{"dot"},
 		VerifyConnection: func(cs tls.ConnectionState) error {
 			if len(cs.PeerCertificates) == 0 {
 				return nil
@@ -248,33 +325,65 @@ func makeAdminMux() *http.ServeMux {
 	handler, err := sherpa.NewHandler("/api/", version, API{}, &apiDoc, opts)
 	xcheckf(err, "making sherpa handler")
 	adminMux := http.NewServeMux()
-	// todo: better authentication mechanism than http basic, perhaps similar as in mox & ding.
-	authedHandler := http.HandlerFunc(httpBasicAuth(handler.ServeHTTP))
+	// Static files and the license don't need authentication, the frontend asks for
+	// a login when an API call needs a session.
+	authedHandler := apiAuth(handler)
 	adminMux.Handle("GET /api/", authedHandler)
 	adminMux.Handle("POST /api/", authedHandler)
 	adminMux.Handle("OPTIONS /api/", authedHandler)
-	adminMux.HandleFunc("GET /license", httpBasicAuth(func(w http.ResponseWriter, r *http.Request) {
+	adminMux.HandleFunc("GET /license", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		err := licenseWrite(w)
 		logCheck(slog.Default(), err, "respond with license")
-	}))
-	adminMux.HandleFunc("GET /dnsclay.db", httpBasicAuth(exportDatabase))
-	adminMux.HandleFunc("GET /", httpBasicAuth(http.FileServerFS(fsys).ServeHTTP))
+	})
+	adminMux.HandleFunc("GET /dnsclay.db", httpAdminAuth(exportDatabase))
+	adminMux.HandleFunc("GET /auth/oidc/login", oidcLoginHandler)
+	adminMux.HandleFunc("GET /auth/oidc/callback", oidcCallbackHandler)
+	adminMux.HandleFunc("GET /", http.FileServerFS(fsys).ServeHTTP)
 	return adminMux
 }
 
+// xparseWaits parses a comma-separated list of durations for a flag.
+func xparseWaits(s, flagName string) []time.Duration {
+	var l []time.Duration
+	for _, e := range strings.Split(s, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(e))
+		if err != nil || d < 0 {
+			log.Fatalf("bad duration %q in -%s", e, flagName)
+		}
+		l = append(l, d)
+	}
+	return l
+}
+
 func cmdServe(args []string) {
 	flg := flag.NewFlagSet("dnsclay serve", flag.ExitOnError)
 
 	var adminpasswordpath string
+	var oidcConfigPath string
 	var tcpdnsupxfrAddrs, tcpdnsnotifyAddrs, tlsdnsupxfrAddrs, tlsdnsnotifyAddrs, adminAddr, metricsAddr string
 	var udpdnsAddrs string
 	var tlskeypem, tlscertpem string
+	var tlsTransition time.Duration
+	var shutdownTimeout time.Duration
+	var adminTLS bool
+	var adminTLSCertPEM, adminTLSKeyPEM string
+	var adminACMEDomain, adminACMEDirectory, adminACMEEmail string
 	var trace string
+	var propagationWaitsStr, nameserverWaitsStr string
+	var secretKeyFile string
+	var secretRefsAllowedStr string
+	var providerCommandsStr string
+	var runUser string
+	var otlpEndpoint, otlpHeaders string
 
 	flg.TextVar(&logLevel, "loglevel", &logLevel, "log level: error, warn, info, debug")
+	flg.StringVar(&logFormat, "logformat", "text", "log format: text for logfmt-like lines, json for one json object per line; with json, dns traces from -trace are logged as json too")
+	flg.BoolVar(&logTime, "logtime", false, "include timestamps in log lines, e.g. when not logging to a system that adds them")
 	flg.StringVar(&trace, "trace", "", "if non-empty, comma-separated formats to log dns request/response traces: text for textual format, json for json, jsonindent for multi-line indented json")
-	flg.StringVar(&adminpasswordpath, "adminpasswordpath", "adminpassword", "file with admin password for http basic auth; if absent, a random password is generated and written")
+	flg.StringVar(&otlpEndpoint, "otlpendpoint", "", "if non-empty, opentelemetry otlp/http url to export tracing spans to with json encoding, for dns requests, provider operations, syncs and dns notifies; path /v1/traces is used if url has no path, e.g. http://localhost:4318")
+	flg.StringVar(&otlpHeaders, "otlpheaders", "", "comma-separated key=value http headers to add to otlp export requests, e.g. for authentication")
+	flg.StringVar(&adminpasswordpath, "adminpasswordpath", "adminpassword", "file with password for admin user \"admin\", only used when the database has no users; if absent, a random password is generated and written")
 	flg.StringVar(&udpdnsAddrs, "dns-udpaddr", "localhost:1053", "comma-separated udp address to serve dns notify and authoritative soa requests on")
 	flg.StringVar(&tcpdnsupxfrAddrs, "dns-upxfr-tcpaddr", "localhost:1053", "comma-separated tcp address to serve dns update and axfr requests on")
 	flg.StringVar(&tcpdnsnotifyAddrs, "dns-notify-tcpaddr", "", "comma-separated tcp address to listen for dns notify messages on")
@@ -282,8 +391,25 @@ func cmdServe(args []string) {
 	flg.StringVar(&tlsdnsnotifyAddrs, "dns-notify-tlsaddr", "", "comma-separated tls address to listen for dns notify messages on")
 	flg.StringVar(&tlskeypem, "tlskeypem", tlskeypemDefault, "path to pem file with pkcs#8 private key file, for dns tls server; if empty an ephemeral tls key is generated at startup; if left at default, file is created if missing")
 	flg.StringVar(&tlscertpem, "tlscertpem", "", "path to pem file with one or more certificates; if empty, an ephemeral minimalistic certificate is generated for the private key")
+	flg.DurationVar(&tlsTransition, "tlstransition", 24*time.Hour, "after the dns tls certificate or key files change (checked on sighup and new connections), keep offering the previous certificate for this long to clients that do not support the new certificate, e.g. due to a different key type")
+	flg.StringVar(&propagationWaitsStr, "propagationwaits", "100ms,1s,2s,3s", "comma-separated durations to wait before each check whether changes made through a provider are visible; if changes are still not visible after the last check, propagation has failed")
+	flg.StringVar(&nameserverWaitsStr, "nameserverwaits", "1s,2s,5s,10s,20s,30s,1m,2m", "comma-separated durations to wait before each check whether changes are served by the authoritative name servers, for zones that verify name servers")
+	flg.StringVar(&secretKeyFile, "secretkeyfile", "", "file with base64-encoded 32-byte keys, one per line, for encrypting provider configs and tsig secrets in the database; first key is used for encryption, others only for decryption; if empty, keys are read from environment variable DNSCLAY_SECRET_KEYS (comma-separated), and secrets are stored in plain text if absent")
+	flg.StringVar(&secretRefsAllowedStr, "secretrefs", "", "comma-separated secret references to resolve in string values of provider configs: env:PREFIX for environment variables (env:NAME) starting with PREFIX, file:/dir for files (file:/path) in directory /dir, exec for commands (exec:command args); admins can reference any allowed secret in a provider config and have it sent to a provider of their choosing, including a webhook, so keep prefixes narrow and the secret key file out of allowed directories; exec allows admins to run any command, so enable with care")
+	flg.StringVar(&providerCommandsStr, "providercommands", "", "comma-separated programs, by path, that provider configs may run as command, for the plugin provider and the reload command of the zonefile provider; admins can run any allowed program with arguments of their choosing, so only allow programs meant for it")
+	flg.DurationVar(&secretRefTTL, "secretrefttl", secretRefTTL, "how long to cache secrets resolved from references in provider configs before resolving again, for picking up rotated secrets")
+	flg.DurationVar(&shutdownTimeout, "shutdowntimeout", 30*time.Second, "at shutdown (sigterm or sigint), how long to wait for in-flight dns requests, api calls, propagation checks, dns notifies and syncs to finish before aborting them")
+	flg.StringVar(&oidcConfigPath, "oidcconfig", "", "if non-empty, json file with openid connect configuration, for logging in to the admin interface through an identity provider, with roles based on groups")
+	flg.StringVar(&configPath, "config", "", "if non-empty, json file with provider configs, zones, notify addresses and credentials to reconcile into the database at startup and on sighup; config-managed objects cannot be changed in the admin interface")
 	flg.StringVar(&adminAddr, "adminaddr", "localhost:8053", "address to serve admin interface on")
 	flg.StringVar(&metricsAddr, "metricsaddr", "localhost:8053", "address to serve prometheus metrics on; can be same as adminaddr, no authentication needed")
+	flg.BoolVar(&adminTLS, "admintls", false, "serve admin interface with https, also metrics if on the same address; certificate from -adminacmedomain, -admintlscertpem and -admintlskeypem, or -tlscertpem and -tlskeypem; certificate files are read again when they change")
+	flg.StringVar(&adminTLSCertPEM, "admintlscertpem", "", "path to pem file with certificate chain for the admin interface, implies -admintls")
+	flg.StringVar(&adminTLSKeyPEM, "admintlskeypem", "", "path to pem file with private key for the admin interface, implies -admintls")
+	flg.StringVar(&adminACMEDomain, "adminacmedomain", "", "if non-empty, domain to request a certificate for through acme for the admin interface, implies -admintls; the dns-01 challenge record is added through the provider of the managed zone containing the domain; renewed automatically")
+	flg.StringVar(&adminACMEDirectory, "adminacmedirectory", "https://acme-v02.api.letsencrypt.org/directory", "acme directory url, for -adminacmedomain")
+	flg.StringVar(&adminACMEEmail, "adminacmeemail", "", "optional contact email address for the acme account, for -adminacmedomain")
+	flg.StringVar(&runUser, "user", "", "if non-empty, user to change to after opening sockets, before reading key files and opening the database; for binding to privileged ports as root without socket activation")
 	flg.Usage = func() {
 		log.Printf("usage: dnsclay serve [flags]")
 		flg.PrintDefaults()
@@ -300,6 +426,11 @@ func cmdServe(args []string) {
 			}
 		}
 	}
+	if logFormat != "text" && logFormat != "json" {
+		log.Fatalf("unknown -logformat value %q", logFormat)
+	}
+	propagationWaits = xparseWaits(propagationWaitsStr, "propagationwaits")
+	nameserverWaits = xparseWaits(nameserverWaitsStr, "nameserverwaits")
 	args = flg.Args()
 	if len(args) != 0 {
 		log.Printf("no parameters allowed")
@@ -308,21 +439,97 @@ func cmdServe(args []string) {
 
 	slogInit()
 
-	// Ensure we have an admin password for the web interface.
-	pwbuf, err := os.ReadFile(adminpasswordpath)
-	if err == nil {
-		adminpassword = strings.TrimRight(string(pwbuf), "\n")
-	} else if errors.Is(err, fs.ErrNotExist) {
-		adminpassword = genpassword()
-		err := os.WriteFile(adminpasswordpath, []byte(adminpassword+"\n"), 0600)
-		xcheckf(err, "write adminpassword file")
-		log.Printf("generated new admin password: %s", adminpassword)
-	} else {
-		log.Fatalf("reading adminpassword: %v", err)
+	// Open all sockets first, so we can drop privileges before opening files. With
+	// systemd socket activation, sockets are passed by name of the address flag.
+	var err error
+	activatedFiles, err = socketActivation()
+	xcheckf(err, "socket activation")
+
+	udpConns := xlistenUDP("dns-udpaddr", udpdnsAddrs)
+
+	type dnsListener struct {
+		conn net.Listener
+		l    listener
+	}
+	var dnsConns []dnsListener
+	dnsListeners := map[string]listener{}
+	addAddrs := func(name, s string, l listener) {
+		if activated(name) {
+			for _, conn := range xlistenTCP(name, "") {
+				dnsConns = append(dnsConns, dnsListener{conn, l})
+			}
+			return
+		}
+		if s == "" {
+			return
+		}
+		for _, a := range strings.Split(s, ",") {
+			x, ok := dnsListeners[a]
+			if ok {
+				if x.tls != l.tls {
+					log.Fatalf("cannot serve plain tcp and tls on same address %s", a)
+				}
+			}
+			l.notify = l.notify || x.notify
+			l.updates = l.updates || x.updates
+			l.xfr = l.xfr || x.xfr
+			l.auth = l.auth || x.auth
+			dnsListeners[a] = l
+		}
+	}
+	addAddrs("dns-upxfr-tcpaddr", tcpdnsupxfrAddrs, listener{false, false, true, true, true})
+	addAddrs("dns-notify-tcpaddr", tcpdnsnotifyAddrs, listener{false, true, false, false, false})
+	addAddrs("dns-upxfr-tlsaddr", tlsdnsupxfrAddrs, listener{true, false, true, true, true})
+	addAddrs("dns-notify-tlsaddr", tlsdnsnotifyAddrs, listener{true, true, false, false, false})
+	for _, addr := range slices.Sorted(maps.Keys(dnsListeners)) {
+		lconn, err := net.Listen("tcp", addr)
+		xcheckf(err, "listening on tcp %s", addr)
+		dnsConns = append(dnsConns, dnsListener{lconn, dnsListeners[addr]})
+	}
+
+	// Admin and metrics share a web server when on the same address, also when the
+	// admin socket is passed through socket activation.
+	sharedWeb := adminAddr != "" && adminAddr == metricsAddr && !activated("metricsaddr")
+	adminConns := xlistenTCP("adminaddr", adminAddr)
+	var metricsConns []net.Listener
+	if !sharedWeb {
+		metricsConns = xlistenTCP("metricsaddr", metricsAddr)
+	}
+
+	err = activationCheckUnused()
+	xcheckf(err, "socket activation")
+
+	if runUser != "" {
+		err := dropPrivileges(runUser)
+		xcheckf(err, "changing to user %q", runUser)
+		slog.Info("changed to user", "user", runUser)
+	}
+
+	secretKeys, err = loadSecretKeys(secretKeyFile)
+	xcheckf(err, "loading secret keys")
+
+	secretRefsAllowed, err = parseSecretRefsAllowed(secretRefsAllowedStr)
+	xcheckf(err, "parsing -secretrefs")
+	providerCommandsAllowed = nil
+	for _, s := range strings.Split(providerCommandsStr, ",") {
+		if s != "" {
+			providerCommandsAllowed = append(providerCommandsAllowed, s)
+		}
 	}
 
 	shutdownCtx, shutdownCancel = context.WithCancel(context.Background())
 
+	if otlpEndpoint != "" {
+		headers, err := parseHeaders(otlpHeaders)
+		xcheckf(err, "parsing -otlpheaders")
+		err = tracingInit(otlpEndpoint, headers)
+		xcheckf(err, "initializing tracing")
+		go func() {
+			defer recoverPanic(slog.Default(), "exporting tracing spans")
+			tracingExporter()
+		}()
+	}
+
 	if tlskeypem != "" {
 		tlsPrivKey = xprivatekey(tlskeypem, true)
 	} else {
@@ -339,18 +546,67 @@ func cmdServe(args []string) {
 		}
 	}
 
-	tlsConfig = tlsServerConfig(tlsCert)
-	tlspubkeysum := sha256.Sum256(tlsCert.Leaf.RawSubjectPublicKeyInfo)
-	tlspubkeyhash := base64.RawURLEncoding.EncodeToString(tlspubkeysum[:])
+	// Certificate files that are loaded again when changed, on sighup or new connections.
+	var certReloaders []*certReloader
+
+	if tlskeypem != "" {
+		paths := []string{tlskeypem}
+		if tlscertpem != "" {
+			paths = []string{tlscertpem, tlskeypem}
+		}
+		certs, err := newCertReloader(paths, loadCertFiles(tlscertpem, tlskeypem), tlsTransition, false)
+		xcheckf(err, "loading dns tls certificate")
+		certReloaders = append(certReloaders, certs)
+		tlsConfig = tlsServerConfig(certs.GetCertificate)
+	} else {
+		tlsConfig = tlsServerConfig(func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return &tlsCert, nil })
+	}
+
+	// Certificate for the admin interface, if enabled.
+	var adminTLSConfig *tls.Config
+	if adminACMEDomain != "" || adminTLSCertPEM != "" || adminTLSKeyPEM != "" {
+		adminTLS = true
+	}
+	if adminTLS {
+		var certs *certReloader
+		if adminACMEDomain != "" {
+			domain := strings.ToLower(strings.TrimSuffix(adminACMEDomain, "."))
+			certPath := "adminacme-" + domain + ".pem"
+			certs, err = newCertReloader([]string{certPath}, loadCertFiles(certPath, certPath), 0, true)
+			xcheckf(err, "loading acme certificate for admin interface")
+			adminACME = &acmeConfig{
+				Domain:         domain,
+				DirectoryURL:   adminACMEDirectory,
+				Email:          adminACMEEmail,
+				AccountKeyPath: "adminacme-account.privkey-ecdsa.pkcs8.pem",
+				CertPath:       certPath,
+				certs:          certs,
+			}
+		} else if adminTLSCertPEM != "" && adminTLSKeyPEM != "" {
+			certs, err = newCertReloader([]string{adminTLSCertPEM, adminTLSKeyPEM}, loadCertFiles(adminTLSCertPEM, adminTLSKeyPEM), 0, false)
+			xcheckf(err, "loading certificate for admin interface")
+		} else if adminTLSCertPEM == "" && adminTLSKeyPEM == "" && tlscertpem != "" && tlskeypem != "" {
+			certs, err = newCertReloader([]string{tlscertpem, tlskeypem}, loadCertFiles(tlscertpem, tlskeypem), 0, false)
+			xcheckf(err, "loading certificate for admin interface")
+		} else {
+			log.Fatalf("-admintls requires -adminacmedomain, both -admintlscertpem and -admintlskeypem, or both -tlscertpem and -tlskeypem")
+		}
+		certReloaders = append(certReloaders, certs)
+		adminTLSConfig = &tls.Config{GetCertificate: certs.GetCertificate}
+	}
+
+	tlspubkeyhash := tlsPublicKeyHash(tlsCert.Leaf)
 
 	// Possibly a shared handler for admin & metrics.
 	adminMux := makeAdminMux()
 	metricsMux := http.NewServeMux()
-	if adminAddr != "" && adminAddr == metricsAddr {
+	if sharedWeb {
 		metricsMux = adminMux
 	}
 
 	metricsMux.Handle("GET /metrics", promhttp.Handler())
+	metricsMux.HandleFunc("GET /healthz", healthzHandle)
+	metricsMux.HandleFunc("GET /readyz", readyzHandle)
 
 	// Open/initialize database.
 	dbopts := bstore.Options{
@@ -359,87 +615,90 @@ func cmdServe(args []string) {
 	database, err = bstore.Open(context.Background(), "dnsclay.db", &dbopts, databaseTypes...)
 	xcheckf(err, "open database")
 
+	err = secretsReencrypt(shutdownCtx, slog.Default())
+	xcheckf(err, "encrypting secrets in database")
+
+	// Ensure a user can log in to the web interface.
+	err = usersInit(shutdownCtx, slog.Default(), adminpasswordpath)
+	xcheckf(err, "initializing users")
+
+	if oidcConfigPath != "" {
+		c, err := oidcParse(oidcConfigPath)
+		xcheckf(err, "loading oidc config file")
+		oidc = newOIDCClient(c)
+	}
+
+	if configPath != "" {
+		err := configLoad(shutdownCtx, slog.Default())
+		xcheckf(err, "loading config file")
+	}
+
 	slog.Info("dnsclay starting",
 		"dns-udpaddr", udpdnsAddrs,
 		"dns-upxfr-tcpaddr", tcpdnsupxfrAddrs,
-		"dns-notify-tcpaddrs", tcpdnsnotifyAddrs,
-		"dns-upxfr-tcpaddrs", tlsdnsupxfrAddrs,
-		"dns-notify-tcpaddrs", tlsdnsnotifyAddrs,
+		"dns-notify-tcpaddr", tcpdnsnotifyAddrs,
+		"dns-upxfr-tlsaddr", tlsdnsupxfrAddrs,
+		"dns-notify-tlsaddr", tlsdnsnotifyAddrs,
 		"tlspubkeyhash", tlspubkeyhash,
 		"adminaddr", adminAddr,
+		"admintls", adminTLS,
 		"metricsaddr", metricsAddr,
+		"otlpendpoint", otlpEndpoint,
 		"version", version)
 
-	dnsListeners := map[string]listener{}
-
-	addAddrs := func(s string, l listener) {
-		if s == "" {
-			return
-		}
-		for _, a := range strings.Split(s, ",") {
-			x, ok := dnsListeners[a]
-			if ok {
-				if x.tls != l.tls {
-					log.Fatalf("cannot serve plain tcp and tls on same address %s", a)
-				}
-			}
-			l.notify = l.notify || x.notify
-			l.updates = l.updates || x.updates
-			l.xfr = l.xfr || x.xfr
-			l.auth = l.auth || x.auth
-			dnsListeners[a] = l
-		}
-	}
-	addAddrs(tcpdnsupxfrAddrs, listener{false, false, true, true, true})
-	addAddrs(tcpdnsnotifyAddrs, listener{false, true, false, false, false})
-	addAddrs(tlsdnsupxfrAddrs, listener{true, false, true, true, true})
-	addAddrs(tlsdnsupxfrAddrs, listener{true, true, false, false, false})
+	// Listeners and servers to stop at shutdown.
+	var listeners []net.Listener
+	var httpServers []*http.Server
 
 	// DNS NOTIFY is commonly done over UDP. AXFR clients may request the SOA before
 	// initiating an AXFR, so we handle requests for authoritative SOA records over UDP
 	// too. rfc/5936:1090
-	if udpdnsAddrs != "" {
-		for _, addr := range strings.Split(udpdnsAddrs, ",") {
-			lconn, err := net.ListenPacket("udp", addr)
-			xcheckf(err, "listen on udp %s", addr)
-
-			// We just read one packet, handle it writing a response, then read the next. We
-			// are only expecting NOTIFY messages and requests for authoritative SOA records.
-			go func() {
-				// Larger than needed, but easy to for shared code with tcp handling.
-				buf := make([]byte, 2+64*1024)
-
-				for {
-					n, addr, err := lconn.ReadFrom(buf)
-					if err != nil {
-						slog.Error("read udp packet", "err", err)
-						return
-					}
-
-					cid := connID.Add(1)
-					c := &conn{
-						cid:           cid,
-						udpRemoteAddr: addr,
-						udpconn:       lconn,
-						log:           slog.With("cid", cid),
-						listener:      listener{false, true, false, false, true},
-						buf:           buf,
-					}
-					c.log.Debug("new request", "remoteaddr", addr)
-					c.handleDNS(buf[:n])
+	for _, lconn := range udpConns {
+		// Reads are interrupted during shutdown.
+		readerAdd(lconn)
+
+		// We just read one packet, handle it writing a response, then read the next. We
+		// are only expecting NOTIFY messages and requests for authoritative SOA records.
+		go func() {
+			// Larger than needed, but easy to for shared code with tcp handling.
+			buf := make([]byte, 2+64*1024)
+
+			for {
+				n, addr, err := lconn.ReadFrom(buf)
+				if err != nil && shuttingDown() {
+					return
+				} else if err != nil {
+					slog.Error("read udp packet", "err", err)
+					return
 				}
-			}()
-		}
+
+				cid := connID.Add(1)
+				c := &conn{
+					cid:           cid,
+					udpRemoteAddr: addr,
+					udpconn:       lconn,
+					log:           slog.With("cid", cid),
+					listener:      listener{false, true, false, false, true},
+					buf:           buf,
+				}
+				c.log.Debug("new request", "remoteaddr", addr)
+				done := operationStart("dns request %x over udp", cid)
+				c.handleDNS(buf[:n])
+				done()
+			}
+		}()
 	}
 
-	for _, addr := range slices.Sorted(maps.Keys(dnsListeners)) {
-		l := dnsListeners[addr]
-		lconn, err := net.Listen("tcp", addr)
-		xcheckf(err, "listening on tcp %s", addr)
+	for _, dl := range dnsConns {
+		lconn, l := dl.conn, dl.l
+		listeners = append(listeners, lconn)
 
 		go func() {
 			for {
 				conn, err := lconn.Accept()
+				if err != nil && shuttingDown() {
+					return
+				}
 				xcheckf(err, "accept")
 				go func() {
 					defer recoverPanic(slog.Default(), "serving dns connection")
@@ -449,58 +708,38 @@ func cmdServe(args []string) {
 		}()
 	}
 
-	if adminAddr != "" && adminAddr == metricsAddr {
-		// Single web server for both admin and metrics.
-		conn, err := net.Listen("tcp", adminAddr)
-		xcheckf(err, "listen for webserver")
-
+	serveHTTP := func(conn net.Listener, handler http.Handler, what string) {
+		server := &http.Server{
+			Handler: handler,
+			ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+				return context.WithValue(ctx, ctxKeyCID, connID.Add(1))
+			},
+		}
+		httpServers = append(httpServers, server)
 		go func() {
-			server := http.Server{
-				Handler: adminMux,
-				ConnContext: func(ctx context.Context, c net.Conn) context.Context {
-					return context.WithValue(ctx, ctxKeyCID, connID.Add(1))
-				},
-			}
 			err := server.Serve(conn)
-			xcheckf(err, "serve webserver")
+			if err != http.ErrServerClosed {
+				xcheckf(err, "serve %s", what)
+			}
 		}()
-	} else {
-		if adminAddr != "" {
-			adminconn, err := net.Listen("tcp", adminAddr)
-			xcheckf(err, "listen for admin webserver")
-
-			go func() {
-				server := http.Server{
-					Handler: adminMux,
-					ConnContext: func(ctx context.Context, c net.Conn) context.Context {
-						return context.WithValue(ctx, ctxKeyCID, connID.Add(1))
-					},
-				}
-				err := server.Serve(adminconn)
-				xcheckf(err, "serve admin webserver")
-			}()
-		}
+	}
 
-		if metricsAddr != "" {
-			// For separate metrics webserver, redirect user to metrics.
-			metricsMux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
-				http.Redirect(w, r, "/metrics", http.StatusFound)
-			})
-
-			metricsconn, err := net.Listen("tcp", metricsAddr)
-			xcheckf(err, "listening for metrics webserver")
-
-			go func() {
-				server := http.Server{
-					Handler: metricsMux,
-					ConnContext: func(ctx context.Context, c net.Conn) context.Context {
-						return context.WithValue(ctx, ctxKeyCID, connID.Add(1))
-					},
-				}
-				err := server.Serve(metricsconn)
-				xcheckf(err, "serving metrics webserver")
-			}()
+	// With a shared web server, adminMux also serves metrics.
+	for _, adminconn := range adminConns {
+		if adminTLSConfig != nil {
+			adminconn = tls.NewListener(adminconn, adminTLSConfig)
 		}
+		serveHTTP(adminconn, adminMux, "admin webserver")
+	}
+
+	if len(metricsConns) > 0 {
+		// For separate metrics webserver, redirect user to metrics.
+		metricsMux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/metrics", http.StatusFound)
+		})
+	}
+	for _, metricsconn := range metricsConns {
+		serveHTTP(metricsconn, metricsMux, "metrics webserver")
 	}
 
 	go func() {
@@ -508,12 +747,86 @@ func cmdServe(args []string) {
 		refresher()
 	}()
 
+	go func() {
+		defer recoverPanic(slog.Default(), "queued change worker")
+		queueWorker()
+	}()
+
+	go func() {
+		defer recoverPanic(slog.Default(), "zone discoverer")
+		discoverer()
+	}()
+
+	propagationResume()
+
+	// Database is open, and listeners are serving.
+	serveReadyMark()
+
 	sigc := make(chan os.Signal, 1)
-	signal.Notify(sigc, syscall.SIGTERM)
-	<-sigc
-	slog.Info("shutting down")
-	shutdownCancel()
-	// todo: wait for all connections and operations to finish, then quit earlier if possible.
-	time.Sleep(time.Second / 2)
+	signal.Notify(sigc, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	for sig := range sigc {
+		if sig == syscall.SIGTERM || sig == syscall.SIGINT {
+			break
+		}
+		secretRefsClear()
+		for _, cr := range certReloaders {
+			err := cr.check()
+			logCheck(slog.Default(), err, "reloading tls certificate, keeping previous")
+		}
+		if configPath == "" {
+			slog.Info("sighup received, cleared cached secrets and checked certificates, no config file to reload")
+			continue
+		}
+		slog.Info("sighup received, reloading config file")
+		err := configLoad(shutdownCtx, slog.Default())
+		logCheck(slog.Default(), err, "reloading config file, database unchanged")
+	}
+	signal.Stop(sigc)
+
+	slog.Info("shutting down, waiting for in-flight operations", "timeout", shutdownTimeout)
+	shutdown(listeners, httpServers, shutdownTimeout)
 	os.Exit(0)
 }
+
+// shutdown stops accepting new connections and starting new work, and waits for
+// in-flight requests and operations until timeout. Operations still pending at
+// the timeout are logged, and canceled through shutdownCtx.
+func shutdown(listeners []net.Listener, httpServers []*http.Server, timeout time.Duration) {
+	shutdownStart()
+	for _, l := range listeners {
+		err := l.Close()
+		logCheck(slog.Default(), err, "closing listener")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, server := range httpServers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := server.Shutdown(ctx)
+			logCheck(slog.Default(), err, "shutting down webserver, active requests are aborted")
+		}()
+	}
+
+	pending := operationsDrain(timeout)
+	wg.Wait()
+	if len(pending) > 0 {
+		slog.Error("shutdown timeout reached, aborting pending operations", "pending", pending)
+	} else {
+		slog.Info("in-flight operations done")
+	}
+	shutdownCancel()
+	if len(pending) > 0 {
+		// Give aborted operations a moment to store their state, like pending
+		// propagation checks.
+		time.Sleep(time.Second / 2)
+	}
+
+	// Export the spans of the last operations.
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := tracingFlush(ctx)
+	logCheck(slog.Default(), err, "exporting spans at shutdown")
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// During a graceful shutdown, we stop accepting new connections and starting new
+// work, and wait for in-flight operations, like handling a DNS request or
+// verifying propagation of a change made through a provider, until a timeout.
+// Only then is shutdownCtx canceled.
+
+type operation struct {
+	desc  string
+	start time.Time
+}
+
+var operations = struct {
+	sync.Mutex
+	next     int64
+	pending  map[int64]operation
+	stopping bool
+
+	// Connections that are waiting for a DNS request. Their reads are interrupted
+	// during shutdown.
+	readers map[deadlineReader]struct{}
+}{
+	pending: map[int64]operation{},
+	readers: map[deadlineReader]struct{}{},
+}
+
+type deadlineReader interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// operationStart registers an in-flight operation that a shutdown waits for. The
+// returned function must be called when the operation is done. Operations that
+// are part of other operations, like sending a NOTIFY after a DNS UPDATE, are
+// registered during shutdown too.
+func operationStart(format string, args ...any) (done func()) {
+	operations.Lock()
+	defer operations.Unlock()
+	operations.next++
+	id := operations.next
+	operations.pending[id] = operation{fmt.Sprintf(format, args...), time.Now()}
+	return func() {
+		operations.Lock()
+		defer operations.Unlock()
+		delete(operations.pending, id)
+	}
+}
+
+// shuttingDown returns whether a shutdown has started. No new work, like a
+// periodic sync, should be started.
+func shuttingDown() bool {
+	operations.Lock()
+	defer operations.Unlock()
+	return operations.stopping
+}
+
+// readerAdd registers a connection that reads DNS requests, for interrupting its
+// reads during shutdown.
+func readerAdd(r deadlineReader) {
+	operations.Lock()
+	defer operations.Unlock()
+	operations.readers[r] = struct{}{}
+}
+
+func readerRemove(r deadlineReader) {
+	operations.Lock()
+	defer operations.Unlock()
+	delete(operations.readers, r)
+}
+
+// shutdownStart marks the start of the shutdown, after which no new work should be
+// started.
+func shutdownStart() {
+	operations.Lock()
+	defer operations.Unlock()
+	operations.stopping = true
+}
+
+// operationsDrain waits until all operations are done, or the timeout expires.
+// Reads of connections are interrupted repeatedly, for connections that had just
+// set a new deadline. The descriptions of operations that are still pending are
+// returned.
+func operationsDrain(timeout time.Duration) (pending []string) {
+	end := time.Now().Add(timeout)
+	for {
+		operations.Lock()
+		for r := range operations.readers {
+			r.SetReadDeadline(time.Now())
+		}
+		n := len(operations.pending)
+		if n == 0 || !time.Now().Before(end) {
+			for _, op := range operations.pending {
+				pending = append(pending, fmt.Sprintf("%s (running for %s)", op.desc, time.Since(op.start).Round(time.Millisecond)))
+			}
+			operations.Unlock()
+			sort.Strings(pending)
+			return pending
+		}
+		operations.Unlock()
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOperationsDrain(t *testing.T) {
+	defer func() {
+		operations.Lock()
+		operations.stopping = false
+		operations.Unlock()
+	}()
+
+	// Reads of idle connections are interrupted.
+	c0, c1 := net.Pipe()
+	defer c0.Close()
+	defer c1.Close()
+	readerAdd(c0)
+	defer readerRemove(c0)
+	readErr := make(chan error, 1)
+	go func() {
+		_, err := c0.Read(make([]byte, 1))
+		readErr <- err
+	}()
+
+	done := operationStart("test operation %d", 1)
+	tcompare(t, shuttingDown(), false)
+	shutdownStart()
+	tcompare(t, shuttingDown(), true)
+
+	// Operation still pending at timeout.
+	pending := operationsDrain(time.Second / 10)
+	if len(pending) != 1 || !strings.HasPrefix(pending[0], "test operation 1 (running for ") {
+		t.Fatalf("got pending %v, expected test operation", pending)
+	}
+	if err := <-readErr; !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("got read error %v, expected deadline exceeded", err)
+	}
+
+	// Operation done before timeout.
+	go func() {
+		time.Sleep(time.Second / 20)
+		done()
+	}()
+	pending = operationsDrain(5 * time.Second)
+	tcompare(t, len(pending), 0)
+}
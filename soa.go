@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
+	"strings"
 
 	"github.com/miekg/dns"
 )
 
 var mockGetSOA func(zone string) (*dns.SOA, error)
+var mockLookupNameservers func(zone string) ([]NameserverCheck, error)
 
 // getSOA gets a SOA record from the authoritative name servers. The record is not
 // DNSSEC-verified.
@@ -26,6 +28,52 @@ func getSOA(ctx context.Context, log *slog.Logger, zone string) (rsoa *dns.SOA,
 		}
 	}()
 
+	nsl, err := lookupNameservers(ctx, log, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	// We will be asking for the SOA record directly from the authoritative name
+	// servers. We don't do DNSSEC verification for simplicity.
+	client := dns.Client{Net: "tcp"}
+
+	lastErr := errors.New("cannot happen")
+	for _, ns := range nsl {
+		var om dns.Msg
+		om.SetQuestion(zone, dns.TypeSOA)
+		om.RecursionDesired = false
+		var soa *dns.SOA
+		im, _, err := client.ExchangeContext(ctx, &om, ns.Addr)
+		if err == nil {
+			err = responseError(im)
+		}
+		if err == nil && len(im.Answer) != 1 {
+			err = fmt.Errorf("got %d answer resource records (%v), expected 1 soa", len(im.Answer), im.Answer)
+		} else if err == nil {
+			var ok bool
+			soa, ok = im.Answer[0].(*dns.SOA)
+			if !ok {
+				err = fmt.Errorf("response not soa record, but %v", im.Answer[0])
+			}
+		}
+		if err != nil {
+			lastErr = err
+			log.Error("querying soa record from nameserver, continuing with others", "err", err, "nameserver", ns.Host, "addr", ns.Addr)
+			continue
+		}
+		return soa, nil
+	}
+	return nil, lastErr
+}
+
+// lookupNameservers returns the addresses of the authoritative name servers for
+// zone, with Host and Addr set. Errors looking up IPs for a name server are
+// logged, an error is only returned if no address was found.
+func lookupNameservers(ctx context.Context, log *slog.Logger, zone string) ([]NameserverCheck, error) {
+	if mockLookupNameservers != nil {
+		return mockLookupNameservers(zone)
+	}
+
 	// Lookup NS. We use the default resolver, using the locally configured recursive
 	// resolver, which may verify DNSSEC signatures.
 	nsl, err := net.DefaultResolver.LookupNS(ctx, zone)
@@ -36,11 +84,8 @@ func getSOA(ctx context.Context, log *slog.Logger, zone string) (rsoa *dns.SOA,
 		return nil, fmt.Errorf("looking up nameservers: %w", err)
 	}
 
-	// We will be asking for the SOA record directly from the authoritative name
-	// servers. We don't do DNSSEC verification for simplicity.
-	client := dns.Client{Net: "tcp"}
-
-	lastErr := errors.New("cannot happen")
+	var l []NameserverCheck
+	var lastErr error
 	for _, ns := range nsl {
 		ips, err := net.DefaultResolver.LookupIPAddr(ctx, ns.Host)
 		if err == nil && len(ips) == 0 {
@@ -51,33 +96,66 @@ func getSOA(ctx context.Context, log *slog.Logger, zone string) (rsoa *dns.SOA,
 			log.Error("looking up ips for nameserver", "err", err, "nameserver", ns.Host)
 			continue
 		}
-
 		for _, ip := range ips {
-			addr := net.JoinHostPort(ip.String(), "53")
-			var om dns.Msg
-			om.SetQuestion(zone, dns.TypeSOA)
-			om.RecursionDesired = false
-			var soa *dns.SOA
-			im, _, err := client.ExchangeContext(ctx, &om, addr)
-			if err == nil {
-				err = responseError(im)
-			}
-			if err == nil && len(im.Answer) != 1 {
-				err = fmt.Errorf("got %d answer resource records (%v), expected 1 soa", len(im.Answer), im.Answer)
-			} else if err == nil {
-				var ok bool
-				soa, ok = im.Answer[0].(*dns.SOA)
-				if !ok {
-					err = fmt.Errorf("response not soa record, but %v", im.Answer[0])
-				}
+			l = append(l, NameserverCheck{Host: ns.Host, Addr: net.JoinHostPort(ip.String(), "53")})
+		}
+	}
+	if len(l) == 0 {
+		return nil, lastErr
+	}
+	return l, nil
+}
+
+// nameserverCheck queries the authoritative name server at addr for the rrsets of
+// the expected changes. It returns an error if records to add are not present
+// (with the same TTL), or records to delete are still present.
+func nameserverCheck(ctx context.Context, addr string, add, del []Record) error {
+	keys := map[rrsetKey]struct{}{}
+	for _, r := range add {
+		keys[r.rrsetKey()] = struct{}{}
+	}
+	for _, r := range del {
+		keys[r.rrsetKey()] = struct{}{}
+	}
+
+	client := dns.Client{Net: "tcp"}
+	served := map[recordKey]bool{}
+	for k := range keys {
+		var om dns.Msg
+		om.SetQuestion(k.AbsName, uint16(k.Type))
+		om.RecursionDesired = false
+		im, _, err := client.ExchangeContext(ctx, &om, addr)
+		if err == nil && im.Rcode != dns.RcodeNameError {
+			err = responseError(im)
+		}
+		if err != nil {
+			return fmt.Errorf("querying %s %s: %w", k.AbsName, dns.TypeToString[uint16(k.Type)], err)
+		}
+		for _, rr := range im.Answer {
+			h := rr.Header()
+			if h.Rrtype != uint16(k.Type) || !strings.EqualFold(h.Name, k.AbsName) {
+				continue
 			}
+			hex, _, err := recordData(rr)
 			if err != nil {
-				lastErr = err
-				log.Error("querying soa record from nameserver, continuing with others", "err", err, "nameserver", ns.Host, "ip", ip)
-				continue
+				return fmt.Errorf("parsing record in response: %v", err)
 			}
-			return soa, nil
+			served[recordKey{k.AbsName, k.Type, Class(h.Class), TTL(h.Ttl), hex}] = true
+			// For deletes, the TTL is not compared.
+			served[recordKey{k.AbsName, k.Type, Class(h.Class), 0, hex}] = true
 		}
 	}
-	return nil, lastErr
+
+	for _, r := range add {
+		if !served[r.recordKey()] {
+			return fmt.Errorf("record %s %d %s %s not yet served", r.AbsName, r.TTL, dns.TypeToString[uint16(r.Type)], r.Value)
+		}
+	}
+	for _, r := range del {
+		r.TTL = 0
+		if served[r.recordKey()] {
+			return fmt.Errorf("deleted record %s %s %s still served", r.AbsName, dns.TypeToString[uint16(r.Type)], r.Value)
+		}
+	}
+	return nil
 }
@@ -55,6 +55,7 @@ func syncRecords(log *slog.Logger, tx *bstore.Tx, z Zone, latest []libdns.Record
 	defer func() {
 		if rerr != nil {
 			metricSyncErrors.Inc()
+			metricZoneSyncErrors.WithLabelValues(z.Name).Inc()
 		}
 	}()
 
@@ -142,10 +143,11 @@ func syncRecords(log *slog.Logger, tx *bstore.Tx, z Zone, latest []libdns.Record
 	}
 
 	// We may be using a different serial locally. Some name servers, like AWS Route53,
-	// don't update serials when records are changed.
+	// don't update serials when records are changed (FixedSerial capability). We keep
+	// our own serial for them, incremented on changes.
 	newSerialRemote := latestSOA.SerialFirst
 
-	if knownSOA != nil && latestSOA.SerialFirst <= 1 {
+	if knownSOA != nil && (latestSOA.SerialFirst <= 1 || providerCapabilities[pc.ProviderName].FixedSerial) {
 		latestSOA.SerialFirst = knownSOA.SerialFirst
 	}
 
@@ -339,22 +341,85 @@ func syncRecords(log *slog.Logger, tx *bstore.Tx, z Zone, latest []libdns.Record
 // ensurePropagate will do several attempts to ensure added/removed records are
 // seen through the provider. Records are fetched from remote, records in the local
 // database updated, and compared against the expected changes. Once all changes
-// are seen, this function returns.
+// are seen, this function returns. Checks are done according to the
+// propagationWaits schedule.
 //
 // Records in expDel may or may not be existing records (with ID nonzero). if their
 // ID is nonzero, those exact records are checked for deletion.
 //
+// For zones with VerifyNameservers, the authoritative name servers are checked in
+// the background after the changes are seen through the provider. If propagated is
+// not nil, the final result, including the name server checks, is sent on it. It
+// must be buffered.
+//
+// The pending check is stored in the database, and resumed after a restart. It is
+// removed when all changes are seen, and marked as failed otherwise.
+//
 // Must be called with zone lock held.
-func ensurePropagate(ctx context.Context, log *slog.Logger, provider Provider, z Zone, expAdd []recordKey, expDel []Record, prevSerial Serial) (inserted, deleted []Record, rerr error) {
+func ensurePropagate(ctx context.Context, log *slog.Logger, provider Provider, z Zone, expAdd []Record, expDel []Record, prevSerial Serial, propagated chan<- error) (inserted, deleted []Record, rerr error) {
+	defer func() {
+		if propagated != nil && (rerr != nil || !z.VerifyNameservers) {
+			propagated <- rerr
+		}
+	}()
+
+	defer operationStart("propagation check for zone %s", z.Name)()
+
+	pc := PropagationCheck{
+		Zone:       z.Name,
+		Add:        expAdd,
+		Delete:     expDel,
+		PrevSerial: prevSerial,
+		NextCheck:  time.Now().Add(propagationWaits[0]),
+	}
+	if err := database.Insert(ctx, &pc); err != nil {
+		metricPropagateErrors.Inc()
+		return nil, nil, fmt.Errorf("storing propagation check: %w", err)
+	}
+	inserted, deleted, rerr = propagationVerify(ctx, log, provider, z, &pc)
+	if rerr == nil && z.VerifyNameservers {
+		opDone := operationStart("propagation check at name servers for zone %s", z.Name)
+		go func() {
+			defer opDone()
+			defer recoverPanic(log, "verifying propagation at name servers")
+			err := nameserverVerify(shutdownCtx, log, z, &pc)
+			logCheck(log, err, "verifying propagation at name servers")
+			if propagated != nil {
+				propagated <- err
+			}
+		}()
+	}
+	return
+}
+
+// propagationFailed marks a propagation check as failed, except during shutdown,
+// when the check is left pending, to be resumed after restart.
+func propagationFailed(log *slog.Logger, pc *PropagationCheck, err error) {
+	metricPropagateErrors.Inc()
+	if shutdownCtx.Err() != nil {
+		return
+	}
+	pc.Failed = true
+	pc.LastError = err.Error()
+	xerr := database.Update(context.Background(), pc)
+	logCheck(log, xerr, "marking propagation check as failed")
+}
+
+// propagationVerify does the remaining checks through the provider for a stored
+// propagation check. When all changes are seen, the check is removed, or for zones
+// with VerifyNameservers, prepared for checking the name servers.
+//
+// Must be called with zone lock held.
+func propagationVerify(ctx context.Context, log *slog.Logger, provider Provider, z Zone, pc *PropagationCheck) (inserted, deleted []Record, rerr error) {
 	var notify bool
 	defer possiblyZoneNotify(log, z.Name, &notify)
 	defer func() {
 		if rerr != nil {
-			metricPropagateErrors.Inc()
+			propagationFailed(log, pc, rerr)
 		}
 	}()
 
-	log.Debug("ensuring propagation", "zone", z.Name, "adds", expAdd, "deletes", expDel, "prevserial", prevSerial)
+	log.Debug("ensuring propagation", "zone", z.Name, "adds", pc.Add, "deletes", pc.Delete, "prevserial", pc.PrevSerial, "checks", pc.Checks)
 
 	var done bool
 
@@ -372,15 +437,15 @@ func ensurePropagate(ctx context.Context, log *slog.Logger, provider Provider, z
 			mrk[r.recordKey()] = r
 		}
 
-		for _, a := range expAdd {
-			if r, ok := mrk[a]; !ok {
-				log.Debug("record not yet added/updated", "record", a, "exists", ok, "serial", r.SerialFirst)
+		for _, a := range pc.Add {
+			if r, ok := mrk[a.recordKey()]; !ok {
+				log.Debug("record not yet added/updated", "record", a.recordKey(), "exists", ok, "serial", r.SerialFirst)
 				return
 			} else {
 				il = append(il, r)
 			}
 		}
-		for _, d := range expDel {
+		for _, d := range pc.Delete {
 			if d.ID > 0 {
 				if r, ok := mid[d.ID]; ok {
 					log.Debug("record not yet deleted", "record", d)
@@ -403,7 +468,7 @@ func ensurePropagate(ctx context.Context, log *slog.Logger, provider Provider, z
 	}
 
 	sync := func() error {
-		latest, err := getRecords(ctx, log, provider, z.Name, false)
+		latest, err := getZoneRecords(ctx, log, provider, z, true)
 		if err != nil {
 			return fmt.Errorf("getting latest records through provider: %v", err)
 		}
@@ -434,27 +499,165 @@ func ensurePropagate(ctx context.Context, log *slog.Logger, provider Provider, z
 		return nil
 	}
 
-	waits := []time.Duration{propagationFirstWait, time.Second, 2 * time.Second, 3 * time.Second}
-	for _, w := range waits {
+	for pc.Checks < len(propagationWaits) {
 		select {
 		case <-ctx.Done():
 			return nil, nil, ctx.Err()
-		case <-time.After(w):
+		case <-time.After(time.Until(pc.NextCheck)):
 		}
 
 		err := sync()
+		now := time.Now()
+		pc.Checks++
+		pc.LastCheck = &now
 		if err != nil {
 			return nil, nil, err
+		} else if done && z.VerifyNameservers {
+			pc.ProviderDone = true
+			pc.Checks = 0
+			pc.NextCheck = now.Add(nameserverWaits[0])
+			if err := database.Update(ctx, pc); err != nil {
+				return nil, nil, fmt.Errorf("storing propagation check progress: %w", err)
+			}
+			return
 		} else if done {
-			break
+			err := database.Delete(context.Background(), pc)
+			logCheck(log, err, "removing completed propagation check")
+			return
 		}
 
-		log.Debug("waiting to check for propagation again", "wait", w)
+		if pc.Checks < len(propagationWaits) {
+			pc.NextCheck = now.Add(propagationWaits[pc.Checks])
+			log.Debug("waiting to check for propagation again", "wait", propagationWaits[pc.Checks])
+		}
+		if err := database.Update(ctx, pc); err != nil {
+			return nil, nil, fmt.Errorf("storing propagation check progress: %w", err)
+		}
 	}
-	if !done {
-		return nil, nil, fmt.Errorf("not all changes found")
+	return nil, nil, fmt.Errorf("not all changes found")
+}
+
+// nameserverVerify checks the authoritative name servers of the zone until they
+// all serve the changes of the propagation check, according to the
+// nameserverWaits schedule. The check is removed when done, and marked as failed
+// otherwise. Does not need the zone lock.
+func nameserverVerify(ctx context.Context, log *slog.Logger, z Zone, pc *PropagationCheck) (rerr error) {
+	defer func() {
+		if rerr != nil {
+			propagationFailed(log, pc, rerr)
+		}
+	}()
+
+	if len(pc.Nameservers) == 0 {
+		nsl, err := lookupNameservers(ctx, log, z.Name)
+		if err != nil {
+			return fmt.Errorf("looking up authoritative name servers: %w", err)
+		}
+		pc.Nameservers = nsl
+		if err := database.Update(ctx, pc); err != nil {
+			return fmt.Errorf("storing name servers for propagation check: %w", err)
+		}
+	}
+
+	for pc.Checks < len(nameserverWaits) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Until(pc.NextCheck)):
+		}
+
+		now := time.Now()
+		pending := 0
+		for i := range pc.Nameservers {
+			ns := &pc.Nameservers[i]
+			if ns.Done {
+				continue
+			}
+			nsctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			err := nameserverCheck(nsctx, ns.Addr, pc.Add, pc.Delete)
+			cancel()
+			ns.LastCheck = &now
+			if err != nil {
+				log.Debug("changes not yet served by name server", "nameserver", ns.Host, "addr", ns.Addr, "err", err)
+				ns.LastError = err.Error()
+				pending++
+			} else {
+				ns.Done = true
+				ns.LastError = ""
+			}
+		}
+		pc.Checks++
+		pc.LastCheck = &now
+		if pending == 0 {
+			log.Debug("changes served by all name servers")
+			err := database.Delete(context.Background(), pc)
+			logCheck(log, err, "removing completed propagation check")
+			return nil
+		}
+
+		if pc.Checks < len(nameserverWaits) {
+			pc.NextCheck = now.Add(nameserverWaits[pc.Checks])
+		}
+		if err := database.Update(ctx, pc); err != nil {
+			return fmt.Errorf("storing propagation check progress: %w", err)
+		}
+	}
+
+	var l []string
+	for _, ns := range pc.Nameservers {
+		if !ns.Done {
+			l = append(l, fmt.Sprintf("%s (%s): %s", ns.Host, ns.Addr, ns.LastError))
+		}
+	}
+	return fmt.Errorf("changes not served by all name servers: %s", strings.Join(l, "; "))
+}
+
+// propagationResume continues verification of propagation checks that were still
+// pending at shutdown. Called at startup.
+func propagationResume() {
+	log := slog.Default()
+
+	checks, err := bstore.QueryDB[PropagationCheck](shutdownCtx, database).FilterEqual("Failed", false).SortAsc("ID").List()
+	if err != nil {
+		logCheck(log, err, "listing pending propagation checks")
+		return
+	}
+	for _, pc := range checks {
+		opDone := operationStart("resumed propagation check for zone %s", pc.Zone)
+		go func() {
+			defer opDone()
+			defer recoverPanic(log, "resuming propagation check")
+
+			log := log.With("propagationcheck", pc.ID, "zone", pc.Zone)
+
+			unlock := lockZone(pc.Zone)
+			defer unlock()
+
+			var z Zone
+			var provider Provider
+			err := database.Read(shutdownCtx, func(tx *bstore.Tx) (err error) {
+				z, provider, err = zoneProvider(tx, pc.Zone)
+				return err
+			})
+			if err != nil {
+				logCheck(log, err, "get zone and provider for propagation check")
+				return
+			}
+
+			log.Info("resuming propagation check", "checks", pc.Checks, "providerdone", pc.ProviderDone)
+			if !pc.ProviderDone {
+				_, _, err = propagationVerify(shutdownCtx, log, provider, z, &pc)
+				logCheck(log, err, "resumed propagation check")
+				if err != nil || !pc.ProviderDone {
+					return
+				}
+			}
+			unlock()
+
+			err = nameserverVerify(shutdownCtx, log, z, &pc)
+			logCheck(log, err, "resumed propagation check at name servers")
+		}()
 	}
-	return
 }
 
 // possiblyZoneNotify is a convenience function for use with "defer", to send DNS
@@ -463,7 +666,9 @@ func possiblyZoneNotify(log *slog.Logger, zone string, notify *bool) {
 	if !*notify {
 		return
 	}
+	opDone := operationStart("dns notify for zone %s", zone)
 	go func() {
+		defer opDone()
 		defer recoverPanic(log, "notifying zones")
 		sendZoneNotify(log, zone)
 	}()
@@ -471,7 +676,11 @@ func possiblyZoneNotify(log *slog.Logger, zone string, notify *bool) {
 
 // Best-effort sending of dns notify for zone, typically called in goroutine.
 func sendZoneNotify(log *slog.Logger, zone string) {
-	ctx := shutdownCtx
+	ctx, span := spanStart(shutdownCtx, "dns notify zone", spanInternal, "zone", zone)
+	var ndest int
+	defer func() {
+		span.finish(nil, "destinations", ndest)
+	}()
 
 	// Reschedule next zone refresh/sync.
 	refreshKick()
@@ -514,12 +723,15 @@ func sendZoneNotify(log *slog.Logger, zone string) {
 	}
 
 	log.Debug("preparing to send dns notify", "ndestinations", len(znl))
+	ndest = len(znl)
 	for i := range znl {
 		zn := znl[i]
+		opDone := operationStart("dns notify for zone %s to %s", zone, zn.Address)
 		go func() {
+			defer opDone()
 			defer recoverPanic(log, "sending dns notify")
 
-			err := dnsNotify(log, zn, soa)
+			err := dnsNotify(ctx, log, zn, soa)
 			if err != nil {
 				log.Info("sending dns notify", "err", err, "zonenotify", zn)
 			}
@@ -528,8 +740,12 @@ func sendZoneNotify(log *slog.Logger, zone string) {
 }
 
 // dnsNotify sends a a single DNS notification to a server address.
-func dnsNotify(log *slog.Logger, zn ZoneNotify, soa dns.SOA) error {
+func dnsNotify(ctx context.Context, log *slog.Logger, zn ZoneNotify, soa dns.SOA) (rerr error) {
 	log = log.With("zone", zn.Zone, "proto", zn.Protocol, "addr", zn.Address)
+	_, span := spanStart(ctx, "dns notify", spanClient, "zone", zn.Zone, "proto", zn.Protocol, "addr", zn.Address)
+	defer func() {
+		span.finish(rerr)
+	}()
 
 	var om dns.Msg
 	om.SetNotify(zn.Zone)
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestPropagationResume(t *testing.T) {
+	defer func(l []time.Duration) {
+		propagationWaits = l
+	}(propagationWaits)
+	propagationWaits = []time.Duration{0, time.Second / 100}
+
+	testDNS(t, func(te testEnv, z Zone) {
+		var present Record
+		for _, r := range te.z0.records {
+			if r.AbsName == "testhost."+z.Name && r.Type == Type(dns.TypeA) {
+				present = r
+				break
+			}
+		}
+		absent := Record{Zone: z.Name, AbsName: "absent." + z.Name, Type: Type(dns.TypeA), Class: Class(dns.ClassINET), TTL: 300, DataHex: "0a000063", Value: "10.0.0.99"}
+
+		// Pending checks as left behind by a shutdown. The second has already done a
+		// check, and will fail after the last check of the schedule.
+		pc0 := PropagationCheck{Zone: z.Name, Add: []Record{present}, NextCheck: time.Now()}
+		err := database.Insert(ctxbg, &pc0)
+		tcheck(t, err, "insert propagation check")
+		pc1 := PropagationCheck{Zone: z.Name, Add: []Record{absent}, Checks: 1, NextCheck: time.Now()}
+		err = database.Insert(ctxbg, &pc1)
+		tcheck(t, err, "insert propagation check")
+
+		propagationResume()
+
+		var checks []PropagationCheck
+		for range 100 {
+			checks = te.api.PropagationChecks(ctxbg)
+			if len(checks) == 1 && checks[0].Failed {
+				break
+			}
+			time.Sleep(time.Second / 100)
+		}
+		tcompare(t, len(checks), 1)
+		tcompare(t, checks[0].ID, pc1.ID)
+		tcompare(t, checks[0].Failed, true)
+		tcompare(t, checks[0].Checks, 2)
+		tcompare(t, checks[0].LastError, "not all changes found")
+
+		te.api.PropagationCheckDelete(ctxbg, pc1.ID)
+		tcompare(t, len(te.api.PropagationChecks(ctxbg)), 0)
+	})
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracing with OpenTelemetry spans for DNS requests, provider operations, syncs
+// and DNS NOTIFYs. Spans are exported in batches to an OTLP/HTTP endpoint, with
+// the JSON encoding. Tracing is disabled when no endpoint is configured. We
+// implement the small part of OTLP we need instead of pulling in the SDK.
+
+// Span kinds, as in OTLP.
+const (
+	spanInternal = 1
+	spanServer   = 2
+	spanClient   = 3
+)
+
+type span struct {
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte // Zero for a root span.
+	name     string
+	kind     int
+	start    time.Time
+	end      time.Time
+	attrs    []any // Key/value pairs, like with slog.
+	err      error
+}
+
+var ctxKeySpan = ctxKey("span")
+
+// Spans are exported when this many are pending, or after the export interval.
+const tracingBatchSize = 512
+const tracingMaxPending = 8 * tracingBatchSize
+
+var tracingInterval = 5 * time.Second
+
+var tracing = struct {
+	sync.Mutex
+	endpoint string // Full URL to post spans to. Empty if tracing is disabled.
+	headers  map[string]string
+	pending  []*span
+	dropped  int
+	kick     chan struct{}
+}{
+	kick: make(chan struct{}, 1),
+}
+
+// tracingInit enables tracing, exporting to endpoint. Without path in the URL,
+// the standard path /v1/traces is used.
+func tracingInit(endpoint string, headers map[string]string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("parsing otlp endpoint: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("otlp endpoint must be http or https url")
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	tracing.Lock()
+	defer tracing.Unlock()
+	tracing.endpoint = u.String()
+	tracing.headers = headers
+	return nil
+}
+
+// spanStart starts a new span, a child of the span in ctx if any. The cid in ctx,
+// as used in logging, is added as attribute. If tracing is disabled, the returned
+// span is nil, and ctx is returned as is. The span must be ended with
+// span.finish.
+func spanStart(ctx context.Context, name string, kind int, attrs ...any) (context.Context, *span) {
+	tracing.Lock()
+	enabled := tracing.endpoint != ""
+	tracing.Unlock()
+	if !enabled {
+		return ctx, nil
+	}
+
+	s := &span{name: name, kind: kind, start: time.Now(), attrs: attrs}
+	if parent, ok := ctx.Value(ctxKeySpan).(*span); ok {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		cryptorand.Read(s.traceID[:])
+	}
+	cryptorand.Read(s.spanID[:])
+	if cid, ok := ctx.Value(ctxKeyCID).(int64); ok {
+		s.attrs = append(s.attrs, "cid", fmt.Sprintf("%x", cid))
+	}
+	return context.WithValue(ctx, ctxKeySpan, s), s
+}
+
+// finish ends the span, marking it as failed if err is set, and queues it for
+// export. Attributes are added to the span.
+func (s *span) finish(err error, attrs ...any) {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	s.err = err
+	s.attrs = append(s.attrs, attrs...)
+
+	tracing.Lock()
+	defer tracing.Unlock()
+	if len(tracing.pending) >= tracingMaxPending {
+		// Export is failing or too slow, don't keep growing.
+		tracing.dropped++
+		return
+	}
+	tracing.pending = append(tracing.pending, s)
+	if len(tracing.pending) >= tracingBatchSize {
+		select {
+		case tracing.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// finishPanic is like finish, for use with defer in API functions, which fail by
+// panicking. The panic continues.
+func (s *span) finishPanic() {
+	if s == nil {
+		return
+	}
+	x := recover()
+	if x == nil {
+		s.finish(nil)
+		return
+	}
+	s.finish(fmt.Errorf("%v", x))
+	panic(x)
+}
+
+// tracingExporter periodically exports pending spans, until shutdown.
+func tracingExporter() {
+	log := slog.With("component", "tracing")
+	t := time.NewTicker(tracingInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-shutdownCtx.Done():
+			return
+		case <-t.C:
+		case <-tracing.kick:
+		}
+		err := tracingFlush(shutdownCtx)
+		logCheck(log, err, "exporting spans")
+	}
+}
+
+// tracingFlush exports all pending spans. Spans are dropped when the export fails.
+func tracingFlush(ctx context.Context) error {
+	tracing.Lock()
+	endpoint, headers := tracing.endpoint, tracing.headers
+	spans := tracing.pending
+	tracing.pending = nil
+	dropped := tracing.dropped
+	tracing.dropped = 0
+	tracing.Unlock()
+
+	if dropped > 0 {
+		slog.Error("dropped spans because too many were pending for export", "dropped", dropped)
+	}
+	for len(spans) > 0 {
+		n := min(len(spans), tracingBatchSize)
+		if err := tracingExport(ctx, endpoint, headers, spans[:n]); err != nil {
+			return fmt.Errorf("exporting %d spans: %w", len(spans), err)
+		}
+		spans = spans[n:]
+	}
+	return nil
+}
+
+// OTLP/HTTP JSON encoding of trace export requests. Trace and span IDs are hex
+// encoded, 64 bit integers are strings, enums are integers.
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 1 for ok, 2 for error.
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+func otlpAttributes(kv []any) []otlpKeyValue {
+	var l []otlpKeyValue
+	for i := 0; i+1 < len(kv); i += 2 {
+		var v map[string]any
+		switch x := kv[i+1].(type) {
+		case string:
+			v = map[string]any{"stringValue": x}
+		case bool:
+			v = map[string]any{"boolValue": x}
+		case int:
+			v = map[string]any{"intValue": strconv.FormatInt(int64(x), 10)}
+		case int64:
+			v = map[string]any{"intValue": strconv.FormatInt(x, 10)}
+		default:
+			v = map[string]any{"stringValue": fmt.Sprint(x)}
+		}
+		l = append(l, otlpKeyValue{fmt.Sprint(kv[i]), v})
+	}
+	return l
+}
+
+func tracingExport(ctx context.Context, endpoint string, headers map[string]string, spans []*span) error {
+	var l []otlpSpan
+	for _, s := range spans {
+		xs := otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.attrs),
+			Status:            otlpStatus{Code: 1},
+		}
+		if s.parentID != [8]byte{} {
+			xs.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		if s.err != nil {
+			xs.Status = otlpStatus{2, s.err.Error()}
+		}
+		l = append(l, xs)
+	}
+	req := map[string]any{
+		"resourceSpans": []any{
+			map[string]any{
+				"resource": map[string]any{
+					"attributes": otlpAttributes([]any{"service.name", "dnsclay", "service.version", version}),
+				},
+				"scopeSpans": []any{
+					map[string]any{
+						"scope": map[string]any{"name": "dnsclay"},
+						"spans": l,
+					},
+				},
+			},
+		},
+	}
+	buf, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshal spans: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	hreq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(buf))
+	if err != nil {
+		return fmt.Errorf("new request: %v", err)
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	hreq.Header.Set("User-Agent", "dnsclay/"+version)
+	for k, v := range headers {
+		hreq.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(hreq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("export response status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// parseHeaders parses comma-separated key=value pairs, for -otlpheaders.
+func parseHeaders(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	m := map[string]string{}
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("bad header %q, must be key=value", kv)
+		}
+		m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return m, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTracing(t *testing.T) {
+	type exportRequest struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []otlpSpan
+			}
+		}
+	}
+	requests := make(chan exportRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Authorization") != "Bearer test" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		var req exportRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		tcheck(t, err, "decode export request")
+		requests <- req
+	}))
+	defer srv.Close()
+
+	// Disabled, no spans.
+	ctx, s := spanStart(ctxbg, "test", spanInternal)
+	tcompare(t, s == nil, true)
+	s.finish(nil)
+	tcompare(t, ctx, ctxbg)
+
+	err := tracingInit(srv.URL, map[string]string{"Authorization": "Bearer test"})
+	tcheck(t, err, "tracing init")
+	defer func() {
+		tracing.Lock()
+		tracing.endpoint = ""
+		tracing.Unlock()
+	}()
+
+	ctx, parent := spanStart(context.WithValue(ctxbg, ctxKeyCID, int64(0x123)), "dns request", spanServer)
+	_, child := spanStart(ctx, "provider get", spanClient, "provider", "test")
+	child.finish(errors.New("boom"), "attempts", 1)
+	parent.finish(nil)
+
+	err = tracingFlush(ctxbg)
+	tcheck(t, err, "flush")
+	req := <-requests
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	tcompare(t, len(spans), 2)
+	c, p := spans[0], spans[1]
+	tcompare(t, c.Name, "provider get")
+	tcompare(t, c.TraceID, p.TraceID)
+	tcompare(t, c.ParentSpanID, p.SpanID)
+	tcompare(t, p.ParentSpanID, "")
+	tcompare(t, c.Status, otlpStatus{2, "boom"})
+	tcompare(t, p.Status, otlpStatus{Code: 1})
+	tcompare(t, p.Kind, spanServer)
+	tcompare(t, c.Attributes, []otlpKeyValue{
+		{"provider", map[string]any{"stringValue": "test"}},
+		{"cid", map[string]any{"stringValue": "123"}},
+		{"attempts", map[string]any{"intValue": "1"}},
+	})
+
+	// Nothing pending, no request.
+	err = tracingFlush(ctxbg)
+	tcheck(t, err, "flush")
+	tcompare(t, len(requests), 0)
+}
@@ -51,6 +51,41 @@ type Zone struct {
 
 	NextSync    time.Time
 	NextRefresh time.Time // Only used when RefreshInterval > 0.
+
+	// If > 0, records fetched from the provider within this window are reused for
+	// DNS UPDATE/XFR and web API requests instead of fetching them again. Useful for
+	// bursts of requests, e.g. DNS UPDATEs for ACME challenges. Changing records
+	// through the provider clears the cached records. If 0, records are fetched for
+	// each request.
+	RecordsFreshness time.Duration
+
+	// If set, records are always fetched from the provider for DNS UPDATE requests
+	// with prerequisites, ignoring RecordsFreshness. Clients can also request this
+	// per message by adding an empty EDNS0 option with code 65301 (from the range for
+	// local/experimental use).
+	FreshPrerequisites bool
+
+	// If set, DNS UPDATEs are validated against the local records (including changes
+	// still queued), stored in a persistent queue and acknowledged immediately. A
+	// background worker applies queued changes through the provider, retrying on
+	// failure. Useful when the provider is not always available, e.g. for ACME
+	// challenges that are allowed to take a while.
+	QueueUpdates bool
+
+	// If set, after changes are seen through the provider, the authoritative name
+	// servers (from the NS records of the zone) are queried directly until they all
+	// serve the changed records.
+	VerifyNameservers bool
+
+	// If set, the response to a DNS UPDATE is only sent after the changes have
+	// propagated, i.e. are seen through the provider, and with VerifyNameservers,
+	// at the authoritative name servers. If propagation fails, SERVFAIL is returned.
+	// Clients may need a longer timeout. Not used with QueueUpdates.
+	DelayUpdateResponse bool
+
+	// If set, the zone is managed through the configuration file, and cannot be
+	// changed or removed through the admin interface.
+	ConfigManaged bool
 }
 
 type ProviderConfig struct {
@@ -62,6 +97,120 @@ type ProviderConfig struct {
 	// JSON encoding of the "Provider" type from the libdns package referenced by
 	// ProviderName.
 	ProviderConfigJSON string
+
+	// Number of times a provider operation that failed with a transient error
+	// (timeout, connection error, HTTP 5xx) is retried, with increasing delay. Adding
+	// records is not retried, it may already have been done by the failed attempt. If
+	// 0, operations are not retried.
+	Retries int
+
+	// Number of consecutive transient failures after which the provider config is
+	// marked unhealthy. While unhealthy, operations fail immediately, and automatic
+	// syncs are paused, with exponential backoff between attempts. If 0, the provider
+	// config is never marked unhealthy.
+	FailureThreshold int
+
+	// Interval for listing zones at the provider, flagging zones that are not
+	// configured and configured zones that vanished. Only for providers that can list
+	// zones. If 0, zones are only listed on request.
+	DiscoverInterval time.Duration
+
+	// If set, changes from DNS UPDATE are applied as complete record sets (name and
+	// type): the full desired record set is passed to a single SetRecords call,
+	// instead of appending and deleting individual records. Only for providers that
+	// implement SetRecords by replacing record sets. Fewer API calls are made, and
+	// there are no intermediate states with partial record sets. Record sets that
+	// become empty are deleted. Enabled by default in the web interface for new
+	// provider configs of providers known to operate on record sets.
+	RRSetUpdates bool
+
+	// If non-zero, the minimum TTL in seconds for records added or set through the
+	// provider, instead of the minimum known for the provider, e.g. after the DNS
+	// operator lowered the minimum for the account.
+	MinTTL uint32
+
+	// If set, managed through the configuration file.
+	ConfigManaged bool
+}
+
+// ZoneDiscovery is the result of the last periodic listing of zones for a
+// provider config.
+type ZoneDiscovery struct {
+	ProviderConfigName string `bstore:"ref ProviderConfig"`
+
+	Last *time.Time
+	Next time.Time
+
+	Error string // Error during last discovery, e.g. provider cannot list zones.
+
+	New      []string // Zones listed at the provider, not configured.
+	Vanished []string // Zones configured with the provider config, not listed at the provider.
+}
+
+// QueuedChange is a change from a DNS UPDATE for a zone with QueueUpdates, still
+// to be applied through the provider. Changes for a zone are applied in order of
+// ID. Records are added, then set, then deleted.
+type QueuedChange struct {
+	ID      int64
+	Created time.Time `bstore:"nonzero,default now"`
+	Zone    string    `bstore:"nonzero,ref Zone"`
+
+	// Cleared after the records have been added/set/deleted through the provider,
+	// so a next attempt only applies the remaining changes.
+	Add    []Record
+	Set    []Record
+	Delete []Record
+
+	Attempts    int // Failed attempts.
+	LastAttempt *time.Time
+	LastError   string
+	NextAttempt time.Time `bstore:"index"`
+
+	// Set after too many failed attempts. Failed changes are no longer attempted until
+	// retried. Later changes for the zone are held back, and new DNS UPDATEs for the
+	// zone are refused, until the failed change is retried or deleted.
+	Failed bool
+}
+
+// PropagationCheck is a verification that changes made through the provider are
+// returned by the provider. Stored while checks are pending, so verification can
+// be resumed after a restart. Removed when all changes are seen. Kept when
+// verification failed, until removed through the admin interface.
+type PropagationCheck struct {
+	ID      int64
+	Created time.Time `bstore:"nonzero,default now"`
+	Zone    string    `bstore:"nonzero,ref Zone"`
+
+	Add        []Record // Records expected to be present.
+	Delete     []Record // Records expected to be gone. If ID is nonzero, that exact record is checked.
+	PrevSerial Serial   // Serial of zone before the changes.
+
+	Checks    int // Number of checks done in the current phase, as index into its schedule.
+	LastCheck *time.Time
+	NextCheck time.Time
+
+	// Whether the changes have been seen through the provider. For zones with
+	// VerifyNameservers, this starts the phase of checking the name servers.
+	ProviderDone bool
+
+	// State for each authoritative name server address, for zones with
+	// VerifyNameservers. Set when the name server phase starts.
+	Nameservers []NameserverCheck
+
+	// Set when the changes weren't all seen after the last check, or when checking
+	// failed.
+	Failed    bool
+	LastError string
+}
+
+// NameserverCheck is the state of propagation of changes at an address of an
+// authoritative name server.
+type NameserverCheck struct {
+	Host      string // From NS record.
+	Addr      string // IP and port.
+	Done      bool   // Whether all changes were served.
+	LastCheck *time.Time
+	LastError string
 }
 
 // ZoneNotify is an address to DNS NOTIFY when a change to the zone is discovered.
@@ -71,6 +220,8 @@ type ZoneNotify struct {
 	Zone     string    `bstore:"nonzero,ref Zone"`
 	Address  string    `bstore:"nonzero"` // E.g. 127.0.0.1:53
 	Protocol string    `bstore:"nonzero"` // "tcp" or "udp"
+
+	ConfigManaged bool // If set, managed through the configuration file.
 }
 
 // Credential is used for TSIG or mutual TLS authentication during DNS.
@@ -81,6 +232,8 @@ type Credential struct {
 	Type         string    `bstore:"nonzero"`        // "tsig" or "tlspubkey"
 	TSIGSecret   string    // Base64-encoded.
 	TLSPublicKey string    `bstore:"index"` // Raw-url-base64-encoded SHA-256 hash of TLS certificate subject public key info ("SPKI").
+
+	ConfigManaged bool // If set, managed through the configuration file.
 }
 
 // ZoneCredential indicates a credential is allowed to access (get and change
@@ -89,14 +242,92 @@ type ZoneCredential struct {
 	ID           int64
 	Zone         string `bstore:"nonzero,ref Zone"`
 	CredentialID int64  `bstore:"nonzero,ref Credential"`
+
+	ConfigManaged bool // If set, managed through the configuration file.
+}
+
+// Role of a user of the admin web interface.
+type Role string
+
+const (
+	RoleViewer Role = "viewer" // Can view zones, records and settings, without secrets.
+	RoleEditor Role = "editor" // Can change records and settings of the zones listed in User.Zones.
+	RoleAdmin  Role = "admin"  // Can change everything, including provider configs and users.
+)
+
+// User is an account for the admin web interface.
+type User struct {
+	ID       int64
+	Created  time.Time `bstore:"nonzero,default now"`
+	Username string    `bstore:"nonzero,unique"`
+	Role     Role      `bstore:"nonzero"`
+
+	// For role editor, the zones that can be changed. Absolute names with trailing
+	// dot.
+	Zones []string
+
+	// Bcrypt hash of the password. Never returned through the API. Empty for users
+	// logging in through OpenID Connect.
+	PasswordHash string
+
+	// For users logging in through OpenID Connect, the issuer and subject of the ID
+	// token, separated by a space. Role and zones are updated from claims at each
+	// login. Empty for local users.
+	OIDCSubject string
+}
+
+// Session is a login session for the admin web interface, referenced by a cookie.
+type Session struct {
+	ID        int64
+	Created   time.Time `bstore:"nonzero,default now"`
+	LastUse   time.Time
+	UserID    int64  `bstore:"nonzero,ref User"`
+	TokenHash string `bstore:"nonzero,unique"` // Hex SHA-256 of the token in the session cookie.
+	CSRFToken string `bstore:"nonzero"`        // Must be sent in the x-dnsclay-csrf header with API calls.
+}
+
+// APIToken is a bearer token for scripts calling the API. A token acts for the
+// user that added it, limited further by its scopes.
+type APIToken struct {
+	ID        int64
+	Created   time.Time `bstore:"nonzero,default now"`
+	UserID    int64     `bstore:"nonzero,ref User"`
+	Name      string    `bstore:"nonzero"`        // Description, e.g. of the script using the token.
+	TokenHash string    `bstore:"nonzero,unique"` // Hex SHA-256 of the token. Never returned through the API.
+	Prefix    string    // Start of the token, for recognizing it.
+
+	// Only viewing, as if the user has role viewer.
+	ReadOnly bool
+
+	// If non-empty, only these zones can be viewed and changed, and functions
+	// requiring an admin cannot be called. Absolute names with trailing dot.
+	Zones []string
+
+	// If non-empty, only these API functions can be called, e.g. "RecordSetUpdate".
+	Functions []string
+
+	Expires  *time.Time // If set, the token cannot be used after this time.
+	LastUsed *time.Time // Updated at most once a minute.
+}
+
+// AuditEvent is a change made, or a secret revealed, through the admin web
+// interface, for attributing changes to users.
+type AuditEvent struct {
+	ID       int64
+	Time     time.Time `bstore:"nonzero,default now,index"`
+	Username string    // Empty for changes not made through the web interface.
+	APIToken string    // Name of the API token used for the change, if any.
+	Zone     string    `bstore:"index"` // Empty for changes not specific to a zone.
+	Action   string    // API function, e.g. "RecordSetAdd".
+	Details  string
 }
 
 // Record is a DNS record that discovered through the API of the provider.
 type Record struct {
 	ID            int64
-	Zone          string    `bstore:"nonzero,ref Zone"` // Name of zone, lower-case.
+	Zone          string    `bstore:"nonzero,ref Zone,index Zone+SerialDeleted"` // Name of zone, lower-case.
 	SerialFirst   Serial    // Serial where this record first appeared. For SOA records, this is equal to its Serial field.
-	SerialDeleted Serial    // Serial when record was removed. For future IXFR.
+	SerialDeleted Serial    // Serial when record was removed, zero for current records. For future IXFR.
 	First         time.Time `bstore:"default now,nonzero"`
 	Deleted       *time.Time
 	AbsName       string // Fully qualified, in lower-case.
@@ -4,6 +4,7 @@ import (
 	"context"
 	cryptorand "crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -12,6 +13,9 @@ import (
 	"maps"
 	"net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
 	"slices"
 	"sort"
 	"strings"
@@ -31,33 +35,119 @@ type API struct{}
 
 var apiDoc sherpadoc.Section
 
-func httpBasicAuth(fn func(w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		username, password, ok := r.BasicAuth()
-		if !ok || username != "admin" || password != adminpassword {
-			w.Header().Set("WWW-Authenticate", `Basic realm="dnsclay"`)
-			http.Error(w, "401 - unauthorized", http.StatusUnauthorized)
-			return
-		}
-		fn(w, r)
-	}
-}
-
 // write a copy of the database from within a readonly transaction, for a consistent view.
+// Secrets that are not encrypted are omitted from the copy. The copy is a new
+// database with redacted records inserted. Clearing fields in a copy of the
+// database file would leave the plain text secrets in freed pages.
 func exportDatabase(w http.ResponseWriter, r *http.Request) {
 	log := cidlog(r.Context())
+
+	// Only accessible to us, not sharing the directory with other users.
+	dir, err := os.MkdirTemp("", "dnsclay-export-*")
+	if err != nil {
+		log.Error("creating temporary directory for database export", "err", err)
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer func() {
+		err := os.RemoveAll(dir)
+		logCheck(log, err, "removing temporary database export directory")
+	}()
+
+	path := filepath.Join(dir, "dnsclay.db")
+	err = exportWrite(r.Context(), path)
+	var f *os.File
+	if err == nil {
+		f, err = os.Open(path)
+	}
+	if err != nil {
+		log.Error("preparing database export", "err", err)
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer func() {
+		err := f.Close()
+		logCheck(log, err, "closing temporary database export file")
+	}()
+
 	h := w.Header()
 	h.Set("Content-Type", "application/octet-stream")
 	h.Set("Cache-Control", "no-cache, max-age=0")
-	err := database.Read(r.Context(), func(tx *bstore.Tx) error {
-		_, err := tx.WriteTo(w)
-		return err
-	})
-	if err != nil && !isClosed(err) {
+	if _, err := io.Copy(w, f); err != nil && !isClosed(err) {
 		log.Error("exporting database", "err", err)
 	}
 }
 
+// exportWrite creates a new database at path with all data from the database,
+// omitting secrets that are not encrypted.
+func exportWrite(ctx context.Context, path string) (rerr error) {
+	db, err := bstore.Open(ctx, path, &bstore.Options{Timeout: 5 * time.Second}, databaseTypes...)
+	if err != nil {
+		return fmt.Errorf("create export database: %w", err)
+	}
+	defer func() {
+		err := db.Close()
+		if rerr == nil && err != nil {
+			rerr = fmt.Errorf("close export database: %w", err)
+		}
+	}()
+
+	// In order of references.
+	copies := []func(tx, ntx *bstore.Tx) error{
+		exportCopy(func(pc *ProviderConfig) {
+			if !secretEncrypted(pc.ProviderConfigJSON) {
+				pc.ProviderConfigJSON = ""
+			}
+		}),
+		exportCopy[Zone](nil),
+		exportCopy[Record](nil),
+		exportCopy[ZoneNotify](nil),
+		exportCopy(func(c *Credential) {
+			if !secretEncrypted(c.TSIGSecret) {
+				c.TSIGSecret = ""
+			}
+		}),
+		exportCopy[ZoneCredential](nil),
+		exportCopy[QueuedChange](nil),
+		exportCopy[PropagationCheck](nil),
+		exportCopy[ZoneDiscovery](nil),
+		exportCopy[User](nil),
+		exportCopy[Session](nil),
+		exportCopy[APIToken](nil),
+		exportCopy[AuditEvent](nil),
+	}
+	if len(copies) != len(databaseTypes) {
+		return fmt.Errorf("export copies %d types, database has %d types", len(copies), len(databaseTypes))
+	}
+
+	return database.Read(ctx, func(tx *bstore.Tx) error {
+		return db.Write(ctx, func(ntx *bstore.Tx) error {
+			for _, fn := range copies {
+				if err := fn(tx, ntx); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// exportCopy returns a function that inserts all records of type T from tx into
+// ntx, with the same IDs, after calling redact, if set.
+func exportCopy[T any](redact func(v *T)) func(tx, ntx *bstore.Tx) error {
+	return func(tx, ntx *bstore.Tx) error {
+		return bstore.QueryTx[T](tx).ForEach(func(v T) error {
+			if redact != nil {
+				redact(&v)
+			}
+			if err := ntx.Insert(&v); err != nil {
+				return fmt.Errorf("inserting %T in export: %w", v, err)
+			}
+			return nil
+		})
+	}
+}
+
 // NOTE: Functions starting with an underscore can panic with a *sherpa.Error. They
 // are are recognized by the sherpa handler and turned into regular error
 // conditions.
@@ -101,7 +191,9 @@ func _recordType(t Type) string {
 
 // Zones returns all zones.
 func (x API) Zones(ctx context.Context) []Zone {
-	zones, err := bstore.QueryDB[Zone](ctx, database).List()
+	q := bstore.QueryDB[Zone](ctx, database)
+	q.FilterFn(func(z Zone) bool { return tokenAllowsZone(ctx, z.Name) })
+	zones, err := q.List()
 	_checkf(err, "listing zones")
 	return zones
 }
@@ -111,23 +203,27 @@ func (x API) Zones(ctx context.Context) []Zone {
 // record sets includes those no long active (i.e. deleted). The
 // history/propagation state fo the record sets only includes those that may still
 // be in caches. Use ZoneRecordSetHistory for the full history for a single record
-// set.
+// set. The provider config JSON and TSIG secrets are not returned, see
+// ProviderConfigReveal and ZoneCredentialReveal.
 func (x API) Zone(ctx context.Context, zone string) (z Zone, pc ProviderConfig, notifies []ZoneNotify, credentials []Credential, sets []RecordSet) {
 	var records []Record
 	_dbread(ctx, func(tx *bstore.Tx) {
 		z = _zone(tx, zone)
+		_checkZoneView(ctx, z.Name)
 
 		pc = ProviderConfig{Name: z.ProviderConfigName}
 		err := tx.Get(&pc)
 		_checkf(err, "get provider config")
+		pc.ProviderConfigJSON = ""
 
 		notifies, err = bstore.QueryTx[ZoneNotify](tx).FilterNonzero(ZoneNotify{Zone: zone}).List()
 		_checkf(err, "listing notify addresses")
 
 		err = bstore.QueryTx[ZoneCredential](tx).FilterNonzero(ZoneCredential{Zone: zone}).ForEach(func(zc ZoneCredential) error {
-			c := Credential{ID: zc.ID}
+			c := Credential{ID: zc.CredentialID}
 			err := tx.Get(&c)
 			_checkf(err, "get credential for zone")
+			c.TSIGSecret = ""
 			credentials = append(credentials, c)
 			return nil
 		})
@@ -147,6 +243,7 @@ func (x API) Zone(ctx context.Context, zone string) (z Zone, pc ProviderConfig,
 func (x API) ZoneRecords(ctx context.Context, zone string) (records []Record) {
 	_dbread(ctx, func(tx *bstore.Tx) {
 		z := _zone(tx, zone)
+		_checkZoneView(ctx, z.Name)
 
 		var err error
 		records, err = bstore.QueryTx[Record](tx).FilterNonzero(Record{Zone: z.Name}).List()
@@ -160,6 +257,7 @@ func (x API) ZoneRecords(ctx context.Context, zone string) (records []Record) {
 // (included deleted) from after the synchronization.
 func (x API) ZoneRefresh(ctx context.Context, zone string) (z Zone, sets []RecordSet) {
 	log := cidlog(ctx)
+	_checkZoneEdit(ctx, zone)
 
 	var provider Provider
 	_dbread(ctx, func(tx *bstore.Tx) {
@@ -171,10 +269,13 @@ func (x API) ZoneRefresh(ctx context.Context, zone string) (z Zone, sets []Recor
 	unlock := lockZone(z.Name)
 	defer unlock()
 
+	ctx, span := spanStart(ctx, "zone sync", spanInternal, "zone", z.Name)
+	defer span.finishPanic()
+
 	var cancel func()
 	ctx, cancel = context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
-	latest, err := getRecords(ctx, log, provider, zone, false)
+	latest, err := getZoneRecords(ctx, log, provider, z, true)
 	_checkf(err, "getting latest records through provider")
 
 	var notify bool
@@ -196,6 +297,8 @@ func (x API) ZoneRefresh(ctx context.Context, zone string) (z Zone, sets []Recor
 
 // ZonePurgeHistory removes historic records from the database, those marked "deleted".
 func (x API) ZonePurgeHistory(ctx context.Context, zone string) (z Zone, sets []RecordSet) {
+	_checkZoneEdit(ctx, zone)
+
 	_dbwrite(ctx, func(tx *bstore.Tx) {
 		z = _zone(tx, zone) // Again.
 
@@ -209,6 +312,7 @@ func (x API) ZonePurgeHistory(ctx context.Context, zone string) (z Zone, sets []
 		_checkf(err, "listing records ")
 		sets = _propagationStates(records)
 	})
+	audit(ctx, z.Name, "ZonePurgeHistory", "purged record history")
 
 	return
 }
@@ -219,126 +323,220 @@ func (x API) ZonePurgeHistory(ctx context.Context, zone string) (z Zone, sets []
 // If pc.ProviderName is non-empty, a new ProviderConfig is added.
 func (x API) ZoneAdd(ctx context.Context, z Zone, notifies []ZoneNotify) (nzone Zone) {
 	log := cidlog(ctx)
+	_checkAdmin(ctx, "adding zones")
 	var provider Provider
 
 	_dbwrite(ctx, func(tx *bstore.Tx) {
-		now := time.Now()
+		z, provider = _zoneAdd(tx, z, notifies)
+	})
 
-		z.Name = _cleanAbsName(strings.TrimSuffix(z.Name, ".") + ".")
-		z.NextSync = now.Add(z.SyncInterval)
-		if z.RefreshInterval > 0 {
-			z.NextRefresh = now.Add(z.RefreshInterval / (5 * 10))
-		}
-		err := tx.Insert(&z)
-		_checkf(err, "adding zone")
+	audit(ctx, z.Name, "ZoneAdd", "added zone with provider config %s", z.ProviderConfigName)
 
-		_, provider, err = zoneProvider(tx, z.Name)
-		_checkf(err, "get zone and provider")
+	go zoneInitialFetch(log, provider, z)
 
-		for _, n := range notifies {
-			n.Zone = z.Name
-			switch n.Protocol {
-			case "tcp", "udp":
-			default:
-				_checkuserf(fmt.Errorf("unknown protocol %q", n.Protocol), "checking notify")
-			}
-			_, _, err := net.SplitHostPort(n.Address)
-			_checkuserf(err, "checking notify address")
-			err = tx.Insert(&n)
-			_checkf(err, "inserting notify")
-		}
+	return z
+}
 
-		tsigbuf := make([]byte, 32)
-		_, err = io.ReadFull(cryptorand.Reader, tsigbuf)
-		_checkf(err, "read random")
-		cred := Credential{
-			Name:       "zone-default-tsig-" + strings.TrimSuffix(z.Name, "."),
-			Type:       "tsig",
-			TSIGSecret: base64.StdEncoding.EncodeToString(tsigbuf),
-		}
-		err = tx.Insert(&cred)
-		_checkf(err, "inserting tsig credential")
-		zonecred := ZoneCredential{
-			Zone:         z.Name,
-			CredentialID: cred.ID,
-		}
-		err = tx.Insert(&zonecred)
-		_checkf(err, "inserting tsig zone credential")
-	})
+// _zoneAdd inserts a new zone with its notifies and a new TSIG credential.
+func _zoneAdd(tx *bstore.Tx, z Zone, notifies []ZoneNotify) (Zone, Provider) {
+	if z.RecordsFreshness < 0 {
+		_checkuserf(errors.New("must be >= 0"), "checking records freshness")
+	}
 
-	go func() {
-		defer recoverPanic(log, "fetching records for new zone")
+	now := time.Now()
 
-		unlock := lockZone(z.Name)
-		defer unlock()
+	z.ConfigManaged = false
+	z.Name = _cleanAbsName(strings.TrimSuffix(z.Name, ".") + ".")
+	z.NextSync = now.Add(z.SyncInterval)
+	if z.RefreshInterval > 0 {
+		z.NextRefresh = now.Add(z.RefreshInterval / (5 * 10))
+	}
+	err := tx.Insert(&z)
+	_checkf(err, "adding zone")
 
-		var cancel func()
-		ctx, cancel = context.WithTimeout(shutdownCtx, 30*time.Second)
-		defer cancel()
-		latest, err := getRecords(ctx, log, provider, z.Name, false)
-		if err != nil {
-			log.Debug("getting latest records through provider", "err", err)
-			return
+	_, provider, err := zoneProvider(tx, z.Name)
+	_checkf(err, "get zone and provider")
+
+	for _, n := range notifies {
+		n.Zone = z.Name
+		n.ConfigManaged = false
+		switch n.Protocol {
+		case "tcp", "udp":
+		default:
+			_checkuserf(fmt.Errorf("unknown protocol %q", n.Protocol), "checking notify")
 		}
+		_, _, err := net.SplitHostPort(n.Address)
+		_checkuserf(err, "checking notify address")
+		err = tx.Insert(&n)
+		_checkf(err, "inserting notify")
+	}
+
+	tsigbuf := make([]byte, 32)
+	_, err = io.ReadFull(cryptorand.Reader, tsigbuf)
+	_checkf(err, "read random")
+	cred := Credential{
+		Name:       "zone-default-tsig-" + strings.TrimSuffix(z.Name, "."),
+		Type:       "tsig",
+		TSIGSecret: _secretEncrypt(base64.StdEncoding.EncodeToString(tsigbuf)),
+	}
+	err = tx.Insert(&cred)
+	_checkf(err, "inserting tsig credential")
+	zonecred := ZoneCredential{
+		Zone:         z.Name,
+		CredentialID: cred.ID,
+	}
+	err = tx.Insert(&zonecred)
+	_checkf(err, "inserting tsig zone credential")
 
-		var changed bool
-		defer possiblyZoneNotify(log, z.Name, &changed)
+	err = discoverZoneConfigured(tx, z.Name)
+	_checkf(err, "updating zone discoveries")
+	return z, provider
+}
 
-		err = database.Write(ctx, func(tx *bstore.Tx) error {
-			_zone(tx, z.Name) // Again.
+// zoneInitialFetch fetches and stores the records for a newly added zone.
+func zoneInitialFetch(log *slog.Logger, provider Provider, z Zone) {
+	defer recoverPanic(log, "fetching records for new zone")
 
-			changed, _, _, _, err = syncRecords(log, tx, z, latest)
-			return err
-		})
-		if err != nil {
-			log.Debug("updating records in database", "err", err)
-		}
-	}()
+	unlock := lockZone(z.Name)
+	defer unlock()
 
-	return z
+	ctx, cancel := context.WithTimeout(shutdownCtx, 30*time.Second)
+	defer cancel()
+	latest, err := getZoneRecords(ctx, log, provider, z, true)
+	if err != nil {
+		log.Debug("getting latest records through provider", "err", err)
+		return
+	}
+
+	var changed bool
+	defer possiblyZoneNotify(log, z.Name, &changed)
+
+	err = database.Write(ctx, func(tx *bstore.Tx) error {
+		_zone(tx, z.Name) // Again.
+
+		changed, _, _, _, err = syncRecords(log, tx, z, latest)
+		return err
+	})
+	if err != nil {
+		log.Debug("updating records in database", "err", err)
+	}
 }
 
 // ZoneDelete removes a zone and all its records, credentials and dns notify addresses, from the database.
 func (x API) ZoneDelete(ctx context.Context, zone string) {
+	_checkAdmin(ctx, "removing zones")
+
 	_dbwrite(ctx, func(tx *bstore.Tx) {
 		z := _zone(tx, zone)
+		_checkNotManaged(z.ConfigManaged, "zone")
 
-		_, err := bstore.QueryTx[ZoneNotify](tx).FilterNonzero(ZoneNotify{Zone: z.Name}).Delete()
-		_checkf(err, "deleting notify addresses for zone")
-
-		zonecreds, err := bstore.QueryTx[ZoneCredential](tx).FilterNonzero(ZoneCredential{Zone: z.Name}).List()
-		_checkf(err, "listing zone credentials")
-		for _, zc := range zonecreds {
-			err = tx.Delete(&zc)
-			_checkf(err, "deleting zone credential")
-			err := tx.Delete(&Credential{ID: zc.CredentialID})
-			_checkf(err, "deleting credential")
-		}
-
-		_, err = bstore.QueryTx[Record](tx).FilterNonzero(Record{Zone: z.Name}).Delete()
-		_checkf(err, "deleting records for zone")
-
-		err = tx.Delete(&z)
-		_checkf(err, "deleting zone")
+		err := zoneRemove(tx, z)
+		_checkf(err, "removing zone")
+		recordsCacheClear(z.Name)
 
+		pc := ProviderConfig{Name: z.ProviderConfigName}
+		err = tx.Get(&pc)
+		_checkf(err, "get provider config")
 		exists, err := bstore.QueryTx[Zone](tx).FilterNonzero(Zone{ProviderConfigName: z.ProviderConfigName}).Exists()
 		_checkf(err, "checking if references to provider config still exists")
-		if !exists {
-			pc := ProviderConfig{Name: z.ProviderConfigName}
+		remove := !exists && !pc.ConfigManaged
+		err = discoverZoneRemoved(tx, z.ProviderConfigName, z.Name, remove)
+		_checkf(err, "updating zone discovery")
+		if remove {
 			err := tx.Delete(&pc)
 			_checkf(err, "deleting provider config")
 		}
 	})
+	audit(ctx, zone, "ZoneDelete", "removed zone")
+}
+
+// zoneRemove removes a zone with its records, credentials, dns notify addresses,
+// queued changes and propagation checks. Credentials are only removed when no
+// other zone references them and they are not managed through the configuration
+// file.
+func zoneRemove(tx *bstore.Tx, z Zone) error {
+	if _, err := bstore.QueryTx[ZoneNotify](tx).FilterNonzero(ZoneNotify{Zone: z.Name}).Delete(); err != nil {
+		return fmt.Errorf("deleting notify addresses for zone: %w", err)
+	}
+
+	zonecreds, err := bstore.QueryTx[ZoneCredential](tx).FilterNonzero(ZoneCredential{Zone: z.Name}).List()
+	if err != nil {
+		return fmt.Errorf("listing zone credentials: %w", err)
+	}
+	for _, zc := range zonecreds {
+		if err := tx.Delete(&zc); err != nil {
+			return fmt.Errorf("deleting zone credential: %w", err)
+		}
+		c := Credential{ID: zc.CredentialID}
+		if err := tx.Get(&c); err != nil {
+			return fmt.Errorf("get credential: %w", err)
+		}
+		exists, err := bstore.QueryTx[ZoneCredential](tx).FilterNonzero(ZoneCredential{CredentialID: c.ID}).Exists()
+		if err != nil {
+			return fmt.Errorf("checking if credential is still referenced: %w", err)
+		}
+		if !exists && !c.ConfigManaged {
+			if err := tx.Delete(&c); err != nil {
+				return fmt.Errorf("deleting credential: %w", err)
+			}
+		}
+	}
+
+	if _, err := bstore.QueryTx[QueuedChange](tx).FilterNonzero(QueuedChange{Zone: z.Name}).Delete(); err != nil {
+		return fmt.Errorf("deleting queued changes for zone: %w", err)
+	}
+	if _, err := bstore.QueryTx[PropagationCheck](tx).FilterNonzero(PropagationCheck{Zone: z.Name}).Delete(); err != nil {
+		return fmt.Errorf("deleting propagation checks for zone: %w", err)
+	}
+	if _, err := bstore.QueryTx[Record](tx).FilterNonzero(Record{Zone: z.Name}).Delete(); err != nil {
+		return fmt.Errorf("deleting records for zone: %w", err)
+	}
+	err = bstore.QueryTx[User](tx).FilterFn(func(u User) bool { return slices.Contains(u.Zones, z.Name) }).ForEach(func(u User) error {
+		u.Zones = slices.DeleteFunc(u.Zones, func(zone string) bool { return zone == z.Name })
+		return tx.Update(&u)
+	})
+	if err != nil {
+		return fmt.Errorf("removing zone from users: %w", err)
+	}
+	if err := tx.Delete(&z); err != nil {
+		return fmt.Errorf("deleting zone: %w", err)
+	}
+	metricZoneSyncErrors.DeleteLabelValues(z.Name)
+	return nil
 }
 
-// ZoneUpdate updates the provider config and refresh & sync interval for a zone.
+// _checkNotManaged fails with a user error if an object is managed through the
+// configuration file.
+func _checkNotManaged(managed bool, what string) {
+	if managed {
+		_checkuserf(errors.New("managed through configuration file"), "changing %s", what)
+	}
+}
+
+// ZoneUpdate updates the provider config, refresh & sync interval, records
+// freshness, DNS UPDATE and propagation settings for a zone.
 func (x API) ZoneUpdate(ctx context.Context, z Zone) (nz Zone) {
+	if z.RecordsFreshness < 0 {
+		_checkuserf(errors.New("must be >= 0"), "checking records freshness")
+	}
+
+	_checkZoneEdit(ctx, z.Name)
+
 	_dbwrite(ctx, func(tx *bstore.Tx) {
 		oz := _zone(tx, z.Name)
+		_checkNotManaged(oz.ConfigManaged, "zone")
+		if oz.ProviderConfigName != z.ProviderConfigName {
+			_checkAdmin(ctx, "changing provider config of zone")
+		}
 
 		oz.ProviderConfigName = z.ProviderConfigName
 		oz.RefreshInterval = z.RefreshInterval
 		oz.SyncInterval = z.SyncInterval
+		oz.RecordsFreshness = z.RecordsFreshness
+		oz.FreshPrerequisites = z.FreshPrerequisites
+		oz.QueueUpdates = z.QueueUpdates
+		oz.VerifyNameservers = z.VerifyNameservers
+		oz.DelayUpdateResponse = z.DelayUpdateResponse
 		if refresh := time.Now().Add(oz.RefreshInterval); refresh.Before(oz.NextRefresh) {
 			oz.NextRefresh = refresh
 		}
@@ -349,6 +547,7 @@ func (x API) ZoneUpdate(ctx context.Context, z Zone) (nz Zone) {
 		_checkf(err, "update zone")
 		nz = oz
 	})
+	audit(ctx, nz.Name, "ZoneUpdate", "changed zone settings")
 
 	refreshKick()
 	return
@@ -363,6 +562,7 @@ func (x API) ZoneNotify(ctx context.Context, zoneNotifyID int64) {
 	_dbread(ctx, func(tx *bstore.Tx) {
 		err := tx.Get(&zn)
 		_checkf(err, "get zone notify details")
+		_checkZoneEdit(ctx, zn.Zone)
 
 		q := bstore.QueryTx[Record](tx)
 		q.FilterNonzero(Record{Type: Type(dns.TypeSOA), Zone: zn.Zone})
@@ -375,32 +575,92 @@ func (x API) ZoneNotify(ctx context.Context, zoneNotifyID int64) {
 		soa = *soarr.(*dns.SOA)
 	})
 
-	err := dnsNotify(log, zn, soa)
+	err := dnsNotify(ctx, log, zn, soa)
 	_checkf(err, "notifying")
 }
 
 // ZoneNotifyAdd adds a new DNS NOTIFY destination to a zone.
 func (x API) ZoneNotifyAdd(ctx context.Context, zn ZoneNotify) (nzn ZoneNotify) {
+	_checkZoneEdit(ctx, zn.Zone)
+
 	_dbwrite(ctx, func(tx *bstore.Tx) {
 		zn.Created = time.Time{}
+		zn.ConfigManaged = false
 		err := tx.Insert(&zn)
 		_checkf(err, "inserting zone notify")
 		nzn = zn
 	})
+	audit(ctx, zn.Zone, "ZoneNotifyAdd", "added notify address %s (%s)", zn.Address, zn.Protocol)
 	return
 }
 
 // ZoneNotifyDelete removes a DNS NOTIFY destination from a zone.
 func (x API) ZoneNotifyDelete(ctx context.Context, zoneNotifyID int64) {
+	zn := ZoneNotify{ID: zoneNotifyID}
 	_dbwrite(ctx, func(tx *bstore.Tx) {
-		zn := ZoneNotify{ID: zoneNotifyID}
-		err := tx.Delete(&zn)
+		err := tx.Get(&zn)
+		_checkf(err, "get zone notify")
+		_checkZoneEdit(ctx, zn.Zone)
+		_checkNotManaged(zn.ConfigManaged, "zone notify")
+		err = tx.Delete(&zn)
 		_checkf(err, "deleting zone notify")
 	})
+	audit(ctx, zn.Zone, "ZoneNotifyDelete", "removed notify address %s (%s)", zn.Address, zn.Protocol)
+}
+
+// ZoneQueuedChanges returns the changes from DNS UPDATEs for the zone that are
+// still to be applied through the provider, including failed changes.
+func (x API) ZoneQueuedChanges(ctx context.Context, zone string) (changes []QueuedChange) {
+	_dbread(ctx, func(tx *bstore.Tx) {
+		z := _zone(tx, zone)
+		_checkZoneView(ctx, z.Name)
+
+		var err error
+		changes, err = bstore.QueryTx[QueuedChange](tx).FilterNonzero(QueuedChange{Zone: z.Name}).SortAsc("ID").List()
+		_checkf(err, "listing queued changes")
+	})
+	return
+}
+
+// QueuedChangeRetry schedules an immediate attempt at applying a queued change,
+// resetting its attempts, e.g. after it was marked as failed.
+func (x API) QueuedChangeRetry(ctx context.Context, queuedChangeID int64) (nqc QueuedChange) {
+	_dbwrite(ctx, func(tx *bstore.Tx) {
+		qc := QueuedChange{ID: queuedChangeID}
+		err := tx.Get(&qc)
+		_checkf(err, "get queued change")
+		_checkZoneEdit(ctx, qc.Zone)
+
+		qc.Attempts = 0
+		qc.Failed = false
+		qc.NextAttempt = time.Now()
+		err = tx.Update(&qc)
+		_checkf(err, "updating queued change")
+		nqc = qc
+	})
+	audit(ctx, nqc.Zone, "QueuedChangeRetry", "retrying queued change %d", nqc.ID)
+	queueKick()
+	return
+}
+
+// QueuedChangeDelete removes a queued change, it will not be applied.
+func (x API) QueuedChangeDelete(ctx context.Context, queuedChangeID int64) {
+	qc := QueuedChange{ID: queuedChangeID}
+	_dbwrite(ctx, func(tx *bstore.Tx) {
+		err := tx.Get(&qc)
+		_checkf(err, "get queued change")
+		_checkZoneEdit(ctx, qc.Zone)
+		err = tx.Delete(&qc)
+		_checkf(err, "deleting queued change")
+	})
+	audit(ctx, qc.Zone, "QueuedChangeDelete", "removed queued change %d, with %d records to add, %d to set, %d to delete", qc.ID, len(qc.Add), len(qc.Set), len(qc.Delete))
+	queueKick()
 }
 
 // ZoneCredentialAdd adds a new TSIG or TLS public key credential to a zone.
 func (x API) ZoneCredentialAdd(ctx context.Context, zone string, c Credential) (nc Credential) {
+	_checkZoneEdit(ctx, zone)
+
 	_dbwrite(ctx, func(tx *bstore.Tx) {
 		_zone(tx, zone)
 
@@ -409,6 +669,7 @@ func (x API) ZoneCredentialAdd(ctx context.Context, zone string, c Credential) (
 		c.Name = strings.TrimSuffix(name, ".")
 
 		c.Created = time.Time{}
+		c.ConfigManaged = false
 		switch c.Type {
 		case "tsig":
 			if c.TSIGSecret == "" {
@@ -445,24 +706,35 @@ func (x API) ZoneCredentialAdd(ctx context.Context, zone string, c Credential) (
 			_checkuserf(fmt.Errorf("unknown value %q", c.Type), "checking type")
 		}
 
+		plain := c.TSIGSecret
+		c.TSIGSecret = _secretEncrypt(plain)
 		err := tx.Insert(&c)
 		_checkf(err, "inserting credential")
 
-		zc := ZoneCredential{0, zone, c.ID}
+		zc := ZoneCredential{0, zone, c.ID, false}
 		err = tx.Insert(&zc)
 		_checkf(err, "inserting zone credential")
 
 		nc = c
+		nc.TSIGSecret = plain
 	})
+	audit(ctx, zone, "ZoneCredentialAdd", "added %s credential %s", nc.Type, nc.Name)
 	return
 }
 
 // ZoneCredentialDelete removes a TSIG/TLS public key credential from a zone.
 func (x API) ZoneCredentialDelete(ctx context.Context, credentialID int64) {
+	c := Credential{ID: credentialID}
+	var zone string
 	_dbwrite(ctx, func(tx *bstore.Tx) {
-		c := Credential{ID: credentialID}
 		err := tx.Get(&c)
 		_checkf(err, "get credential")
+		_checkNotManaged(c.ConfigManaged, "credential")
+
+		zc, err := bstore.QueryTx[ZoneCredential](tx).FilterNonzero(ZoneCredential{CredentialID: c.ID}).Get()
+		_checkf(err, "get zone credential")
+		zone = zc.Zone
+		_checkZoneEdit(ctx, zone)
 
 		n, err := bstore.QueryTx[ZoneCredential](tx).FilterNonzero(ZoneCredential{CredentialID: c.ID}).Delete()
 		if err == nil && n != 1 {
@@ -473,6 +745,40 @@ func (x API) ZoneCredentialDelete(ctx context.Context, credentialID int64) {
 		err = tx.Delete(&c)
 		_checkf(err, "delete credential")
 	})
+	audit(ctx, zone, "ZoneCredentialDelete", "removed %s credential %s", c.Type, c.Name)
+}
+
+// ZoneCredentialReveal returns the TSIG secret of a credential. The secret gives
+// access to all zones the credential is used for, so the user must be able to
+// change all of them.
+func (x API) ZoneCredentialReveal(ctx context.Context, credentialID int64) (tsigSecret string) {
+	c := Credential{ID: credentialID}
+	var zcl []ZoneCredential
+	_dbread(ctx, func(tx *bstore.Tx) {
+		err := tx.Get(&c)
+		_checkf(err, "get credential")
+
+		zcl, err = bstore.QueryTx[ZoneCredential](tx).FilterNonzero(ZoneCredential{CredentialID: c.ID}).List()
+		_checkf(err, "listing zone credentials")
+		if len(zcl) == 0 {
+			_checkAdmin(ctx, "revealing credentials not used by zones")
+		}
+		for _, zc := range zcl {
+			_checkZoneEdit(ctx, zc.Zone)
+		}
+
+		if c.Type != "tsig" {
+			_checkuserf(fmt.Errorf("credential has type %s", c.Type), "checking credential")
+		}
+		tsigSecret = _secretDecrypt(c.TSIGSecret)
+	})
+	if len(zcl) == 0 {
+		audit(ctx, "", "ZoneCredentialReveal", "revealed tsig secret of credential %s", c.Name)
+	}
+	for _, zc := range zcl {
+		audit(ctx, zc.Zone, "ZoneCredentialReveal", "revealed tsig secret of credential %s", c.Name)
+	}
+	return
 }
 
 // ZoneImportRecords parses records in zonefile, assuming standard zone file syntax,
@@ -482,6 +788,8 @@ func (x API) ZoneCredentialDelete(ctx context.Context, credentialID int64) {
 func (x API) ZoneImportRecords(ctx context.Context, zone, zonefile string) []Record {
 	log := cidlog(ctx)
 
+	_checkZoneEdit(ctx, zone)
+
 	var z Zone
 	var provider Provider
 	_dbread(ctx, func(tx *bstore.Tx) {
@@ -517,7 +825,7 @@ func (x API) ZoneImportRecords(ctx context.Context, zone, zonefile string) []Rec
 	defer unlock()
 
 	// Get latest.
-	latest, err := getRecords(ctx, log, provider, z.Name, false)
+	latest, err := getZoneRecords(ctx, log, provider, z, false)
 	_checkf(err, "get latest records")
 
 	var notify bool
@@ -541,12 +849,9 @@ func (x API) ZoneImportRecords(ctx context.Context, zone, zonefile string) []Rec
 	}
 	_checkf(err, "adding records via provider")
 	log.Debug("added record through provider", "records", l, "ladded", ladded)
+	audit(ctx, z.Name, "ZoneImportRecords", "imported %d records", len(l))
 
-	var rkl []recordKey
-	for _, r := range l {
-		rkl = append(rkl, r.recordKey())
-	}
-	inserted, _, err := ensurePropagate(ctx, log, provider, z, rkl, nil, soa.SerialFirst)
+	inserted, _, err := ensurePropagate(ctx, log, provider, z, l, nil, soa.SerialFirst, nil)
 	_checkf(err, "ensuring record propagation")
 	return inserted
 }
@@ -600,6 +905,8 @@ func _parseNewSet(zone string, rsc RecordSetChange) []Record {
 func (x API) RecordSetAdd(ctx context.Context, zone string, rsc RecordSetChange) []Record {
 	log := cidlog(ctx)
 
+	_checkZoneEdit(ctx, zone)
+
 	var z Zone
 	var provider Provider
 	var soa Record
@@ -615,7 +922,7 @@ func (x API) RecordSetAdd(ctx context.Context, zone string, rsc RecordSetChange)
 	defer unlock()
 
 	// Get latest.
-	latest, err := getRecords(ctx, log, provider, zone, false)
+	latest, err := getZoneRecords(ctx, log, provider, z, false)
 	_checkf(err, "get latest records")
 
 	var notify bool
@@ -647,12 +954,9 @@ func (x API) RecordSetAdd(ctx context.Context, zone string, rsc RecordSetChange)
 	}
 	_checkf(err, "adding records via provider")
 	log.Debug("added record through provider", "records", nset, "ladded", ladded)
+	audit(ctx, z.Name, "RecordSetAdd", "added %s %s with %d values", nset[0].AbsName, dns.Type(nset[0].Type), len(nset))
 
-	var expAdd []recordKey
-	for _, r := range nset {
-		expAdd = append(expAdd, r.recordKey())
-	}
-	inserted, _, err := ensurePropagate(ctx, log, provider, z, expAdd, nil, soa.SerialFirst)
+	inserted, _, err := ensurePropagate(ctx, log, provider, z, nset, nil, soa.SerialFirst, nil)
 	_checkf(err, "ensuring record propagation")
 	return inserted
 }
@@ -671,6 +975,8 @@ func (x API) RecordSetAdd(ctx context.Context, zone string, rsc RecordSetChange)
 func (x API) RecordSetUpdate(ctx context.Context, zone string, oldRelName string, rsc RecordSetChange, prevRecordIDs, valueRecordIDs []int64) []Record {
 	log := cidlog(ctx)
 
+	_checkZoneEdit(ctx, zone)
+
 	if len(rsc.Values) != len(valueRecordIDs) {
 		_checkuserf(errors.New("providerIDs must have same number of values"), "checking values")
 	}
@@ -693,7 +999,7 @@ func (x API) RecordSetUpdate(ctx context.Context, zone string, oldRelName string
 	defer unlock()
 
 	// Get latest.
-	latest, err := getRecords(ctx, log, provider, zone, false)
+	latest, err := getZoneRecords(ctx, log, provider, z, false)
 	_checkf(err, "get latest records")
 
 	var notify bool
@@ -747,7 +1053,7 @@ func (x API) RecordSetUpdate(ctx context.Context, zone string, oldRelName string
 	// no reference; SOA is an exception, there is always exactly 1), and use
 	// AppendRecords/DeleteRecords otherwise.
 	var adds []Record
-	var expAdds []recordKey
+	var expAdds []Record
 	var dels []Record
 	var sets []Record
 	for _, or := range oset {
@@ -764,11 +1070,11 @@ func (x API) RecordSetUpdate(ctx context.Context, zone string, oldRelName string
 			continue // Unchanged.
 		} else if oset[0].AbsName != oldAbsName || orID <= 0 || (ormap[orID].ProviderID == "" && ormap[orID].Type != Type(dns.TypeSOA)) {
 			adds = append(adds, nr)
-			expAdds = append(expAdds, nr.recordKey())
+			expAdds = append(expAdds, nr)
 		} else {
 			nr.ProviderID = ormap[orID].ProviderID
 			sets = append(sets, nr)
-			expAdds = append(expAdds, nr.recordKey())
+			expAdds = append(expAdds, nr)
 		}
 	}
 
@@ -813,7 +1119,9 @@ func (x API) RecordSetUpdate(ctx context.Context, zone string, oldRelName string
 		log.Debug("records added through provider", "added", ladded)
 	}
 
-	inserted, _, err := ensurePropagate(ctx, log, provider, z, expAdds, dels, soa.SerialFirst)
+	audit(ctx, z.Name, "RecordSetUpdate", "updated %s %s, %d values removed, %d updated, %d added", nset[0].AbsName, dns.Type(nset[0].Type), len(dels), len(sets), len(adds))
+
+	inserted, _, err := ensurePropagate(ctx, log, provider, z, expAdds, dels, soa.SerialFirst, nil)
 	_checkf(err, "ensuring propagation")
 	return inserted
 }
@@ -828,6 +1136,8 @@ func (x API) RecordSetUpdate(ctx context.Context, zone string, oldRelName string
 func (x API) RecordSetDelete(ctx context.Context, zone string, relName string, typ Type, recordIDs []int64) []Record {
 	log := cidlog(ctx)
 
+	_checkZoneEdit(ctx, zone)
+
 	_checkType(typ)
 
 	var z Zone
@@ -843,7 +1153,7 @@ func (x API) RecordSetDelete(ctx context.Context, zone string, relName string, t
 	defer unlock()
 
 	// Get latest.
-	latest, err := getRecords(ctx, log, provider, zone, false)
+	latest, err := getZoneRecords(ctx, log, provider, z, false)
 	_checkf(err, "get latest records")
 
 	var notify bool
@@ -889,8 +1199,9 @@ func (x API) RecordSetDelete(ctx context.Context, zone string, relName string, t
 	}
 	_checkf(err, "deleting records through provider")
 	log.Debug("records removed", "records", removed)
+	audit(ctx, z.Name, "RecordSetDelete", "removed %s %s with %d values", records[0].AbsName, dns.Type(records[0].Type), len(records))
 
-	_, dels, err := ensurePropagate(ctx, log, provider, z, nil, records, soa.SerialFirst)
+	_, dels, err := ensurePropagate(ctx, log, provider, z, nil, records, soa.SerialFirst, nil)
 	_checkf(err, "ensuring propagation")
 	return dels
 }
@@ -907,8 +1218,8 @@ func (x API) DNSTypeNames(ctx context.Context) map[uint16]string {
 
 // KnownProviders is a dummy method whose sole purpose is to get an API description
 // of all known providers in the API documentation, for use in TypeScript.
-func (x API) KnownProviders(ctx context.Context) (KnownProviders, sherpadoc.Section) {
-	return KnownProviders{}, sherpadoc.Section{}
+func (x API) KnownProviders(ctx context.Context) (KnownProviders, BuiltinProviders, sherpadoc.Section) {
+	return KnownProviders{}, BuiltinProviders{}, sherpadoc.Section{}
 }
 
 // Docs returns the API docs. The TypeScript code uses this documentation to build
@@ -923,6 +1234,8 @@ func (x API) Docs(ctx context.Context) sherpadoc.Section {
 func (x API) ProviderConfigTest(ctx context.Context, zone string, refreshIntervalSeconds int64, provider string, providerConfigJSON string) (nrecords int) {
 	log := cidlog(ctx)
 
+	_checkAdmin(ctx, "testing provider configs")
+
 	zone = strings.TrimSuffix(zone, ".") + "."
 	zone = _cleanAbsName(zone)
 
@@ -938,13 +1251,32 @@ func (x API) ProviderConfigTest(ctx context.Context, zone string, refreshInterva
 	return len(records)
 }
 
-// ProviderConfigs returns all provider configs.
+// ProviderConfigs returns all provider configs, without their provider config
+// JSON, see ProviderConfigReveal.
 func (x API) ProviderConfigs(ctx context.Context) (providerConfigs []ProviderConfig) {
 	_dbread(ctx, func(tx *bstore.Tx) {
 		var err error
 		providerConfigs, err = bstore.QueryTx[ProviderConfig](tx).List()
 		_checkf(err, "listing provider configs")
+		for i := range providerConfigs {
+			providerConfigs[i].ProviderConfigJSON = ""
+		}
+	})
+	return
+}
+
+// ProviderConfigReveal returns the provider config JSON of a provider config,
+// with its secrets, e.g. for editing.
+func (x API) ProviderConfigReveal(ctx context.Context, providerConfigName string) (providerConfigJSON string) {
+	_checkAdmin(ctx, "revealing provider configs")
+
+	_dbread(ctx, func(tx *bstore.Tx) {
+		pc := ProviderConfig{Name: providerConfigName}
+		err := tx.Get(&pc)
+		_checkf(err, "get provider config")
+		providerConfigJSON = _secretDecrypt(pc.ProviderConfigJSON)
 	})
+	audit(ctx, "", "ProviderConfigReveal", "revealed provider config %s", providerConfigName)
 	return
 }
 
@@ -954,8 +1286,18 @@ func (x API) ProviderURLs(ctx context.Context) map[string]string {
 	return providerURLs
 }
 
+// ProviderCapabilities returns the known capabilities of providers, by name.
+// Providers without capabilities are not known to have limitations.
+func (x API) ProviderCapabilities(ctx context.Context) map[string]ProviderCapabilities {
+	return providerCapabilities
+}
+
 // ProviderConfigAdd adds a new provider config.
 func (x API) ProviderConfigAdd(ctx context.Context, pc ProviderConfig) (npc ProviderConfig) {
+	_checkAdmin(ctx, "adding provider configs")
+	_checkProviderConfigPolicy(pc)
+	pc.ConfigManaged = false
+
 	_dbwrite(ctx, func(tx *bstore.Tx) {
 		_, err := providerForConfig(pc.ProviderName, pc.ProviderConfigJSON)
 		if err != nil && errors.Is(err, errProviderUserError) {
@@ -963,20 +1305,28 @@ func (x API) ProviderConfigAdd(ctx context.Context, pc ProviderConfig) (npc Prov
 		}
 		_checkf(err, "checking provider config")
 
+		npc = pc
+		pc.ProviderConfigJSON = _secretEncrypt(pc.ProviderConfigJSON)
 		err = tx.Insert(&pc)
 		_checkf(err, "update providerconfig")
-
-		npc = pc
 	})
+	audit(ctx, "", "ProviderConfigAdd", "added provider config %s for provider %s", pc.Name, pc.ProviderName)
+	discoverKick()
 	return
 }
 
-// ProviderConfigUpdate updates a provider config.
+// ProviderConfigUpdate updates a provider config. The health of the provider
+// config is reset.
 func (x API) ProviderConfigUpdate(ctx context.Context, pc ProviderConfig) (npc ProviderConfig) {
+	_checkAdmin(ctx, "changing provider configs")
+	_checkProviderConfigPolicy(pc)
+
 	_dbwrite(ctx, func(tx *bstore.Tx) {
 		opc := ProviderConfig{Name: pc.Name}
 		err := tx.Get(&opc)
 		_checkf(err, "get provider config")
+		_checkNotManaged(opc.ConfigManaged, "provider config")
+		pc.ConfigManaged = false
 
 		_, err = providerForConfig(pc.ProviderName, pc.ProviderConfigJSON)
 		if err != nil && errors.Is(err, errProviderUserError) {
@@ -984,14 +1334,159 @@ func (x API) ProviderConfigUpdate(ctx context.Context, pc ProviderConfig) (npc P
 		}
 		_checkf(err, "checking provider config")
 
+		npc = pc
+		pc.ProviderConfigJSON = _secretEncrypt(pc.ProviderConfigJSON)
 		err = tx.Update(&pc)
 		_checkf(err, "update providerconfig")
-
-		npc = pc
 	})
+	audit(ctx, "", "ProviderConfigUpdate", "updated provider config %s", pc.Name)
+	providerHealthReset(pc.Name)
+	recordsCacheClearProviderConfig(pc.Name)
+	refreshKick()
+	discoverKick()
 	return
 }
 
+func _checkProviderConfigPolicy(pc ProviderConfig) {
+	if pc.Retries < 0 {
+		_checkuserf(errors.New("must be >= 0"), "checking retries")
+	}
+	if pc.FailureThreshold < 0 {
+		_checkuserf(errors.New("must be >= 0"), "checking failure threshold")
+	}
+	if pc.DiscoverInterval < 0 {
+		_checkuserf(errors.New("must be >= 0"), "checking discover interval")
+	}
+}
+
+// ProviderConfigDiscoverZones lists the zones at the provider of a provider
+// config. Zones that are already configured have their provider config set.
+// Vanished are the zones configured with the provider config that are not listed
+// at the provider. Not all providers can list zones.
+func (x API) ProviderConfigDiscoverZones(ctx context.Context, providerConfigName string) (zones []DiscoveredZone, vanished []string) {
+	log := cidlog(ctx)
+
+	_checkAdmin(ctx, "discovering zones")
+
+	pc := ProviderConfig{Name: providerConfigName}
+	_dbread(ctx, func(tx *bstore.Tx) {
+		err := tx.Get(&pc)
+		_checkf(err, "get provider config")
+	})
+
+	zones, vanished, err := discoverZones(ctx, pc)
+	serr := discoverStore(ctx, log, pc, zones, vanished, err)
+	logCheck(log, serr, "storing zone discovery", "providerconfig", pc.Name)
+	if err != nil && (errors.Is(err, errProviderUserError) || errors.Is(err, errZoneListUnsupported)) {
+		_checkuserf(err, "discovering zones")
+	}
+	_checkf(err, "discovering zones")
+	return zones, vanished
+}
+
+// ZonesAdd adds zones with an existing provider config, typically zones found
+// with ProviderConfigDiscoverZones, with default refresh and sync intervals. Each
+// zone gets the notifies, and its own TSIG credential. Zones are added in a single
+// transaction, if one zone cannot be added, none are.
+func (x API) ZonesAdd(ctx context.Context, providerConfigName string, zones []string, notifies []ZoneNotify) (nzones []Zone) {
+	log := cidlog(ctx)
+	_checkAdmin(ctx, "adding zones")
+
+	var providers []Provider
+	_dbwrite(ctx, func(tx *bstore.Tx) {
+		for _, name := range zones {
+			z := Zone{
+				Name:               name,
+				ProviderConfigName: providerConfigName,
+				RefreshInterval:    time.Hour,
+				SyncInterval:       24 * time.Hour,
+			}
+			nz, provider := _zoneAdd(tx, z, slices.Clone(notifies))
+			nzones = append(nzones, nz)
+			providers = append(providers, provider)
+		}
+	})
+
+	for i, z := range nzones {
+		audit(ctx, z.Name, "ZoneAdd", "added zone with provider config %s", z.ProviderConfigName)
+		go zoneInitialFetch(log, providers[i], z)
+	}
+	return nzones
+}
+
+// ZoneDiscoveries returns the results of the last zone discovery for provider
+// configs, with zones that are new at the provider or have vanished. Requires
+// admin, API tokens limited to zones are not allowed.
+func (x API) ZoneDiscoveries(ctx context.Context) (discoveries []ZoneDiscovery) {
+	_checkAdmin(ctx, "listing zone discoveries")
+	discoveries, err := bstore.QueryDB[ZoneDiscovery](ctx, database).List()
+	_checkf(err, "listing zone discoveries")
+	return discoveries
+}
+
+// _tokenProviderConfigs returns the names of provider configs used by zones in
+// scope of the API token of the call, or nil if the call isn't limited to zones.
+func _tokenProviderConfigs(ctx context.Context, tx *bstore.Tx) map[string]bool {
+	if t := requestToken(ctx); t == nil || len(t.Zones) == 0 {
+		return nil
+	}
+	pcnames := map[string]bool{}
+	err := bstore.QueryTx[Zone](tx).FilterFn(func(z Zone) bool { return tokenAllowsZone(ctx, z.Name) }).ForEach(func(z Zone) error {
+		pcnames[z.ProviderConfigName] = true
+		return nil
+	})
+	_checkf(err, "listing zones")
+	return pcnames
+}
+
+// ProviderHealth returns the health of provider configs as tracked by the
+// circuit breaker, for provider configs used since startup. For API tokens
+// limited to zones, only provider configs of those zones are returned. Errors are
+// only returned to admins.
+func (x API) ProviderHealth(ctx context.Context) []ProviderHealth {
+	var pcnames map[string]bool
+	_dbread(ctx, func(tx *bstore.Tx) {
+		pcnames = _tokenProviderConfigs(ctx, tx)
+	})
+	admin := userIsAdmin(ctx)
+
+	var l []ProviderHealth
+	for _, ph := range providerHealthList() {
+		if pcnames != nil && !pcnames[ph.ProviderConfigName] {
+			continue
+		}
+		if !admin {
+			ph.LastError = ""
+		}
+		l = append(l, ph)
+	}
+	return l
+}
+
+// PropagationChecks returns the verifications of changes made through providers
+// that are still pending, or that have failed.
+func (x API) PropagationChecks(ctx context.Context) (checks []PropagationCheck) {
+	q := bstore.QueryDB[PropagationCheck](ctx, database)
+	q.FilterFn(func(pc PropagationCheck) bool { return tokenAllowsZone(ctx, pc.Zone) })
+	checks, err := q.SortAsc("ID").List()
+	_checkf(err, "listing propagation checks")
+	return checks
+}
+
+// PropagationCheckDelete removes a propagation check, typically after it failed.
+// A pending check is no longer resumed after a restart.
+func (x API) PropagationCheckDelete(ctx context.Context, propagationCheckID int64) {
+	pc := PropagationCheck{ID: propagationCheckID}
+	_dbwrite(ctx, func(tx *bstore.Tx) {
+		err := tx.Get(&pc)
+		_checkf(err, "get propagation check")
+		_checkZoneEdit(ctx, pc.Zone)
+		err = tx.Delete(&pc)
+		_checkf(err, "deleting propagation check")
+	})
+	audit(ctx, pc.Zone, "PropagationCheckDelete", "removed propagation check %d", pc.ID)
+}
+
 func _propagationStates(records []Record) (sets []RecordSet) {
 	m, err := propagationStates(time.Now(), records, "", -1, true)
 	_checkf(err, "get record sets and propagation states")
@@ -1016,6 +1511,7 @@ func _propagationStates(records []Record) (sets []RecordSet) {
 func (x API) ZoneRecordSets(ctx context.Context, zone string) (sets []RecordSet) {
 	_dbread(ctx, func(tx *bstore.Tx) {
 		z := _zone(tx, zone)
+		_checkZoneView(ctx, z.Name)
 		records, err := bstore.QueryTx[Record](tx).FilterNonzero(Record{Zone: z.Name}).List()
 		_checkf(err, "list records")
 		sets = _propagationStates(records)
@@ -1032,6 +1528,7 @@ func (x API) ZoneRecordSetHistory(ctx context.Context, zone, relName string, typ
 
 	_dbread(ctx, func(tx *bstore.Tx) {
 		z := _zone(tx, zone)
+		_checkZoneView(ctx, z.Name)
 		var err error
 		records, err = bstore.QueryTx[Record](tx).FilterNonzero(Record{Zone: z.Name}).List()
 		_checkf(err, "list records")
@@ -1050,3 +1547,303 @@ func (x API) ZoneRecordSetHistory(ctx context.Context, zone, relName string, typ
 	}
 	return versions[len(versions)-1].States
 }
+
+// LoginPrep returns a login token, and sets it as cookie. Both must be present
+// in the call to Login.
+func (x API) LoginPrep(ctx context.Context) string {
+	ri := ctx.Value(ctxKeyRequestInfo).(requestInfo)
+
+	token := genToken()
+	http.SetCookie(ri.w, &http.Cookie{
+		Name:     cookieLogin,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   30 * 60,
+		Secure:   ri.r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token
+}
+
+// OIDCEnabled returns whether users can log in through OpenID Connect, at
+// /auth/oidc/login.
+func (x API) OIDCEnabled(ctx context.Context) bool {
+	return oidc != nil
+}
+
+// Login verifies the username and password, and starts a session, setting a
+// session cookie. The returned CSRF token must be sent with subsequent API calls
+// in the x-dnsclay-csrf header.
+func (x API) Login(ctx context.Context, loginToken, username, password string) (csrfToken string) {
+	ri := ctx.Value(ctxKeyRequestInfo).(requestInfo)
+	log := cidlog(ctx)
+
+	c, err := ri.r.Cookie(cookieLogin)
+	if err != nil || c.Value == "" || subtle.ConstantTimeCompare([]byte(c.Value), []byte(loginToken)) != 1 {
+		panic(&sherpa.Error{Code: "user:error", Message: "missing or mismatched login token, try again"})
+	}
+
+	u, err := passwordLogin(ctx, username, password)
+	if err != nil {
+		panic(&sherpa.Error{Code: "user:loginFailed", Message: "invalid username or password"})
+	}
+
+	http.SetCookie(ri.w, &http.Cookie{Name: cookieLogin, Path: "/", MaxAge: -1})
+	csrfToken, err = sessionStart(ctx, ri.w, ri.r, u)
+	_checkf(err, "starting session")
+	log.Info("login", "username", u.Username)
+	return csrfToken
+}
+
+// Logout ends the session.
+func (x API) Logout(ctx context.Context) {
+	ri := ctx.Value(ctxKeyRequestInfo).(requestInfo)
+
+	err := database.Delete(ctx, &ri.session)
+	_checkf(err, "removing session")
+	http.SetCookie(ri.w, &http.Cookie{Name: cookieSession, Path: "/", MaxAge: -1})
+}
+
+// CurrentUser returns the logged in user.
+func (x API) CurrentUser(ctx context.Context) User {
+	u := requestUser(ctx)
+	if u == nil {
+		return User{}
+	}
+	nu := *u
+	nu.PasswordHash = ""
+	return nu
+}
+
+// PasswordChange changes the password of the logged in user. Other sessions of
+// the user are ended.
+func (x API) PasswordChange(ctx context.Context, currentPassword, newPassword string) {
+	ri := ctx.Value(ctxKeyRequestInfo).(requestInfo)
+	if ri.user == nil {
+		_checkuserf(errors.New("not logged in"), "changing password")
+	}
+	_checkPassword(newPassword)
+
+	_dbwrite(ctx, func(tx *bstore.Tx) {
+		u := User{ID: ri.user.ID}
+		err := tx.Get(&u)
+		_checkf(err, "get user")
+		err = passwordVerify(u.PasswordHash, currentPassword)
+		_checkuserf(err, "checking current password")
+
+		u.PasswordHash, err = passwordHash(newPassword)
+		_checkf(err, "hashing password")
+		err = tx.Update(&u)
+		_checkf(err, "updating user")
+
+		_, err = bstore.QueryTx[Session](tx).FilterNonzero(Session{UserID: u.ID}).FilterNotEqual("ID", ri.session.ID).Delete()
+		_checkf(err, "removing other sessions")
+	})
+	audit(ctx, "", "PasswordChange", "changed own password")
+}
+
+// Users returns all users, without password hashes.
+func (x API) Users(ctx context.Context) (users []User) {
+	_checkAdmin(ctx, "listing users")
+
+	users, err := bstore.QueryDB[User](ctx, database).SortAsc("Username").List()
+	_checkf(err, "listing users")
+	for i := range users {
+		users[i].PasswordHash = ""
+	}
+	return users
+}
+
+func _checkUser(tx *bstore.Tx, u *User) {
+	switch u.Role {
+	case RoleViewer, RoleAdmin:
+		u.Zones = nil
+	case RoleEditor:
+		for i, zone := range u.Zones {
+			z := _zone(tx, _cleanAbsName(strings.TrimSuffix(zone, ".")+"."))
+			u.Zones[i] = z.Name
+		}
+	default:
+		_checkuserf(fmt.Errorf("unknown role %q", u.Role), "checking role")
+	}
+}
+
+// _checkAdminRemains fails if the database would have no admin user anymore.
+func _checkAdminRemains(tx *bstore.Tx) {
+	exists, err := bstore.QueryTx[User](tx).FilterNonzero(User{Role: RoleAdmin}).Exists()
+	_checkf(err, "checking for admin users")
+	if !exists {
+		_checkuserf(errors.New("at least one admin user must remain"), "checking users")
+	}
+}
+
+// UserAdd adds a new user with a password.
+func (x API) UserAdd(ctx context.Context, u User, password string) (nu User) {
+	_checkAdmin(ctx, "adding users")
+	_checkPassword(password)
+
+	_dbwrite(ctx, func(tx *bstore.Tx) {
+		_checkUser(tx, &u)
+		var err error
+		u.ID = 0
+		u.Created = time.Time{}
+		u.PasswordHash, err = passwordHash(password)
+		_checkf(err, "hashing password")
+		err = tx.Insert(&u)
+		_checkf(err, "adding user")
+	})
+	audit(ctx, "", "UserAdd", "added user %s with role %s, zones %v", u.Username, u.Role, u.Zones)
+	nu = u
+	nu.PasswordHash = ""
+	return
+}
+
+// UserUpdate changes the role and zones of a user.
+func (x API) UserUpdate(ctx context.Context, u User) (nu User) {
+	_checkAdmin(ctx, "changing users")
+
+	_dbwrite(ctx, func(tx *bstore.Tx) {
+		ou := User{ID: u.ID}
+		err := tx.Get(&ou)
+		_checkf(err, "get user")
+
+		_checkUser(tx, &u)
+		ou.Role = u.Role
+		ou.Zones = u.Zones
+		err = tx.Update(&ou)
+		_checkf(err, "updating user")
+		_checkAdminRemains(tx)
+		nu = ou
+	})
+	audit(ctx, "", "UserUpdate", "changed user %s to role %s, zones %v", nu.Username, nu.Role, nu.Zones)
+	nu.PasswordHash = ""
+	return
+}
+
+// UserPasswordSet sets a new password for a user, ending the sessions of the
+// user.
+func (x API) UserPasswordSet(ctx context.Context, userID int64, password string) {
+	_checkAdmin(ctx, "setting passwords")
+	_checkPassword(password)
+
+	u := User{ID: userID}
+	_dbwrite(ctx, func(tx *bstore.Tx) {
+		err := tx.Get(&u)
+		_checkf(err, "get user")
+		u.PasswordHash, err = passwordHash(password)
+		_checkf(err, "hashing password")
+		err = tx.Update(&u)
+		_checkf(err, "updating user")
+		_, err = bstore.QueryTx[Session](tx).FilterNonzero(Session{UserID: u.ID}).Delete()
+		_checkf(err, "removing sessions")
+	})
+	audit(ctx, "", "UserPasswordSet", "set password for user %s", u.Username)
+}
+
+// UserDelete removes a user, its sessions and API tokens.
+func (x API) UserDelete(ctx context.Context, userID int64) {
+	_checkAdmin(ctx, "removing users")
+
+	u := User{ID: userID}
+	_dbwrite(ctx, func(tx *bstore.Tx) {
+		err := tx.Get(&u)
+		_checkf(err, "get user")
+		_, err = bstore.QueryTx[Session](tx).FilterNonzero(Session{UserID: u.ID}).Delete()
+		_checkf(err, "removing sessions")
+		_, err = bstore.QueryTx[APIToken](tx).FilterNonzero(APIToken{UserID: u.ID}).Delete()
+		_checkf(err, "removing api tokens")
+		err = tx.Delete(&u)
+		_checkf(err, "removing user")
+		_checkAdminRemains(tx)
+	})
+	audit(ctx, "", "UserDelete", "removed user %s", u.Username)
+}
+
+// AuditEvents returns the most recent changes made through the admin web
+// interface, newest first. If zone is non-empty, only changes for that zone are
+// returned.
+func (x API) AuditEvents(ctx context.Context, zone string) (events []AuditEvent) {
+	q := bstore.QueryDB[AuditEvent](ctx, database)
+	if zone != "" {
+		_checkZoneView(ctx, zone)
+		q.FilterNonzero(AuditEvent{Zone: zone})
+	} else if t := requestToken(ctx); t != nil && len(t.Zones) > 0 {
+		q.FilterFn(func(ev AuditEvent) bool { return slices.Contains(t.Zones, ev.Zone) })
+	}
+	events, err := q.SortDesc("ID").Limit(100).List()
+	_checkf(err, "listing audit events")
+	return events
+}
+
+// APITokens returns the API tokens of the logged in user, or of all users for
+// admins.
+func (x API) APITokens(ctx context.Context) (tokens []APIToken) {
+	q := bstore.QueryDB[APIToken](ctx, database)
+	if u := requestUser(ctx); u != nil && u.Role != RoleAdmin {
+		q.FilterNonzero(APIToken{UserID: u.ID})
+	}
+	tokens, err := q.SortAsc("ID").List()
+	_checkf(err, "listing api tokens")
+	for i := range tokens {
+		tokens[i].TokenHash = ""
+	}
+	return tokens
+}
+
+// APITokenAdd adds an API token for the logged in user, for use as bearer token
+// in the Authorization header of API calls. The token is only returned now, only
+// a hash is stored.
+func (x API) APITokenAdd(ctx context.Context, t APIToken) (nt APIToken, token string) {
+	u := requestUser(ctx)
+	if u == nil {
+		_checkuserf(errors.New("not logged in"), "adding api token")
+	}
+	if t.Name == "" {
+		_checkuserf(errors.New("name required"), "checking api token")
+	}
+	if t.Expires != nil && t.Expires.Before(time.Now()) {
+		_checkuserf(errors.New("expiration time in the past"), "checking api token")
+	}
+	for _, fn := range t.Functions {
+		if _, ok := reflect.TypeFor[API]().MethodByName(fn); !ok || slices.Contains(apiTokenExcluded, fn) {
+			_checkuserf(fmt.Errorf("unknown or excluded api function %q", fn), "checking api token")
+		}
+	}
+
+	token = "dnsclay_" + genToken()
+	_dbwrite(ctx, func(tx *bstore.Tx) {
+		for i, zone := range t.Zones {
+			z := _zone(tx, _cleanAbsName(strings.TrimSuffix(zone, ".")+"."))
+			t.Zones[i] = z.Name
+		}
+		t.ID = 0
+		t.Created = time.Time{}
+		t.UserID = u.ID
+		t.TokenHash = sessionTokenHash(token)
+		t.Prefix = token[:12]
+		t.LastUsed = nil
+		err := tx.Insert(&t)
+		_checkf(err, "adding api token")
+	})
+	audit(ctx, "", "APITokenAdd", "added api token %s (%s), read-only %v, zones %v, functions %v", t.Name, t.Prefix, t.ReadOnly, t.Zones, t.Functions)
+	nt = t
+	nt.TokenHash = ""
+	return nt, token
+}
+
+// APITokenRevoke removes an API token. Users can revoke their own tokens, admins
+// can revoke all tokens.
+func (x API) APITokenRevoke(ctx context.Context, apiTokenID int64) {
+	t := APIToken{ID: apiTokenID}
+	_dbwrite(ctx, func(tx *bstore.Tx) {
+		err := tx.Get(&t)
+		_checkf(err, "get api token")
+		if u := requestUser(ctx); u != nil && u.Role != RoleAdmin && u.ID != t.UserID {
+			_forbidden("revoking api token of other user")
+		}
+		err = tx.Delete(&t)
+		_checkf(err, "removing api token")
+	})
+	audit(ctx, "", "APITokenRevoke", "revoked api token %s (%s)", t.Name, t.Prefix)
+}
@@ -239,17 +239,24 @@ var api;
 		BaseURL["Sandbox"] = "https://api.sandbox.dnsmadeeasy.com/V2.0/";
 		BaseURL["Prod"] = "https://api.dnsmadeeasy.com/V2.0/";
 	})(BaseURL = api.BaseURL || (api.BaseURL = {}));
-	api.structTypes = { "AuthOpenStack": true, "Credential": true, "IntValue": true, "KnownProviders": true, "PropagationState": true, "Provider": true, "ProviderConfig": true, "Provider_alidns": true, "Provider_autodns": true, "Provider_azure": true, "Provider_bunny": true, "Provider_civo": true, "Provider_cloudflare": true, "Provider_cloudns": true, "Provider_ddnss": true, "Provider_desec": true, "Provider_digitalocean": true, "Provider_directadmin": true, "Provider_dnsimple": true, "Provider_dnsmadeeasy": true, "Provider_dnspod": true, "Provider_dnsupdate": true, "Provider_domainnameshop": true, "Provider_dreamhost": true, "Provider_duckdns": true, "Provider_dynu": true, "Provider_dynv6": true, "Provider_easydns": true, "Provider_exoscale": true, "Provider_gandi": true, "Provider_gcore": true, "Provider_glesys": true, "Provider_godaddy": true, "Provider_googleclouddns": true, "Provider_he": true, "Provider_hetzner": true, "Provider_hexonet": true, "Provider_hosttech": true, "Provider_huaweicloud": true, "Provider_infomaniak": true, "Provider_inwx": true, "Provider_ionos": true, "Provider_katapult": true, "Provider_leaseweb": true, "Provider_linode": true, "Provider_loopia": true, "Provider_luadns": true, "Provider_mailinabox": true, "Provider_metaname": true, "Provider_mijnhost": true, "Provider_mythicbeasts": true, "Provider_namecheap": true, "Provider_namedotcom": true, "Provider_namesilo": true, "Provider_nanelo": true, "Provider_netcup": true, "Provider_netlify": true, "Provider_nfsn": true, "Provider_njalla": true, "Provider_ovh": true, "Provider_porkbun": true, "Provider_powerdns": true, "Provider_rfc2136": true, "Provider_route53": true, "Provider_scaleway": true, "Provider_selectel": true, "Provider_tencentcloud": true, "Provider_timeweb": true, "Provider_totaluptime": true, "Provider_vultr": true, "Provider_westcn": true, "Record": true, "RecordSet": true, "RecordSetChange": true, "StringValue": true, "Zone": true, "ZoneNotify": true, "sherpadocArg": true, "sherpadocField": true, "sherpadocFunction": true, "sherpadocInts": true, "sherpadocSection": true, "sherpadocStrings": true, "sherpadocStruct": true };
-	api.stringsTypes = { "BaseURL": true };
+	let Role;
+	(function (Role) {
+		Role["RoleViewer"] = "viewer"; // Can view zones, records and settings, without secrets.
+		Role["RoleEditor"] = "editor"; // Can change records and settings of the zones listed in User.Zones.
+		Role["RoleAdmin"] = "admin"; // Can change everything, including provider configs and users.
+	})(Role = api.Role || (api.Role = {}));
+	api.structTypes = { "APIToken": true, "AuditEvent": true, "AuthOpenStack": true, "BuiltinProviders": true, "Credential": true, "DiscoveredZone": true, "IntValue": true, "KnownProviders": true, "NameserverCheck": true, "PropagationCheck": true, "PropagationState": true, "Provider": true, "ProviderCapabilities": true, "ProviderConfig": true, "ProviderHealth": true, "Provider_alidns": true, "Provider_autodns": true, "Provider_azure": true, "Provider_bunny": true, "Provider_civo": true, "Provider_cloudflare": true, "Provider_cloudns": true, "Provider_ddnss": true, "Provider_desec": true, "Provider_digitalocean": true, "Provider_directadmin": true, "Provider_dnsimple": true, "Provider_dnsmadeeasy": true, "Provider_dnspod": true, "Provider_dnsupdate": true, "Provider_domainnameshop": true, "Provider_dreamhost": true, "Provider_duckdns": true, "Provider_dynu": true, "Provider_dynv6": true, "Provider_easydns": true, "Provider_exoscale": true, "Provider_gandi": true, "Provider_gcore": true, "Provider_glesys": true, "Provider_godaddy": true, "Provider_googleclouddns": true, "Provider_he": true, "Provider_hetzner": true, "Provider_hexonet": true, "Provider_hosttech": true, "Provider_huaweicloud": true, "Provider_infomaniak": true, "Provider_inmemory": true, "Provider_inwx": true, "Provider_ionos": true, "Provider_katapult": true, "Provider_leaseweb": true, "Provider_linode": true, "Provider_loopia": true, "Provider_luadns": true, "Provider_mailinabox": true, "Provider_metaname": true, "Provider_mijnhost": true, "Provider_mythicbeasts": true, "Provider_namecheap": true, "Provider_namedotcom": true, "Provider_namesilo": true, "Provider_nanelo": true, "Provider_netcup": true, "Provider_netlify": true, "Provider_nfsn": true, "Provider_njalla": true, "Provider_ovh": true, "Provider_plugin": true, "Provider_porkbun": true, "Provider_powerdns": true, "Provider_rfc2136": true, "Provider_route53": true, "Provider_scaleway": true, "Provider_selectel": true, "Provider_tencentcloud": true, "Provider_timeweb": true, "Provider_totaluptime": true, "Provider_vultr": true, "Provider_webhook": true, "Provider_westcn": true, "Provider_zonefile": true, "QueuedChange": true, "Record": true, "RecordSet": true, "RecordSetChange": true, "StringValue": true, "User": true, "Zone": true, "ZoneDiscovery": true, "ZoneNotify": true, "sherpadocArg": true, "sherpadocField": true, "sherpadocFunction": true, "sherpadocInts": true, "sherpadocSection": true, "sherpadocStrings": true, "sherpadocStruct": true };
+	api.stringsTypes = { "BaseURL": true, "Role": true };
 	api.intsTypes = {};
 	api.types = {
-		"Zone": { "Name": "Zone", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "ProviderConfigName", "Docs": "", "Typewords": ["string"] }, { "Name": "SerialLocal", "Docs": "", "Typewords": ["uint32"] }, { "Name": "SerialRemote", "Docs": "", "Typewords": ["uint32"] }, { "Name": "LastSync", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "LastRecordChange", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "SyncInterval", "Docs": "", "Typewords": ["int64"] }, { "Name": "RefreshInterval", "Docs": "", "Typewords": ["int64"] }, { "Name": "NextSync", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "NextRefresh", "Docs": "", "Typewords": ["timestamp"] }] },
-		"ProviderConfig": { "Name": "ProviderConfig", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "ProviderName", "Docs": "", "Typewords": ["string"] }, { "Name": "ProviderConfigJSON", "Docs": "", "Typewords": ["string"] }] },
-		"ZoneNotify": { "Name": "ZoneNotify", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Zone", "Docs": "", "Typewords": ["string"] }, { "Name": "Address", "Docs": "", "Typewords": ["string"] }, { "Name": "Protocol", "Docs": "", "Typewords": ["string"] }] },
-		"Credential": { "Name": "Credential", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Type", "Docs": "", "Typewords": ["string"] }, { "Name": "TSIGSecret", "Docs": "", "Typewords": ["string"] }, { "Name": "TLSPublicKey", "Docs": "", "Typewords": ["string"] }] },
+		"Zone": { "Name": "Zone", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "ProviderConfigName", "Docs": "", "Typewords": ["string"] }, { "Name": "SerialLocal", "Docs": "", "Typewords": ["uint32"] }, { "Name": "SerialRemote", "Docs": "", "Typewords": ["uint32"] }, { "Name": "LastSync", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "LastRecordChange", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "SyncInterval", "Docs": "", "Typewords": ["int64"] }, { "Name": "RefreshInterval", "Docs": "", "Typewords": ["int64"] }, { "Name": "NextSync", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "NextRefresh", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "RecordsFreshness", "Docs": "", "Typewords": ["int64"] }, { "Name": "FreshPrerequisites", "Docs": "", "Typewords": ["bool"] }, { "Name": "QueueUpdates", "Docs": "", "Typewords": ["bool"] }, { "Name": "VerifyNameservers", "Docs": "", "Typewords": ["bool"] }, { "Name": "DelayUpdateResponse", "Docs": "", "Typewords": ["bool"] }, { "Name": "ConfigManaged", "Docs": "", "Typewords": ["bool"] }] },
+		"ProviderConfig": { "Name": "ProviderConfig", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "ProviderName", "Docs": "", "Typewords": ["string"] }, { "Name": "ProviderConfigJSON", "Docs": "", "Typewords": ["string"] }, { "Name": "Retries", "Docs": "", "Typewords": ["int32"] }, { "Name": "FailureThreshold", "Docs": "", "Typewords": ["int32"] }, { "Name": "DiscoverInterval", "Docs": "", "Typewords": ["int64"] }, { "Name": "RRSetUpdates", "Docs": "", "Typewords": ["bool"] }, { "Name": "MinTTL", "Docs": "", "Typewords": ["uint32"] }, { "Name": "ConfigManaged", "Docs": "", "Typewords": ["bool"] }] },
+		"ZoneNotify": { "Name": "ZoneNotify", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Zone", "Docs": "", "Typewords": ["string"] }, { "Name": "Address", "Docs": "", "Typewords": ["string"] }, { "Name": "Protocol", "Docs": "", "Typewords": ["string"] }, { "Name": "ConfigManaged", "Docs": "", "Typewords": ["bool"] }] },
+		"Credential": { "Name": "Credential", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Type", "Docs": "", "Typewords": ["string"] }, { "Name": "TSIGSecret", "Docs": "", "Typewords": ["string"] }, { "Name": "TLSPublicKey", "Docs": "", "Typewords": ["string"] }, { "Name": "ConfigManaged", "Docs": "", "Typewords": ["bool"] }] },
 		"RecordSet": { "Name": "RecordSet", "Docs": "", "Fields": [{ "Name": "Records", "Docs": "", "Typewords": ["[]", "Record"] }, { "Name": "States", "Docs": "", "Typewords": ["[]", "PropagationState"] }] },
 		"Record": { "Name": "Record", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Zone", "Docs": "", "Typewords": ["string"] }, { "Name": "SerialFirst", "Docs": "", "Typewords": ["uint32"] }, { "Name": "SerialDeleted", "Docs": "", "Typewords": ["uint32"] }, { "Name": "First", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Deleted", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "AbsName", "Docs": "", "Typewords": ["string"] }, { "Name": "Type", "Docs": "", "Typewords": ["uint16"] }, { "Name": "Class", "Docs": "", "Typewords": ["uint16"] }, { "Name": "TTL", "Docs": "", "Typewords": ["uint32"] }, { "Name": "DataHex", "Docs": "", "Typewords": ["string"] }, { "Name": "Value", "Docs": "", "Typewords": ["string"] }, { "Name": "ProviderID", "Docs": "", "Typewords": ["string"] }] },
 		"PropagationState": { "Name": "PropagationState", "Docs": "", "Fields": [{ "Name": "Start", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "End", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "Negative", "Docs": "", "Typewords": ["bool"] }, { "Name": "Records", "Docs": "", "Typewords": ["[]", "Record"] }] },
+		"QueuedChange": { "Name": "QueuedChange", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Zone", "Docs": "", "Typewords": ["string"] }, { "Name": "Add", "Docs": "", "Typewords": ["[]", "Record"] }, { "Name": "Set", "Docs": "", "Typewords": ["[]", "Record"] }, { "Name": "Delete", "Docs": "", "Typewords": ["[]", "Record"] }, { "Name": "Attempts", "Docs": "", "Typewords": ["int32"] }, { "Name": "LastAttempt", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "LastError", "Docs": "", "Typewords": ["string"] }, { "Name": "NextAttempt", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Failed", "Docs": "", "Typewords": ["bool"] }] },
 		"RecordSetChange": { "Name": "RecordSetChange", "Docs": "", "Fields": [{ "Name": "RelName", "Docs": "", "Typewords": ["string"] }, { "Name": "TTL", "Docs": "", "Typewords": ["uint32"] }, { "Name": "Type", "Docs": "", "Typewords": ["uint16"] }, { "Name": "Values", "Docs": "", "Typewords": ["[]", "string"] }] },
 		"KnownProviders": { "Name": "KnownProviders", "Docs": "", "Fields": [{ "Name": "Xalidns", "Docs": "", "Typewords": ["Provider_alidns"] }, { "Name": "Xautodns", "Docs": "", "Typewords": ["Provider_autodns"] }, { "Name": "Xazure", "Docs": "", "Typewords": ["Provider_azure"] }, { "Name": "Xbunny", "Docs": "", "Typewords": ["Provider_bunny"] }, { "Name": "Xcivo", "Docs": "", "Typewords": ["Provider_civo"] }, { "Name": "Xcloudflare", "Docs": "", "Typewords": ["Provider_cloudflare"] }, { "Name": "Xcloudns", "Docs": "", "Typewords": ["Provider_cloudns"] }, { "Name": "Xddnss", "Docs": "", "Typewords": ["Provider_ddnss"] }, { "Name": "Xdesec", "Docs": "", "Typewords": ["Provider_desec"] }, { "Name": "Xdigitalocean", "Docs": "", "Typewords": ["Provider_digitalocean"] }, { "Name": "Xdirectadmin", "Docs": "", "Typewords": ["Provider_directadmin"] }, { "Name": "Xdnsimple", "Docs": "", "Typewords": ["Provider_dnsimple"] }, { "Name": "Xdnsmadeeasy", "Docs": "", "Typewords": ["Provider_dnsmadeeasy"] }, { "Name": "Xdnspod", "Docs": "", "Typewords": ["Provider_dnspod"] }, { "Name": "Xdnsupdate", "Docs": "", "Typewords": ["Provider_dnsupdate"] }, { "Name": "Xdomainnameshop", "Docs": "", "Typewords": ["Provider_domainnameshop"] }, { "Name": "Xdreamhost", "Docs": "", "Typewords": ["Provider_dreamhost"] }, { "Name": "Xduckdns", "Docs": "", "Typewords": ["Provider_duckdns"] }, { "Name": "Xdynu", "Docs": "", "Typewords": ["Provider_dynu"] }, { "Name": "Xdynv6", "Docs": "", "Typewords": ["Provider_dynv6"] }, { "Name": "Xeasydns", "Docs": "", "Typewords": ["Provider_easydns"] }, { "Name": "Xexoscale", "Docs": "", "Typewords": ["Provider_exoscale"] }, { "Name": "Xgandi", "Docs": "", "Typewords": ["Provider_gandi"] }, { "Name": "Xgcore", "Docs": "", "Typewords": ["Provider_gcore"] }, { "Name": "Xglesys", "Docs": "", "Typewords": ["Provider_glesys"] }, { "Name": "Xgodaddy", "Docs": "", "Typewords": ["Provider_godaddy"] }, { "Name": "Xgoogleclouddns", "Docs": "", "Typewords": ["Provider_googleclouddns"] }, { "Name": "Xhe", "Docs": "", "Typewords": ["Provider_he"] }, { "Name": "Xhetzner", "Docs": "", "Typewords": ["Provider_hetzner"] }, { "Name": "Xhexonet", "Docs": "", "Typewords": ["Provider_hexonet"] }, { "Name": "Xhosttech", "Docs": "", "Typewords": ["Provider_hosttech"] }, { "Name": "Xhuaweicloud", "Docs": "", "Typewords": ["Provider_huaweicloud"] }, { "Name": "Xinfomaniak", "Docs": "", "Typewords": ["Provider_infomaniak"] }, { "Name": "Xinwx", "Docs": "", "Typewords": ["Provider_inwx"] }, { "Name": "Xionos", "Docs": "", "Typewords": ["Provider_ionos"] }, { "Name": "Xkatapult", "Docs": "", "Typewords": ["Provider_katapult"] }, { "Name": "Xleaseweb", "Docs": "", "Typewords": ["Provider_leaseweb"] }, { "Name": "Xlinode", "Docs": "", "Typewords": ["Provider_linode"] }, { "Name": "Xloopia", "Docs": "", "Typewords": ["Provider_loopia"] }, { "Name": "Xluadns", "Docs": "", "Typewords": ["Provider_luadns"] }, { "Name": "Xmailinabox", "Docs": "", "Typewords": ["Provider_mailinabox"] }, { "Name": "Xmetaname", "Docs": "", "Typewords": ["Provider_metaname"] }, { "Name": "Xmijnhost", "Docs": "", "Typewords": ["Provider_mijnhost"] }, { "Name": "Xmythicbeasts", "Docs": "", "Typewords": ["Provider_mythicbeasts"] }, { "Name": "Xnamecheap", "Docs": "", "Typewords": ["Provider_namecheap"] }, { "Name": "Xnamedotcom", "Docs": "", "Typewords": ["Provider_namedotcom"] }, { "Name": "Xnamesilo", "Docs": "", "Typewords": ["Provider_namesilo"] }, { "Name": "Xnanelo", "Docs": "", "Typewords": ["Provider_nanelo"] }, { "Name": "Xnetcup", "Docs": "", "Typewords": ["Provider_netcup"] }, { "Name": "Xnetlify", "Docs": "", "Typewords": ["Provider_netlify"] }, { "Name": "Xnfsn", "Docs": "", "Typewords": ["Provider_nfsn"] }, { "Name": "Xnjalla", "Docs": "", "Typewords": ["Provider_njalla"] }, { "Name": "Xopenstackdesignate", "Docs": "", "Typewords": ["Provider"] }, { "Name": "Xovh", "Docs": "", "Typewords": ["Provider_ovh"] }, { "Name": "Xporkbun", "Docs": "", "Typewords": ["Provider_porkbun"] }, { "Name": "Xpowerdns", "Docs": "", "Typewords": ["Provider_powerdns"] }, { "Name": "Xrfc2136", "Docs": "", "Typewords": ["Provider_rfc2136"] }, { "Name": "Xroute53", "Docs": "", "Typewords": ["Provider_route53"] }, { "Name": "Xscaleway", "Docs": "", "Typewords": ["Provider_scaleway"] }, { "Name": "Xselectel", "Docs": "", "Typewords": ["Provider_selectel"] }, { "Name": "Xtencentcloud", "Docs": "", "Typewords": ["Provider_tencentcloud"] }, { "Name": "Xtimeweb", "Docs": "", "Typewords": ["Provider_timeweb"] }, { "Name": "Xtotaluptime", "Docs": "", "Typewords": ["Provider_totaluptime"] }, { "Name": "Xvultr", "Docs": "", "Typewords": ["Provider_vultr"] }, { "Name": "Xwestcn", "Docs": "", "Typewords": ["Provider_westcn"] }] },
 		"Provider_alidns": { "Name": "Provider_alidns", "Docs": "", "Fields": [{ "Name": "access_key_id", "Docs": "", "Typewords": ["string"] }, { "Name": "access_key_secret", "Docs": "", "Typewords": ["string"] }, { "Name": "region_id", "Docs": "", "Typewords": ["nullable", "string"] }] },
@@ -318,6 +325,11 @@ var api;
 		"Provider_totaluptime": { "Name": "Provider_totaluptime", "Docs": "", "Fields": [{ "Name": "username", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "password", "Docs": "", "Typewords": ["nullable", "string"] }] },
 		"Provider_vultr": { "Name": "Provider_vultr", "Docs": "", "Fields": [{ "Name": "api_token", "Docs": "", "Typewords": ["nullable", "string"] }] },
 		"Provider_westcn": { "Name": "Provider_westcn", "Docs": "", "Fields": [{ "Name": "username", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "api_password", "Docs": "", "Typewords": ["nullable", "string"] }] },
+		"BuiltinProviders": { "Name": "BuiltinProviders", "Docs": "", "Fields": [{ "Name": "Xinmemory", "Docs": "", "Typewords": ["Provider_inmemory"] }, { "Name": "Xplugin", "Docs": "", "Typewords": ["Provider_plugin"] }, { "Name": "Xwebhook", "Docs": "", "Typewords": ["Provider_webhook"] }, { "Name": "Xzonefile", "Docs": "", "Typewords": ["Provider_zonefile"] }] },
+		"Provider_inmemory": { "Name": "Provider_inmemory", "Docs": "", "Fields": [{ "Name": "store", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "latency_ms", "Docs": "", "Typewords": ["nullable", "int32"] }, { "Name": "propagation_delay", "Docs": "", "Typewords": ["nullable", "int32"] }, { "Name": "fixed_serial", "Docs": "", "Typewords": ["nullable", "bool"] }, { "Name": "error_every", "Docs": "", "Typewords": ["nullable", "int32"] }, { "Name": "error_transient", "Docs": "", "Typewords": ["nullable", "bool"] }, { "Name": "unsupported_types", "Docs": "", "Typewords": ["nullable", "string"] }] },
+		"Provider_plugin": { "Name": "Provider_plugin", "Docs": "", "Fields": [{ "Name": "command", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "socket", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "config", "Docs": "", "Typewords": ["nullable", "string"] }] },
+		"Provider_webhook": { "Name": "Provider_webhook", "Docs": "", "Fields": [{ "Name": "base_url", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "auth_header", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "get_method", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "get_path", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "append_method", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "append_path", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "append_body", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "set_method", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "set_path", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "set_body", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "delete_method", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "delete_path", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "delete_body", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "records_path", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "field_id", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "field_type", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "field_name", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "field_value", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "field_ttl", "Docs": "", "Typewords": ["nullable", "string"] }] },
+		"Provider_zonefile": { "Name": "Provider_zonefile", "Docs": "", "Fields": [{ "Name": "path", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "reload_command", "Docs": "", "Typewords": ["nullable", "string"] }] },
 		"sherpadocSection": { "Name": "sherpadocSection", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Docs", "Docs": "", "Typewords": ["string"] }, { "Name": "Functions", "Docs": "", "Typewords": ["[]", "nullable", "sherpadocFunction"] }, { "Name": "Sections", "Docs": "", "Typewords": ["[]", "nullable", "sherpadocSection"] }, { "Name": "Structs", "Docs": "", "Typewords": ["[]", "sherpadocStruct"] }, { "Name": "Ints", "Docs": "", "Typewords": ["[]", "sherpadocInts"] }, { "Name": "Strings", "Docs": "", "Typewords": ["[]", "sherpadocStrings"] }, { "Name": "Version", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "SherpaVersion", "Docs": "", "Typewords": ["int32"] }, { "Name": "SherpadocVersion", "Docs": "", "Typewords": ["nullable", "int32"] }] },
 		"sherpadocFunction": { "Name": "sherpadocFunction", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Docs", "Docs": "", "Typewords": ["string"] }, { "Name": "Params", "Docs": "", "Typewords": ["[]", "sherpadocArg"] }, { "Name": "Returns", "Docs": "", "Typewords": ["[]", "sherpadocArg"] }] },
 		"sherpadocArg": { "Name": "sherpadocArg", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Typewords", "Docs": "", "Typewords": ["[]", "string"] }] },
@@ -327,7 +339,17 @@ var api;
 		"IntValue": { "Name": "IntValue", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Value", "Docs": "", "Typewords": ["int64"] }, { "Name": "Docs", "Docs": "", "Typewords": ["string"] }] },
 		"sherpadocStrings": { "Name": "sherpadocStrings", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Docs", "Docs": "", "Typewords": ["string"] }, { "Name": "Values", "Docs": "", "Typewords": ["[]", "StringValue"] }] },
 		"StringValue": { "Name": "StringValue", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Value", "Docs": "", "Typewords": ["string"] }, { "Name": "Docs", "Docs": "", "Typewords": ["string"] }] },
+		"ProviderCapabilities": { "Name": "ProviderCapabilities", "Docs": "", "Fields": [{ "Name": "Builtin", "Docs": "", "Typewords": ["bool"] }, { "Name": "Types", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "MinTTL", "Docs": "", "Typewords": ["uint32"] }, { "Name": "MaxTTL", "Docs": "", "Typewords": ["uint32"] }, { "Name": "Notes", "Docs": "", "Typewords": ["string"] }, { "Name": "RRSets", "Docs": "", "Typewords": ["bool"] }, { "Name": "NoSOA", "Docs": "", "Typewords": ["bool"] }, { "Name": "FixedSerial", "Docs": "", "Typewords": ["bool"] }] },
+		"DiscoveredZone": { "Name": "DiscoveredZone", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "ProviderConfigName", "Docs": "", "Typewords": ["string"] }] },
+		"ZoneDiscovery": { "Name": "ZoneDiscovery", "Docs": "", "Fields": [{ "Name": "ProviderConfigName", "Docs": "", "Typewords": ["string"] }, { "Name": "Last", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "Next", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Error", "Docs": "", "Typewords": ["string"] }, { "Name": "New", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Vanished", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"ProviderHealth": { "Name": "ProviderHealth", "Docs": "", "Fields": [{ "Name": "ProviderConfigName", "Docs": "", "Typewords": ["string"] }, { "Name": "Healthy", "Docs": "", "Typewords": ["bool"] }, { "Name": "ConsecutiveFailures", "Docs": "", "Typewords": ["int32"] }, { "Name": "LastError", "Docs": "", "Typewords": ["string"] }, { "Name": "LastErrorTime", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "UnhealthySince", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "NextAttempt", "Docs": "", "Typewords": ["nullable", "timestamp"] }] },
+		"PropagationCheck": { "Name": "PropagationCheck", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Zone", "Docs": "", "Typewords": ["string"] }, { "Name": "Add", "Docs": "", "Typewords": ["[]", "Record"] }, { "Name": "Delete", "Docs": "", "Typewords": ["[]", "Record"] }, { "Name": "PrevSerial", "Docs": "", "Typewords": ["uint32"] }, { "Name": "Checks", "Docs": "", "Typewords": ["int32"] }, { "Name": "LastCheck", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "NextCheck", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "ProviderDone", "Docs": "", "Typewords": ["bool"] }, { "Name": "Nameservers", "Docs": "", "Typewords": ["[]", "NameserverCheck"] }, { "Name": "Failed", "Docs": "", "Typewords": ["bool"] }, { "Name": "LastError", "Docs": "", "Typewords": ["string"] }] },
+		"NameserverCheck": { "Name": "NameserverCheck", "Docs": "", "Fields": [{ "Name": "Host", "Docs": "", "Typewords": ["string"] }, { "Name": "Addr", "Docs": "", "Typewords": ["string"] }, { "Name": "Done", "Docs": "", "Typewords": ["bool"] }, { "Name": "LastCheck", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "LastError", "Docs": "", "Typewords": ["string"] }] },
+		"User": { "Name": "User", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Username", "Docs": "", "Typewords": ["string"] }, { "Name": "Role", "Docs": "", "Typewords": ["Role"] }, { "Name": "Zones", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "PasswordHash", "Docs": "", "Typewords": ["string"] }, { "Name": "OIDCSubject", "Docs": "", "Typewords": ["string"] }] },
+		"AuditEvent": { "Name": "AuditEvent", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Time", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Username", "Docs": "", "Typewords": ["string"] }, { "Name": "APIToken", "Docs": "", "Typewords": ["string"] }, { "Name": "Zone", "Docs": "", "Typewords": ["string"] }, { "Name": "Action", "Docs": "", "Typewords": ["string"] }, { "Name": "Details", "Docs": "", "Typewords": ["string"] }] },
+		"APIToken": { "Name": "APIToken", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "UserID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "TokenHash", "Docs": "", "Typewords": ["string"] }, { "Name": "Prefix", "Docs": "", "Typewords": ["string"] }, { "Name": "ReadOnly", "Docs": "", "Typewords": ["bool"] }, { "Name": "Zones", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Functions", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Expires", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "LastUsed", "Docs": "", "Typewords": ["nullable", "timestamp"] }] },
 		"BaseURL": { "Name": "BaseURL", "Docs": "", "Values": [{ "Name": "Sandbox", "Value": "https://api.sandbox.dnsmadeeasy.com/V2.0/", "Docs": "" }, { "Name": "Prod", "Value": "https://api.dnsmadeeasy.com/V2.0/", "Docs": "" }] },
+		"Role": { "Name": "Role", "Docs": "", "Values": [{ "Name": "RoleViewer", "Value": "viewer", "Docs": "" }, { "Name": "RoleEditor", "Value": "editor", "Docs": "" }, { "Name": "RoleAdmin", "Value": "admin", "Docs": "" }] },
 	};
 	api.parser = {
 		Zone: (v) => api.parse("Zone", v),
@@ -337,6 +359,7 @@ var api;
 		RecordSet: (v) => api.parse("RecordSet", v),
 		Record: (v) => api.parse("Record", v),
 		PropagationState: (v) => api.parse("PropagationState", v),
+		QueuedChange: (v) => api.parse("QueuedChange", v),
 		RecordSetChange: (v) => api.parse("RecordSetChange", v),
 		KnownProviders: (v) => api.parse("KnownProviders", v),
 		Provider_alidns: (v) => api.parse("Provider_alidns", v),
@@ -405,6 +428,11 @@ var api;
 		Provider_totaluptime: (v) => api.parse("Provider_totaluptime", v),
 		Provider_vultr: (v) => api.parse("Provider_vultr", v),
 		Provider_westcn: (v) => api.parse("Provider_westcn", v),
+		BuiltinProviders: (v) => api.parse("BuiltinProviders", v),
+		Provider_inmemory: (v) => api.parse("Provider_inmemory", v),
+		Provider_plugin: (v) => api.parse("Provider_plugin", v),
+		Provider_webhook: (v) => api.parse("Provider_webhook", v),
+		Provider_zonefile: (v) => api.parse("Provider_zonefile", v),
 		sherpadocSection: (v) => api.parse("sherpadocSection", v),
 		sherpadocFunction: (v) => api.parse("sherpadocFunction", v),
 		sherpadocArg: (v) => api.parse("sherpadocArg", v),
@@ -414,7 +442,17 @@ var api;
 		IntValue: (v) => api.parse("IntValue", v),
 		sherpadocStrings: (v) => api.parse("sherpadocStrings", v),
 		StringValue: (v) => api.parse("StringValue", v),
+		ProviderCapabilities: (v) => api.parse("ProviderCapabilities", v),
+		DiscoveredZone: (v) => api.parse("DiscoveredZone", v),
+		ZoneDiscovery: (v) => api.parse("ZoneDiscovery", v),
+		ProviderHealth: (v) => api.parse("ProviderHealth", v),
+		PropagationCheck: (v) => api.parse("PropagationCheck", v),
+		NameserverCheck: (v) => api.parse("NameserverCheck", v),
+		User: (v) => api.parse("User", v),
+		AuditEvent: (v) => api.parse("AuditEvent", v),
+		APIToken: (v) => api.parse("APIToken", v),
 		BaseURL: (v) => api.parse("BaseURL", v),
+		Role: (v) => api.parse("Role", v),
 	};
 	// API is the webapi used by the admin frontend.
 	let defaultOptions = { slicesNullable: true, mapsNullable: true, nullableOptional: true };
@@ -452,7 +490,8 @@ var api;
 		// record sets includes those no long active (i.e. deleted). The
 		// history/propagation state fo the record sets only includes those that may still
 		// be in caches. Use ZoneRecordSetHistory for the full history for a single record
-		// set.
+		// set. The provider config JSON and TSIG secrets are not returned, see
+		// ProviderConfigReveal and ZoneCredentialReveal.
 		async Zone(zone) {
 			const fn = "Zone";
 			const paramTypes = [["string"]];
@@ -506,7 +545,8 @@ var api;
 			const params = [zone];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
-		// ZoneUpdate updates the provider config and refresh & sync interval for a zone.
+		// ZoneUpdate updates the provider config, refresh & sync interval, records
+		// freshness, DNS UPDATE and propagation settings for a zone.
 		async ZoneUpdate(z) {
 			const fn = "ZoneUpdate";
 			const paramTypes = [["Zone"]];
@@ -538,6 +578,32 @@ var api;
 			const params = [zoneNotifyID];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// ZoneQueuedChanges returns the changes from DNS UPDATEs for the zone that are
+		// still to be applied through the provider, including failed changes.
+		async ZoneQueuedChanges(zone) {
+			const fn = "ZoneQueuedChanges";
+			const paramTypes = [["string"]];
+			const returnTypes = [["[]", "QueuedChange"]];
+			const params = [zone];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// QueuedChangeRetry schedules an immediate attempt at applying a queued change,
+		// resetting its attempts, e.g. after it was marked as failed.
+		async QueuedChangeRetry(queuedChangeID) {
+			const fn = "QueuedChangeRetry";
+			const paramTypes = [["int64"]];
+			const returnTypes = [["QueuedChange"]];
+			const params = [queuedChangeID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// QueuedChangeDelete removes a queued change, it will not be applied.
+		async QueuedChangeDelete(queuedChangeID) {
+			const fn = "QueuedChangeDelete";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [queuedChangeID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// ZoneCredentialAdd adds a new TSIG or TLS public key credential to a zone.
 		async ZoneCredentialAdd(zone, c) {
 			const fn = "ZoneCredentialAdd";
@@ -554,6 +620,16 @@ var api;
 			const params = [credentialID];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// ZoneCredentialReveal returns the TSIG secret of a credential. The secret gives
+		// access to all zones the credential is used for, so the user must be able to
+		// change all of them.
+		async ZoneCredentialReveal(credentialID) {
+			const fn = "ZoneCredentialReveal";
+			const paramTypes = [["int64"]];
+			const returnTypes = [["string"]];
+			const params = [credentialID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// ZoneImportRecords parses records in zonefile, assuming standard zone file syntax,
 		// and adds the records via the provider and syncs the newly added records to the
 		// local database. The latest records, included historic/deleted records after the
@@ -632,7 +708,7 @@ var api;
 		async KnownProviders() {
 			const fn = "KnownProviders";
 			const paramTypes = [];
-			const returnTypes = [["KnownProviders"], ["sherpadocSection"]];
+			const returnTypes = [["KnownProviders"], ["BuiltinProviders"], ["sherpadocSection"]];
 			const params = [];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
@@ -655,7 +731,8 @@ var api;
 			const params = [zone, refreshIntervalSeconds, provider, providerConfigJSON];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
-		// ProviderConfigs returns all provider configs.
+		// ProviderConfigs returns all provider configs, without their provider config
+		// JSON, see ProviderConfigReveal.
 		async ProviderConfigs() {
 			const fn = "ProviderConfigs";
 			const paramTypes = [];
@@ -663,6 +740,15 @@ var api;
 			const params = [];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// ProviderConfigReveal returns the provider config JSON of a provider config,
+		// with its secrets, e.g. for editing.
+		async ProviderConfigReveal(providerConfigName) {
+			const fn = "ProviderConfigReveal";
+			const paramTypes = [["string"]];
+			const returnTypes = [["string"]];
+			const params = [providerConfigName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// ProviderURLs returns a mapping of provider names to URLs of their
 		// repositories, for further help/instructions.
 		async ProviderURLs() {
@@ -672,6 +758,15 @@ var api;
 			const params = [];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// ProviderCapabilities returns the known capabilities of providers, by name.
+		// Providers without capabilities are not known to have limitations.
+		async ProviderCapabilities() {
+			const fn = "ProviderCapabilities";
+			const paramTypes = [];
+			const returnTypes = [["{}", "ProviderCapabilities"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// ProviderConfigAdd adds a new provider config.
 		async ProviderConfigAdd(pc) {
 			const fn = "ProviderConfigAdd";
@@ -680,7 +775,8 @@ var api;
 			const params = [pc];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
-		// ProviderConfigUpdate updates a provider config.
+		// ProviderConfigUpdate updates a provider config. The health of the provider
+		// config is reset.
 		async ProviderConfigUpdate(pc) {
 			const fn = "ProviderConfigUpdate";
 			const paramTypes = [["ProviderConfig"]];
@@ -688,6 +784,67 @@ var api;
 			const params = [pc];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// ProviderConfigDiscoverZones lists the zones at the provider of a provider
+		// config. Zones that are already configured have their provider config set.
+		// Vanished are the zones configured with the provider config that are not listed
+		// at the provider. Not all providers can list zones.
+		async ProviderConfigDiscoverZones(providerConfigName) {
+			const fn = "ProviderConfigDiscoverZones";
+			const paramTypes = [["string"]];
+			const returnTypes = [["[]", "DiscoveredZone"], ["[]", "string"]];
+			const params = [providerConfigName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// ZonesAdd adds zones with an existing provider config, typically zones found
+		// with ProviderConfigDiscoverZones, with default refresh and sync intervals. Each
+		// zone gets the notifies, and its own TSIG credential. Zones are added in a single
+		// transaction, if one zone cannot be added, none are.
+		async ZonesAdd(providerConfigName, zones, notifies) {
+			const fn = "ZonesAdd";
+			const paramTypes = [["string"], ["[]", "string"], ["[]", "ZoneNotify"]];
+			const returnTypes = [["[]", "Zone"]];
+			const params = [providerConfigName, zones, notifies];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// ZoneDiscoveries returns the results of the last zone discovery for provider
+		// configs, with zones that are new at the provider or have vanished. Requires
+		// admin, API tokens limited to zones are not allowed.
+		async ZoneDiscoveries() {
+			const fn = "ZoneDiscoveries";
+			const paramTypes = [];
+			const returnTypes = [["[]", "ZoneDiscovery"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// ProviderHealth returns the health of provider configs as tracked by the
+		// circuit breaker, for provider configs used since startup. For API tokens
+		// limited to zones, only provider configs of those zones are returned. Errors are
+		// only returned to admins.
+		async ProviderHealth() {
+			const fn = "ProviderHealth";
+			const paramTypes = [];
+			const returnTypes = [["[]", "ProviderHealth"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// PropagationChecks returns the verifications of changes made through providers
+		// that are still pending, or that have failed.
+		async PropagationChecks() {
+			const fn = "PropagationChecks";
+			const paramTypes = [];
+			const returnTypes = [["[]", "PropagationCheck"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// PropagationCheckDelete removes a propagation check, typically after it failed.
+		// A pending check is no longer resumed after a restart.
+		async PropagationCheckDelete(propagationCheckID) {
+			const fn = "PropagationCheckDelete";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [propagationCheckID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// ZoneRecordSets returns the current record sets including propagation states that
 		// are not the latest version but that may still be in caches. For the full history
 		// of a record set, see ZoneRecordSetHistory.
@@ -707,6 +864,138 @@ var api;
 			const params = [zone, relName, typ];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// LoginPrep returns a login token, and sets it as cookie. Both must be present
+		// in the call to Login.
+		async LoginPrep() {
+			const fn = "LoginPrep";
+			const paramTypes = [];
+			const returnTypes = [["string"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// OIDCEnabled returns whether users can log in through OpenID Connect, at
+		// /auth/oidc/login.
+		async OIDCEnabled() {
+			const fn = "OIDCEnabled";
+			const paramTypes = [];
+			const returnTypes = [["bool"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// Login verifies the username and password, and starts a session, setting a
+		// session cookie. The returned CSRF token must be sent with subsequent API calls
+		// in the x-dnsclay-csrf header.
+		async Login(loginToken, username, password) {
+			const fn = "Login";
+			const paramTypes = [["string"], ["string"], ["string"]];
+			const returnTypes = [["string"]];
+			const params = [loginToken, username, password];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// Logout ends the session.
+		async Logout() {
+			const fn = "Logout";
+			const paramTypes = [];
+			const returnTypes = [];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// CurrentUser returns the logged in user.
+		async CurrentUser() {
+			const fn = "CurrentUser";
+			const paramTypes = [];
+			const returnTypes = [["User"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// PasswordChange changes the password of the logged in user. Other sessions of
+		// the user are ended.
+		async PasswordChange(currentPassword, newPassword) {
+			const fn = "PasswordChange";
+			const paramTypes = [["string"], ["string"]];
+			const returnTypes = [];
+			const params = [currentPassword, newPassword];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// Users returns all users, without password hashes.
+		async Users() {
+			const fn = "Users";
+			const paramTypes = [];
+			const returnTypes = [["[]", "User"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// UserAdd adds a new user with a password.
+		async UserAdd(u, password) {
+			const fn = "UserAdd";
+			const paramTypes = [["User"], ["string"]];
+			const returnTypes = [["User"]];
+			const params = [u, password];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// UserUpdate changes the role and zones of a user.
+		async UserUpdate(u) {
+			const fn = "UserUpdate";
+			const paramTypes = [["User"]];
+			const returnTypes = [["User"]];
+			const params = [u];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// UserPasswordSet sets a new password for a user, ending the sessions of the
+		// user.
+		async UserPasswordSet(userID, password) {
+			const fn = "UserPasswordSet";
+			const paramTypes = [["int64"], ["string"]];
+			const returnTypes = [];
+			const params = [userID, password];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// UserDelete removes a user, its sessions and API tokens.
+		async UserDelete(userID) {
+			const fn = "UserDelete";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [userID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AuditEvents returns the most recent changes made through the admin web
+		// interface, newest first. If zone is non-empty, only changes for that zone are
+		// returned.
+		async AuditEvents(zone) {
+			const fn = "AuditEvents";
+			const paramTypes = [["string"]];
+			const returnTypes = [["[]", "AuditEvent"]];
+			const params = [zone];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// APITokens returns the API tokens of the logged in user, or of all users for
+		// admins.
+		async APITokens() {
+			const fn = "APITokens";
+			const paramTypes = [];
+			const returnTypes = [["[]", "APIToken"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// APITokenAdd adds an API token for the logged in user, for use as bearer token
+		// in the Authorization header of API calls. The token is only returned now, only
+		// a hash is stored.
+		async APITokenAdd(t) {
+			const fn = "APITokenAdd";
+			const paramTypes = [["APIToken"]];
+			const returnTypes = [["APIToken"], ["string"]];
+			const params = [t];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// APITokenRevoke removes an API token. Users can revoke their own tokens, admins
+		// can revoke all tokens.
+		async APITokenRevoke(apiTokenID) {
+			const fn = "APITokenRevoke";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [apiTokenID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 	}
 	api.Client = Client;
 	api.defaultBaseURL = (function () {
@@ -1063,7 +1352,40 @@ let crumbElem = dom.span();
 let pageElem = dom.div(style({ padding: '1em' }), dom.div(style({ textAlign: 'center' }), 'Loading...'));
 let version;
 let dnsTypeNames = {};
-const client = new api.Client();
+let currentUser;
+// login is called by the API client when a call fails for lack of a session. It
+// shows a login form, and resolves with the CSRF token, which is also stored in
+// localStorage for use after a reload.
+const login = async (reason) => {
+	const oidcEnabled = await client.OIDCEnabled();
+	return new Promise((resolve) => {
+		let fieldset;
+		let username;
+		let password;
+		let errorElem;
+		const [close] = popupOpts(true, dom.h1('Login'), dom.form(async function submit(e) {
+			e.preventDefault();
+			e.stopPropagation();
+			fieldset.disabled = true;
+			try {
+				const loginToken = await client.LoginPrep();
+				const token = await client.Login(loginToken, username.value, password.value);
+				localStorage.setItem('dnsclaycsrftoken', token);
+				close();
+				resolve(token);
+			}
+			catch (err) {
+				dom._kids(errorElem, err.message);
+				password.focus();
+			}
+			finally {
+				fieldset.disabled = false;
+			}
+		}, fieldset = dom.fieldset(style({ display: 'flex', flexDirection: 'column', gap: '2ex', width: '20em' }), errorElem = dom.div(style({ color: 'red' }), reason ? 'Error: ' + reason : ''), dom.label(dom.div('Username'), username = dom.input(attr.required(''), attr.autocomplete('username'), style({ width: '100%' }))), dom.label(dom.div('Password'), password = dom.input(attr.type('password'), attr.required(''), attr.autocomplete('current-password'), style({ width: '100%' }))), dom.div(dom.submitbutton('Login')))), oidcEnabled ? dom.p(dom.a(attr.href('auth/oidc/login'), 'Login with single sign-on')) : []);
+		username.focus();
+	});
+};
+const client = new api.Client().withOptions({ csrfHeader: 'x-dnsclay-csrf', login: login }).withAuthToken(localStorage.getItem('dnsclaycsrftoken') || '');
 const link = (href, anchor) => dom.a(attr.href(href), anchor);
 const trimDot = (s) => s.replace(/\.$/, '');
 const check = async (elem, fn) => {
@@ -1140,6 +1462,9 @@ const chunked = (l, len) => {
 	return r;
 };
 const popup = (...kids) => popupOpts(false, ...kids);
+// Attributes for buttons that change objects managed through the configuration
+// file, disabling them.
+const configManaged = (managed) => managed ? [attr.disabled(''), attr.title('Managed through configuration file.')] : [];
 const availableProviders = async () => {
 	const docs = await client.Docs();
 	const stringEnums = new Map();
@@ -1149,6 +1474,41 @@ const availableProviders = async () => {
 	const providers = (docs.Structs || []).filter(struct => struct.Name.startsWith('Provider_'));
 	return [stringEnums, providers];
 };
+// capabilitiesText returns a description of the known capabilities of a provider.
+const capabilitiesText = (c) => {
+	if (!c) {
+		return 'No known limitations.';
+	}
+	const l = [];
+	if (c.Types && c.Types.length > 0) {
+		l.push('Record types: ' + c.Types.join(', ') + '.');
+	}
+	if (c.MinTTL) {
+		l.push('Minimum TTL: ' + c.MinTTL + 's.');
+	}
+	if (c.MaxTTL) {
+		l.push('Maximum TTL: ' + c.MaxTTL + 's.');
+	}
+	if (c.RRSets) {
+		l.push('Operates on record sets.');
+	}
+	if (c.NoSOA) {
+		l.push('Does not return SOA record.');
+	}
+	if (c.FixedSerial) {
+		l.push('SOA serial does not change.');
+	}
+	if (c.Notes) {
+		l.push(c.Notes);
+	}
+	return l.length > 0 ? l.join(' ') : 'No known limitations.';
+};
+const providerHealthView = (h) => {
+	if (h.Healthy) {
+		return dom.span('healthy', h.LastError ? attr.title('Last error: ' + h.LastError) : []);
+	}
+	return dom.span(style({ color: 'red' }), 'unhealthy', attr.title(`Unhealthy since ${formatDate(h.UnhealthySince)} after ${h.ConsecutiveFailures} consecutive failures.\nNext attempt at ${formatDate(h.NextAttempt)}.\nLast error: ${h.LastError}`));
+};
 const providerConfigJSON = (fields) => {
 	const config = {};
 	for (const [k, f] of fields.fieldMap) {
@@ -1200,7 +1560,7 @@ const providerFields = (p, stringEnums, configJSON) => {
 			typ = 'number';
 		}
 		else if (tw[0] === 'string') {
-			input = dom.input(config[f.Name] ? attr.value('' + config[f.Name]) : []);
+			input = dom.input(config[f.Name] ? attr.value('' + config[f.Name]) : [], attr.placeholder('value, or secret reference env:NAME, file:/path, exec:command'), attr.title('Secrets can be referenced instead of entered: env:NAME for an environment variable, file:/path for a file, exec:command args for the output of a command (if enabled). References are resolved by dnsclay, only the reference is stored.'));
 		}
 		else {
 			const values = stringEnums.get(tw[0]);
@@ -1217,25 +1577,146 @@ const providerFields = (p, stringEnums, configJSON) => {
 	}));
 	return { root: root, fieldMap: fieldMap };
 };
+const auditEventsView = (events, showZone) => {
+	return dom.table(dom.thead(dom.tr(dom.th('Age'), dom.th('User'), showZone ? dom.th('Zone') : [], dom.th('Action'), dom.th('Details'))), dom.tbody(events.length ? [] : dom.tr(dom.td(attr.colspan(showZone ? '5' : '4'), 'No changes.', style({ textAlign: 'left' }))), events.map(ev => dom.tr(dom.td(formatAge(ev.Time), attr.title(formatDate(ev.Time))), dom.td(ev.Username || '-', ev.APIToken ? [' ', dom.span('(token ' + ev.APIToken + ')', attr.title('Change made with an API token.'))] : []), showZone ? dom.td(ev.Zone ? dom.a(attr.href('#zones/' + trimDot(ev.Zone)), trimDot(ev.Zone)) : '-') : [], dom.td(ev.Action), dom.td(style({ textAlign: 'left' }), ev.Details)))));
+};
+const isAdmin = () => currentUser.Role === api.Role.RoleAdmin;
+const userEdit = (u, zones, done) => {
+	let fieldset;
+	let username;
+	let password;
+	let role;
+	let zonesElem;
+	const zoneCheckboxes = [];
+	const [close] = popup(dom.h1(u ? 'Edit user' : 'Add user'), dom.form(async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		const nu = {
+			ID: u ? u.ID : 0,
+			Created: u ? u.Created : new Date(),
+			Username: u ? u.Username : username.value,
+			Role: role.value,
+			Zones: role.value === api.Role.RoleEditor ? zoneCheckboxes.filter(zc => zc.checkbox.checked).map(zc => zc.name) : [],
+			PasswordHash: '',
+			OIDCSubject: u ? u.OIDCSubject : '',
+		};
+		if (u) {
+			await check(fieldset, () => client.UserUpdate(nu));
+		}
+		else {
+			await check(fieldset, () => client.UserAdd(nu, password.value));
+		}
+		close();
+		done();
+	}, fieldset = dom.fieldset(style({ display: 'flex', flexDirection: 'column', gap: '2ex' }), dom.label(dom.div('Username'), username = dom.input(attr.required(''), u ? [attr.value(u.Username), attr.disabled('')] : [])), u ? [] : dom.label(dom.div('Password'), password = dom.input(attr.type('password'), attr.required(''), attr.autocomplete('new-password'))), dom.label(dom.div('Role'), role = dom.select(dom.option('Viewer', attr.value(api.Role.RoleViewer), u?.Role === api.Role.RoleViewer ? attr.selected('') : []), dom.option('Editor', attr.value(api.Role.RoleEditor), u?.Role === api.Role.RoleEditor ? attr.selected('') : []), dom.option('Admin', attr.value(api.Role.RoleAdmin), u?.Role === api.Role.RoleAdmin ? attr.selected('') : []), function change() {
+		zonesElem.style.display = role.value === api.Role.RoleEditor ? '' : 'none';
+	}), dom.div(style({ fontStyle: 'italic' }), 'Viewers can see zones and records, but not secrets. Editors can change the selected zones. Admins can change everything, including provider configs and users.')), zonesElem = dom.div(u?.Role === api.Role.RoleEditor ? [] : style({ display: 'none' }), dom.div('Zones that can be changed'), zones.map(z => {
+		const checkbox = dom.input(attr.type('checkbox'), (u?.Zones || []).includes(z.Name) ? attr.checked('') : []);
+		zoneCheckboxes.push({ name: z.Name, checkbox: checkbox });
+		return dom.div(dom.label(checkbox, ' ', trimDot(z.Name)));
+	})), dom.div(dom.submitbutton(u ? 'Save' : 'Add user')))));
+	if (!u) {
+		username.focus();
+	}
+};
+const apiTokenAdd = (zones, done) => {
+	let fieldset;
+	let name;
+	let readOnly;
+	let functions;
+	let expires;
+	const zoneCheckboxes = [];
+	const [close] = popup(dom.h1('Add API token'), dom.form(async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		const t = {
+			ID: 0,
+			Created: new Date(),
+			UserID: 0,
+			Name: name.value,
+			TokenHash: '',
+			Prefix: '',
+			ReadOnly: readOnly.checked,
+			Zones: zoneCheckboxes.filter(zc => zc.checkbox.checked).map(zc => zc.name),
+			Functions: functions.value.split(/[ ,]+/).filter(s => !!s),
+			Expires: expires.value ? new Date(expires.value) : null,
+		};
+		const [, token] = await check(fieldset, () => client.APITokenAdd(t));
+		close();
+		done();
+		popup(dom.h1('API token added'), dom.p('Copy the token now, it is not shown again.'), dom.pre(token), dom.p('Use it as bearer token, for example:'), dom.pre('curl -H \'Authorization: Bearer ' + token + '\' -H \'Content-Type: application/json\' -d \'{"params": []}\' ' + location.origin + '/api/Zones'));
+	}, fieldset = dom.fieldset(style({ display: 'flex', flexDirection: 'column', gap: '2ex' }), dom.label(dom.div('Name'), name = dom.input(attr.required(''), attr.placeholder('e.g. name of script using the token'))), dom.label(readOnly = dom.input(attr.type('checkbox')), ' Read-only', dom.div(style({ fontStyle: 'italic' }), 'Only viewing, even if your role allows changes.')), dom.div(dom.div('Zones'), zones.map(z => {
+		const checkbox = dom.input(attr.type('checkbox'));
+		zoneCheckboxes.push({ name: z.Name, checkbox: checkbox });
+		return dom.div(dom.label(checkbox, ' ', trimDot(z.Name)));
+	}), dom.div(style({ fontStyle: 'italic' }), 'If any are selected, only these zones can be viewed and changed, and functions requiring an admin are not allowed.')), dom.label(dom.div('API functions'), functions = dom.input(attr.placeholder('e.g. Zones, ZoneRecords, RecordSetUpdate')), dom.div(style({ fontStyle: 'italic' }), 'If set, only these functions can be called. See the API documentation at /api/.')), dom.label(dom.div('Expires'), expires = dom.input(attr.type('datetime-local')), dom.div(style({ fontStyle: 'italic' }), 'Optional.')), dom.div(dom.submitbutton('Add API token')))));
+	name.focus();
+};
+const passwordSet = (title, withCurrent, fn) => {
+	let fieldset;
+	let current;
+	let password;
+	let password2;
+	const [close] = popup(dom.h1(title), dom.form(async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		if (password.value !== password2.value) {
+			alert('Passwords do not match.');
+			return;
+		}
+		await check(fieldset, () => fn(withCurrent ? current.value : '', password.value));
+		close();
+	}, fieldset = dom.fieldset(style({ display: 'flex', flexDirection: 'column', gap: '2ex' }), withCurrent ? dom.label(dom.div('Current password'), current = dom.input(attr.type('password'), attr.required(''), attr.autocomplete('current-password'))) : [], dom.label(dom.div('New password'), password = dom.input(attr.type('password'), attr.required(''), attr.autocomplete('new-password'))), dom.label(dom.div('New password again'), password2 = dom.input(attr.type('password'), attr.required(''), attr.autocomplete('new-password'))), dom.div(dom.submitbutton('Set password')))));
+	if (withCurrent) {
+		current.focus();
+	}
+	else {
+		password.focus();
+	}
+};
 const pageHome = async () => {
-	let [zones0] = await Promise.all([
+	let [zones0, health0, providerConfigs0, discoveries0, events0, users0, apiTokens0] = await Promise.all([
 		client.Zones(),
+		client.ProviderHealth(),
+		client.ProviderConfigs(),
+		isAdmin() ? client.ZoneDiscoveries() : Promise.resolve([]),
+		client.AuditEvents(''),
+		isAdmin() ? client.Users() : Promise.resolve([]),
+		client.APITokens(),
 	]);
 	let zones = zones0 || [];
+	const health = health0 || [];
+	let providerConfigs = providerConfigs0 || [];
+	let discoveries = discoveries0 || [];
+	const events = events0 || [];
+	let users = users0 || [];
+	let apiTokens = apiTokens0 || [];
 	dom._kids(crumbElem, dom.a(attr.href('#'), 'Home'));
 	document.title = 'Dnsclay';
 	let zonesTbody;
+	let providerConfigsTbody;
+	let usersTbody;
+	let apiTokensTbody;
 	const root = dom.div(dom.div(dom.clickbutton('Add zone', async function click() {
 		let zone;
 		let refreshInterval;
 		let syncInterval;
+		let recordsFreshness;
+		let freshPrerequisites;
+		let queueUpdates;
+		let verifyNameservers;
+		let delayUpdateResponse;
 		let fieldset;
 		let testResult;
 		let newProviderConfigName;
+		let retries;
+		let failureThreshold;
+		let rrsetUpdates;
 		let existingProviderConfigName;
-		const [[stringEnums, providers], providerURLs] = await Promise.all([
+		const [[stringEnums, providers], providerURLs, capabilities] = await Promise.all([
 			availableProviders(),
 			client.ProviderURLs(),
+			client.ProviderCapabilities(),
 		]);
 		const providerConfigs = await client.ProviderConfigs() || [];
 		let fields;
@@ -1248,7 +1729,9 @@ const pageHome = async () => {
 				return;
 			}
 			const url = providerURLs[providerName];
-			dom._kids(providerConfigBox, style({ display: 'flex', flexDirection: 'column', gap: '2ex' }), dom.label(dom.div('Name'), dom.div(newProviderConfigName = dom.input(attr.required(''), attr.value(newProviderConfigName?.value || zone.value)))), dom.div(style({ padding: '1em', border: '1px solid #ddd' }), dom.h2('"' + providerName + '" fields'), dom.p('Implemented through ', dom.a(attr.href('https://' + url), url, attr.rel('noreferrer noopener')), ', see ', dom.a(attr.href('https://pkg.go.dev/' + url), 'Go documentation', attr.rel('noreferrer noopener'))), dom.div(style({ display: 'flex', flexDirection: 'column', gap: '2ex' }), fields = providerFields(p, stringEnums, null))));
+			dom._kids(providerConfigBox, style({ display: 'flex', flexDirection: 'column', gap: '2ex' }), dom.label(dom.div('Name'), dom.div(newProviderConfigName = dom.input(attr.required(''), attr.value(newProviderConfigName?.value || zone.value)))), dom.label(dom.div('Retries', attr.title('Number of times a provider operation that failed with a transient error (timeout, connection error, HTTP 5xx) is retried. Adding records is not retried.')), dom.div(retries = dom.input(attr.type('number'), attr.required(''), attr.value(retries?.value || '2')))), dom.label(dom.div('Failure threshold', attr.title('Number of consecutive transient failures after which the provider config is marked unhealthy. While unhealthy, operations fail immediately and automatic syncs are paused, with increasing pauses between attempts. 0 disables.')), dom.div(failureThreshold = dom.input(attr.type('number'), attr.required(''), attr.value(failureThreshold?.value || '5')))), dom.label(rrsetUpdates = dom.input(attr.type('checkbox'), capabilities[providerName]?.RRSets ? attr.checked('') : []), ' Apply DNS UPDATEs as complete record sets', attr.title('Changes from DNS UPDATEs are applied by setting the complete desired record set (name and type) with a single call, instead of adding and deleting individual records. Fewer API calls, and no partial record sets in between calls. Only for providers that replace record sets when setting records. Enabled by default for providers known to operate on record sets.')), dom.div(style({ padding: '1em', border: '1px solid #ddd' }), dom.h2('"' + providerName + '" fields'), capabilities[providerName]?.Builtin ?
+				dom.p('Built into dnsclay, see ', dom.a(attr.href('https://' + url), 'documentation', attr.rel('noreferrer noopener'))) :
+				dom.p('Implemented through ', dom.a(attr.href('https://' + url), url, attr.rel('noreferrer noopener')), ', see ', dom.a(attr.href('https://pkg.go.dev/' + url), 'Go documentation', attr.rel('noreferrer noopener'))), dom.p('Capabilities: ', capabilitiesText(capabilities[providerName])), dom.div(style({ display: 'flex', flexDirection: 'column', gap: '2ex' }), fields = providerFields(p, stringEnums, null))));
 		};
 		let providerConfigBox;
 		const [close] = popup(dom.div(dom.h1('New zone'), dom.form(async function submit(e) {
@@ -1271,7 +1754,7 @@ const pageHome = async () => {
 					return;
 				}
 				pName = pc.ProviderName;
-				pcJSON = pc.ProviderConfigJSON;
+				pcJSON = await check(fieldset, () => client.ProviderConfigReveal(pc.Name));
 			}
 			else {
 				if (!fields) {
@@ -1283,8 +1766,8 @@ const pageHome = async () => {
 			}
 			const nrecords = await check(fieldset, () => client.ProviderConfigTest(trimSuffix(zone.value, '.') + '.', parseInt(refreshInterval.value), pName, pcJSON));
 			testResult.innerText = 'Success, found ' + nrecords + ' DNS records';
-		}, fieldset = dom.fieldset(style({ display: 'flex', flexDirection: 'column', gap: '2ex' }), dom.div(dom.div(dom.label('Zone')), zone = dom.input(attr.required(''))), dom.div(dom.div(dom.label('Refresh interval (in seconds)'), attr.title('The zone SOA DNS record is fetched through the DNS resolver to check for updates. An interval of 0 disables periodic SOA DNS record lookup.')), refreshInterval = dom.input(attr.type('number'), attr.required(''), attr.value('3600')), dom.div(style({ fontStyle: 'italic' }), '0 disables SOA refresh checks')), dom.div(dom.div(dom.label('Sync interval (in seconds)'), attr.title('The zone is fetched in full during each sync.')), syncInterval = dom.input(attr.type('number'), attr.required(''), attr.value('86400'))), dom.div(dom.div(dom.label('Create new provider config')), dom.div(style({ display: 'flex', gap: '1em' }), chunked(providers, 10).map(plist => dom.div(plist.map(p => {
-			return dom.div(dom.label(dom.input(attr.type('radio'), attr.name('provider'), attr.value(trimPrefix(p.Name, 'Provider_')), function change() { updateProviderConfig(); }), ' ', trimPrefix(p.Name, 'Provider_')));
+		}, fieldset = dom.fieldset(style({ display: 'flex', flexDirection: 'column', gap: '2ex' }), dom.div(dom.div(dom.label('Zone')), zone = dom.input(attr.required(''))), dom.div(dom.div(dom.label('Refresh interval (in seconds)'), attr.title('The zone SOA DNS record is fetched through the DNS resolver to check for updates. An interval of 0 disables periodic SOA DNS record lookup.')), refreshInterval = dom.input(attr.type('number'), attr.required(''), attr.value('3600')), dom.div(style({ fontStyle: 'italic' }), '0 disables SOA refresh checks')), dom.div(dom.div(dom.label('Sync interval (in seconds)'), attr.title('The zone is fetched in full during each sync.')), syncInterval = dom.input(attr.type('number'), attr.required(''), attr.value('86400'))), dom.div(dom.div(dom.label('Records freshness (in seconds)'), attr.title('Records fetched from the provider within this window are reused for DNS UPDATE/XFR and web API requests, instead of fetching them again. Useful for bursts of DNS UPDATEs.')), recordsFreshness = dom.input(attr.type('number'), attr.required(''), attr.value('0')), dom.div(style({ fontStyle: 'italic' }), '0 fetches records for each request')), dom.label(freshPrerequisites = dom.input(attr.type('checkbox')), ' Always fetch records for DNS UPDATE prerequisites'), dom.label(verifyNameservers = dom.input(attr.type('checkbox')), ' Verify propagation at authoritative name servers', attr.title('After changes are seen through the provider, the authoritative name servers of the zone are queried until they all serve the changed records.')), dom.label(delayUpdateResponse = dom.input(attr.type('checkbox')), ' Delay DNS UPDATE response until propagated', attr.title('Only respond to DNS UPDATEs after the changes have propagated, and respond with SERVFAIL if propagation failed. Clients may need a longer timeout. Not used when DNS UPDATEs are queued.')), dom.label(queueUpdates = dom.input(attr.type('checkbox')), ' Queue DNS UPDATEs', attr.title('DNS UPDATEs are validated against the local records, acknowledged, and applied through the provider in the background, with retries. Useful when the provider is not always available.')), dom.div(dom.div(dom.label('Create new provider config')), dom.div(style({ display: 'flex', gap: '1em' }), chunked(providers, 10).map(plist => dom.div(plist.map(p => {
+			return dom.div(dom.label(dom.input(attr.type('radio'), attr.name('provider'), attr.value(trimPrefix(p.Name, 'Provider_')), function change() { updateProviderConfig(); }), ' ', trimPrefix(p.Name, 'Provider_'), attr.title(capabilitiesText(capabilities[trimPrefix(p.Name, 'Provider_')]))));
 		}))))), providerConfigBox = dom.div(), dom.label(dom.div('Use existing provider config'), dom.div(existingProviderConfigName = dom.select(dom.option('', attr.value('')), providerConfigs.map(pc => dom.option(pc.Name))))), dom.div(dom.submitbutton('Test config'), ' ', testResult = dom.span()), dom.div(dom.clickbutton('Add zone', async function click() {
 			let pcName = existingProviderConfigName.value;
 			if (!pcName) {
@@ -1296,9 +1779,16 @@ const pageHome = async () => {
 					Name: newProviderConfigName.value,
 					ProviderName: providerName,
 					ProviderConfigJSON: providerConfigJSON(fields),
+					Retries: parseInt(retries.value),
+					FailureThreshold: parseInt(failureThreshold.value),
+					DiscoverInterval: 0,
+					RRSetUpdates: rrsetUpdates.checked,
+					MinTTL: 0,
+					ConfigManaged: false,
 				};
 				pc = await check(fieldset, () => client.ProviderConfigAdd(pc));
 				pcName = pc.Name;
+				providerConfigs.push(pc);
 			}
 			const z = {
 				Name: trimSuffix(zone.value, '.') + '.',
@@ -1309,24 +1799,130 @@ const pageHome = async () => {
 				RefreshInterval: parseInt(refreshInterval.value) * 1000 * 1000 * 1000,
 				NextSync: new Date(),
 				NextRefresh: new Date(),
+				RecordsFreshness: parseInt(recordsFreshness.value) * 1000 * 1000 * 1000,
+				FreshPrerequisites: freshPrerequisites.checked,
+				QueueUpdates: queueUpdates.checked,
+				VerifyNameservers: verifyNameservers.checked,
+				DelayUpdateResponse: delayUpdateResponse.checked,
+				ConfigManaged: false,
 			};
 			const nz = await check(fieldset, () => client.ZoneAdd(z, [])); // todo: allow specifying notifies
 			zones.push(nz);
 			render();
+			renderProviderConfigs();
 			close();
 		}))))));
 		zone.focus();
-	})), dom.br(), dom.h1('Zones (Domains)'), dom.table(dom.thead(dom.tr(dom.th('Zone'), dom.th('Provider Config'), dom.th('Last sync'), dom.th('Last record change'), dom.th('Serial'), dom.th('Refresh next/interval'), dom.th('Sync next/interval'))), zonesTbody = dom.tbody()));
+	})), dom.br(), dom.h1('Zones (Domains)'), dom.table(dom.thead(dom.tr(dom.th('Zone'), dom.th('Provider Config'), dom.th('Last sync'), dom.th('Last record change'), dom.th('Serial'), dom.th('Refresh next/interval'), dom.th('Sync next/interval'))), zonesTbody = dom.tbody()), dom.br(), dom.h1('Provider configs'), dom.table(dom.thead(dom.tr(dom.th('Name'), dom.th('Provider'), dom.th('Zones'), dom.th('Zone discovery', attr.title('Zones at the provider that are not configured (new), and configured zones no longer at the provider (vanished), as found during the last periodic or requested discovery.')), dom.th('Action'))), providerConfigsTbody = dom.tbody()), isAdmin() ? [
+		dom.br(),
+		dom.div(style({ display: 'flex', gap: '.5em', alignItems: 'baseline' }), dom.h1('Users'), ' ', dom.clickbutton('Add user', function click() {
+			userEdit(null, zones, async () => {
+				users = await client.Users() || [];
+				renderUsers();
+			});
+		})),
+		dom.table(dom.thead(dom.tr(dom.th('Username'), dom.th('Role'), dom.th('Zones'), dom.th('Age'), dom.th('Action'))), usersTbody = dom.tbody()),
+	] : [], dom.br(), dom.div(style({ display: 'flex', gap: '.5em', alignItems: 'baseline' }), dom.h1('API tokens'), ' ', dom.clickbutton('Add API token', function click() {
+		apiTokenAdd(zones, async () => {
+			apiTokens = await client.APITokens() || [];
+			renderAPITokens();
+		});
+	})), dom.p('API tokens let scripts call the API with limited access, as bearer token.'), dom.table(dom.thead(dom.tr(dom.th('Name'), dom.th('Token'), isAdmin() ? dom.th('User') : [], dom.th('Scope'), dom.th('Age'), dom.th('Last used'), dom.th('Expires'), dom.th('Action'))), apiTokensTbody = dom.tbody()), dom.br(), dom.h1('Recent changes'), auditEventsView(events, true));
+	const discoverZones = async (btn, pc) => {
+		const [discovered, vanished] = await check(btn, () => client.ProviderConfigDiscoverZones(pc.Name));
+		discoveries = await client.ZoneDiscoveries() || [];
+		renderProviderConfigs();
+		let fieldset;
+		let notifyAddress;
+		let notifyProtocol;
+		const selected = [];
+		const [close] = popup(dom.h1('Discover zones'), dom.p('Zones at provider config ', dom.b(pc.Name), '. Selected zones are added with this provider config, default refresh and sync intervals, and a new TSIG credential.'), vanished && vanished.length ? dom.p(style({ color: 'red' }), 'Configured zones no longer at provider: ', vanished.map(name => trimDot(name)).join(', ')) : [], dom.form(async function submit(e) {
+			e.preventDefault();
+			e.stopPropagation();
+			const names = selected.filter(x => x.checkbox.checked).map(x => x.name);
+			if (!names.length) {
+				alert('No zones selected.');
+				return;
+			}
+			const notifies = [];
+			if (notifyAddress.value) {
+				notifies.push({ ID: 0, Created: new Date(), Zone: '', Address: notifyAddress.value, Protocol: notifyProtocol.value, ConfigManaged: false });
+			}
+			const nzones = await check(fieldset, () => client.ZonesAdd(pc.Name, names, notifies)) || [];
+			zones.push(...nzones);
+			discoveries = await client.ZoneDiscoveries() || [];
+			render();
+			renderProviderConfigs();
+			close();
+		}, fieldset = dom.fieldset(style({ display: 'flex', flexDirection: 'column', gap: '2ex' }), discovered && discovered.length ? dom.table(dom.thead(dom.tr(dom.th(), dom.th('Zone'), dom.th('Configured with'))), dom.tbody(discovered.map(dz => {
+			let checkbox;
+			if (!dz.ProviderConfigName) {
+				checkbox = dom.input(attr.type('checkbox'), attr.checked(''));
+				selected.push({ name: dz.Name, checkbox: checkbox });
+			}
+			return dom.tr(dom.td(checkbox || []), dom.td(trimDot(dz.Name)), dom.td(dz.ProviderConfigName || '-'));
+		}))) : dom.p('No zones at provider.'), dom.label(dom.div('DNS NOTIFY address for added zones (optional)'), dom.div(notifyAddress = dom.input(attr.placeholder('127.0.0.1:53')), ' ', notifyProtocol = dom.select(dom.option('tcp'), dom.option('udp')))), dom.div(dom.submitbutton('Add selected zones')))));
+	};
+	const renderProviderConfigs = () => {
+		dom._kids(providerConfigsTbody, providerConfigs.length ? [] : dom.tr(dom.td(attr.colspan('5'), 'No provider configs.', style({ textAlign: 'left' }))), providerConfigs.map(pc => {
+			const zd = discoveries.find(zd => zd.ProviderConfigName === pc.Name);
+			return dom.tr(dom.td(pc.Name, pc.ConfigManaged ? [' ', dom.span('(config file)', attr.title('Managed through configuration file.'))] : []), dom.td(pc.ProviderName), dom.td('' + zones.filter(z => z.ProviderConfigName === pc.Name).length), dom.td(!zd ? '-' : [
+				zd.New && zd.New.length ? dom.div('New: ', zd.New.map(name => trimDot(name)).join(', ')) : [],
+				zd.Vanished && zd.Vanished.length ? dom.div(style({ color: 'red' }), 'Vanished: ', zd.Vanished.map(name => trimDot(name)).join(', ')) : [],
+				zd.Error ? dom.div(style({ color: 'red' }), 'Error: ', zd.Error) : [],
+				zd.Last ? dom.div(style({ fontStyle: 'italic' }), 'Checked ', formatAge(zd.Last), attr.title(formatDate(zd.Last))) : [],
+			]), dom.td(dom.clickbutton('Discover zones', attr.title('List zones at the provider, and add zones that are not yet configured. Not all providers can list zones.'), async function click(e) {
+				await discoverZones(e.target, pc);
+			})));
+		}));
+	};
 	const render = () => {
 		const now = new Date();
-		dom._kids(zonesTbody, zones.length ? [] : dom.tr(dom.td(attr.colspan('6'), 'No zones.', style({ textAlign: 'left' }))), zones.map(z => dom.tr(dom.td(dom.a(attr.href('#zones/' + trimDot(z.Name)), trimDot(z.Name))), dom.td(z.ProviderConfigName), dom.td(z.LastSync ? [formatAge(z.LastSync), attr.title(formatDate(z.LastSync))] : []), dom.td(z.LastRecordChange ? [formatAge(z.LastRecordChange), attr.title(formatDate(z.LastRecordChange))] : []), dom.td('' + z.SerialLocal, z.SerialLocal !== z.SerialRemote ? ' (at remote: ' + z.SerialRemote + ')' : '', attr.title((z.RefreshInterval === 0 ? 'Periodic refresh with SOA-check disabled\n' : `Next SOA check in ${formatAge(undefined, z.NextRefresh)} at ${formatDate(z.NextRefresh)}.\n`) +
+		dom._kids(zonesTbody, zones.length ? [] : dom.tr(dom.td(attr.colspan('6'), 'No zones.', style({ textAlign: 'left' }))), zones.map(z => dom.tr(dom.td(dom.a(attr.href('#zones/' + trimDot(z.Name)), trimDot(z.Name))), dom.td(z.ProviderConfigName, health.filter(h => h.ProviderConfigName === z.ProviderConfigName && !h.Healthy).map(h => [' (', providerHealthView(h), ')'])), dom.td(z.LastSync ? [formatAge(z.LastSync), attr.title(formatDate(z.LastSync))] : []), dom.td(z.LastRecordChange ? [formatAge(z.LastRecordChange), attr.title(formatDate(z.LastRecordChange))] : []), dom.td('' + z.SerialLocal, z.SerialLocal !== z.SerialRemote ? ' (at remote: ' + z.SerialRemote + ')' : '', attr.title((z.RefreshInterval === 0 ? 'Periodic refresh with SOA-check disabled\n' : `Next SOA check in ${formatAge(undefined, z.NextRefresh)} at ${formatDate(z.NextRefresh)}.\n`) +
 			`Next sync in ${formatAge(undefined, z.NextSync)} at ${formatDate(z.NextSync)}.`)), dom.td(z.RefreshInterval === 0 ? '-' : [
 			formatAge(now, z.NextRefresh),
 			' / ',
 			formatAge(now, new Date(now.getTime() + z.RefreshInterval / (1000 * 1000))),
 		]), dom.td(formatAge(now, z.NextSync), ' / ', formatAge(now, new Date(now.getTime() + z.SyncInterval / (1000 * 1000)))))));
 	};
+	const renderUsers = () => {
+		if (!usersTbody) {
+			return;
+		}
+		dom._kids(usersTbody, users.map(u => dom.tr(dom.td(u.Username, u.ID === currentUser.ID ? ' (you)' : '', u.OIDCSubject ? [' ', dom.span('(single sign-on)', attr.title('Role and zones are updated from the identity provider at each login.'))] : []), dom.td(u.Role), dom.td(u.Role === api.Role.RoleEditor ? (u.Zones || []).map(z => trimDot(z)).join(', ') || '-' : 'all'), dom.td(formatAge(u.Created), attr.title(formatDate(u.Created))), dom.td(dom.clickbutton('Edit', function click() {
+			userEdit(u, zones, async () => {
+				users = await client.Users() || [];
+				renderUsers();
+			});
+		}), ' ', dom.clickbutton('Set password', u.OIDCSubject ? attr.disabled('') : [], function click() {
+			passwordSet('Set password for ' + u.Username, false, (_, password) => client.UserPasswordSet(u.ID, password));
+		}), ' ', dom.clickbutton('Delete', async function click(e) {
+			if (!confirm('Are you sure you want to remove user ' + u.Username + '?')) {
+				return;
+			}
+			await check(e.target, () => client.UserDelete(u.ID));
+			users = users.filter(x => x !== u);
+			renderUsers();
+		})))));
+	};
+	const renderAPITokens = () => {
+		dom._kids(apiTokensTbody, apiTokens.length ? [] : dom.tr(dom.td(attr.colspan(isAdmin() ? '8' : '7'), 'No API tokens.', style({ textAlign: 'left' }))), apiTokens.map(t => dom.tr(dom.td(t.Name), dom.td(t.Prefix + '...'), isAdmin() ? dom.td(users.find(u => u.ID === t.UserID)?.Username || '-') : [], dom.td(style({ textAlign: 'left' }), [
+			t.ReadOnly ? 'read-only' : '',
+			(t.Zones || []).length ? 'zones: ' + (t.Zones || []).map(z => trimDot(z)).join(', ') : '',
+			(t.Functions || []).length ? 'functions: ' + (t.Functions || []).join(', ') : '',
+		].filter(s => !!s).join('; ') || 'all'), dom.td(formatAge(t.Created), attr.title(formatDate(t.Created))), dom.td(t.LastUsed ? [formatAge(t.LastUsed), attr.title(formatDate(t.LastUsed))] : 'never'), dom.td(t.Expires ? [formatDate(t.Expires), t.Expires.getTime() < Date.now() ? ' (expired)' : ''] : 'never'), dom.td(dom.clickbutton('Revoke', async function click(e) {
+			if (!confirm('Are you sure you want to revoke API token ' + t.Name + '? Scripts using it will no longer work.')) {
+				return;
+			}
+			await check(e.target, () => client.APITokenRevoke(t.ID));
+			apiTokens = apiTokens.filter(x => x !== t);
+			renderAPITokens();
+		})))));
+	};
 	render();
+	renderProviderConfigs();
+	renderUsers();
+	renderAPITokens();
 	return root;
 };
 // todo: add mechanims to keep age up to date while page is alive. with setInterval/setTimeout, and clearing those timers when we navigate away, like in ding. also use mechanism to keep propagation colors up to date.
@@ -1473,6 +2069,9 @@ const pageZone = async (zonestr) => {
 	let notifies = notifies0 || [];
 	let credentials = credentials0 || [];
 	let sets = sets0 || [];
+	const health = (await client.ProviderHealth() || []).find(h => h.ProviderConfigName === zone.ProviderConfigName);
+	let queued = await client.ZoneQueuedChanges(zone.Name) || [];
+	const events = await client.AuditEvents(zone.Name) || [];
 	dom._kids(crumbElem, dom.a(attr.href('#'), 'Home'), ' / ', dom.a(attr.href('#zones/' + trimDot(zone.Name)), 'Zone ' + trimDot(zone.Name)));
 	document.title = 'Dnsclay - Zone ' + trimDot(zone.Name);
 	const relName = (s) => zoneRelName(zone, s);
@@ -1491,6 +2090,22 @@ const pageZone = async (zonestr) => {
 	let showHistoric;
 	let showDNSSEC;
 	let recordsTbody;
+	const queuedTbody = dom.tbody();
+	const renderQueued = () => {
+		const change = (op, r) => dom.div(op, ' ', relName(r.AbsName), ' ', '' + r.TTL, ' ', dnsTypeNames[r.Type] || ('' + r.Type), ' ', r.Value);
+		dom._kids(queuedTbody, queued.length ? [] : dom.tr(dom.td(attr.colspan('6'), 'No queued changes.', style({ textAlign: 'left' }))), queued.map(qc => dom.tr(dom.td(formatAge(qc.Created), attr.title(formatDate(qc.Created))), dom.td(style({ textAlign: 'left' }), (qc.Add || []).map(r => change('add', r)), (qc.Set || []).map(r => change('set', r)), (qc.Delete || []).map(r => change('delete', r))), dom.td('' + qc.Attempts), dom.td(qc.Failed ? dom.span(style({ color: 'red' }), 'Failed') : [formatAge(undefined, qc.NextAttempt), attr.title(formatDate(qc.NextAttempt))]), dom.td(style({ textAlign: 'left' }), qc.LastError), dom.td(dom.clickbutton('Retry', async function click(e) {
+			const nqc = await check(e.target, () => client.QueuedChangeRetry(qc.ID));
+			queued.splice(queued.indexOf(qc), 1, nqc);
+			renderQueued();
+		}), ' ', dom.clickbutton('Delete', attr.title('Remove from the queue, the changes will not be applied.'), async function click(e) {
+			if (!confirm('Are you sure?')) {
+				return;
+			}
+			await check(e.target, () => client.QueuedChangeDelete(qc.ID));
+			queued.splice(queued.indexOf(qc), 1);
+			renderQueued();
+		})))));
+	};
 	const refresh = async (elem) => {
 		const [nzone, npc, nnotifies, ncredentials, nsets] = await check(elem, () => client.Zone(zone.Name));
 		zone = nzone;
@@ -1500,10 +2115,15 @@ const pageZone = async (zonestr) => {
 		sets = nsets || [];
 		render();
 	};
-	const root = dom.div(dom.div(dom.p(`Provider config: ${zone.ProviderConfigName} (provider ${providerConfig.ProviderName})`), dom.clickbutton('Edit zone config', async function click(e) {
+	const root = dom.div(dom.div(dom.p(`Provider config: ${zone.ProviderConfigName} (provider ${providerConfig.ProviderName})`, health ? [', ', providerHealthView(health)] : [], zone.ConfigManaged ? ', zone managed through configuration file' : ''), dom.clickbutton('Edit zone config', configManaged(zone.ConfigManaged), async function click(e) {
 		let fieldset;
 		let refreshival;
 		let syncival;
+		let freshness;
+		let freshPrerequisites;
+		let queueUpdates;
+		let verifyNameservers;
+		let delayUpdateResponse;
 		let providerConfigName;
 		const providerConfigs = await check(e.target, () => client.ProviderConfigs()) || [];
 		const [close] = popup(dom.h1('Edit zone'), dom.br(), dom.form(async function submit(e) {
@@ -1513,10 +2133,15 @@ const pageZone = async (zonestr) => {
 			nz.ProviderConfigName = providerConfigName.value;
 			nz.RefreshInterval = 1000 * 1000 * 1000 * parseInt(refreshival.value);
 			nz.SyncInterval = 1000 * 1000 * 1000 * parseInt(syncival.value);
+			nz.RecordsFreshness = 1000 * 1000 * 1000 * parseInt(freshness.value);
+			nz.FreshPrerequisites = freshPrerequisites.checked;
+			nz.QueueUpdates = queueUpdates.checked;
+			nz.VerifyNameservers = verifyNameservers.checked;
+			nz.DelayUpdateResponse = delayUpdateResponse.checked;
 			zone = await check(fieldset, () => client.ZoneUpdate(nz));
 			close();
-		}, fieldset = dom.fieldset(style({ display: 'flex', flexDirection: 'column', gap: '2ex' }), dom.label(dom.div('Refresh interval (in seconds)', attr.title('The zone SOA DNS record is fetched through the DNS resolver to check for updates. An interval of 0 disables periodic SOA DNS record lookup.')), refreshival = dom.input(attr.type('number'), attr.required(''), attr.value('' + (zone.RefreshInterval / (1000 * 1000 * 1000)))), dom.div(style({ fontStyle: 'italic' }), '0 disables SOA refresh checks')), dom.label(dom.div('Sync interval (in seconds)', attr.title('The zone is fetched in full during each sync.')), syncival = dom.input(attr.type('number'), attr.required(''), attr.value('' + (zone.SyncInterval / (1000 * 1000 * 1000))))), dom.label(dom.div('Provider config'), providerConfigName = dom.select(providerConfigs.sort((a, b) => a.Name < b.Name ? -1 : 1).map(pc => dom.option(pc.Name)), prop({ value: zone.ProviderConfigName }))), dom.div(dom.submitbutton('Save')))));
-	}), ' ', dom.clickbutton('Edit provider config', async function click(e) {
+		}, fieldset = dom.fieldset(style({ display: 'flex', flexDirection: 'column', gap: '2ex' }), dom.label(dom.div('Refresh interval (in seconds)', attr.title('The zone SOA DNS record is fetched through the DNS resolver to check for updates. An interval of 0 disables periodic SOA DNS record lookup.')), refreshival = dom.input(attr.type('number'), attr.required(''), attr.value('' + (zone.RefreshInterval / (1000 * 1000 * 1000)))), dom.div(style({ fontStyle: 'italic' }), '0 disables SOA refresh checks')), dom.label(dom.div('Sync interval (in seconds)', attr.title('The zone is fetched in full during each sync.')), syncival = dom.input(attr.type('number'), attr.required(''), attr.value('' + (zone.SyncInterval / (1000 * 1000 * 1000))))), dom.label(dom.div('Records freshness (in seconds)', attr.title('Records fetched from the provider within this window are reused for DNS UPDATE/XFR and web API requests, instead of fetching them again. Useful for bursts of DNS UPDATEs.')), freshness = dom.input(attr.type('number'), attr.required(''), attr.value('' + (zone.RecordsFreshness / (1000 * 1000 * 1000)))), dom.div(style({ fontStyle: 'italic' }), '0 fetches records for each request')), dom.label(freshPrerequisites = dom.input(attr.type('checkbox'), zone.FreshPrerequisites ? attr.checked('') : []), ' Always fetch records for DNS UPDATE prerequisites'), dom.label(verifyNameservers = dom.input(attr.type('checkbox'), zone.VerifyNameservers ? attr.checked('') : []), ' Verify propagation at authoritative name servers', attr.title('After changes are seen through the provider, the authoritative name servers of the zone are queried until they all serve the changed records.')), dom.label(delayUpdateResponse = dom.input(attr.type('checkbox'), zone.DelayUpdateResponse ? attr.checked('') : []), ' Delay DNS UPDATE response until propagated', attr.title('Only respond to DNS UPDATEs after the changes have propagated, and respond with SERVFAIL if propagation failed. Clients may need a longer timeout. Not used when DNS UPDATEs are queued.')), dom.label(queueUpdates = dom.input(attr.type('checkbox'), zone.QueueUpdates ? attr.checked('') : []), ' Queue DNS UPDATEs', attr.title('DNS UPDATEs are validated against the local records, acknowledged, and applied through the provider in the background, with retries. Useful when the provider is not always available.')), dom.label(dom.div('Provider config'), providerConfigName = dom.select(providerConfigs.sort((a, b) => a.Name < b.Name ? -1 : 1).map(pc => dom.option(pc.Name)), prop({ value: zone.ProviderConfigName }))), dom.div(dom.submitbutton('Save')))));
+	}), ' ', dom.clickbutton('Edit provider config', configManaged(providerConfig.ConfigManaged), async function click(e) {
 		let fieldset;
 		const [stringEnums, providers] = await check(e.target, () => availableProviders());
 		const p = providers.find(p => p.Name === 'Provider_' + providerConfig.ProviderName);
@@ -1524,8 +2149,14 @@ const pageZone = async (zonestr) => {
 			alert('cannot find provider ' + providerConfig.ProviderName);
 			return;
 		}
+		const pcJSON = await check(e.target, () => client.ProviderConfigReveal(providerConfig.Name));
 		let testResult;
 		let fields;
+		let retries;
+		let failureThreshold;
+		let discoverInterval;
+		let rrsetUpdates;
+		let minTTL;
 		const [close] = popup(dom.h1('Edit provider config'), dom.form(async function submit(e) {
 			e.preventDefault();
 			e.stopPropagation();
@@ -1533,11 +2164,17 @@ const pageZone = async (zonestr) => {
 			testResult.innerText = '';
 			const nrecords = await check(fieldset, () => client.ProviderConfigTest(zone.Name, zone.RefreshInterval / (1000 * 1000 * 1000), providerConfig.ProviderName, providerConfigJSON(fields)));
 			testResult.innerText = 'Success, found ' + nrecords + ' DNS records';
-		}, fieldset = dom.fieldset(style({ display: 'flex', flexDirection: 'column', gap: '2ex' }), dom.label(dom.div('Name'), dom.div(dom.input(attr.value(providerConfig.Name), attr.disabled('')))), dom.label(dom.div('Provider'), dom.div(dom.select(attr.disabled(''), dom.option(providerConfig.ProviderName), prop({ value: providerConfig.ProviderName })))), dom.div(style({ padding: '1em', border: '1px solid #ddd' }), dom.h2('Provider config'), dom.div(style({ display: 'flex', flexDirection: 'column', gap: '2ex' }), fields = providerFields(p, stringEnums, providerConfig.ProviderConfigJSON))), dom.div(dom.submitbutton('Test config'), ' ', testResult = dom.span()), dom.div(dom.clickbutton('Save', async function click() {
+		}, fieldset = dom.fieldset(style({ display: 'flex', flexDirection: 'column', gap: '2ex' }), dom.label(dom.div('Name'), dom.div(dom.input(attr.value(providerConfig.Name), attr.disabled('')))), dom.label(dom.div('Provider'), dom.div(dom.select(attr.disabled(''), dom.option(providerConfig.ProviderName), prop({ value: providerConfig.ProviderName })))), dom.label(dom.div('Retries', attr.title('Number of times a provider operation that failed with a transient error (timeout, connection error, HTTP 5xx) is retried. Adding records is not retried.')), dom.div(retries = dom.input(attr.type('number'), attr.required(''), attr.value('' + providerConfig.Retries)))), dom.label(dom.div('Failure threshold', attr.title('Number of consecutive transient failures after which the provider config is marked unhealthy. While unhealthy, operations fail immediately and automatic syncs are paused, with increasing pauses between attempts. 0 disables.')), dom.div(failureThreshold = dom.input(attr.type('number'), attr.required(''), attr.value('' + providerConfig.FailureThreshold)))), dom.label(dom.div('Zone discovery interval (in seconds)', attr.title('Interval for listing zones at the provider, flagging zones that are not configured and configured zones that vanished. Only for providers that can list zones. 0 disables.')), dom.div(discoverInterval = dom.input(attr.type('number'), attr.required(''), attr.value('' + (providerConfig.DiscoverInterval / (1000 * 1000 * 1000)))))), dom.label(rrsetUpdates = dom.input(attr.type('checkbox'), providerConfig.RRSetUpdates ? attr.checked('') : []), ' Apply DNS UPDATEs as complete record sets', attr.title('Changes from DNS UPDATEs are applied by setting the complete desired record set (name and type) with a single call, instead of adding and deleting individual records. Fewer API calls, and no partial record sets in between calls. Only for providers that replace record sets when setting records.')), dom.label(dom.div('Minimum TTL (in seconds)', attr.title('Minimum TTL for records added or set through the provider, instead of the minimum known for the provider, e.g. after the DNS operator lowered it for the account. 0 uses the known minimum.')), dom.div(minTTL = dom.input(attr.type('number'), attr.required(''), attr.min('0'), attr.value('' + providerConfig.MinTTL)))), dom.div(style({ padding: '1em', border: '1px solid #ddd' }), dom.h2('Provider config'), dom.div(style({ display: 'flex', flexDirection: 'column', gap: '2ex' }), fields = providerFields(p, stringEnums, pcJSON))), dom.div(dom.submitbutton('Test config'), ' ', testResult = dom.span()), dom.div(dom.clickbutton('Save', async function click() {
 			let npc = {
 				Name: providerConfig.Name,
 				ProviderName: providerConfig.ProviderName,
 				ProviderConfigJSON: providerConfigJSON(fields),
+				Retries: parseInt(retries.value),
+				FailureThreshold: parseInt(failureThreshold.value),
+				DiscoverInterval: parseInt(discoverInterval.value) * 1000 * 1000 * 1000,
+				RRSetUpdates: rrsetUpdates.checked,
+				MinTTL: parseInt(minTTL.value),
+				ConfigManaged: false,
 			};
 			providerConfig = await check(fieldset, () => client.ProviderConfigUpdate(npc));
 			close();
@@ -1554,6 +2191,7 @@ const pageZone = async (zonestr) => {
 				Zone: zone.Name,
 				Protocol: fieldset.querySelector('input[name=notifyprotocol]:checked')?.value || '',
 				Address: address.value,
+				ConfigManaged: false,
 			};
 			const nzn = await check(fieldset, () => client.ZoneNotifyAdd(zn));
 			notifies.push(nzn);
@@ -1563,7 +2201,7 @@ const pageZone = async (zonestr) => {
 	})), dom.table(dom.thead(dom.tr(dom.th('Protocol'), dom.th('Address'), dom.th())), dom.tbody(notifies.length ? [] : dom.tr(dom.td(attr.colspan('3'), 'No notify addressses.', style({ textAlign: 'left' }))), notifies.map(n => {
 		const row = dom.tr(dom.td(n.Protocol), dom.td(n.Address), dom.td(dom.clickbutton('Notify', async function click(e) {
 			await check(e.target, () => client.ZoneNotify(n.ID));
-		}), ' ', dom.clickbutton('Delete', async function click(e) {
+		}), ' ', dom.clickbutton('Delete', configManaged(n.ConfigManaged), async function click(e) {
 			if (!confirm('Are you sure?')) {
 				return;
 			}
@@ -1587,6 +2225,7 @@ const pageZone = async (zonestr) => {
 				Type: typ,
 				TSIGSecret: typ === 'tsig' ? key.value : '',
 				TLSPublicKey: typ === 'tlspubkey' ? key.value : '',
+				ConfigManaged: false,
 			};
 			const nc = await check(fieldset, () => client.ZoneCredentialAdd(zone.Name, c));
 			credentials.push(nc);
@@ -1595,9 +2234,10 @@ const pageZone = async (zonestr) => {
 		}, fieldset = dom.fieldset(style({ display: 'flex', flexDirection: 'column', gap: '2ex' }), dom.div(dom.div(dom.label('Name')), name = dom.input(attr.type('required'), attr.placeholder('name-with-dashes-or-dots'), style({ width: '100%' })), dom.div(style({ fontStyle: 'italic' }), 'Must be a valid DNS name for TSIG.')), dom.div(dom.div(dom.label('Type')), dom.label(dom.input(attr.type('radio'), attr.name('credentialtype'), attr.value('tsig')), ' TSIG'), ' ', dom.label(dom.input(attr.type('radio'), attr.name('credentialtype'), attr.value('tlspubkey')), ' TLS public key')), dom.div(dom.div(dom.label('TSIG secret or TLS public key')), key = dom.input(style({ width: '100%' })), dom.div(style({ fontStyle: 'italic' }), 'In case of a TSIG secret, if left empty, a random key will be generated.')), dom.div(dom.submitbutton('Add')))));
 	})), dom.table(dom.thead(dom.tr(dom.th('Name'), dom.th('Type'), dom.th('TSIG Secret / TLS public key'), dom.th('Age'), dom.th(''))), dom.tbody(credentials.length ? [] : dom.tr(dom.td(attr.colspan('5'), 'No credentials.', style({ textAlign: 'left' }))), credentials.map(c => {
 		const row = dom.tr(dom.td(c.Name), dom.td(c.Type), dom.td(c.Type === 'tsig' ?
-			dom.clickbutton('Show', function click(e) {
-				e.target.replaceWith(dom.span(c.TSIGSecret));
-			}) : c.TLSPublicKey), dom.td(formatAge(c.Created), attr.title(formatDate(c.Created))), dom.td(dom.clickbutton('Delete', async function click(e) {
+			dom.clickbutton('Show', async function click(e) {
+				const secret = await check(e.target, () => client.ZoneCredentialReveal(c.ID));
+				e.target.replaceWith(dom.span(secret));
+			}) : c.TLSPublicKey), dom.td(formatAge(c.Created), attr.title(formatDate(c.Created))), dom.td(dom.clickbutton('Delete', configManaged(c.ConfigManaged), async function click(e) {
 			if (!confirm('Are you sure?')) {
 				return;
 			}
@@ -1606,7 +2246,14 @@ const pageZone = async (zonestr) => {
 			row.remove();
 		})));
 		return row;
-	}))))), dom.br(), dom.div(style({ display: 'flex', gap: '.5em', alignItems: 'baseline' }), dom.h2('Records'), ' ', dom.clickbutton('Add records', async function click(e) {
+	}))))), dom.br(), !zone.QueueUpdates && queued.length === 0 ? [] : [
+			dom.div(style({ display: 'flex', gap: '.5em', alignItems: 'baseline' }), dom.h2('Queued changes'), ' ', dom.clickbutton('Reload', async function click(e) {
+				queued = await check(e.target, () => client.ZoneQueuedChanges(zone.Name)) || [];
+				renderQueued();
+			})),
+			dom.table(dom.thead(dom.tr(dom.th('Age'), dom.th('Changes'), dom.th('Failed attempts'), dom.th('Next attempt'), dom.th('Last error'), dom.th())), queuedTbody),
+			dom.br(),
+		], dom.div(style({ display: 'flex', gap: '.5em', alignItems: 'baseline' }), dom.h2('Records'), ' ', dom.clickbutton('Add records', async function click(e) {
 		await popupEdit(zone, [], true);
 		await refresh(e.target);
 	}), ' ', dom.clickbutton('Import records', attr.title('Import records from zone file'), function click() {
@@ -1647,7 +2294,7 @@ const pageZone = async (zonestr) => {
 			localStorage.removeItem('showDNSSEC');
 		}
 		render();
-	}), ' Show DNSSEC signature records', attr.title('RRSIG, NSEC and NSEC3 records are hidden by default'))), dom.table(dom._class('hover'), dom._class('striped'), dom.thead(dom.tr(dom.th(), dom.th('Age'), dom.th('Name'), dom.th('TTL'), dom.th('Type'), dom.th('Value'), dom.th('Actions'))), recordsTbody = dom.tbody()), dom.br(), dom.h2('Danger'), dom.clickbutton('Remove zone', attr.title('Remove zone from management in dnsclay. The zone and its records are not changed at the provider.'), async function click(e) {
+	}), ' Show DNSSEC signature records', attr.title('RRSIG, NSEC and NSEC3 records are hidden by default'))), dom.table(dom._class('hover'), dom._class('striped'), dom.thead(dom.tr(dom.th(), dom.th('Age'), dom.th('Name'), dom.th('TTL'), dom.th('Type'), dom.th('Value'), dom.th('Actions'))), recordsTbody = dom.tbody()), dom.br(), dom.h2('Recent changes'), auditEventsView(events, false), dom.br(), dom.h2('Danger'), dom.clickbutton('Remove zone', attr.title('Remove zone from management in dnsclay. The zone and its records are not changed at the provider.'), configManaged(zone.ConfigManaged), async function click(e) {
 		if (!confirm('Are you sure you want to remove this zone from management in dnsclay? The zone and its records are not changed at the provider.')) {
 			return;
 		}
@@ -1721,6 +2368,7 @@ const pageZone = async (zonestr) => {
 		}));
 	};
 	render();
+	renderQueued();
 	return root;
 };
 const hashchange = async (e) => {
@@ -1748,11 +2396,18 @@ const hashchange = async (e) => {
 	}
 };
 const init = async () => {
-	[version, dnsTypeNames] = await Promise.all([
+	[version, dnsTypeNames, currentUser] = await Promise.all([
 		client.Version(),
 		client.DNSTypeNames(),
+		client.CurrentUser(),
 	]);
-	const root = dom.div(dom.div(style({ display: 'flex', justifyContent: 'space-between', marginBottom: '1ex', padding: '.5em 1em', backgroundColor: '#f8f8f8' }), crumbElem, dom.div(dom.a(attr.href('https://github.com/mjl-/dnsclay'), 'dnsclay'), ' ', version, ' ', dom.a(attr.href('license'), 'license'))), dom.div(pageElem));
+	const root = dom.div(dom.div(style({ display: 'flex', justifyContent: 'space-between', marginBottom: '1ex', padding: '.5em 1em', backgroundColor: '#f8f8f8' }), crumbElem, dom.div(currentUser.Username, ' (', currentUser.Role, ') ', currentUser.OIDCSubject ? [] : dom.clickbutton('Change password', function click() {
+		passwordSet('Change password', true, (current, password) => client.PasswordChange(current, password));
+	}), ' ', dom.clickbutton('Logout', async function click(e) {
+		await check(e.target, () => client.Logout());
+		localStorage.removeItem('dnsclaycsrftoken');
+		window.location.reload();
+	}), ' | ', dom.a(attr.href('https://github.com/mjl-/dnsclay'), 'dnsclay'), ' ', version, ' ', dom.a(attr.href('license'), 'license'))), dom.div(pageElem));
 	document.getElementById('rootElem').replaceWith(root);
 	rootElem = root;
 	window.addEventListener('hashchange', hashchange);
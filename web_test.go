@@ -1,43 +1,395 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/libdns/libdns"
 	"github.com/miekg/dns"
+	"github.com/mjl-/bstore"
+	"github.com/mjl-/sherpa"
 )
 
-func TestWebAuthExport(t *testing.T) {
-	mux := makeAdminMux()
-
-	testAuth := func(path string) {
-		// Missing credentials.
-		req := httptest.NewRequest("GET", path, nil)
-		rec := httptest.ResponseRecorder{}
-		mux.ServeHTTP(&rec, req)
-		tcompare(t, rec.Code, http.StatusUnauthorized)
-
-		// Bad password.
-		req.SetBasicAuth("admin", "bad")
-		rec = httptest.ResponseRecorder{}
-		mux.ServeHTTP(&rec, req)
-		tcompare(t, rec.Code, http.StatusUnauthorized)
-
-		// Allowed.
-		req.SetBasicAuth("admin", adminpassword)
-		rec = httptest.ResponseRecorder{}
-		mux.ServeHTTP(&rec, req)
-		tcompare(t, rec.Code, http.StatusOK)
+// webClient makes API calls over HTTP, like the frontend, with cookies. If
+// username is set, HTTP basic auth is used, and if bearer is set an API token is
+// used, like scripts do.
+type webClient struct {
+	t        *testing.T
+	c        *http.Client
+	url      string
+	csrf     string
+	username string
+	password string
+	bearer   string
+}
+
+func newWebClient(t *testing.T, url string) *webClient {
+	jar, err := cookiejar.New(nil)
+	tcheck(t, err, "new cookie jar")
+	return &webClient{t, &http.Client{Jar: jar}, url, "", "", "", ""}
+}
+
+// call calls API function fn with params. If expCode is non-empty, the call must
+// fail with that sherpa error code. Otherwise the result is stored in result.
+func (wc *webClient) call(expCode string, result any, fn string, params ...any) {
+	t := wc.t
+	t.Helper()
+
+	if params == nil {
+		params = []any{}
+	}
+	buf, err := json.Marshal(map[string]any{"params": params})
+	tcheck(t, err, "marshal params")
+	req, err := http.NewRequest("POST", wc.url+"/api/"+fn, bytes.NewReader(buf))
+	tcheck(t, err, "new request")
+	req.Header.Set("Content-Type", "application/json")
+	if wc.csrf != "" {
+		req.Header.Set(headerCSRF, wc.csrf)
+	}
+	if wc.username != "" {
+		req.SetBasicAuth(wc.username, wc.password)
+	}
+	if wc.bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+wc.bearer)
+	}
+	resp, err := wc.c.Do(req)
+	tcheck(t, err, "api call")
+	defer resp.Body.Close()
+	var r struct {
+		Result json.RawMessage
+		Error  *sherpa.Error
 	}
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	tcheck(t, err, "parsing response")
+	if r.Error != nil && r.Error.Code != expCode {
+		t.Fatalf("api call %s: got error %q (%s), expected %q", fn, r.Error.Code, r.Error.Message, expCode)
+	} else if r.Error == nil && expCode != "" {
+		t.Fatalf("api call %s: got success, expected error %q", fn, expCode)
+	} else if r.Error == nil && result != nil {
+		err := json.Unmarshal(r.Result, result)
+		tcheck(t, err, "parsing result")
+	}
+}
+
+func (wc *webClient) login(username, password, expCode string) {
+	wc.t.Helper()
+	var token string
+	wc.call("", &token, "LoginPrep")
+	wc.call(expCode, &wc.csrf, "Login", token, username, password)
+}
+
+func (wc *webClient) get(path string, expStatus int) {
+	wc.t.Helper()
+	req, err := http.NewRequest("GET", wc.url+path, nil)
+	tcheck(wc.t, err, "new request")
+	if wc.username != "" {
+		req.SetBasicAuth(wc.username, wc.password)
+	}
+	if wc.bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+wc.bearer)
+	}
+	resp, err := wc.c.Do(req)
+	tcheck(wc.t, err, "get")
+	resp.Body.Close()
+	tcompare(wc.t, resp.StatusCode, expStatus)
+}
+
+var testAdminMuxOnce sync.Once
+var testAdminMuxValue *http.ServeMux
+
+// testAdminMux returns the admin mux. It is made only once because it registers
+// prometheus metrics.
+func testAdminMux() *http.ServeMux {
+	testAdminMuxOnce.Do(func() {
+		testAdminMuxValue = makeAdminMux()
+	})
+	return testAdminMuxValue
+}
+
+func TestWebAuth(t *testing.T) {
+	loginFailureDelay = 0
+	defer func() { loginFailureDelay = time.Second }()
+
+	mux := testAdminMux()
 
 	testDNS(t, func(te testEnv, z Zone) {
-		testAuth("/dnsclay.db")
-		testAuth("/")
-		testAuth("/api/")
-		testAuth("/license")
+		ts := httptest.NewServer(mux)
+		defer ts.Close()
+
+		err := usersInit(ctxbg, slog.Default(), "testdata/adminpassword")
+		tcheck(t, err, "init users")
+		pwbuf, err := os.ReadFile("testdata/adminpassword")
+		tcheck(t, err, "read admin password")
+		os.Remove("testdata/adminpassword")
+		adminpassword := strings.TrimSpace(string(pwbuf))
+
+		// No session needed for static files, the license and the API description.
+		anon := newWebClient(t, ts.URL)
+		anon.get("/", http.StatusOK)
+		anon.get("/license", http.StatusOK)
+		anon.get("/api/", http.StatusOK)
+		anon.get("/dnsclay.db", http.StatusUnauthorized)
+		anon.call("user:noAuth", nil, "Zones")
+
+		// Login token must match the cookie.
+		anon.call("user:error", nil, "Login", "bogus", "admin", adminpassword)
+		anon.login("admin", "badpassword", "user:loginFailed")
+		anon.login("bogus", adminpassword, "user:loginFailed")
+
+		admin := newWebClient(t, ts.URL)
+		admin.login("admin", adminpassword, "")
+		var zones []Zone
+		admin.call("", &zones, "Zones")
+		tcompare(t, len(zones), 2)
+		admin.get("/dnsclay.db", http.StatusOK)
+
+		// Scripts can use HTTP basic auth instead of a session.
+		script := newWebClient(t, ts.URL)
+		script.username, script.password = "admin", adminpassword
+		script.get("/dnsclay.db", http.StatusOK)
+		script.call("", &zones, "Zones")
+		tcompare(t, len(zones), 2)
+		script.password = "badpassword"
+		script.get("/dnsclay.db", http.StatusUnauthorized)
+		script.call("user:badAuth", nil, "Zones")
+
+		// CSRF token is required.
+		csrf := admin.csrf
+		admin.csrf = "bogus"
+		admin.call("user:noAuth", nil, "Zones")
+		admin.csrf = csrf
+
+		var viewerUser, editorUser User
+		admin.call("", &viewerUser, "UserAdd", User{Username: "viewer", Role: RoleViewer}, "viewerpassword")
+		admin.call("", &editorUser, "UserAdd", User{Username: "editor", Role: RoleEditor, Zones: []string{z.Name}}, "editorpassword")
+		admin.call("user:error", nil, "UserAdd", User{Username: "bad", Role: "bogus"}, "badpassword")
+		admin.call("user:error", nil, "UserAdd", User{Username: "short", Role: RoleViewer}, "short")
+		admin.call("user:notFound", nil, "UserAdd", User{Username: "editor2", Role: RoleEditor, Zones: []string{"bogus.example."}}, "editorpassword")
+
+		// Last admin cannot be removed or demoted.
+		var users []User
+		admin.call("", &users, "Users")
+		tcompare(t, len(users), 3)
+		var adminUser User
+		admin.call("", &adminUser, "CurrentUser")
+		tcompare(t, adminUser.Role, RoleAdmin)
+		admin.call("user:error", nil, "UserDelete", adminUser.ID)
+		adminUser.Role = RoleViewer
+		admin.call("user:error", nil, "UserUpdate", adminUser)
+
+		rsc := RecordSetChange{"testhost2", 300, Type(dns.TypeA), []string{"10.0.0.3"}}
+
+		viewer := newWebClient(t, ts.URL)
+		viewer.login("viewer", "viewerpassword", "")
+		viewer.call("", &zones, "Zones")
+		viewer.get("/dnsclay.db", http.StatusForbidden)
+		viewer.call("user:forbidden", nil, "RecordSetAdd", z.Name, rsc)
+		viewer.call("user:forbidden", nil, "Users")
+		var pcs []ProviderConfig
+		viewer.call("", &pcs, "ProviderConfigs")
+		tcompare(t, pcs[0].ProviderConfigJSON, "")
+		viewer.call("user:forbidden", nil, "ProviderConfigReveal", pcs[0].Name)
+		viewer.call("user:forbidden", nil, "ZoneCredentialReveal", te.z0.credTSIG.ID)
+		viewer.call("user:forbidden", nil, "ZoneDiscoveries")
+
+		// Provider errors are only shown to admins.
+		providerResult(te.z0.pc, errors.New("unexpected status code 503"))
+		defer providerHealthReset(te.z0.pc.Name)
+		lastError := func(wc *webClient) string {
+			var health []ProviderHealth
+			wc.call("", &health, "ProviderHealth")
+			i := slices.IndexFunc(health, func(ph ProviderHealth) bool { return ph.ProviderConfigName == te.z0.pc.Name })
+			return health[i].LastError
+		}
+		tcompare(t, lastError(viewer), "")
+		tcompare(t, lastError(admin), "unexpected status code 503")
+
+		editor := newWebClient(t, ts.URL)
+		editor.login("editor", "editorpassword", "")
+		editor.call("user:forbidden", nil, "RecordSetAdd", te.z1.z.Name, rsc)
+		editor.call("user:forbidden", nil, "ZoneDelete", z.Name)
+		editor.call("", nil, "RecordSetAdd", z.Name, rsc)
+
+		// Change is attributed to the editor.
+		var events []AuditEvent
+		viewer.call("", &events, "AuditEvents", z.Name)
+		tcompare(t, events[0].Username, "editor")
+		tcompare(t, events[0].Action, "RecordSetAdd")
+
+		// Secret of a credential shared with a zone the editor cannot change is not revealed.
+		var secret string
+		editor.call("", &secret, "ZoneCredentialReveal", te.z0.credTSIG.ID)
+		err = database.Insert(ctxbg, &ZoneCredential{Zone: te.z1.z.Name, CredentialID: te.z0.credTSIG.ID})
+		tcheck(t, err, "insert zone credential")
+		editor.call("user:forbidden", nil, "ZoneCredentialReveal", te.z0.credTSIG.ID)
+		admin.call("", &secret, "ZoneCredentialReveal", te.z0.credTSIG.ID)
+		tcompare(t, secret, te.z0.credTSIG.TSIGSecret)
+
+		// Password change ends other sessions.
+		editor2 := newWebClient(t, ts.URL)
+		editor2.login("editor", "editorpassword", "")
+		editor.call("user:error", nil, "PasswordChange", "badpassword", "editorpassword2")
+		editor.call("", nil, "PasswordChange", "editorpassword", "editorpassword2")
+		editor2.call("user:noAuth", nil, "Zones")
+		editor.call("", &zones, "Zones")
+
+		// Setting a password ends all sessions.
+		admin.call("", nil, "UserPasswordSet", viewerUser.ID, "viewerpassword2")
+		viewer.call("user:noAuth", nil, "Zones")
+
+		// Expired session is refused and removed.
+		expire := func(s Session) bool { return s.UserID == editorUser.ID }
+		_, err = bstore.QueryDB[Session](ctxbg, database).FilterFn(expire).UpdateField("LastUse", time.Now().Add(-sessionIdleTimeout-time.Minute))
+		tcheck(t, err, "expire sessions")
+		editor.call("user:noAuth", nil, "Zones")
+		n, err := bstore.QueryDB[Session](ctxbg, database).FilterFn(expire).Count()
+		tcheck(t, err, "count sessions")
+		tcompare(t, n, 0)
+
+		// Removing a zone removes it from users.
+		admin.call("", nil, "ZoneDelete", z.Name)
+		var nusers []User
+		admin.call("", &nusers, "Users")
+		for _, u := range nusers {
+			if u.Username == "editor" {
+				tcompare(t, len(u.Zones), 0)
+			}
+		}
+
+		admin.call("", nil, "Logout")
+		admin.call("user:noAuth", nil, "Zones")
+	})
+}
+
+func TestAPITokens(t *testing.T) {
+	loginFailureDelay = 0
+	defer func() { loginFailureDelay = time.Second }()
+
+	mux := testAdminMux()
+
+	testDNS(t, func(te testEnv, z Zone) {
+		ts := httptest.NewServer(mux)
+		defer ts.Close()
+
+		// Tokens are added by logged in users, not with full access.
+		te.sherpaError("user:error", func() { te.api.APITokenAdd(ctxbg, APIToken{Name: "test"}) })
+
+		te.api.UserAdd(ctxbg, User{Username: "admin", Role: RoleAdmin}, "adminpassword")
+		admin := newWebClient(t, ts.URL)
+		admin.login("admin", "adminpassword", "")
+		admin.call("", nil, "UserAdd", User{Username: "editor", Role: RoleEditor, Zones: []string{z.Name, te.z1.z.Name}}, "editorpassword")
+		editor := newWebClient(t, ts.URL)
+		editor.login("editor", "editorpassword", "")
+
+		past := time.Now().Add(-time.Hour)
+		admin.call("user:error", nil, "APITokenAdd", APIToken{})
+		admin.call("user:error", nil, "APITokenAdd", APIToken{Name: "test", Functions: []string{"Bogus"}})
+		admin.call("user:error", nil, "APITokenAdd", APIToken{Name: "test", Functions: []string{"APITokenAdd"}})
+		admin.call("user:error", nil, "APITokenAdd", APIToken{Name: "test", Expires: &past})
+		admin.call("user:notFound", nil, "APITokenAdd", APIToken{Name: "test", Zones: []string{"bogus.example."}})
+
+		var result []any
+		tokenAdd := func(wc *webClient, t APIToken) (APIToken, *webClient) {
+			wc.call("", &result, "APITokenAdd", t)
+			buf, _ := json.Marshal(result[0])
+			var nt APIToken
+			json.Unmarshal(buf, &nt)
+			script := newWebClient(wc.t, ts.URL)
+			script.bearer = result[1].(string)
+			return nt, script
+		}
+
+		rsc := RecordSetChange{"testhost2", 300, Type(dns.TypeA), []string{"10.0.0.3"}}
+
+		// Full access token of an admin.
+		fullToken, full := tokenAdd(admin, APIToken{Name: "full"})
+		tcompare(t, fullToken.TokenHash, "")
+		var zones []Zone
+		full.call("", &zones, "Zones")
+		tcompare(t, len(zones), 2)
+		full.call("", nil, "Users")
+		full.call("user:forbidden", nil, "APITokens")
+		full.call("user:forbidden", nil, "Logout")
+		full.get("/dnsclay.db", http.StatusUnauthorized)
+
+		// Read-only token.
+		_, readonly := tokenAdd(admin, APIToken{Name: "readonly", ReadOnly: true})
+		readonly.call("", &zones, "Zones")
+		readonly.call("user:forbidden", nil, "RecordSetAdd", z.Name, rsc)
+		readonly.call("user:forbidden", nil, "ZoneDelete", z.Name)
+
+		// Admin token limited to a zone acts as editor of that zone.
+		_, zoned := tokenAdd(admin, APIToken{Name: "zoned", Zones: []string{z.Name}})
+		zoned.call("", &zones, "Zones")
+		tcompare(t, len(zones), 1)
+		zoned.call("user:forbidden", nil, "ZoneRecords", te.z1.z.Name)
+		zoned.call("user:forbidden", nil, "RecordSetAdd", te.z1.z.Name, rsc)
+		zoned.call("user:forbidden", nil, "ZoneDelete", z.Name)
+		zoned.call("user:forbidden", nil, "Users")
+
+		zoned.call("user:forbidden", nil, "ZoneDiscoveries")
+
+		// Only health of provider configs of the zones of the token.
+		providerResult(te.z0.pc, nil)
+		providerResult(te.z1.pc, nil)
+		defer providerHealthReset(te.z0.pc.Name)
+		defer providerHealthReset(te.z1.pc.Name)
+		var health []ProviderHealth
+		zoned.call("", &health, "ProviderHealth")
+		tcompare(t, len(health), 1)
+		tcompare(t, health[0].ProviderConfigName, te.z0.pc.Name)
+
+		zoned.call("", nil, "RecordSetAdd", z.Name, rsc)
+
+		// Change is attributed to the user and token.
+		var events []AuditEvent
+		admin.call("", &events, "AuditEvents", z.Name)
+		tcompare(t, events[0].Username, "admin")
+		tcompare(t, events[0].APIToken, "zoned")
+
+		// Token limited to functions.
+		_, fns := tokenAdd(editor, APIToken{Name: "fns", Functions: []string{"Zones"}})
+		fns.call("", &zones, "Zones")
+		fns.call("user:forbidden", nil, "ZoneRecords", z.Name)
+
+		// Editor token cannot exceed the editor's zones.
+		editorToken, ed := tokenAdd(editor, APIToken{Name: "editor", Zones: []string{z.Name}})
+		ed.call("user:forbidden", nil, "RecordSetAdd", te.z1.z.Name, rsc)
+
+		// Users see their own tokens, admins all.
+		var tokens []APIToken
+		editor.call("", &tokens, "APITokens")
+		tcompare(t, len(tokens), 2)
+		admin.call("", &tokens, "APITokens")
+		tcompare(t, len(tokens), 5)
+		tcompare(t, tokens[0].TokenHash, "")
+		tcompare(t, tokens[0].LastUsed != nil, true)
+		editor.call("user:forbidden", nil, "APITokenRevoke", fullToken.ID)
+
+		// Revoked and expired tokens are refused.
+		editor.call("", nil, "APITokenRevoke", editorToken.ID)
+		ed.call("user:badAuth", nil, "Zones")
+		expiredToken, expired := tokenAdd(admin, APIToken{Name: "expired"})
+		err := database.Get(ctxbg, &expiredToken)
+		tcheck(t, err, "get token")
+		expiredToken.Expires = &past
+		err = database.Update(ctxbg, &expiredToken)
+		tcheck(t, err, "update token")
+		expired.call("user:badAuth", nil, "Zones")
+		bogus := newWebClient(t, ts.URL)
+		bogus.bearer = "dnsclay_bogus"
+		bogus.call("user:badAuth", nil, "Zones")
 	})
 }
 
@@ -209,33 +561,33 @@ func TestZoneCredentialAdd(t *testing.T) {
 	testDNS(t, func(te testEnv, z Zone) {
 		// Unknown zone.
 		te.sherpaError("user:notFound", func() {
-			te.api.ZoneCredentialAdd(ctxbg, "bogus", Credential{0, time.Time{}, "tsig1", "tsig", "bWFkZSB5b3UgbG9vayEK", ""})
+			te.api.ZoneCredentialAdd(ctxbg, "bogus", Credential{0, time.Time{}, "tsig1", "tsig", "bWFkZSB5b3UgbG9vayEK", "", false})
 		})
 
-		te.api.ZoneCredentialAdd(ctxbg, z.Name, Credential{0, time.Time{}, "tsig0", "tsig", "bWFkZSB5b3UgbG9vayEK", ""})
-		nc1 := te.api.ZoneCredentialAdd(ctxbg, z.Name, Credential{0, time.Time{}, "tsig1", "tsig", "", ""})
+		te.api.ZoneCredentialAdd(ctxbg, z.Name, Credential{0, time.Time{}, "tsig0", "tsig", "bWFkZSB5b3UgbG9vayEK", "", false})
+		nc1 := te.api.ZoneCredentialAdd(ctxbg, z.Name, Credential{0, time.Time{}, "tsig1", "tsig", "", "", false})
 		tcompare(t, len(nc1.TSIGSecret) > 0, true)
-		te.api.ZoneCredentialAdd(ctxbg, z.Name, Credential{0, time.Time{}, "pubkey0", "tlspubkey", "", "MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE"})
+		te.api.ZoneCredentialAdd(ctxbg, z.Name, Credential{0, time.Time{}, "pubkey0", "tlspubkey", "", "MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE", false})
 
 		// Duplicate name.
 		te.sherpaError("user:error", func() {
-			te.api.ZoneCredentialAdd(ctxbg, z.Name, Credential{0, time.Time{}, "tsig1", "tsig", "bWFkZSB5b3UgbG9vayEK", ""})
+			te.api.ZoneCredentialAdd(ctxbg, z.Name, Credential{0, time.Time{}, "tsig1", "tsig", "bWFkZSB5b3UgbG9vayEK", "", false})
 		})
 		// Bad type.
 		te.sherpaError("user:error", func() {
-			te.api.ZoneCredentialAdd(ctxbg, z.Name, Credential{0, time.Time{}, "tsig2", "badtype", "", ""})
+			te.api.ZoneCredentialAdd(ctxbg, z.Name, Credential{0, time.Time{}, "tsig2", "badtype", "", "", false})
 		})
 		// Bad tls pub key length.
 		te.sherpaError("user:error", func() {
-			te.api.ZoneCredentialAdd(ctxbg, z.Name, Credential{0, time.Time{}, "tlspubkey2", "tlspubkey", "", "bWFkZSB5b3UgbG9vayEK"})
+			te.api.ZoneCredentialAdd(ctxbg, z.Name, Credential{0, time.Time{}, "tlspubkey2", "tlspubkey", "", "bWFkZSB5b3UgbG9vayEK", false})
 		})
 	})
 }
 
 func TestZoneCredentialDelete(t *testing.T) {
 	testDNS(t, func(te testEnv, z Zone) {
-		nc0 := te.api.ZoneCredentialAdd(ctxbg, z.Name, Credential{0, time.Time{}, "tsig1", "tsig", "bWFkZSB5b3UgbG9vayEK", ""})
-		nc1 := te.api.ZoneCredentialAdd(ctxbg, z.Name, Credential{0, time.Time{}, "pubkey1", "tlspubkey", "", "MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE"})
+		nc0 := te.api.ZoneCredentialAdd(ctxbg, z.Name, Credential{0, time.Time{}, "tsig1", "tsig", "bWFkZSB5b3UgbG9vayEK", "", false})
+		nc1 := te.api.ZoneCredentialAdd(ctxbg, z.Name, Credential{0, time.Time{}, "pubkey1", "tlspubkey", "", "MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE", false})
 
 		te.api.ZoneCredentialDelete(ctxbg, nc0.ID)
 		te.api.ZoneCredentialDelete(ctxbg, nc1.ID)
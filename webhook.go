@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// WebhookProvider is a provider for DNS systems with an HTTP/JSON API, e.g. an
+// in-house IPAM, configured with templates for requests and a mapping for
+// responses.
+//
+// Paths and bodies are Go text/template templates, executed with fields Zone
+// (with trailing dot), Record (for append/delete, with fields ID, Type, Name,
+// Value and TTL in seconds) and RRSet (for set, with fields Type, Name, TTL,
+// Values and Records). Template functions: "json" for JSON-encoding a value,
+// "path" for escaping a URL path segment, "trimdot" for removing a trailing dot.
+// Records are appended and deleted with one request per record. Set replaces
+// rrsets, with one request per rrset, as records for new rrsets have no ID.
+//
+// The get response (and optionally append/set responses) is parsed as JSON. The
+// records are found at records_path (dot-separated keys, or empty for a top-level
+// array), with each record an object with fields as configured in the field_*
+// settings.
+type WebhookProvider struct {
+	BaseURL    string `json:"base_url,omitempty"`    // Base URL, paths are appended, e.g. https://ipam.example/api.
+	AuthHeader string `json:"auth_header,omitempty"` // Optional header for authentication, e.g. "Authorization: Bearer token". Can reference secrets, e.g. "env:IPAM_AUTH".
+
+	GetMethod    string `json:"get_method,omitempty"`    // Default GET.
+	GetPath      string `json:"get_path,omitempty"`      // Template, default "/zones/{{path (trimdot .Zone)}}/records".
+	AppendMethod string `json:"append_method,omitempty"` // Default POST.
+	AppendPath   string `json:"append_path,omitempty"`   // Template, default "/zones/{{path (trimdot .Zone)}}/records".
+	AppendBody   string `json:"append_body,omitempty"`   // Template, default a JSON object with fields as configured for records.
+	SetMethod    string `json:"set_method,omitempty"`    // Default PUT.
+	SetPath      string `json:"set_path,omitempty"`      // Template, default "/zones/{{path (trimdot .Zone)}}/rrsets".
+	SetBody      string `json:"set_body,omitempty"`      // Template, default a JSON object with fields "type", "name", "ttl" and "records" with objects like append_body.
+	DeleteMethod string `json:"delete_method,omitempty"` // Default DELETE.
+	DeletePath   string `json:"delete_path,omitempty"`   // Template, default "/zones/{{path (trimdot .Zone)}}/records/{{path .Record.ID}}".
+	DeleteBody   string `json:"delete_body,omitempty"`   // Template, default no body.
+
+	RecordsPath string `json:"records_path,omitempty"` // Dot-separated path to the array of records in the get response, e.g. "data.records". Empty for a top-level array.
+	FieldID     string `json:"field_id,omitempty"`     // Name of field with record ID, default "id".
+	FieldType   string `json:"field_type,omitempty"`   // Default "type".
+	FieldName   string `json:"field_name,omitempty"`   // Name relative to zone, default "name".
+	FieldValue  string `json:"field_value,omitempty"`  // Default "value".
+	FieldTTL    string `json:"field_ttl,omitempty"`    // TTL in seconds, default "ttl".
+}
+
+var _ libdnsProvider = (*WebhookProvider)(nil)
+var _ providerConfigChecker = (*WebhookProvider)(nil)
+
+type webhookRecord struct {
+	ID    string
+	Type  string
+	Name  string
+	Value string
+	TTL   int64
+}
+
+type webhookRRSet struct {
+	Type    string
+	Name    string
+	TTL     int64
+	Values  []string
+	Records []webhookRecord
+}
+
+type webhookData struct {
+	Zone   string
+	Record webhookRecord
+	RRSet  webhookRRSet
+}
+
+var webhookFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		buf, err := json.Marshal(v)
+		return string(buf), err
+	},
+	"path":    url.PathEscape,
+	"trimdot": func(s string) string { return strings.TrimSuffix(s, ".") },
+}
+
+func webhookDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func (p *WebhookProvider) fields() (id, typ, name, value, ttl string) {
+	return webhookDefault(p.FieldID, "id"), webhookDefault(p.FieldType, "type"), webhookDefault(p.FieldName, "name"), webhookDefault(p.FieldValue, "value"), webhookDefault(p.FieldTTL, "ttl")
+}
+
+// defaultBody returns the default body template for append, with the configured
+// field names.
+func (p *WebhookProvider) defaultBody() string {
+	return p.recordBody(".Record")
+}
+
+// defaultSetBody returns the default body template for set, an object for the
+// rrset with its records.
+func (p *WebhookProvider) defaultSetBody() string {
+	return `{"type": {{json .RRSet.Type}}, "name": {{json .RRSet.Name}}, "ttl": {{.RRSet.TTL}}, "records": [{{range $i, $r := .RRSet.Records}}{{if $i}}, {{end}}` + p.recordBody("$r") + `{{end}}]}`
+}
+
+// recordBody returns a template for a JSON object for the record in variable or
+// field v, with the configured field names.
+func (p *WebhookProvider) recordBody(v string) string {
+	_, typ, name, value, ttl := p.fields()
+	q := func(s string) string { return strconv.Quote(s) }
+	return fmt.Sprintf(`{%[1]s: {{json %[5]s.Type}}, %[2]s: {{json %[5]s.Name}}, %[3]s: {{json %[5]s.Value}}, %[4]s: {{%[5]s.TTL}}}`, q(typ), q(name), q(value), q(ttl), v)
+}
+
+func (p *WebhookProvider) checkConfig() error {
+	if p.BaseURL == "" {
+		return errors.New("webhook provider config needs base_url")
+	}
+	u, err := url.Parse(p.BaseURL)
+	if err != nil {
+		return fmt.Errorf("parsing base_url: %v", err)
+	} else if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("base_url must be an http or https url with host")
+	}
+	if p.AuthHeader != "" && !strings.Contains(p.AuthHeader, ":") {
+		return errors.New(`auth_header must have form "name: value"`)
+	}
+	templates := []struct{ what, text string }{
+		{"get_path", p.GetPath},
+		{"append_path", p.AppendPath},
+		{"append_body", p.AppendBody},
+		{"set_path", p.SetPath},
+		{"set_body", p.SetBody},
+		{"delete_path", p.DeletePath},
+		{"delete_body", p.DeleteBody},
+	}
+	for _, t := range templates {
+		if _, err := webhookParse(t.what, t.text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func webhookParse(what, text string) (*template.Template, error) {
+	t, err := template.New(what).Funcs(webhookFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s template: %v", what, err)
+	}
+	return t, nil
+}
+
+func webhookExec(what, text string, data webhookData) (string, error) {
+	t, err := webhookParse(what, text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("executing %s template: %v", what, err)
+	}
+	return b.String(), nil
+}
+
+// do executes the path and body templates, makes the request, and returns the
+// response body.
+func (p *WebhookProvider) do(ctx context.Context, method, pathTemplate, bodyTemplate string, data webhookData) ([]byte, error) {
+	path, err := webhookExec("path", pathTemplate, data)
+	if err != nil {
+		return nil, err
+	}
+	var body io.Reader
+	if bodyTemplate != "" {
+		s, err := webhookExec("body", bodyTemplate, data)
+		if err != nil {
+			return nil, err
+		}
+		body = strings.NewReader(s)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(p.BaseURL, "/")+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("new webhook request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if p.AuthHeader != "" {
+		k, v, _ := strings.Cut(p.AuthHeader, ":")
+		req.Header.Set(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("webhook request: %w", err)
+	}
+	defer resp.Body.Close()
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, 16*1024*1024))
+	if err != nil {
+		return nil, fmt.Errorf("reading webhook response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		msg := strings.TrimSpace(string(buf))
+		if len(msg) > 256 {
+			msg = msg[:256] + "..."
+		}
+		return nil, fmt.Errorf("webhook %s %s: http status %d: %s", method, path, resp.StatusCode, msg)
+	}
+	return buf, nil
+}
+
+// parseRecord returns a record from a JSON object in a response.
+func (p *WebhookProvider) parseRecord(v any) (libdns.Record, error) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return libdns.Record{}, fmt.Errorf("record is %T, not an object", v)
+	}
+	str := func(k string) string {
+		switch x := m[k].(type) {
+		case string:
+			return x
+		case json.Number:
+			return x.String()
+		}
+		return ""
+	}
+	fid, ftyp, fname, fvalue, fttl := p.fields()
+	ttl, _ := strconv.ParseInt(str(fttl), 10, 64)
+	r := libdns.Record{ID: str(fid), Type: str(ftyp), Name: str(fname), Value: str(fvalue), TTL: time.Duration(ttl) * time.Second}
+	if r.Type == "" {
+		return libdns.Record{}, fmt.Errorf("record without field %q for type", ftyp)
+	}
+	return r, nil
+}
+
+func webhookDecode(buf []byte) (any, error) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("parsing webhook response: %v", err)
+	}
+	return v, nil
+}
+
+func (p *WebhookProvider) GetRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
+	buf, err := p.do(ctx, webhookDefault(p.GetMethod, "GET"), webhookDefault(p.GetPath, "/zones/{{path (trimdot .Zone)}}/records"), "", webhookData{Zone: zone})
+	if err != nil {
+		return nil, err
+	}
+	v, err := webhookDecode(buf)
+	if err != nil {
+		return nil, err
+	}
+	if p.RecordsPath != "" {
+		for _, k := range strings.Split(p.RecordsPath, ".") {
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("webhook response: cannot find %q in %T", k, v)
+			}
+			v = m[k]
+		}
+	}
+	l, ok := v.([]any)
+	if !ok && v != nil {
+		return nil, fmt.Errorf("webhook response: records are %T, not an array", v)
+	}
+	var recs []libdns.Record
+	for i, e := range l {
+		r, err := p.parseRecord(e)
+		if err != nil {
+			return nil, fmt.Errorf("webhook response: record %d: %v", i, err)
+		}
+		recs = append(recs, r)
+	}
+	return recs, nil
+}
+
+// change makes a request for each record, returning the records from the
+// responses if they are records, and the request records otherwise.
+func (p *WebhookProvider) change(ctx context.Context, zone, method, pathTemplate, bodyTemplate string, recs []libdns.Record) ([]libdns.Record, error) {
+	var l []libdns.Record
+	for _, r := range recs {
+		data := webhookData{Zone: zone, Record: webhookRecord{r.ID, r.Type, r.Name, r.Value, int64(r.TTL / time.Second)}}
+		buf, err := p.do(ctx, method, pathTemplate, bodyTemplate, data)
+		if err != nil {
+			return l, err
+		}
+		if v, err := webhookDecode(buf); err == nil {
+			if nr, err := p.parseRecord(v); err == nil {
+				r = nr
+			}
+		}
+		l = append(l, r)
+	}
+	return l, nil
+}
+
+func (p *WebhookProvider) AppendRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	return p.change(ctx, zone, webhookDefault(p.AppendMethod, "POST"), webhookDefault(p.AppendPath, "/zones/{{path (trimdot .Zone)}}/records"), webhookDefault(p.AppendBody, p.defaultBody()), recs)
+}
+
+// SetRecords makes a request for each rrset, returning the records from the
+// response if it is an array of records, and the request records otherwise.
+func (p *WebhookProvider) SetRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	type key struct{ name, typ string }
+	var keys []key
+	rrsets := map[key][]libdns.Record{}
+	for _, r := range recs {
+		k := key{r.Name, r.Type}
+		if _, ok := rrsets[k]; !ok {
+			keys = append(keys, k)
+		}
+		rrsets[k] = append(rrsets[k], r)
+	}
+
+	method := webhookDefault(p.SetMethod, "PUT")
+	pathTemplate := webhookDefault(p.SetPath, "/zones/{{path (trimdot .Zone)}}/rrsets")
+	bodyTemplate := webhookDefault(p.SetBody, p.defaultSetBody())
+	var l []libdns.Record
+	for _, k := range keys {
+		rrset := webhookRRSet{Type: k.typ, Name: k.name}
+		for _, r := range rrsets[k] {
+			rrset.TTL = int64(r.TTL / time.Second)
+			rrset.Values = append(rrset.Values, r.Value)
+			rrset.Records = append(rrset.Records, webhookRecord{r.ID, r.Type, r.Name, r.Value, int64(r.TTL / time.Second)})
+		}
+		buf, err := p.do(ctx, method, pathTemplate, bodyTemplate, webhookData{Zone: zone, RRSet: rrset})
+		if err != nil {
+			return l, err
+		}
+		nl := rrsets[k]
+		if v, err := webhookDecode(buf); err == nil {
+			if a, ok := v.([]any); ok {
+				var xl []libdns.Record
+				for _, e := range a {
+					nr, err := p.parseRecord(e)
+					if err != nil {
+						xl = nil
+						break
+					}
+					xl = append(xl, nr)
+				}
+				if xl != nil {
+					nl = xl
+				}
+			}
+		}
+		l = append(l, nl...)
+	}
+	return l, nil
+}
+
+func (p *WebhookProvider) DeleteRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	return p.change(ctx, zone, webhookDefault(p.DeleteMethod, "DELETE"), webhookDefault(p.DeletePath, "/zones/{{path (trimdot .Zone)}}/records/{{path .Record.ID}}"), p.DeleteBody, recs)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/libdns/libdns"
+)
+
+func TestWebhook(t *testing.T) {
+	type ipamRecord struct {
+		ID      string `json:"id"`
+		Type    string `json:"type"`
+		Name    string `json:"name"`
+		Content string `json:"content"`
+		TTL     int    `json:"ttl"`
+	}
+	var mu sync.Mutex
+	records := []ipamRecord{{"1", "A", "testhost", "10.0.0.1", 300}}
+	nextID := 2
+	var fail bool
+	var nset int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("X-Token") != "secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		// Set replaces an rrset, responding with its new records.
+		if r.Method == "PUT" && r.URL.Path == "/api/zones/example.com/rrsets" {
+			var rrset struct {
+				Type    string
+				Name    string
+				Records []ipamRecord
+			}
+			if err := json.NewDecoder(r.Body).Decode(&rrset); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			nset++
+			var nrecords []ipamRecord
+			for _, rec := range records {
+				if rec.Name != rrset.Name || rec.Type != rrset.Type {
+					nrecords = append(nrecords, rec)
+				}
+			}
+			for i := range rrset.Records {
+				rrset.Records[i].ID = fmt.Sprintf("%d", nextID)
+				nextID++
+			}
+			records = append(nrecords, rrset.Records...)
+			json.NewEncoder(w).Encode(rrset.Records)
+			return
+		}
+
+		prefix := "/api/zones/example.com/records"
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			http.NotFound(w, r)
+			return
+		}
+		id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		switch r.Method {
+		case "GET":
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"records": records}})
+		case "POST":
+			var rec ipamRecord
+			if err := json.NewDecoder(r.Body).Decode(&rec); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			rec.ID = fmt.Sprintf("%d", nextID)
+			nextID++
+			records = append(records, rec)
+			json.NewEncoder(w).Encode(rec)
+		case "DELETE":
+			for i, rec := range records {
+				if rec.ID == id {
+					records = append(records[:i], records[i+1:]...)
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+			}
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	config := func(token string) string {
+		return fmt.Sprintf(`{"base_url": "%s/api", "auth_header": "X-Token: %s", "records_path": "data.records", "field_value": "content"}`, srv.URL, token)
+	}
+	p, err := providerForConfig("webhook", config("secret"))
+	tcheck(t, err, "provider for config")
+
+	zone := "example.com."
+	recs, err := p.GetRecords(ctxbg, zone)
+	tcheck(t, err, "get records")
+	tcompare(t, recs, []libdns.Record{ldr("1", "testhost", 300, "A", "10.0.0.1")})
+
+	added, err := p.AppendRecords(ctxbg, zone, []libdns.Record{ldr("", "other", 60, "TXT", "hi")})
+	tcheck(t, err, "append records")
+	tcompare(t, added, []libdns.Record{ldr("2", "other", 60, "TXT", "hi")})
+
+	// Set replaces rrsets with one request per rrset, records need no ID.
+	set, err := p.SetRecords(ctxbg, zone, []libdns.Record{
+		ldr("", "other", 120, "TXT", "bye"),
+		ldr("", "multi", 300, "A", "10.0.0.2"),
+		ldr("", "multi", 300, "A", "10.0.0.3"),
+	})
+	tcheck(t, err, "set records")
+	tcompare(t, nset, 2)
+	tcompare(t, set, []libdns.Record{
+		ldr("3", "other", 120, "TXT", "bye"),
+		ldr("4", "multi", 300, "A", "10.0.0.2"),
+		ldr("5", "multi", 300, "A", "10.0.0.3"),
+	})
+
+	_, err = p.DeleteRecords(ctxbg, zone, []libdns.Record{ldr("1", "testhost", 300, "A", "10.0.0.1")})
+	tcheck(t, err, "delete records")
+
+	recs, err = p.GetRecords(ctxbg, zone)
+	tcheck(t, err, "get records")
+	tcompare(t, recs, []libdns.Record{
+		ldr("3", "other", 120, "TXT", "bye"),
+		ldr("4", "multi", 300, "A", "10.0.0.2"),
+		ldr("5", "multi", 300, "A", "10.0.0.3"),
+	})
+
+	// Server errors are transient, so operations are retried.
+	mu.Lock()
+	fail = true
+	mu.Unlock()
+	_, err = p.GetRecords(ctxbg, zone)
+	if err == nil || !isTransientError(err) {
+		t.Fatalf("got err %v, expected transient error", err)
+	}
+
+	// Bad credentials.
+	p, err = providerForConfig("webhook", config("bad"))
+	tcheck(t, err, "provider for config")
+	_, err = p.GetRecords(ctxbg, zone)
+	if err == nil || isTransientError(err) {
+		t.Fatalf("got err %v, expected permanent error", err)
+	}
+
+	// Invalid configs.
+	bad := []string{
+		`{}`,
+		`{"base_url": "ftp://ipam.example"}`,
+		`{"base_url": "https://ipam.example", "auth_header": "bogus"}`,
+		`{"base_url": "https://ipam.example", "set_path": "/{{"}`,
+	}
+	for _, c := range bad {
+		_, err := providerForConfig("webhook", c)
+		if !errors.Is(err, errProviderUserError) {
+			t.Fatalf("got err %v, expected user error for config %s", err, c)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/miekg/dns"
+)
+
+// ZoneFileProvider stores records in a master zone file on disk, as used by BIND,
+// Knot, NSD and others. For labs, air-gapped setups and testing without a DNS
+// operator account.
+//
+// Files are rewritten atomically, by writing a new file and renaming it over the
+// old file. The SOA serial is incremented for each change, unless the change sets
+// the SOA record. The file must exist and have a SOA record. Comments and
+// formatting are not preserved.
+type ZoneFileProvider struct {
+	Path          string `json:"path,omitempty"`           // Path to zone file. "{zone}" is replaced with the zone name without trailing dot, e.g. "/var/lib/knot/{zone}.zone".
+	ReloadCommand string `json:"reload_command,omitempty"` // Optional command to run after writing the file, with arguments separated by whitespace, not run through a shell. "{zone}" is replaced with the zone name, e.g. "knotc zone-reload {zone}". The program must be allowed with -providercommands.
+}
+
+var _ libdnsProvider = (*ZoneFileProvider)(nil)
+var _ providerConfigChecker = (*ZoneFileProvider)(nil)
+
+func (p *ZoneFileProvider) checkConfig() error {
+	if p.Path == "" {
+		return errors.New("zonefile provider config needs path")
+	}
+	// The program is checked against -providercommands when the command is run.
+	if p.ReloadCommand != "" && len(strings.Fields(p.ReloadCommand)) == 0 {
+		return errors.New("zonefile provider config has empty reload command")
+	}
+	return nil
+}
+
+// zonefileLock serializes reading and writing zone files.
+var zonefileLock sync.Mutex
+
+func (p *ZoneFileProvider) path(zone string) (string, error) {
+	if p.Path == "" {
+		return "", fmt.Errorf("%w: zonefile provider config needs path", errProviderUserError)
+	}
+	return strings.ReplaceAll(p.Path, "{zone}", strings.TrimSuffix(zone, ".")), nil
+}
+
+// read parses the zone file. The SOA record is first.
+func (p *ZoneFileProvider) read(zone string) ([]dns.RR, error) {
+	path, err := p.path(zone)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open zone file: %w", err)
+	}
+	defer f.Close()
+
+	var soa dns.RR
+	var l []dns.RR
+	zp := dns.NewZoneParser(f, dns.Fqdn(zone), path)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		if rr.Header().Rrtype == dns.TypeSOA && strings.EqualFold(rr.Header().Name, dns.Fqdn(zone)) {
+			soa = rr
+			continue
+		}
+		l = append(l, rr)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, fmt.Errorf("parsing zone file: %v", err)
+	}
+	if soa == nil {
+		return nil, fmt.Errorf("zone file %s has no soa record for zone", path)
+	}
+	return append([]dns.RR{soa}, l...), nil
+}
+
+// write replaces the zone file and runs the reload command, if any.
+func (p *ZoneFileProvider) write(ctx context.Context, zone string, rrs []dns.RR) error {
+	path, err := p.path(zone)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "; Written by dnsclay at %s.\n$ORIGIN %s\n", time.Now().UTC().Format(time.RFC3339), dns.Fqdn(zone))
+	for _, rr := range rrs {
+		fmt.Fprintln(&b, rr.String())
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat zone file: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("create temporary zone file: %w", err)
+	}
+	defer func() {
+		if f != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err := f.Write(b.Bytes()); err != nil {
+		return fmt.Errorf("write temporary zone file: %w", err)
+	}
+	if err := f.Chmod(fi.Mode().Perm()); err != nil {
+		return fmt.Errorf("set permissions of temporary zone file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("sync temporary zone file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close temporary zone file: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("replacing zone file: %w", err)
+	}
+	f = nil
+
+	if p.ReloadCommand == "" {
+		return nil
+	}
+	args, err := providerCommandArgs(strings.ReplaceAll(p.ReloadCommand, "{zone}", strings.TrimSuffix(zone, ".")))
+	if err != nil {
+		return fmt.Errorf("zone file written, but not running reload command: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput(); err != nil {
+		return fmt.Errorf("zone file written, but reload command failed: %w (%s)", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// zonefileRR parses a libdns record into an RR.
+func zonefileRR(zone string, r libdns.Record) (dns.RR, error) {
+	text := fmt.Sprintf("%s %d %s %s", libdns.AbsoluteName(r.Name, dns.Fqdn(zone)), r.TTL/time.Second, r.Type, r.Value)
+	rr, err := dns.NewRR(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing record %q: %v", text, err)
+	} else if rr == nil {
+		return nil, fmt.Errorf("parsing record %q: no record", text)
+	}
+	return rr, nil
+}
+
+func zonefileRecord(zone string, rr dns.RR) (libdns.Record, error) {
+	_, value, err := recordData(rr)
+	if err != nil {
+		return libdns.Record{}, err
+	}
+	h := rr.Header()
+	return libdns.Record{
+		Type:  dns.TypeToString[h.Rrtype],
+		Name:  libdns.RelativeName(h.Name, dns.Fqdn(zone)),
+		Value: value,
+		TTL:   time.Duration(h.Ttl) * time.Second,
+	}, nil
+}
+
+func (p *ZoneFileProvider) GetRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
+	zonefileLock.Lock()
+	defer zonefileLock.Unlock()
+
+	rrs, err := p.read(zone)
+	if err != nil {
+		return nil, err
+	}
+	var l []libdns.Record
+	for _, rr := range rrs {
+		r, err := zonefileRecord(zone, rr)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, r)
+	}
+	return l, nil
+}
+
+// change reads the zone file, calls fn to modify the records, and writes the file
+// if anything changed, with an incremented SOA serial unless fn changed the SOA
+// record. fn returns the records that were changed.
+func (p *ZoneFileProvider) change(ctx context.Context, zone string, recs []libdns.Record, fn func(rrs []dns.RR, rr dns.RR) ([]dns.RR, bool)) ([]libdns.Record, error) {
+	zonefileLock.Lock()
+	defer zonefileLock.Unlock()
+
+	rrs, err := p.read(zone)
+	if err != nil {
+		return nil, err
+	}
+	soa := dns.Copy(rrs[0])
+
+	var changed []libdns.Record
+	for _, r := range recs {
+		rr, err := zonefileRR(zone, r)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		rrs, ok = fn(rrs, rr)
+		if ok {
+			changed = append(changed, r)
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	// Ensure SOA is first, and bump its serial if it wasn't changed.
+	var nsoa *dns.SOA
+	for i, rr := range rrs {
+		if x, ok := rr.(*dns.SOA); ok && strings.EqualFold(x.Hdr.Name, dns.Fqdn(zone)) {
+			nsoa = x
+			rrs = append([]dns.RR{rr}, append(rrs[:i:i], rrs[i+1:]...)...)
+			break
+		}
+	}
+	if nsoa == nil {
+		return nil, fmt.Errorf("zone would have no soa record")
+	}
+	if dns.IsDuplicate(nsoa, soa) {
+		nsoa.Serial++
+	}
+
+	if err := p.write(ctx, zone, rrs); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+func zonefileIndex(rrs []dns.RR, rr dns.RR) int {
+	for i, x := range rrs {
+		if dns.IsDuplicate(x, rr) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *ZoneFileProvider) AppendRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	return p.change(ctx, zone, recs, func(rrs []dns.RR, rr dns.RR) ([]dns.RR, bool) {
+		if zonefileIndex(rrs, rr) >= 0 {
+			return rrs, false
+		}
+		return append(rrs, rr), true
+	})
+}
+
+// SetRecords replaces the records of the rrsets (name and type) of recs.
+func (p *ZoneFileProvider) SetRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	type rrsetKey struct {
+		name string
+		typ  uint16
+	}
+	replaced := map[rrsetKey]bool{}
+	return p.change(ctx, zone, recs, func(rrs []dns.RR, rr dns.RR) ([]dns.RR, bool) {
+		h := rr.Header()
+		k := rrsetKey{strings.ToLower(h.Name), h.Rrtype}
+		if !replaced[k] {
+			replaced[k] = true
+			var l []dns.RR
+			for _, x := range rrs {
+				xh := x.Header()
+				if xh.Rrtype != h.Rrtype || !strings.EqualFold(xh.Name, h.Name) {
+					l = append(l, x)
+				}
+			}
+			rrs = l
+		}
+		return append(rrs, rr), true
+	})
+}
+
+func (p *ZoneFileProvider) DeleteRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	return p.change(ctx, zone, recs, func(rrs []dns.RR, rr dns.RR) ([]dns.RR, bool) {
+		i := zonefileIndex(rrs, rr)
+		if i < 0 {
+			return rrs, false
+		}
+		return append(rrs[:i:i], rrs[i+1:]...), true
+	})
+}
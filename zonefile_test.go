@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/libdns/libdns"
+)
+
+func TestZoneFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "example.com.zone")
+	err := os.WriteFile(path, []byte(`$ORIGIN example.com.
+$TTL 300
+@	IN	SOA	ns0.example.com. hostmaster.example.com. 2024010100 3600 300 1209600 300
+@	IN	NS	ns0.example.com.
+testhost	IN	A	10.0.0.1
+`), 0640)
+	tcheck(t, err, "write zone file")
+
+	// Invalid configs.
+	for _, c := range []string{`{"path": "x", "reload_command": " "}`, `{}`} {
+		_, err = providerForConfig("zonefile", c)
+		if !errors.Is(err, errProviderUserError) {
+			t.Fatalf("got err %v, expected user error for config %s", err, c)
+		}
+	}
+
+	reloaded := filepath.Join(dir, "reloaded")
+	config := `{"path": "` + filepath.Join(dir, "{zone}.zone") + `", "reload_command": "touch ` + reloaded + `"}`
+	p, err := providerForConfig("zonefile", config)
+	tcheck(t, err, "provider for config")
+	defer func(l []string) {
+		providerCommandsAllowed = l
+	}(providerCommandsAllowed)
+	providerCommandsAllowed = []string{"touch"}
+
+	zone := "example.com."
+	soa := func(serial string) libdns.Record {
+		return ldr("", "", 300, "SOA", "ns0.example.com. hostmaster.example.com. "+serial+" 3600 300 1209600 300")
+	}
+	recs, err := p.GetRecords(ctxbg, zone)
+	tcheck(t, err, "get records")
+	tcompare(t, recs, []libdns.Record{
+		soa("2024010100"),
+		ldr("", "", 300, "NS", "ns0.example.com."),
+		ldr("", "testhost", 300, "A", "10.0.0.1"),
+	})
+
+	// Appending bumps the serial and runs the reload command.
+	added, err := p.AppendRecords(ctxbg, zone, []libdns.Record{ldr("", "other", 60, "TXT", `"hi"`)})
+	tcheck(t, err, "append records")
+	tcompare(t, len(added), 1)
+	_, err = os.Stat(reloaded)
+	tcheck(t, err, "stat file created by reload command")
+
+	// Adding a record that exists is a no-op.
+	added, err = p.AppendRecords(ctxbg, zone, []libdns.Record{ldr("", "other", 60, "TXT", `"hi"`)})
+	tcheck(t, err, "append records")
+	tcompare(t, len(added), 0)
+
+	// Set replaces the rrset.
+	_, err = p.SetRecords(ctxbg, zone, []libdns.Record{ldr("", "testhost", 300, "A", "10.0.0.2"), ldr("", "testhost", 300, "A", "10.0.0.3")})
+	tcheck(t, err, "set records")
+
+	_, err = p.DeleteRecords(ctxbg, zone, []libdns.Record{ldr("", "testhost", 0, "A", "10.0.0.3")})
+	tcheck(t, err, "delete records")
+
+	recs, err = p.GetRecords(ctxbg, zone)
+	tcheck(t, err, "get records")
+	tcompare(t, recs, []libdns.Record{
+		soa("2024010103"),
+		ldr("", "", 300, "NS", "ns0.example.com."),
+		ldr("", "other", 60, "TXT", `"hi"`),
+		ldr("", "testhost", 300, "A", "10.0.0.2"),
+	})
+
+	fi, err := os.Stat(path)
+	tcheck(t, err, "stat zone file")
+	tcompare(t, fi.Mode().Perm(), os.FileMode(0640))
+
+	// Setting the SOA record keeps its serial.
+	_, err = p.SetRecords(ctxbg, zone, []libdns.Record{soa("2025010100")})
+	tcheck(t, err, "set soa")
+	recs, err = p.GetRecords(ctxbg, zone)
+	tcheck(t, err, "get records")
+	tcompare(t, recs[0], soa("2025010100"))
+
+	// Reload command must be allowed when it is run. The file is still written.
+	providerCommandsAllowed = nil
+	_, err = p.AppendRecords(ctxbg, zone, []libdns.Record{ldr("", "notallowed", 60, "TXT", `"hi"`)})
+	if !errors.Is(err, errProviderUserError) {
+		t.Fatalf("got err %v, expected user error for reload command not allowed", err)
+	}
+	recs, err = p.GetRecords(ctxbg, zone)
+	tcheck(t, err, "get records")
+	tcompare(t, len(recs), 5)
+
+	// Missing zone file.
+	_, err = p.GetRecords(ctxbg, "other.example.")
+	if err == nil {
+		t.Fatalf("expected error for missing zone file")
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/mjl-/bstore"
+)
+
+// zoneCollector exports per-zone gauges, gathered from the database at scrape
+// time, so removed zones don't leave stale series behind.
+type zoneCollector struct{}
+
+var (
+	descZoneLastSync = prometheus.NewDesc(
+		"dnsclay_zone_last_sync_timestamp_seconds",
+		"Time of last successful sync of the zone with the records at the provider, as unix timestamp.",
+		[]string{"zone"}, nil,
+	)
+	descZoneLastRecordChange = prometheus.NewDesc(
+		"dnsclay_zone_last_record_change_timestamp_seconds",
+		"Time a record change was last detected for the zone, as unix timestamp.",
+		[]string{"zone"}, nil,
+	)
+	descZoneSyncInterval = prometheus.NewDesc(
+		"dnsclay_zone_sync_interval_seconds",
+		"Interval between automatic syncs of the zone, for alerting on stale zones.",
+		[]string{"zone"}, nil,
+	)
+	descZoneSerial = prometheus.NewDesc(
+		"dnsclay_zone_serial",
+		"Serial of the zone, local as known by dnsclay and remote as last seen at the provider.",
+		[]string{"zone", "serial"}, nil, // serial: "local", "remote"
+	)
+	descZoneRecords = prometheus.NewDesc(
+		"dnsclay_zone_records",
+		"Number of current records in the zone.",
+		[]string{"zone"}, nil,
+	)
+	descZonePropagationChecks = prometheus.NewDesc(
+		"dnsclay_zone_propagation_checks",
+		"Number of checks whether changes are visible at the provider or name servers.",
+		[]string{"zone", "state"}, nil, // state: "pending", "failed"
+	)
+)
+
+func init() {
+	prometheus.MustRegister(zoneCollector{})
+}
+
+func (zoneCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descZoneLastSync
+	ch <- descZoneLastRecordChange
+	ch <- descZoneSyncInterval
+	ch <- descZoneSerial
+	ch <- descZoneRecords
+	ch <- descZonePropagationChecks
+}
+
+func (zoneCollector) Collect(ch chan<- prometheus.Metric) {
+	if database == nil {
+		return
+	}
+
+	type propagationCounts struct {
+		pending, failed int
+	}
+	var zones []Zone
+	records := map[string]int{}
+	checks := map[string]propagationCounts{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := database.Read(ctx, func(tx *bstore.Tx) error {
+		var err error
+		zones, err = bstore.QueryTx[Zone](tx).List()
+		if err != nil {
+			return err
+		}
+		// Count current records through the index, history records aren't read.
+		for _, z := range zones {
+			q := bstore.QueryTx[Record](tx)
+			q.FilterNonzero(Record{Zone: z.Name})
+			q.FilterEqual("SerialDeleted", Serial(0))
+			n, err := q.Count()
+			if err != nil {
+				return err
+			}
+			records[z.Name] = n
+		}
+		return bstore.QueryTx[PropagationCheck](tx).ForEach(func(pc PropagationCheck) error {
+			c := checks[pc.Zone]
+			if pc.Failed {
+				c.failed++
+			} else {
+				c.pending++
+			}
+			checks[pc.Zone] = c
+			return nil
+		})
+	})
+	if err != nil {
+		slog.Error("gathering zone metrics", "err", err)
+		return
+	}
+
+	gauge := func(desc *prometheus.Desc, v float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, labels...)
+	}
+	for _, z := range zones {
+		if z.LastSync != nil {
+			gauge(descZoneLastSync, float64(z.LastSync.Unix()), z.Name)
+		}
+		if z.LastRecordChange != nil {
+			gauge(descZoneLastRecordChange, float64(z.LastRecordChange.Unix()), z.Name)
+		}
+		gauge(descZoneSyncInterval, max(z.SyncInterval, time.Minute).Seconds(), z.Name)
+		gauge(descZoneSerial, float64(z.SerialLocal), z.Name, "local")
+		gauge(descZoneSerial, float64(z.SerialRemote), z.Name, "remote")
+		gauge(descZoneRecords, float64(records[z.Name]), z.Name)
+		gauge(descZonePropagationChecks, float64(checks[z.Name].pending), z.Name, "pending")
+		gauge(descZonePropagationChecks, float64(checks[z.Name].failed), z.Name, "failed")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/mjl-/bstore"
+)
+
+func TestZoneMetrics(t *testing.T) {
+	testDNS(t, func(te testEnv, z Zone) {
+		err := refreshZoneSync(slog.Default(), z)
+		tcheck(t, err, "zone sync")
+		err = database.Get(ctxbg, &z)
+		tcheck(t, err, "get zone")
+		nrecords, err := bstore.QueryDB[Record](ctxbg, database).FilterNonzero(Record{Zone: z.Name}).FilterFn(func(r Record) bool { return r.Deleted == nil }).Count()
+		tcheck(t, err, "count records")
+
+		// Gauge values for zone, by metric name and label values.
+		gauges := func() map[string]float64 {
+			mfl, err := prometheus.DefaultGatherer.Gather()
+			tcheck(t, err, "gather metrics")
+			m := map[string]float64{}
+			for _, mf := range mfl {
+				for _, metric := range mf.Metric {
+					var zone bool
+					key := mf.GetName()
+					for _, lp := range metric.Label {
+						if lp.GetName() == "zone" {
+							zone = lp.GetValue() == z.Name
+						} else {
+							key += "/" + lp.GetValue()
+						}
+					}
+					if zone && metric.Gauge != nil {
+						m[key] = metric.Gauge.GetValue()
+					}
+				}
+			}
+			return m
+		}
+
+		m := gauges()
+		tcompare(t, m["dnsclay_zone_last_sync_timestamp_seconds"], float64(z.LastSync.Unix()))
+		tcompare(t, m["dnsclay_zone_serial/local"], float64(z.SerialLocal))
+		tcompare(t, m["dnsclay_zone_serial/remote"], float64(z.SerialRemote))
+		tcompare(t, m["dnsclay_zone_records"], float64(nrecords))
+		tcompare(t, m["dnsclay_zone_propagation_checks/pending"], 0.0)
+
+		// Deleted records are history, not counted.
+		var ids []int64
+		for _, r := range te.z0.records {
+			if r.AbsName == "testhost."+z.Name && r.Type == Type(dns.TypeA) {
+				ids = append(ids, r.ID)
+			}
+		}
+		tcompare(t, len(ids), 2)
+		te.api.RecordSetDelete(ctxbg, z.Name, "testhost", Type(dns.TypeA), ids)
+		m = gauges()
+		tcompare(t, m["dnsclay_zone_records"], float64(nrecords-len(ids)))
+
+		err = database.Insert(ctxbg, &PropagationCheck{Zone: z.Name, Failed: true})
+		tcheck(t, err, "insert propagation check")
+		m = gauges()
+		tcompare(t, m["dnsclay_zone_propagation_checks/failed"], 1.0)
+	})
+}